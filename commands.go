@@ -0,0 +1,503 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/browser"
+	"github.com/vinistoisr/timewarp/internal/db"
+	"github.com/vinistoisr/timewarp/internal/profiles"
+	"github.com/vinistoisr/timewarp/internal/rules"
+)
+
+// commands are the maintenance subcommands, run as `timewarp <name> [flags]`.
+// Each parses its own flags and exits when done.
+var commands = map[string]func(args []string) error{
+	"reattribute":  cmdReattribute,
+	"resessionize": cmdResessionize,
+	"add-entry":    cmdAddEntry,
+	"edit-entry":   cmdEditEntry,
+	"delete-entry": cmdDeleteEntry,
+	"list-entries": cmdListEntries,
+
+	"purge": cmdPurge,
+
+	"encrypt":        cmdEncrypt,
+	"decrypt":        cmdDecrypt,
+	"encryption-key": cmdEncryptionKey,
+
+	"install-browser-host": cmdInstallBrowserHost,
+}
+
+// cmdReattribute re-evaluates attribution rules over stored sessions in every
+// DB file in the folder.
+func cmdReattribute(args []string) error {
+	fs := flag.NewFlagSet("reattribute", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	rulesFile := fs.String("rules", "", "JSON file of attribution rules (default: "+rules.FileName+" in the DB folder, else YY-NNN project numbers)")
+	from := fs.String("from", "", "First day to re-evaluate, inclusive (ISO, e.g. 2026-03-02; default: earliest)")
+	to := fs.String("to", "", "Last day to re-evaluate, inclusive (ISO; default: latest)")
+	dryRun := fs.Bool("dry-run", false, "Report what would change without writing anything")
+	tz := tzFlag(fs)
+	fs.Parse(args)
+
+	p := dbPathOrExeDir(*path)
+	r, err := loadRules(*rulesFile, p)
+	if err != nil {
+		return err
+	}
+	loc, err := db.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+
+	var dateFrom, dateTo time.Time
+	if *from != "" {
+		if dateFrom, err = db.ParseDate(*from, loc); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *to != "" {
+		if dateTo, err = db.ParseDate(*to, loc); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+		dateTo = dateTo.AddDate(0, 0, 1)
+	}
+
+	report, err := db.Reattribute(p, r, dateFrom, dateTo, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range report.Changes {
+		fmt.Printf("%s #%d  %s  %5.1f min  %s  %q\n", c.Machine, c.SessionID, c.StartedAt, c.Minutes, c.Process, c.Title)
+		for _, f := range []struct{ name, old, new string }{
+			{"project", c.Old.Project, c.New.Project},
+			{"client", c.Old.Client, c.New.Client},
+			{"task", c.Old.Task, c.New.Task},
+			{"category", c.Old.Category, c.New.Category},
+		} {
+			if f.old != f.new {
+				fmt.Printf("    %-8s %q -> %q\n", f.name, f.old, f.new)
+			}
+		}
+	}
+	verb := "Updated"
+	if report.DryRun {
+		verb = "Would update"
+	}
+	fmt.Printf("%s %d of %d sessions in %d files\n", verb, len(report.Changes), report.Examined, len(report.Files))
+	printSkipped(report.Skipped)
+	return nil
+}
+
+// cmdResessionize rebuilds this machine's sessions from its raw focus log
+// with the current session profiles.
+func cmdResessionize(args []string) error {
+	fs := flag.NewFlagSet("resessionize", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	profilesFile := fs.String("profiles", "", "JSON file of session profiles (default: "+profiles.FileName+" in the DB folder, else the built-in settings)")
+	rulesFile := fs.String("rules", "", "JSON file of attribution rules (default: "+rules.FileName+" in the DB folder, else YY-NNN project numbers)")
+	from := fs.String("from", "", "First day to rebuild, inclusive (ISO, e.g. 2026-03-02; default: start of the raw log)")
+	to := fs.String("to", "", "Last day to rebuild, inclusive (ISO; default: end of the raw log)")
+	dryRun := fs.Bool("dry-run", false, "Report what would change without writing anything")
+	tz := tzFlag(fs)
+	fs.Parse(args)
+
+	p := dbPathOrExeDir(*path)
+	r, err := loadRules(*rulesFile, p)
+	if err != nil {
+		return err
+	}
+	var set *profiles.Set
+	if *profilesFile != "" {
+		set, err = profiles.Load(*profilesFile)
+	} else {
+		set, err = profiles.LoadDir(p)
+	}
+	if err != nil {
+		return err
+	}
+	loc, err := db.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+
+	var dateFrom, dateTo time.Time
+	if *from != "" {
+		if dateFrom, err = db.ParseDate(*from, loc); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *to != "" {
+		if dateTo, err = db.ParseDate(*to, loc); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+		dateTo = dateTo.AddDate(0, 0, 1)
+	}
+
+	report, err := db.Resessionize(p, set, r, dateFrom, dateTo, *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "Replaced"
+	if report.DryRun {
+		verb = "Would replace"
+	}
+	fmt.Printf("Raw log from %s to %s: %d runs\n", report.From.In(loc).Format(time.RFC3339), report.To.In(loc).Format(time.RFC3339), report.Runs)
+	fmt.Printf("%s %d sessions and %d meetings (%.1f min) with %d sessions and %d meetings (%.1f min)\n", verb,
+		report.Before.Sessions, report.Before.Meetings, report.Before.Minutes,
+		report.After.Sessions, report.After.Meetings, report.After.Minutes)
+	verb = "Dropped"
+	if report.DryRun {
+		verb = "Would drop"
+	}
+	for _, c := range report.Dropped {
+		fmt.Printf("%s correction: %s\n", verb, c)
+	}
+	return nil
+}
+
+// entryFlags registers the fields of a manual entry on fs.
+type entryFlags struct {
+	project, client, task, category, description *string
+	date, start, tz                              *string
+	minutes                                      *float64
+}
+
+func newEntryFlags(fs *flag.FlagSet) *entryFlags {
+	return &entryFlags{
+		project:     fs.String("project", "", "Project number"),
+		client:      fs.String("client", "", "Client"),
+		task:        fs.String("task", "", "Task"),
+		category:    fs.String("category", "", "Category"),
+		description: fs.String("description", "", "What the time was for (e.g. Site visit)"),
+		date:        fs.String("date", "", "Day of the entry (ISO, e.g. 2026-03-02)"),
+		start:       fs.String("start", "", "Start time (HH:MM, optional)"),
+		minutes:     fs.Float64("minutes", 0, "Length of the entry in minutes"),
+		tz:          tzFlag(fs),
+	}
+}
+
+// input returns the flags that were given on the command line.
+func (f *entryFlags) input(fs *flag.FlagSet) (db.ManualInput, error) {
+	set := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	opt := func(name string, v *string) *string {
+		if set[name] {
+			return v
+		}
+		return nil
+	}
+
+	in := db.ManualInput{
+		Project:     opt("project", f.project),
+		Client:      opt("client", f.client),
+		Task:        opt("task", f.task),
+		Category:    opt("category", f.category),
+		Description: opt("description", f.description),
+	}
+	if set["minutes"] {
+		in.Minutes = f.minutes
+	}
+	if set["start"] && !set["date"] {
+		return in, fmt.Errorf("-start needs -date")
+	}
+	if set["date"] {
+		loc, err := db.LoadLocation(*f.tz)
+		if err != nil {
+			return in, err
+		}
+		start, err := db.ParseEntryStart(*f.date, *f.start, loc)
+		if err != nil {
+			return in, err
+		}
+		in.Start = &start
+	}
+	return in, nil
+}
+
+func printEntry(e *db.ManualEntry) {
+	fmt.Printf("%s  %s  %6.1f min  %s  %s\n", e.ID, e.Start, e.Minutes, e.Project, e.Description)
+}
+
+// cmdAddEntry logs a manual time entry on this machine.
+func cmdAddEntry(args []string) error {
+	fs := flag.NewFlagSet("add-entry", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	f := newEntryFlags(fs)
+	fs.Parse(args)
+
+	in, err := f.input(fs)
+	if err != nil {
+		return err
+	}
+	e, err := db.AddManualEntry(dbPathOrExeDir(*path), in)
+	if err != nil {
+		return err
+	}
+	printEntry(e)
+	return nil
+}
+
+// cmdEditEntry changes the given fields of a manual entry logged on this
+// machine.
+func cmdEditEntry(args []string) error {
+	fs := flag.NewFlagSet("edit-entry", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	id := fs.String("id", "", "Entry ID (hostname:mN, as shown by list-entries)")
+	f := newEntryFlags(fs)
+	fs.Parse(args)
+
+	if *id == "" {
+		return fmt.Errorf("-id is required")
+	}
+	in, err := f.input(fs)
+	if err != nil {
+		return err
+	}
+	e, err := db.EditManualEntry(dbPathOrExeDir(*path), *id, in)
+	if err != nil {
+		return err
+	}
+	printEntry(e)
+	return nil
+}
+
+// cmdDeleteEntry removes a manual entry logged on this machine.
+func cmdDeleteEntry(args []string) error {
+	fs := flag.NewFlagSet("delete-entry", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	id := fs.String("id", "", "Entry ID (hostname:mN, as shown by list-entries)")
+	fs.Parse(args)
+
+	if *id == "" {
+		return fmt.Errorf("-id is required")
+	}
+	if err := db.DeleteManualEntry(dbPathOrExeDir(*path), *id); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted %s\n", *id)
+	return nil
+}
+
+// cmdListEntries prints the manual entries from every machine in a date range.
+func cmdListEntries(args []string) error {
+	fs := flag.NewFlagSet("list-entries", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	from := fs.String("from", "", "First day, inclusive (ISO; default: Monday this week)")
+	to := fs.String("to", "", "Last day, inclusive (ISO; default: Sunday after -from)")
+	tz := tzFlag(fs)
+	fs.Parse(args)
+
+	loc, err := db.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	dateFrom := db.CurrentWeekMonday(loc)
+	if *from != "" {
+		if dateFrom, err = db.ParseDate(*from, loc); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	dateTo := dateFrom.AddDate(0, 0, 7)
+	if *to != "" {
+		t, err := db.ParseDate(*to, loc)
+		if err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+		dateTo = t.AddDate(0, 0, 1)
+	}
+
+	entries, err := db.ListManualEntries(dbPathOrExeDir(*path), dateFrom, dateTo)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		printEntry(&entries[i])
+	}
+	return nil
+}
+
+// cmdInstallBrowserHost registers this executable as the native-messaging
+// host of the browser extension.
+func cmdInstallBrowserHost(args []string) error {
+	fs := flag.NewFlagSet("install-browser-host", flag.ExitOnError)
+	ids := fs.String("extension-id", "", "Comma-separated IDs of the extension as loaded in Chrome, Edge or Brave (shown on the extensions page)")
+	fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	var extensionIDs []string
+	for _, id := range strings.Split(*ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			extensionIDs = append(extensionIDs, id)
+		}
+	}
+	if len(extensionIDs) == 0 {
+		fmt.Println("No -extension-id given; only Firefox will be able to connect")
+	}
+	written, err := browser.Install(exe, extensionIDs)
+	if err != nil {
+		return err
+	}
+	for _, path := range written {
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return nil
+}
+
+// cmdPurge deletes tracked time from every DB file in the folder for good.
+func cmdPurge(args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	before := fs.String("before", "", "Delete what started before this day (ISO, e.g. 2025-01-01)")
+	process := fs.String("process", "", "Delete only this process or app, e.g. chrome.exe")
+	titleMatch := fs.String("title-match", "", "Delete only sessions and meetings with a title matching this regular expression")
+	yes := fs.Bool("yes", false, "Delete for good; without it, only report what would be deleted")
+	tz := tzFlag(fs)
+	fs.Parse(args)
+
+	loc, err := db.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	var f db.PurgeFilter
+	if *before != "" {
+		if f.To, err = db.ParseDate(*before, loc); err != nil {
+			return fmt.Errorf("invalid -before: %w", err)
+		}
+	}
+	f.Process = *process
+	if *titleMatch != "" {
+		if f.TitleMatch, err = regexp.Compile(*titleMatch); err != nil {
+			return fmt.Errorf("invalid -title-match: %w", err)
+		}
+	}
+
+	report, err := db.Purge(dbPathOrExeDir(*path), f, !*yes)
+	if err != nil {
+		return err
+	}
+	verb := "Deleted"
+	if report.DryRun {
+		verb = "Would delete"
+	}
+	fmt.Printf("%s %d sessions, %d meetings, %d raw log rows, %d rollups, %d inactivity periods and %d system events in %d files\n",
+		verb, report.Sessions, report.Meetings, report.RawEvents, report.Rollups, report.Inactivity, report.System, len(report.Files))
+	printSkipped(report.Skipped)
+	if report.DryRun {
+		fmt.Println("Run again with -yes to delete them")
+	}
+	return nil
+}
+
+// printSkipped lists the files a change across the folder left alone.
+func printSkipped(skipped []string) {
+	for _, s := range skipped {
+		fmt.Printf("Skipped %s\n", s)
+	}
+}
+
+// cmdEncrypt turns on encryption of this machine's DB file and seals the
+// free text already in it.
+func cmdEncrypt(args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	fs.Parse(args)
+
+	id, err := db.Encrypt(dbPathOrExeDir(*path))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted with key %s\n", id)
+	fmt.Println("Machines that read this folder need the key: run `timewarp encryption-key -export` here and `timewarp encryption-key -import <key>` there")
+	return nil
+}
+
+// cmdDecrypt turns encryption of this machine's DB file off.
+func cmdDecrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	fs.Parse(args)
+
+	if err := db.Decrypt(dbPathOrExeDir(*path)); err != nil {
+		return err
+	}
+	fmt.Println("Decrypted")
+	return nil
+}
+
+// cmdEncryptionKey copies the key of an encrypted DB file between machines.
+func cmdEncryptionKey(args []string) error {
+	fs := flag.NewFlagSet("encryption-key", flag.ExitOnError)
+	path := fs.String("dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	export := fs.Bool("export", false, "Print the key this machine's DB file is encrypted with")
+	imp := fs.String("import", "", "Key printed by -export on another machine, to keep in this machine's keyring")
+	fs.Parse(args)
+
+	switch {
+	case *export && *imp != "":
+		return fmt.Errorf("-export and -import cannot be used together")
+	case *export:
+		key, err := db.ExportKey(dbPathOrExeDir(*path))
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+	case *imp != "":
+		id, err := db.ImportKey(*imp)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported key %s\n", id)
+	default:
+		return fmt.Errorf("-export or -import is required")
+	}
+	return nil
+}
+
+// tzFlag registers the -tz flag, the zone dates and times are given in.
+func tzFlag(fs *flag.FlagSet) *string {
+	return fs.String("tz", "", "IANA time zone for dates and times, e.g. America/Toronto (default: system local)")
+}
+
+// dbPathOrExeDir returns p, or the executable's directory if p is empty.
+func dbPathOrExeDir(p string) string {
+	if p == "" {
+		return db.ExeDir()
+	}
+	return p
+}
+
+// loadRules returns the rules in file, or those in the DB folder dir when
+// file is empty.
+func loadRules(file, dir string) (*rules.Set, error) {
+	if file != "" {
+		return rules.Load(file)
+	}
+	return rules.LoadDir(dir)
+}
+
+// runCommand runs os.Args[1] if it names a subcommand, and reports whether
+// it did.
+func runCommand() bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		return false
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+	return true
+}
@@ -7,21 +7,27 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unsafe"
+	_ "time/tzdata" // IANA zones for -tz on Windows, which has no zoneinfo
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"golang.org/x/sys/windows"
 
+	"github.com/vinistoisr/timewarp/internal/browser"
+	"github.com/vinistoisr/timewarp/internal/calendar"
+	"github.com/vinistoisr/timewarp/internal/capture"
+	"github.com/vinistoisr/timewarp/internal/clock"
 	"github.com/vinistoisr/timewarp/internal/db"
 	"github.com/vinistoisr/timewarp/internal/mcp"
+	"github.com/vinistoisr/timewarp/internal/privacy"
+	"github.com/vinistoisr/timewarp/internal/profiles"
+	"github.com/vinistoisr/timewarp/internal/rules"
 	"github.com/vinistoisr/timewarp/internal/tray"
 	"github.com/vinistoisr/timewarp/internal/windowinfo"
 )
@@ -38,6 +44,23 @@ var (
 	installMode            bool
 	uninstallMode          bool
 	dbpath                 string
+	replayPath             string
+	eventMode              bool
+	heartbeat              time.Duration
+	rulesPath              string
+	profilesPath           string
+	privacyPath            string
+	rawLog                 bool
+	rawRetentionDays       int
+	titleRetentionDays     int
+	sessionRetentionMonths int
+	calendarPath           string
+	nativeMessaging        bool
+	privateDomains         string
+	commandLines           bool
+	commandLineRedact      string
+	reapplyRules           bool
+	timezone               string
 )
 
 // Prometheus metrics
@@ -89,6 +112,11 @@ var (
 	paused           atomic.Bool
 	inactThresholdMs atomic.Uint64
 
+	// attribution is the rules set from -rules, or nil to use the DB folder's.
+	attribution *rules.Set
+	// redactCommandLine is the pattern from -command-line-redact, if any.
+	redactCommandLine []*regexp.Regexp
+
 	promMu     sync.Mutex
 	promServer *http.Server
 	promReg    *prometheus.Registry
@@ -121,13 +149,30 @@ func init() {
 	flag.Uint64Var(&inactivityThresholdSec, "inactivityThreshold", inactivityThresholdSec, "The inactivity threshold in seconds")
 	flag.StringVar(&listenInterface, "interface", listenInterface, "The interface to listen on (default is all interfaces)")
 	flag.IntVar(&listenPort, "port", listenPort, "The port to listen on (default is 9183)")
-	flag.BoolVar(&privateMode, "private", privateMode, "When true, the window title will be replaced with the process name for increased privacy")
+	flag.BoolVar(&privateMode, "private", privateMode, "When true, window titles are replaced with process names in the metrics and the DB alike")
 	flag.BoolVar(&debugMode, "debug", debugMode, "When true, output all values to the console")
 	flag.BoolVar(&mcpMode, "mcp", false, "Run as MCP stdio server instead of Prometheus exporter")
 	flag.BoolVar(&silentMode, "silent", false, "Run without system tray icon")
 	flag.BoolVar(&installMode, "install", false, "Install as a startup task (runs at logon)")
 	flag.BoolVar(&uninstallMode, "uninstall", false, "Remove the startup task")
 	flag.StringVar(&dbpath, "dbpath", "", "Directory for DB file(s) (default: same directory as the executable)")
+	flag.BoolVar(&eventMode, "events", true, "Capture focus changes as they happen where the platform supports it, polling only as a heartbeat")
+	flag.DurationVar(&heartbeat, "heartbeat", 5*time.Second, "Polling interval when focus events are available (polling is every second otherwise)")
+	flag.StringVar(&replayPath, "replay", "", "Play a JSON timeline through the tracker on a simulated clock, then exit")
+	flag.StringVar(&rulesPath, "rules", "", "JSON file of attribution rules (default: "+rules.FileName+" in the DB folder, else YY-NNN project numbers in window titles)")
+	flag.StringVar(&profilesPath, "profiles", "", "JSON file of per-process sessionization profiles (default: "+profiles.FileName+" in the DB folder, else the built-in settings)")
+	flag.StringVar(&privacyPath, "privacy", "", "JSON file of processes never recorded, titles blanked out and apps recorded without titles (default: "+privacy.FileName+" in the DB folder, else only private browsing windows lose their titles)")
+	flag.BoolVar(&rawLog, "raw-log", false, "Keep a compact log of every focus change, so that sessions can be rebuilt later with the resessionize command")
+	flag.IntVar(&rawRetentionDays, "raw-retention", 30, "Days of raw focus log to keep (0 keeps all)")
+	flag.IntVar(&titleRetentionDays, "title-retention", 0, "Days to keep window titles, URLs and command lines, after which sessions keep only their process name (0 keeps them all)")
+	flag.IntVar(&sessionRetentionMonths, "session-retention", 0, "Months to keep individual sessions, after which they are rolled up into hourly totals per app and project (0 keeps them all)")
+	flag.StringVar(&calendarPath, "calendar", "", "An .ics file, or a folder of them, to import calendar events from; imported again whenever it changes")
+	flag.BoolVar(&nativeMessaging, "native-messaging", false, "Run as the native-messaging host of the browser extension, passing the active tab to the tracker, until the browser disconnects")
+	flag.StringVar(&privateDomains, "private-domains", "", "Comma-separated domains, e.g. bank.example,mail.example, whose URLs (and those of their subdomains) are never recorded")
+	flag.BoolVar(&commandLines, "command-line", false, "Record the command line of the process in front with each session, less anything that looks like a password or token")
+	flag.StringVar(&commandLineRedact, "command-line-redact", "", "Regular expression for more to blank out of recorded command lines: the groups it captures, or else all it matches")
+	flag.StringVar(&timezone, "tz", "", "IANA time zone for day and week boundaries in MCP queries, e.g. America/Toronto (default: system local)")
+	flag.BoolVar(&reapplyRules, "reapply-rules", false, "Re-evaluate the attribution rules over every stored session in this machine's DB, then exit")
 }
 
 // setupMetrics initializes the Prometheus metrics and returns the registry
@@ -184,16 +229,17 @@ func stopPrometheus() {
 	log.Printf("Prometheus endpoint stopped")
 }
 
-func runExporter(ctx context.Context) {
+func runExporter(ctx context.Context, clk clock.Clock) {
 	path := dbpath
 	if path == "" {
 		path = db.ExeDir()
 	}
 
-	t, err := db.Open(path)
+	t, err := openTracker(path)
 	if err != nil {
 		log.Printf("Warning: DB tracking disabled: %v", err)
 	} else {
+		t.SetClock(clk)
 		setTracker(t)
 		log.Printf("DB path: %s", path)
 	}
@@ -213,114 +259,303 @@ func runExporter(ctx context.Context) {
 	promMu.Unlock()
 
 	windowinfo.LastWindowInfo, _ = windowinfo.GetActiveWindowInfo(*focusChangeCounter)
-	windowinfo.LastWindowFocusTime = time.Now()
+	windowinfo.LastWindowFocusTime = clk.Now()
+
+	// With focus events the tick is only a heartbeat (title changes the
+	// platform doesn't report, idle detection), so it can run less often.
+	interval := 1 * time.Second
+	var events chan focusEvent
+	watchErr := make(chan error, 1)
+	if w, ok := capture.Current().(capture.Watcher); ok && eventMode {
+		events = make(chan focusEvent, 64)
+		go func() {
+			watchErr <- w.Watch(ctx, func(win capture.Window, at time.Time) {
+				// Never block the platform's event thread; the heartbeat catches up.
+				select {
+				case events <- focusEvent{win, at}:
+				default:
+				}
+			})
+		}()
+		interval = heartbeat
+		log.Printf("Focus events enabled, heartbeat every %s", interval)
+	}
+	windowinfo.TickInterval = interval
 
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+	if calendarPath != "" {
+		go watchCalendar(ctx, clk, calendarPath)
+	}
+
+	if w, ok := capture.Current().(capture.SystemWatcher); ok {
+		go func() {
+			if err := w.WatchSystem(ctx, handleSystemEvent); err != nil && ctx.Err() == nil {
+				log.Printf("System events unavailable: %v", err)
+			}
+		}()
+	}
+
+	ticker := clk.NewTicker(interval)
+	defer func() { ticker.Stop() }()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if paused.Load() {
-				continue
-			}
-			// Avoid nil-interface trap: only pass tracker as interface when non-nil
-			cur := getTracker()
-			var ti windowinfo.FocusTracker
-			if cur != nil {
-				ti = cur
+			tick(clk)
+		case ev := <-events:
+			handleFocusEvent(ev)
+		case err := <-watchErr:
+			if ctx.Err() != nil {
+				return
 			}
-			windowinfo.ProcessWindowInfo(inactThresholdMs.Load(), privateMode, debugMode, focusChangeCounter, focusedWindowDuration, meetingDuration, inactivityMetric, windowPidGauge, ti)
+			log.Printf("Focus events stopped, falling back to polling: %v", err)
+			ticker.Stop()
+			ticker = clk.NewTicker(1 * time.Second)
+			windowinfo.TickInterval = 1 * time.Second
 		}
 	}
 }
 
-// elevateAndRun re-launches the current exe with admin privileges via UAC prompt.
-func elevateAndRun() error {
-	exe, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("could not determine executable path: %w", err)
-	}
-
-	args := strings.Join(os.Args[1:], " ")
-
-	verbPtr, _ := windows.UTF16PtrFromString("runas")
-	exePtr, _ := windows.UTF16PtrFromString(exe)
-	argsPtr, _ := windows.UTF16PtrFromString(args)
+// focusEvent is a focus change pushed by a capture.Watcher.
+type focusEvent struct {
+	win capture.Window
+	at  time.Time
+}
 
-	err = windows.ShellExecute(0, verbPtr, exePtr, argsPtr, nil, windows.SW_NORMAL)
-	if err != nil {
-		return fmt.Errorf("UAC elevation failed: %w", err)
+func handleFocusEvent(ev focusEvent) {
+	if paused.Load() {
+		return
 	}
-	return nil
+	cur := getTracker()
+	var ti windowinfo.FocusTracker
+	if cur != nil {
+		ti = cur
+	}
+	windowinfo.ProcessFocusEvent(ev.win, ev.at, debugMode, focusChangeCounter, focusedWindowDuration, meetingDuration, windowPidGauge, ti)
 }
 
-// isElevated checks if the current process has admin privileges.
-func isElevated() bool {
-	token := windows.GetCurrentProcessToken()
-	elevated := false
-	var elevation struct{ TokenIsElevated uint32 }
-	var size uint32
-	err := windows.GetTokenInformation(token, windows.TokenElevation, (*byte)(unsafe.Pointer(&elevation)), uint32(unsafe.Sizeof(elevation)), &size)
-	if err == nil && elevation.TokenIsElevated != 0 {
-		elevated = true
-	}
-	return elevated
+// handleSystemEvent records a system event. It runs on the watcher's
+// goroutine rather than going through the loop, as a shutdown may not wait
+// for the loop to get to it, and it ignores pause so that a lock from before
+// a pause is not left without its unlock.
+func handleSystemEvent(ev capture.SystemEvent, at time.Time) {
+	cur := getTracker()
+	var ti windowinfo.FocusTracker
+	if cur != nil {
+		ti = cur
+	}
+	windowinfo.ProcessSystemEvent(ev, at, debugMode, ti)
 }
 
-func doInstall() error {
-	if !isElevated() {
-		fmt.Println("Requesting administrator privileges...")
-		return elevateAndRun()
+// tick samples the foreground window once and feeds it to the metrics and the tracker.
+func tick(clk clock.Clock) {
+	if paused.Load() {
+		return
+	}
+	// Avoid nil-interface trap: only pass tracker as interface when non-nil
+	cur := getTracker()
+	var ti windowinfo.FocusTracker
+	if cur != nil {
+		ti = cur
 	}
+	windowinfo.ProcessWindowInfo(inactThresholdMs.Load(), debugMode, clk, focusChangeCounter, focusedWindowDuration, meetingDuration, inactivityMetric, windowPidGauge, ti)
+}
 
-	exePath, err := os.Executable()
+// openTracker opens this machine's DB file in path with the attribution
+// rules from -rules, or from the rules file in path if there is one. The
+// privacy policy for path is put in place first, whether or not the DB
+// opens, as the metrics go through it too.
+func openTracker(path string) (*db.Tracker, error) {
+	policy := usePrivacy(path)
+	t, err := db.Open(path)
 	if err != nil {
-		return fmt.Errorf("could not determine executable path: %w", err)
+		return nil, err
+	}
+	r := attribution
+	if r == nil {
+		if r, err = rules.LoadDir(path); err != nil {
+			log.Printf("Warning: using default attribution rules: %v", err)
+			r = rules.Default()
+		}
 	}
+	t.SetRules(r)
+	p, err := loadProfiles(path)
+	if err != nil {
+		log.Printf("Warning: using default session profiles: %v", err)
+		p = profiles.Default()
+	}
+	t.SetProfiles(p)
+	t.SetRawLog(rawLog, time.Duration(rawRetentionDays)*24*time.Hour)
+	t.SetRetention(db.Retention{TitleDays: titleRetentionDays, SessionMonths: sessionRetentionMonths})
+	t.SetCommandLines(commandLines, redactCommandLine)
+	if !privateMode {
+		if state, err := browser.StatePath(); err != nil {
+			log.Printf("Warning: browser URLs disabled: %v", err)
+		} else {
+			tabs := browser.NewTabs(state, strings.Split(privateDomains, ","))
+			if err := tabs.SharePrivate(); err != nil {
+				log.Printf("Warning: private domains may reach the browser tab file: %v", err)
+			}
+			t.SetURLs(func(process, title string) string {
+				return policy.Redact(tabs.URL(process, title))
+			})
+		}
+	}
+	return t, nil
+}
 
-	tr := `"` + exePath + `"`
-	if dbpath != "" {
-		tr += ` -dbpath "` + dbpath + `"`
+// runNativeMessaging serves the browser extension on stdin and stdout until
+// the browser disconnects.
+func runNativeMessaging() error {
+	state, err := browser.StatePath()
+	if err != nil {
+		return err
 	}
+	return browser.Serve(os.Stdin, os.Stdout, state, clock.Real{})
+}
+
+// calendarPoll is how often the -calendar path is checked for changes.
+const calendarPoll = time.Minute
+
+// watchCalendar imports the calendar at path into the tracker's DB, and
+// again whenever its .ics files change or the DB folder does.
+func watchCalendar(ctx context.Context, clk clock.Clock, path string) {
+	ticker := clk.NewTicker(calendarPoll)
+	defer ticker.Stop()
 
-	cmd := exec.Command("schtasks", "/create",
-		"/tn", "Timewarp",
-		"/tr", tr,
-		"/sc", "onlogon",
-		"/rl", "limited",
-		"/delay", "0000:30",
-		"/f",
+	var (
+		imported *db.Tracker
+		last     string
+		lastErr  string
 	)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("schtasks create failed: %w", err)
+	report := func(err error) {
+		// Once per error, not every poll
+		if err.Error() != lastErr {
+			log.Printf("Calendar: %v", err)
+			lastErr = err.Error()
+		}
+	}
+	for {
+		stamp, err := calendar.Fingerprint(path)
+		if err != nil {
+			report(err)
+		} else if t := getTracker(); t != nil && (t != imported || stamp != last) {
+			if n, err := t.ImportCalendar(path); err != nil {
+				report(err)
+			} else {
+				log.Printf("Calendar: imported %d events from %d files", n.Events, n.Files)
+				imported, last, lastErr = t, stamp, ""
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-	fmt.Println("Timewarp installed as a startup task.")
-	return nil
 }
 
-func doUninstall() error {
-	if !isElevated() {
-		fmt.Println("Requesting administrator privileges...")
-		return elevateAndRun()
+// usePrivacy puts the privacy policy from -privacy, or from the privacy
+// file in path if there is one, in front of the metrics and the tracker.
+// If it cannot be read, no titles are recorded until it can.
+func usePrivacy(path string) *privacy.Policy {
+	var (
+		p   *privacy.Policy
+		err error
+	)
+	if privacyPath != "" {
+		p, err = privacy.Load(privacyPath)
+	} else {
+		p, err = privacy.LoadDir(path)
+	}
+	if err != nil {
+		log.Printf("Warning: recording no window titles: %v", err)
+		p = privacy.Default().Private()
+	}
+	if privateMode {
+		p = p.Private()
 	}
+	windowinfo.SetPrivacy(p)
+	return p
+}
 
-	cmd := exec.Command("schtasks", "/delete", "/tn", "Timewarp", "/f")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("schtasks delete failed: %w", err)
+// loadProfiles reads the sessionization profiles from -profiles, or from
+// the profiles file in path if there is one. It reads the file each time,
+// so that edits can be picked up without a restart.
+func loadProfiles(path string) (*profiles.Set, error) {
+	if profilesPath != "" {
+		return profiles.Load(profilesPath)
 	}
-	fmt.Println("Timewarp startup task removed.")
-	return nil
+	return profiles.LoadDir(path)
 }
 
 func main() {
-	flag.Parse()
+	// Browsers start the host with arguments of their own rather than ours
+	if browser.LaunchedByBrowser(os.Args[1:]) {
+		nativeMessaging = true
+	} else if runCommand() {
+		return
+	} else {
+		flag.Parse()
+	}
+
+	if nativeMessaging {
+		if err := runNativeMessaging(); err != nil {
+			fmt.Fprintf(os.Stderr, "Native messaging error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if rulesPath != "" {
+		r, err := rules.Load(rulesPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Rules error: %v\n", err)
+			os.Exit(1)
+		}
+		attribution = r
+	}
+	if commandLineRedact != "" {
+		re, err := regexp.Compile(commandLineRedact)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Command line redaction error: %v\n", err)
+			os.Exit(1)
+		}
+		redactCommandLine = []*regexp.Regexp{re}
+	}
+	if profilesPath != "" {
+		if _, err := profiles.Load(profilesPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Profiles error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if privacyPath != "" {
+		if _, err := privacy.Load(privacyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Privacy error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if reapplyRules {
+		path := dbpath
+		if path == "" {
+			path = db.ExeDir()
+		}
+		t, err := openTracker(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Reapply rules error: %v\n", err)
+			os.Exit(1)
+		}
+		n, err := t.ReapplyRules()
+		t.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Reapply rules error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Updated attribution on %d sessions\n", n)
+		return
+	}
 
 	if installMode {
 		if err := doInstall(); err != nil {
@@ -338,11 +573,25 @@ func main() {
 		return
 	}
 
+	if replayPath != "" {
+		if err := runReplay(replayPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Replay error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if mcpMode {
 		path := dbpath
 		if path == "" {
 			path = db.ExeDir()
 		}
+		loc, err := db.LoadLocation(timezone)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "MCP server error: %v\n", err)
+			os.Exit(1)
+		}
+		mcp.DefaultLocation = loc
 		if err := mcp.Run(path); err != nil {
 			fmt.Fprintf(os.Stderr, "MCP server error: %v\n", err)
 			os.Exit(1)
@@ -376,7 +625,7 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	if silentMode {
-		runExporter(ctx)
+		runExporter(ctx, clock.Real{})
 		cancel()
 		return
 	}
@@ -388,7 +637,7 @@ func main() {
 	}
 	tray.Run(path, tray.Callbacks{
 		OnReady: func() {
-			go runExporter(ctx)
+			go runExporter(ctx, clock.Real{})
 		},
 		OnQuit: func() {
 			cancel()
@@ -408,6 +657,21 @@ func main() {
 			inactThresholdMs.Store(seconds * 1000)
 			log.Printf("Inactivity threshold changed to %d seconds", seconds)
 		},
+		ReloadProfiles: func() string {
+			p := dbpath
+			if p == "" {
+				p = db.ExeDir()
+			}
+			set, err := loadProfiles(p)
+			if err != nil {
+				return fmt.Sprintf("Could not load the session profiles, so the previous ones are still in use:\n\n%v", err)
+			}
+			if t := getTracker(); t != nil {
+				t.SetProfiles(set)
+			}
+			log.Printf("Session profiles reloaded")
+			return strings.Join(set.Describe(), "\n")
+		},
 		OnPrometheusToggle: func(enable bool) {
 			if enable {
 				startPrometheus()
@@ -454,7 +718,7 @@ func main() {
 				setTracker(nil)
 			}
 			// Open new tracker
-			t, err := db.Open(newPath)
+			t, err := openTracker(newPath)
 			if err != nil {
 				log.Printf("Failed to open DB at %s: %v", newPath, err)
 				return
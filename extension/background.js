@@ -0,0 +1,38 @@
+// Reports the active tab of the focused window to Timewarp, run as the
+// native-messaging host com.timewarp.browser, whenever it changes.
+
+const HOST = "com.timewarp.browser";
+const api = globalThis.browser ?? globalThis.chrome;
+
+let port = null;
+
+function send(message) {
+  if (port === null) {
+    port = api.runtime.connectNative(HOST);
+    port.onDisconnect.addListener(() => {
+      port = null;
+    });
+  }
+  port.postMessage(message);
+}
+
+async function report() {
+  const [tab] = await api.tabs.query({ active: true, lastFocusedWindow: true });
+  if (!tab) {
+    return;
+  }
+  send({ url: tab.url ?? "", title: tab.title ?? "", incognito: tab.incognito });
+}
+
+api.tabs.onActivated.addListener(report);
+api.tabs.onUpdated.addListener((tabId, change, tab) => {
+  if (tab.active && (change.url !== undefined || change.title !== undefined)) {
+    report();
+  }
+});
+api.windows.onFocusChanged.addListener((windowId) => {
+  if (windowId !== api.windows.WINDOW_ID_NONE) {
+    report();
+  }
+});
+api.runtime.onStartup.addListener(report);
@@ -0,0 +1,61 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// autostartFile returns the XDG autostart entry used in place of a scheduled task.
+func autostartFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine config directory: %w", err)
+	}
+	return filepath.Join(dir, "autostart", "timewarp.desktop"), nil
+}
+
+func doInstall() error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not determine executable path: %w", err)
+	}
+	p, err := autostartFile()
+	if err != nil {
+		return err
+	}
+
+	exec := `"` + exePath + `" -silent`
+	if dbpath != "" {
+		exec += ` -dbpath "` + dbpath + `"`
+	}
+	entry := "[Desktop Entry]\n" +
+		"Type=Application\n" +
+		"Name=Timewarp\n" +
+		"Comment=Track focused windows for timesheets\n" +
+		"Exec=" + exec + "\n" +
+		"X-GNOME-Autostart-enabled=true\n"
+
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("create autostart directory: %w", err)
+	}
+	if err := os.WriteFile(p, []byte(entry), 0644); err != nil {
+		return fmt.Errorf("write autostart entry: %w", err)
+	}
+	fmt.Println("Timewarp installed as a startup application:", p)
+	return nil
+}
+
+func doUninstall() error {
+	p, err := autostartFile()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove autostart entry: %w", err)
+	}
+	fmt.Println("Timewarp startup application removed.")
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// elevateAndRun re-launches the current exe with admin privileges via UAC prompt.
+func elevateAndRun() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not determine executable path: %w", err)
+	}
+
+	args := strings.Join(os.Args[1:], " ")
+
+	verbPtr, _ := windows.UTF16PtrFromString("runas")
+	exePtr, _ := windows.UTF16PtrFromString(exe)
+	argsPtr, _ := windows.UTF16PtrFromString(args)
+
+	err = windows.ShellExecute(0, verbPtr, exePtr, argsPtr, nil, windows.SW_NORMAL)
+	if err != nil {
+		return fmt.Errorf("UAC elevation failed: %w", err)
+	}
+	return nil
+}
+
+// isElevated checks if the current process has admin privileges.
+func isElevated() bool {
+	token := windows.GetCurrentProcessToken()
+	elevated := false
+	var elevation struct{ TokenIsElevated uint32 }
+	var size uint32
+	err := windows.GetTokenInformation(token, windows.TokenElevation, (*byte)(unsafe.Pointer(&elevation)), uint32(unsafe.Sizeof(elevation)), &size)
+	if err == nil && elevation.TokenIsElevated != 0 {
+		elevated = true
+	}
+	return elevated
+}
+
+func doInstall() error {
+	if !isElevated() {
+		fmt.Println("Requesting administrator privileges...")
+		return elevateAndRun()
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not determine executable path: %w", err)
+	}
+
+	tr := `"` + exePath + `"`
+	if dbpath != "" {
+		tr += ` -dbpath "` + dbpath + `"`
+	}
+
+	cmd := exec.Command("schtasks", "/create",
+		"/tn", "Timewarp",
+		"/tr", tr,
+		"/sc", "onlogon",
+		"/rl", "limited",
+		"/delay", "0000:30",
+		"/f",
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("schtasks create failed: %w", err)
+	}
+	fmt.Println("Timewarp installed as a startup task.")
+	return nil
+}
+
+func doUninstall() error {
+	if !isElevated() {
+		fmt.Println("Requesting administrator privileges...")
+		return elevateAndRun()
+	}
+
+	cmd := exec.Command("schtasks", "/delete", "/tn", "Timewarp", "/f")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("schtasks delete failed: %w", err)
+	}
+	fmt.Println("Timewarp startup task removed.")
+	return nil
+}
@@ -0,0 +1,193 @@
+// Package appid derives the identity of the application behind a window.
+// Most applications are named well enough by their process, but runtimes
+// such as java, python, node and electron host many: those are told apart
+// by the script or archive they run, or failing that by the window class.
+package appid
+
+import (
+	"regexp"
+	"strings"
+)
+
+// host matches the process names of runtimes, with any version suffix
+// (python3.12) and .exe, capturing the runtime.
+var host = regexp.MustCompile(`(?i)^(javaw?|pythonw?|py|node|electron|dotnet|mono|ruby|perl|php|wscript|cscript|deno|bun)[\d.]*?(?:\.exe)?$`)
+
+// genericClass matches window classes that runtimes and toolkits give every
+// window, which say nothing of the application.
+var genericClass = regexp.MustCompile(`(?i)^(SunAwt|sun-awt-|java-lang-|Chrome_WidgetWin_|ConsoleWindowClass$|CASCADIA_HOSTING_WINDOW_CLASS$|TkTopLevel$|Tk$|Qt\d*QWindow|HwndWrapper\[|WindowsForms\d*\.|GLFW|SDL_app$)`)
+
+// Identify returns the application identity of a window from its process
+// name, executable path, window class and command line, any of which may be
+// empty. It is the process name, unless that is a runtime: then it is the
+// runtime and what it runs, as in "java:build.jar" or "python:manage.py",
+// or the runtime and the window class, as in "electron:Slack".
+func Identify(process, exePath, class, commandLine string) string {
+	if process == "" {
+		process = base(exePath)
+	}
+	m := host.FindStringSubmatch(process)
+	if m == nil {
+		if process == "" {
+			return class
+		}
+		return process
+	}
+	runtime := strings.ToLower(m[1])
+	switch runtime {
+	case "javaw":
+		runtime = "java"
+	case "pythonw", "py":
+		runtime = "python"
+	}
+	if target := runs(runtime, SplitCommandLine(commandLine)); target != "" {
+		return runtime + ":" + target
+	}
+	if class != "" && !genericClass.MatchString(class) {
+		return runtime + ":" + class
+	}
+	return process
+}
+
+// runs returns what a runtime's command line runs: the main class or jar
+// for java, the module or script for python, and the script otherwise.
+func runs(runtime string, args []string) string {
+	if len(args) < 2 {
+		return ""
+	}
+	args = args[1:]
+	// Options that take the next argument as their value
+	var valued map[string]bool
+	switch runtime {
+	case "java":
+		valued = map[string]bool{"-cp": true, "-classpath": true, "--class-path": true, "-p": true, "--module-path": true, "--add-modules": true, "--add-opens": true, "--add-exports": true}
+	case "python":
+		valued = map[string]bool{"-W": true, "-X": true, "-Q": true}
+	case "node", "electron", "deno", "bun":
+		valued = map[string]bool{"-r": true, "--require": true, "--import": true, "--loader": true}
+	}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case (runtime == "java" && (a == "-jar" || a == "-m" || a == "--module")) || (runtime == "python" && a == "-m"):
+			if i+1 < len(args) {
+				return base(args[i+1])
+			}
+			return ""
+		case runtime == "python" && a == "-c", runtime == "node" && (a == "-e" || a == "--eval"):
+			return "" // inline code
+		case valued[a]:
+			i++
+		case strings.HasPrefix(a, "-"), strings.HasPrefix(a, "//"):
+		case a == "run" || a == "exec":
+			// deno run, bun run, dotnet run
+		default:
+			if b := base(a); b != "." && b != "" {
+				return b
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// base returns the last element of a Windows or Unix path.
+func base(path string) string {
+	path = strings.TrimRight(path, `/\`)
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		path = path[i+1:]
+	}
+	return path
+}
+
+// SplitCommandLine splits a command line into arguments. Double quotes
+// group an argument with spaces in it, and a backslash before a quote
+// escapes it, as on Windows.
+func SplitCommandLine(s string) []string {
+	var (
+		args   []string
+		cur    strings.Builder
+		quoted bool
+		inArg  bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '"':
+			cur.WriteByte('"')
+			inArg = true
+			i++
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// JoinCommandLine joins arguments into a command line that SplitCommandLine
+// splits back into them.
+func JoinCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"") {
+			a = `"` + strings.ReplaceAll(a, `"`, `\"`) + `"`
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Secrets matches credentials in command lines: the values of options
+// named like passwords, tokens and keys, and the password in a URL.
+var Secrets = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:^|\s)(?:--?|/)[\w.-]*(?:password|passwd|pwd|token|secret|api[-_]?key|apikey|credential)[\w.-]*(?:[=:]|\s+)("[^"]*"|\S+)`),
+	regexp.MustCompile(`://[^/\s:@]+:([^/\s@]+)@`),
+}
+
+// Redacted replaces what Redact removes.
+const Redacted = "***"
+
+// Redact replaces what each pattern matches in s with Redacted: the
+// pattern's groups, if it has any, or else the whole match.
+func Redact(s string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		matches := re.FindAllStringSubmatchIndex(s, -1)
+		if matches == nil {
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			spans := [][2]int{{m[0], m[1]}}
+			if len(m) > 2 {
+				spans = spans[:0]
+				for g := 2; g < len(m); g += 2 {
+					if m[g] >= 0 && m[g] >= last {
+						spans = append(spans, [2]int{m[g], m[g+1]})
+					}
+				}
+			}
+			for _, sp := range spans {
+				b.WriteString(s[last:sp[0]])
+				b.WriteString(Redacted)
+				last = sp[1]
+			}
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s
+}
@@ -0,0 +1,168 @@
+// Package browser gets the URL of the tab in front from a companion browser
+// extension. The extension talks to timewarp run as a native-messaging
+// host, which leaves the active tab in a small state file; the tracker
+// reads it back whenever a browser window is in front.
+package browser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostName is the name the extension connects to the host by.
+const HostName = "com.timewarp.browser"
+
+// ProcessPattern matches the processes of the browsers the extension
+// supports, and those Google Meet runs in.
+const ProcessPattern = `(?i)^(chrome|msedge|firefox|brave|opera|vivaldi|chromium|google-chrome)(\.exe)?$`
+
+var browserProcess = regexp.MustCompile(ProcessPattern)
+
+// IsBrowser reports whether process is a browser.
+func IsBrowser(process string) bool {
+	return browserProcess.MatchString(process)
+}
+
+// Tab is the active tab of the browser window last in front.
+type Tab struct {
+	URL   string    `json:"url"`
+	Title string    `json:"title"`
+	At    time.Time `json:"at"`
+}
+
+// StatePath is the file the host leaves the active tab in. It is in the
+// user's cache folder rather than the DB folder, which the host, started by
+// the browser, has no way to know.
+func StatePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("browser: %w", err)
+	}
+	return filepath.Join(dir, "timewarp", "browser-tab.json"), nil
+}
+
+// Tabs reads the active tab left by the host, for a tracker to look up the
+// URL of a browser window.
+type Tabs struct {
+	path    string
+	private []string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	tab     Tab
+}
+
+// NewTabs reads the state file at path. URLs on the private domains, or
+// their subdomains, are never returned.
+func NewTabs(path string, private []string) *Tabs {
+	t := &Tabs{path: path, private: []string{}}
+	for _, d := range private {
+		if d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), "."); d != "" {
+			t.private = append(t.private, d)
+		}
+	}
+	return t
+}
+
+// SharePrivate leaves t's private domains next to the state file for the
+// host, which the browser starts without timewarp's settings, so that it
+// drops their URLs before a tab is written to the state file at all.
+func (t *Tabs) SharePrivate() error {
+	data, err := json.Marshal(t.private)
+	if err != nil {
+		return err
+	}
+	if err := replaceFile(privatePath(t.path), data); err != nil {
+		return fmt.Errorf("browser: %w", err)
+	}
+	return nil
+}
+
+// privatePath is the file the private domains are shared with the host
+// in, beside the state file at path.
+func privatePath(path string) string {
+	return filepath.Join(filepath.Dir(path), "browser-private.json")
+}
+
+// readPrivate returns the private domains shared beside the state file at
+// path, or none if they have not been.
+func readPrivate(path string) []string {
+	data, err := os.ReadFile(privatePath(path))
+	if err != nil {
+		return nil
+	}
+	var private []string
+	json.Unmarshal(data, &private)
+	return private
+}
+
+// URL returns the URL of the tab shown in the window of process with title,
+// or "" if it is not a browser, the extension has not reported the tab, or
+// the tab is on a private domain. The window title must start with the
+// tab's, so a stale report is not taken for a later tab.
+func (t *Tabs) URL(process, title string) string {
+	if !IsBrowser(process) {
+		return ""
+	}
+	tab := t.current()
+	if tab.URL == "" || tab.Title == "" || !strings.HasPrefix(title, tab.Title) {
+		return ""
+	}
+	if isPrivate(t.private, tab.URL) {
+		return ""
+	}
+	return tab.URL
+}
+
+// current returns the tab in the state file, reading it again only when
+// it has changed.
+func (t *Tabs) current() Tab {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, err := os.Stat(t.path)
+	if err != nil {
+		t.tab, t.modTime, t.size = Tab{}, time.Time{}, 0
+		return t.tab
+	}
+	if info.ModTime().Equal(t.modTime) && info.Size() == t.size {
+		return t.tab
+	}
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return t.tab
+	}
+	var tab Tab
+	if json.Unmarshal(data, &tab) != nil {
+		return t.tab
+	}
+	t.tab, t.modTime, t.size = tab, info.ModTime(), info.Size()
+	return tab
+}
+
+// isPrivate reports whether the URL raw is on one of the private domains.
+func isPrivate(private []string, raw string) bool {
+	host := Domain(raw)
+	for _, d := range private {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Domain returns the lower-case host name of a URL, or "" if it has none.
+func Domain(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,133 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/clock"
+)
+
+func frame(t *testing.T, v any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeMessage(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestServe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tab.json")
+	clk := clock.NewSim(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+
+	var in bytes.Buffer
+	in.Write(frame(t, report{URL: "https://acme.atlassian.net/browse/OPS-17", Title: "OPS-17 Fix login - Jira"}))
+	in.Write(frame(t, report{URL: "https://mail.example/inbox", Title: "Inbox", Incognito: true}))
+	var out bytes.Buffer
+	if err := Serve(&in, &out, path, clk); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		var n uint32
+		binary.Read(&out, binary.LittleEndian, &n)
+		var reply map[string]any
+		if err := json.Unmarshal(out.Next(int(n)), &reply); err != nil || reply["ok"] != true {
+			t.Errorf("reply %d: got %v, %v", i, reply, err)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	var tab Tab
+	json.Unmarshal(data, &tab)
+	if tab.URL != "" || tab.Title != "Inbox" || !tab.At.Equal(clk.Now()) {
+		t.Errorf("expected the incognito tab without its URL, got %+v", tab)
+	}
+}
+
+func TestServe_PrivateDomains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tab.json")
+	if err := NewTabs(path, []string{"Bank.example"}).SharePrivate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var in bytes.Buffer
+	in.Write(frame(t, report{URL: "https://login.bank.example/accounts", Title: "Accounts"}))
+	if err := Serve(&in, &bytes.Buffer{}, path, clock.Real{}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	var tab Tab
+	json.Unmarshal(data, &tab)
+	if tab.URL != "" || tab.Title != "Accounts" {
+		t.Errorf("expected the private tab written without its URL, got %+v", tab)
+	}
+}
+
+func TestServe_TooLong(t *testing.T) {
+	var in bytes.Buffer
+	binary.Write(&in, binary.LittleEndian, uint32(maxMessage+1))
+	if err := Serve(&in, &bytes.Buffer{}, filepath.Join(t.TempDir(), "tab.json"), clock.Real{}); err == nil {
+		t.Error("expected an error for an oversized message")
+	}
+}
+
+func TestTabs_URL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tab.json")
+	tabs := NewTabs(path, []string{" Bank.example ", ""})
+	if got := tabs.URL("chrome.exe", "OPS-17 Fix login - Jira - Google Chrome"); got != "" {
+		t.Errorf("expected no URL before the host has run, got %q", got)
+	}
+
+	write := func(url, title string) {
+		t.Helper()
+		if err := writeTab(path, Tab{URL: url, Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("https://acme.atlassian.net/browse/OPS-17", "OPS-17 Fix login - Jira")
+	cases := []struct {
+		process, title, want string
+	}{
+		{"chrome.exe", "OPS-17 Fix login - Jira - Google Chrome", "https://acme.atlassian.net/browse/OPS-17"},
+		{"firefox", "OPS-17 Fix login - Jira — Mozilla Firefox", "https://acme.atlassian.net/browse/OPS-17"},
+		{"chrome.exe", "Inbox - Google Chrome", ""}, // a tab not reported yet
+		{"acad.exe", "OPS-17 Fix login - Jira", ""},
+	}
+	for _, c := range cases {
+		if got := tabs.URL(c.process, c.title); got != c.want {
+			t.Errorf("%s %q: got %q, want %q", c.process, c.title, got, c.want)
+		}
+	}
+
+	// Longer, so that the change is seen whatever the file system's mtime
+	// resolution
+	write("https://online.bank.example/accounts/checking", "Accounts - Online banking")
+	if got := tabs.URL("msedge.exe", "Accounts - Online banking - Microsoft Edge"); got != "" {
+		t.Errorf("expected a private subdomain to be dropped, got %q", got)
+	}
+	if got := Domain("https://ACME.atlassian.net:443/browse"); got != "acme.atlassian.net" {
+		t.Errorf("Domain: got %q", got)
+	}
+}
+
+func TestLaunchedByBrowser(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"chrome-extension://abcdefghijklmnop/", "--parent-window=0"}, true},
+		{[]string{"/home/me/.mozilla/native-messaging-hosts/com.timewarp.browser.json", FirefoxExtensionID}, true},
+		{[]string{"-dbpath", "/data"}, false},
+		{nil, false},
+	} {
+		if got := LaunchedByBrowser(c.args); got != c.want {
+			t.Errorf("%q: got %v", c.args, got)
+		}
+	}
+}
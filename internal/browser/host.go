@@ -0,0 +1,99 @@
+package browser
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vinistoisr/timewarp/internal/clock"
+)
+
+// maxMessage is the largest message taken from the browser. Tab reports
+// are far smaller, so anything bigger is not from the extension.
+const maxMessage = 1 << 20
+
+// report is what the extension sends when the active tab changes.
+type report struct {
+	URL       string `json:"url"`
+	Title     string `json:"title"`
+	Incognito bool   `json:"incognito"`
+}
+
+// Serve runs the native-messaging host. It reads the extension's tab
+// reports from r, each a JSON message after its length as a 32-bit
+// little-endian integer, until the browser closes r; leaves each tab in the
+// state file at path; and answers each on w in the same framing. Incognito
+// tabs, and those on the private domains the tracker shared (see
+// SharePrivate), are left without their URL.
+func Serve(r io.Reader, w io.Writer, path string, clk clock.Clock) error {
+	for {
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("browser: read: %w", err)
+		}
+		if n > maxMessage {
+			return fmt.Errorf("browser: message of %d bytes is too long", n)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return fmt.Errorf("browser: read: %w", err)
+		}
+
+		reply := map[string]any{"ok": true}
+		var m report
+		err := json.Unmarshal(buf, &m)
+		if err == nil {
+			if m.Incognito || isPrivate(readPrivate(path), m.URL) {
+				m.URL = ""
+			}
+			err = writeTab(path, Tab{URL: m.URL, Title: m.Title, At: clk.Now().UTC()})
+		}
+		if err != nil {
+			reply = map[string]any{"ok": false, "error": err.Error()}
+		}
+		if err := writeMessage(w, reply); err != nil {
+			return fmt.Errorf("browser: write: %w", err)
+		}
+	}
+}
+
+func writeMessage(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// writeTab replaces the state file with tab.
+func writeTab(path string, tab Tab) error {
+	data, err := json.Marshal(tab)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, data)
+}
+
+// replaceFile replaces the file at path with data, through a temporary file
+// so that no reader sees half of it.
+func replaceFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
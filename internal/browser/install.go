@@ -0,0 +1,67 @@
+package browser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FirefoxExtensionID is the ID of the companion extension in Firefox, which
+// names extensions by ID rather than by origin.
+const FirefoxExtensionID = "browser@timewarp"
+
+// manifest is a native-messaging host manifest. Chromium-based browsers
+// allow origins and Firefox extension IDs.
+type manifest struct {
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Path              string   `json:"path"`
+	Type              string   `json:"type"`
+	AllowedOrigins    []string `json:"allowed_origins,omitempty"`
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+}
+
+// LaunchedByBrowser reports whether the process was started by a browser as
+// a native-messaging host, from its arguments: Chromium-based browsers pass
+// the extension's origin, and Firefox the manifest's path and the
+// extension's ID.
+func LaunchedByBrowser(args []string) bool {
+	for _, a := range args {
+		if strings.HasPrefix(a, "chrome-extension://") || a == FirefoxExtensionID {
+			return true
+		}
+	}
+	return false
+}
+
+// writeManifest writes the host manifest for exe to dir, for the Chromium
+// extension IDs given or, with firefox, for the Firefox extension.
+func writeManifest(dir, exe string, extensionIDs []string, firefox bool) (string, error) {
+	m := manifest{
+		Name:        HostName,
+		Description: "Timewarp browser tab capture",
+		Path:        exe,
+		Type:        "stdio",
+	}
+	if firefox {
+		m.AllowedExtensions = []string{FirefoxExtensionID}
+	} else {
+		for _, id := range extensionIDs {
+			m.AllowedOrigins = append(m.AllowedOrigins, "chrome-extension://"+id+"/")
+		}
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("browser: %w", err)
+	}
+	path := filepath.Join(dir, HostName+".json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("browser: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package browser
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Install registers exe as the native-messaging host with every browser
+// that has a profile folder, allowing the Chromium extension IDs given, and
+// returns the manifests written.
+func Install(exe string, extensionIDs []string) ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	// Each browser's folder, then where it looks for host manifests in it
+	type target struct {
+		profile, hosts string
+		firefox        bool
+	}
+	var targets []target
+	if runtime.GOOS == "darwin" {
+		support := filepath.Join(home, "Library", "Application Support")
+		for _, b := range []string{"Google/Chrome", "Chromium", "Microsoft Edge", "BraveSoftware/Brave-Browser"} {
+			targets = append(targets, target{filepath.Join(support, b), filepath.Join(support, b, "NativeMessagingHosts"), false})
+		}
+		targets = append(targets, target{filepath.Join(support, "Mozilla"), filepath.Join(support, "Mozilla", "NativeMessagingHosts"), true})
+	} else {
+		config := filepath.Join(home, ".config")
+		for _, b := range []string{"google-chrome", "chromium", "microsoft-edge", "BraveSoftware/Brave-Browser"} {
+			targets = append(targets, target{filepath.Join(config, b), filepath.Join(config, b, "NativeMessagingHosts"), false})
+		}
+		targets = append(targets, target{filepath.Join(home, ".mozilla"), filepath.Join(home, ".mozilla", "native-messaging-hosts"), true})
+	}
+
+	var written []string
+	for _, t := range targets {
+		if _, err := os.Stat(t.profile); err != nil {
+			continue
+		}
+		path, err := writeManifest(t.hosts, exe, extensionIDs, t.firefox)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
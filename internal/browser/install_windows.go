@@ -0,0 +1,47 @@
+package browser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Install registers exe as the native-messaging host with Chrome, Edge,
+// Brave and Firefox for the current user, allowing the Chromium extension
+// IDs given, and returns the manifests written. Windows browsers find the
+// manifests through the registry, so they are kept next to the settings.
+func Install(exe string, extensionIDs []string) ([]string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(config, "timewarp")
+	chromium, err := writeManifest(filepath.Join(dir, "chromium"), exe, extensionIDs, false)
+	if err != nil {
+		return nil, err
+	}
+	firefox, err := writeManifest(filepath.Join(dir, "firefox"), exe, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, manifest := range map[string]string{
+		`Software\Google\Chrome\NativeMessagingHosts\` + HostName:               chromium,
+		`Software\Microsoft\Edge\NativeMessagingHosts\` + HostName:              chromium,
+		`Software\BraveSoftware\Brave-Browser\NativeMessagingHosts\` + HostName: chromium,
+		`Software\Mozilla\NativeMessagingHosts\` + HostName:                     firefox,
+	} {
+		k, _, err := registry.CreateKey(registry.CURRENT_USER, key, registry.SET_VALUE)
+		if err != nil {
+			return nil, fmt.Errorf("browser: %s: %w", key, err)
+		}
+		err = k.SetStringValue("", manifest)
+		k.Close()
+		if err != nil {
+			return nil, fmt.Errorf("browser: %s: %w", key, err)
+		}
+	}
+	return []string{chromium, firefox}, nil
+}
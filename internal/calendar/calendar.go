@@ -0,0 +1,358 @@
+// Package calendar reads events from iCalendar (.ics) files, such as those
+// exported from Outlook or Google Calendar, expanding recurring events into
+// their occurrences.
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is one occurrence of a calendar event.
+type Event struct {
+	UID         string
+	Subject     string
+	Description string
+	Location    string
+	Start, End  time.Time
+}
+
+// maxOccurrences caps the occurrences taken from one recurring event, for
+// rules that would otherwise run on for years.
+const maxOccurrences = 1000
+
+// Files returns the .ics files at path: path itself if it is a file, or
+// those directly in it if it is a folder.
+func Files(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("calendar: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("calendar: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".ics") {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// Fingerprint summarises the names, sizes and modification times of the
+// .ics files at path, so that a caller polling it can tell when to import
+// again.
+func Fingerprint(path string) (string, error) {
+	files, err := Files(path)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", fmt.Errorf("calendar: %w", err)
+		}
+		fmt.Fprintf(&b, "%s|%d|%d\n", f, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// ParseFile reads the events in an .ics file. See Parse.
+func ParseFile(path string, until time.Time) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("calendar: %w", err)
+	}
+	defer f.Close()
+	events, err := Parse(f, until)
+	if err != nil {
+		return nil, fmt.Errorf("calendar: %s: %w", path, err)
+	}
+	return events, nil
+}
+
+// Parse reads the events in an iCalendar stream in order of their start,
+// with recurring events expanded up to until. Cancelled and all-day events
+// are left out, as neither is a meeting that was attended.
+func Parse(r io.Reader, until time.Time) ([]Event, error) {
+	events, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return expand(events, until), nil
+}
+
+// prop is one content line: NAME;PARAM=VALUE:value.
+type prop struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// vevent is a VEVENT as written, before recurrences are expanded.
+type vevent struct {
+	Event
+	rule         string
+	exdates      []time.Time
+	recurrenceID time.Time
+	allDay       bool
+	cancelled    bool
+}
+
+// parse reads the VEVENTs in an iCalendar stream.
+func parse(r io.Reader) ([]vevent, error) {
+	var (
+		events []vevent
+		cur    *vevent
+		dur    time.Duration
+		depth  int // components nested in the VEVENT, such as VALARM
+	)
+	props, err := readProps(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range props {
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT") && cur == nil:
+			cur, dur, depth = &vevent{}, 0, 0
+			continue
+		case cur == nil:
+			continue
+		case p.name == "BEGIN":
+			depth++
+			continue
+		case p.name == "END" && depth > 0:
+			depth--
+			continue
+		case p.name == "END":
+			if cur.End.IsZero() && dur > 0 {
+				cur.End = cur.Start.Add(dur)
+			}
+			// Cancelled events are kept until expand, as a cancelled
+			// occurrence still removes the one it replaces
+			if !cur.allDay && !cur.Start.IsZero() && cur.End.After(cur.Start) {
+				events = append(events, *cur)
+			}
+			cur = nil
+			continue
+		case depth > 0:
+			continue
+		}
+
+		var err error
+		switch p.name {
+		case "UID":
+			cur.UID = p.value
+		case "SUMMARY":
+			cur.Subject = unescape(p.value)
+		case "DESCRIPTION":
+			cur.Description = unescape(p.value)
+		case "LOCATION":
+			cur.Location = unescape(p.value)
+		case "STATUS":
+			cur.cancelled = strings.EqualFold(p.value, "CANCELLED")
+		case "DTSTART":
+			cur.Start, cur.allDay, err = parseTime(p, p.value)
+		case "DTEND":
+			cur.End, _, err = parseTime(p, p.value)
+		case "DURATION":
+			dur, err = parseDuration(p.value)
+		case "RRULE":
+			cur.rule = p.value
+		case "EXDATE":
+			for _, v := range strings.Split(p.value, ",") {
+				t, _, err := parseTime(p, v)
+				if err != nil {
+					return nil, fmt.Errorf("EXDATE: %w", err)
+				}
+				cur.exdates = append(cur.exdates, t)
+			}
+		case "RECURRENCE-ID":
+			cur.recurrenceID, _, err = parseTime(p, p.value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+	}
+	return events, nil
+}
+
+// expand turns parsed events into occurrences, up to until for recurring
+// events without an end. A changed occurrence (one with a RECURRENCE-ID)
+// replaces the one it was moved from.
+func expand(events []vevent, until time.Time) []Event {
+	type key struct {
+		uid string
+		at  int64
+	}
+	moved := map[key]bool{}
+	for _, e := range events {
+		if !e.recurrenceID.IsZero() {
+			moved[key{e.UID, e.recurrenceID.Unix()}] = true
+		}
+	}
+
+	var out []Event
+	for _, e := range events {
+		if e.cancelled {
+			continue
+		}
+		if !e.recurrenceID.IsZero() || e.rule == "" {
+			out = append(out, e.Event)
+			continue
+		}
+		r, err := parseRule(e.rule, e.Start.Location())
+		if err != nil {
+			// An unsupported rule still has its first occurrence
+			out = append(out, e.Event)
+			continue
+		}
+		length := e.End.Sub(e.Start)
+		excluded := map[int64]bool{}
+		for _, x := range e.exdates {
+			excluded[x.Unix()] = true
+		}
+		for _, start := range r.occurrences(e.Start, until) {
+			if excluded[start.Unix()] || moved[key{e.UID, start.Unix()}] {
+				continue
+			}
+			occ := e.Event
+			occ.Start, occ.End = start, start.Add(length)
+			out = append(out, occ)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+// readProps reads the content lines of an iCalendar stream, joining folded
+// lines back together.
+func readProps(r io.Reader) ([]prop, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var lines []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	props := make([]prop, 0, len(lines))
+	for _, line := range lines {
+		p, ok := parseProp(line)
+		if ok {
+			props = append(props, p)
+		}
+	}
+	return props, nil
+}
+
+// parseProp splits a content line into its name, parameters and value. The
+// value starts at the first colon outside a quoted parameter value.
+func parseProp(line string) (prop, bool) {
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return prop{}, false
+	}
+	p := prop{value: line[colon+1:], params: map[string]string{}}
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return p, true
+}
+
+// unescape undoes the escaping of TEXT values.
+func unescape(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// parseTime parses a DATE or DATE-TIME value of p: UTC if it ends in Z, in
+// the zone named by TZID if it has one, and otherwise in local time.
+func parseTime(p prop, v string) (t time.Time, allDay bool, err error) {
+	v = strings.TrimSpace(v)
+	if p.params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err = time.ParseInLocation("20060102", v, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err = time.Parse("20060102T150405Z", v)
+		return t, false, err
+	}
+	t, err = time.ParseInLocation("20060102T150405", v, location(p.params["TZID"]))
+	return t, false, err
+}
+
+// parseDuration parses a DURATION value such as PT1H30M or P1D.
+func parseDuration(v string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(v, "+"), "P")
+	if strings.HasPrefix(v, "-") || s == v {
+		return 0, fmt.Errorf("bad duration %q", v)
+	}
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			num += string(c)
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q", v)
+		}
+		num = ""
+		switch {
+		case c == 'W':
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case c == 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("bad duration %q", v)
+		}
+	}
+	return d, nil
+}
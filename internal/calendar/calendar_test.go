@@ -0,0 +1,158 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// outlook is trimmed from an Outlook export: a folded description, Windows
+// zone names, a weekly meeting with one week skipped and one moved, a
+// cancelled meeting and an all-day event.
+const outlook = `BEGIN:VCALENDAR
+PRODID:-//Microsoft Corporation//Outlook 16.0 MIMEDIR//EN
+VERSION:2.0
+BEGIN:VTIMEZONE
+TZID:Eastern Standard Time
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:design-review
+SUMMARY:25-019 Design Review
+DESCRIPTION:Agenda:\n- Lighting layout\, level 2\n- Panel schedules for 25-
+ 019
+DTSTART;TZID="Eastern Standard Time":20260302T140000
+DTEND;TZID="Eastern Standard Time":20260302T150000
+RRULE:FREQ=WEEKLY;COUNT=4;BYDAY=MO
+EXDATE;TZID="Eastern Standard Time":20260309T140000
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Reminder
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:design-review
+RECURRENCE-ID;TZID="Eastern Standard Time":20260316T140000
+SUMMARY:25-019 Design Review (moved)
+DTSTART;TZID="Eastern Standard Time":20260317T100000
+DTEND;TZID="Eastern Standard Time":20260317T110000
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled
+SUMMARY:Coordination
+STATUS:CANCELLED
+DTSTART:20260303T150000Z
+DTEND:20260303T160000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday
+SUMMARY:Office closed
+DTSTART;VALUE=DATE:20260306
+DTEND;VALUE=DATE:20260307
+END:VEVENT
+BEGIN:VEVENT
+UID:site-visit
+SUMMARY:Site visit
+DTSTART:20260304T130000Z
+DURATION:PT1H30M
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParse_Outlook(t *testing.T) {
+	events, err := Parse(strings.NewReader(strings.ReplaceAll(outlook, "\n", "\r\n")), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+	want := []struct {
+		subject    string
+		start, end time.Time
+	}{
+		{"25-019 Design Review", time.Date(2026, 3, 2, 14, 0, 0, 0, ny), time.Date(2026, 3, 2, 15, 0, 0, 0, ny)},
+		{"Site visit", time.Date(2026, 3, 4, 13, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 14, 30, 0, 0, time.UTC)},
+		{"25-019 Design Review (moved)", time.Date(2026, 3, 17, 10, 0, 0, 0, ny), time.Date(2026, 3, 17, 11, 0, 0, 0, ny)},
+		{"25-019 Design Review", time.Date(2026, 3, 23, 14, 0, 0, 0, ny), time.Date(2026, 3, 23, 15, 0, 0, 0, ny)},
+	}
+	if len(events) != len(want) {
+		for _, e := range events {
+			t.Logf("%s %s", e.Subject, e.Start)
+		}
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Subject != w.subject || !e.Start.Equal(w.start) || !e.End.Equal(w.end) {
+			t.Errorf("event %d: got %q %s-%s, want %q %s-%s", i, e.Subject, e.Start, e.End, w.subject, w.start, w.end)
+		}
+	}
+	if d := events[0].Description; d != "Agenda:\n- Lighting layout, level 2\n- Panel schedules for 25-019" {
+		t.Errorf("description not unfolded and unescaped: %q", d)
+	}
+}
+
+func TestRule_Occurrences(t *testing.T) {
+	start := time.Date(2026, 1, 13, 9, 0, 0, 0, time.UTC) // a Tuesday
+	limit := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		rule string
+		want []string
+	}{
+		{"FREQ=DAILY;COUNT=3", []string{"2026-01-13", "2026-01-14", "2026-01-15"}},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=5", []string{"2026-01-13", "2026-01-14", "2026-01-15", "2026-01-16", "2026-01-19"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;UNTIL=20260206", []string{"2026-01-13", "2026-01-15", "2026-01-27", "2026-01-29"}},
+		{"FREQ=MONTHLY;BYDAY=2TU;COUNT=3", []string{"2026-01-13", "2026-02-10", "2026-03-10"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=2", []string{"2026-01-30", "2026-02-27"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=2", []string{"2026-01-31", "2026-02-28"}},
+		{"FREQ=YEARLY;COUNT=2", []string{"2026-01-13", "2027-01-13"}},
+		{"FREQ=MONTHLY;INTERVAL=2", []string{"2026-01-13", "2026-03-13", "2026-05-13"}},
+	}
+	for _, c := range cases {
+		r, err := parseRule(c.rule, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", c.rule, err)
+			continue
+		}
+		var got []string
+		for _, o := range r.occurrences(start, limit) {
+			if o.Hour() != 9 {
+				t.Errorf("%s: occurrence at %s, not 09:00", c.rule, o)
+			}
+			got = append(got, o.Format("2006-01-02"))
+		}
+		if strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("%s: got %v, want %v", c.rule, got, c.want)
+		}
+	}
+
+	if _, err := parseRule("FREQ=MONTHLY;BYSETPOS=-1;BYDAY=MO,TU", time.UTC); err == nil {
+		t.Error("expected an error for BYSETPOS")
+	}
+}
+
+func TestRule_KeepsWallClockOverDST(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	r, _ := parseRule("FREQ=WEEKLY;COUNT=2", ny)
+	occ := r.occurrences(time.Date(2026, 3, 2, 14, 0, 0, 0, ny), time.Time{})
+	// Clocks go forward on 8 March
+	if len(occ) != 2 || occ[1].Hour() != 14 || occ[1].Sub(occ[0]) != 7*24*time.Hour-time.Hour {
+		t.Errorf("got %v", occ)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"work.ics", "Personal.ICS", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte(outlook), 0o644)
+	}
+	files, err := Files(dir)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected the two .ics files, got %v %v", files, err)
+	}
+	before, _ := Fingerprint(dir)
+	os.WriteFile(filepath.Join(dir, "work.ics"), []byte(outlook+"\n"), 0o644)
+	if after, _ := Fingerprint(dir); after == before {
+		t.Error("expected the fingerprint to change with a file")
+	}
+}
@@ -0,0 +1,288 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rule is the part of an RRULE this package supports: what Outlook and
+// Google Calendar write for daily, weekly, monthly and yearly meetings.
+type rule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []weekdayNum
+	byMonthDay []int
+}
+
+// weekdayNum is a BYDAY entry such as TU, or 2TU (the second Tuesday) or
+// -1FR (the last Friday) in a monthly rule.
+type weekdayNum struct {
+	n   int
+	day time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// maxPeriods bounds the days, weeks, months or years stepped through in
+// expanding a rule whose periods may have no occurrences.
+const maxPeriods = 5000
+
+// parseRule parses an RRULE value. A local UNTIL is taken to be in loc.
+func parseRule(s string, loc *time.Location) (rule, error) {
+	r := rule{interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			r.freq = strings.ToUpper(v)
+			switch r.freq {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+			default:
+				return rule{}, fmt.Errorf("unsupported FREQ %s", v)
+			}
+		case "INTERVAL":
+			if r.interval, err = strconv.Atoi(v); err == nil && r.interval < 1 {
+				err = fmt.Errorf("INTERVAL %d", r.interval)
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(v)
+		case "UNTIL":
+			r.until, err = parseUntil(v, loc)
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				wd, ok := weekdays[strings.ToUpper(d[max(len(d)-2, 0):])]
+				if !ok {
+					return rule{}, fmt.Errorf("BYDAY %s", d)
+				}
+				n := 0
+				if num := d[:len(d)-2]; num != "" {
+					if n, err = strconv.Atoi(num); err != nil {
+						return rule{}, fmt.Errorf("BYDAY %s", d)
+					}
+				}
+				r.byDay = append(r.byDay, weekdayNum{n, wd})
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(v, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return rule{}, fmt.Errorf("BYMONTHDAY %s", d)
+				}
+				r.byMonthDay = append(r.byMonthDay, n)
+			}
+		case "WKST", "":
+		default:
+			return rule{}, fmt.Errorf("unsupported %s", k)
+		}
+		if err != nil {
+			return rule{}, fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	if r.freq == "" {
+		return rule{}, fmt.Errorf("no FREQ")
+	}
+	return r, nil
+}
+
+// parseUntil parses UNTIL, which includes the whole day when it is a date.
+func parseUntil(v string, loc *time.Location) (time.Time, error) {
+	switch {
+	case len(v) == 8:
+		t, err := time.ParseInLocation("20060102", v, loc)
+		return t.AddDate(0, 0, 1).Add(-time.Second), err
+	case strings.HasSuffix(v, "Z"):
+		return time.Parse("20060102T150405Z", v)
+	default:
+		return time.ParseInLocation("20060102T150405", v, loc)
+	}
+}
+
+// occurrences returns the starts of the occurrences of an event first
+// starting at start, up to the rule's UNTIL or COUNT. A rule with neither is
+// expanded up to limit.
+func (r rule) occurrences(start, limit time.Time) []time.Time {
+	end := limit
+	if r.count > 0 {
+		end = time.Time{}
+	}
+	if !r.until.IsZero() && (end.IsZero() || r.until.Before(end)) {
+		end = r.until
+	}
+
+	var out []time.Time
+	for k := 0; k < maxPeriods; k++ {
+		for _, t := range r.period(start, k) {
+			if t.Before(start) {
+				continue
+			}
+			if !end.IsZero() && t.After(end) {
+				return out
+			}
+			out = append(out, t)
+			if len(out) == r.count || len(out) == maxOccurrences {
+				return out
+			}
+		}
+	}
+	return out
+}
+
+// period returns the occurrences in the k'th day, week, month or year of
+// the rule, at the time of day of start.
+func (r rule) period(start time.Time, k int) []time.Time {
+	y, m, d := start.Date()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+	step := k * r.interval
+
+	var out []time.Time
+	switch r.freq {
+	case "DAILY":
+		t := at(y, m, d+step)
+		if r.onDay(t) {
+			out = append(out, t)
+		}
+	case "WEEKLY":
+		// Weeks start on Monday
+		monday := d - (int(start.Weekday())+6)%7 + 7*step
+		if len(r.byDay) == 0 {
+			out = append(out, at(y, m, d+7*step))
+		}
+		for _, wd := range r.byDay {
+			out = append(out, at(y, m, monday+(int(wd.day)+6)%7))
+		}
+	case "MONTHLY":
+		first := at(y, m+time.Month(step), 1)
+		y, m := first.Year(), first.Month()
+		days := at(y, m+1, 0).Day()
+		var mdays []int
+		for _, md := range r.byMonthDay {
+			if md < 0 {
+				md += days + 1
+			}
+			mdays = append(mdays, md)
+		}
+		for _, wd := range r.byDay {
+			firstOf := 1 + (int(wd.day)-int(first.Weekday())+7)%7
+			switch {
+			case wd.n > 0:
+				mdays = append(mdays, firstOf+7*(wd.n-1))
+			case wd.n < 0:
+				last := at(y, m, days).Weekday()
+				mdays = append(mdays, days-(int(last)-int(wd.day)+7)%7+7*(wd.n+1))
+			default:
+				for md := firstOf; md <= days; md += 7 {
+					mdays = append(mdays, md)
+				}
+			}
+		}
+		if len(r.byMonthDay) == 0 && len(r.byDay) == 0 {
+			mdays = append(mdays, d)
+		}
+		for _, md := range mdays {
+			if md < 1 || md > days {
+				continue
+			}
+			t := at(y, m, md)
+			// With both, BYDAY narrows the BYMONTHDAY days
+			if len(r.byMonthDay) > 0 && len(r.byDay) > 0 && !r.onDay(t) {
+				continue
+			}
+			out = append(out, t)
+		}
+	case "YEARLY":
+		if t := at(y+step, m, d); t.Day() == d {
+			out = append(out, t)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return dedupe(out)
+}
+
+// onDay reports whether t falls on one of the rule's BYDAY weekdays, if it
+// has any.
+func (r rule) onDay(t time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, wd := range r.byDay {
+		if t.Weekday() == wd.day {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupe(ts []time.Time) []time.Time {
+	out := ts[:0]
+	for i, t := range ts {
+		if i == 0 || !t.Equal(ts[i-1]) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// windowsZones maps the Windows time zone names Outlook writes in TZID to
+// IANA zones, for the zones most often seen.
+var windowsZones = map[string]string{
+	"UTC":                            "UTC",
+	"GMT Standard Time":              "Europe/London",
+	"Greenwich Standard Time":        "Atlantic/Reykjavik",
+	"W. Europe Standard Time":        "Europe/Berlin",
+	"Romance Standard Time":          "Europe/Paris",
+	"Central Europe Standard Time":   "Europe/Budapest",
+	"Central European Standard Time": "Europe/Warsaw",
+	"E. Europe Standard Time":        "Europe/Chisinau",
+	"FLE Standard Time":              "Europe/Kiev",
+	"Newfoundland Standard Time":     "America/St_Johns",
+	"Atlantic Standard Time":         "America/Halifax",
+	"Eastern Standard Time":          "America/New_York",
+	"Central Standard Time":          "America/Chicago",
+	"Canada Central Standard Time":   "America/Regina",
+	"Mountain Standard Time":         "America/Denver",
+	"US Mountain Standard Time":      "America/Phoenix",
+	"Pacific Standard Time":          "America/Los_Angeles",
+	"Alaskan Standard Time":          "America/Anchorage",
+	"Hawaiian Standard Time":         "Pacific/Honolulu",
+	"India Standard Time":            "Asia/Kolkata",
+	"China Standard Time":            "Asia/Shanghai",
+	"Singapore Standard Time":        "Asia/Singapore",
+	"Tokyo Standard Time":            "Asia/Tokyo",
+	"AUS Eastern Standard Time":      "Australia/Sydney",
+	"New Zealand Standard Time":      "Pacific/Auckland",
+}
+
+// location returns the zone a TZID names: an IANA zone, one of the common
+// Windows zones, or an IANA zone after a prefix such as
+// /mozilla.org/20050126_1/. Unknown zones are taken to be local time.
+func location(tzid string) *time.Location {
+	if tzid == "" {
+		return time.Local
+	}
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return loc
+	}
+	if name, ok := windowsZones[tzid]; ok {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	if parts := strings.Split(tzid, "/"); len(parts) > 2 {
+		if loc, err := time.LoadLocation(strings.Join(parts[len(parts)-2:], "/")); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
@@ -0,0 +1,95 @@
+// Package capture reports the foreground window and user idle time from the
+// desktop session. Each platform provides its own Source, selected by build
+// tags; the rest of the tracker only sees the Source interface.
+package capture
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Window describes the foreground window as reported by a Source.
+type Window struct {
+	// ID is the platform window handle. It is only used to detect focus changes.
+	ID          uint64
+	Title       string
+	ProcessID   uint32
+	ProcessName string
+	// ExePath, Class and CommandLine are the process's executable, the
+	// window's class (WM_CLASS or app ID on Linux) and the process's command
+	// line, where the backend can get them.
+	ExePath     string
+	Class       string
+	CommandLine string
+}
+
+// Source is a platform capture backend.
+type Source interface {
+	// ActiveWindow returns the window that currently has input focus.
+	ActiveWindow() (Window, error)
+	// IdleTime returns how long it has been since the last user input.
+	IdleTime() (time.Duration, error)
+}
+
+// Watcher is implemented by Sources that can report focus changes as they
+// happen instead of waiting to be polled.
+type Watcher interface {
+	// Watch calls fn with the new foreground window and the time of the change
+	// whenever focus moves or the focused window's title changes. It blocks
+	// until ctx is done or the event stream fails.
+	Watch(ctx context.Context, fn func(Window, time.Time)) error
+}
+
+// SystemEvent is a change in the machine's power or session state.
+type SystemEvent string
+
+const (
+	Suspend  SystemEvent = "suspend"
+	Resume   SystemEvent = "resume"
+	Lock     SystemEvent = "lock"
+	Unlock   SystemEvent = "unlock"
+	Shutdown SystemEvent = "shutdown"
+)
+
+// SystemWatcher is implemented by Sources that can report when the machine
+// sleeps, wakes, locks, unlocks or shuts down.
+type SystemWatcher interface {
+	// WatchSystem calls fn with each system event and the time it happened.
+	// It blocks until ctx is done or the event stream fails.
+	WatchSystem(ctx context.Context, fn func(SystemEvent, time.Time)) error
+}
+
+var (
+	mu      sync.Mutex
+	current Source
+)
+
+// Current returns the Source used by the tracker. On first use it connects to
+// the platform backend; if that fails, every call on the returned Source
+// reports the connection error.
+func Current() Source {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		s, err := newPlatformSource()
+		if err != nil {
+			s = unavailable{err}
+		}
+		current = s
+	}
+	return current
+}
+
+// SetCurrent replaces the Source used by the tracker.
+func SetCurrent(s Source) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = s
+}
+
+// unavailable is returned by Current when the platform backend could not be started.
+type unavailable struct{ err error }
+
+func (u unavailable) ActiveWindow() (Window, error)    { return Window{}, u.err }
+func (u unavailable) IdleTime() (time.Duration, error) { return 0, u.err }
@@ -0,0 +1,191 @@
+//go:build linux
+
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// gnomeSource reads the focused window from GNOME Shell on Wayland. GNOME has
+// no public API for this, so it relies on the "Focused Window D-Bus" shell
+// extension, which exposes the window as JSON.
+type gnomeSource struct {
+	logind
+	conn *dbus.Conn
+	idle idler
+}
+
+func newGnomeSource(idle idler) (*gnomeSource, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("capture: session bus: %w", err)
+	}
+	return &gnomeSource{conn: conn, idle: idle}, nil
+}
+
+func (g *gnomeSource) ActiveWindow() (Window, error) {
+	var raw string
+	err := g.conn.Object("org.gnome.Shell", "/org/gnome/shell/extensions/FocusedWindow").
+		Call("org.gnome.shell.extensions.FocusedWindow.Get", 0).Store(&raw)
+	if err != nil {
+		return Window{}, fmt.Errorf("capture: GNOME focused window (is the Focused Window D-Bus extension enabled?): %w", err)
+	}
+
+	var fw struct {
+		ID      uint64 `json:"id"`
+		Title   string `json:"title"`
+		WMClass string `json:"wm_class"`
+		PID     uint32 `json:"pid"`
+	}
+	if err := json.Unmarshal([]byte(raw), &fw); err != nil {
+		return Window{}, fmt.Errorf("capture: parse GNOME focused window: %w", err)
+	}
+	if fw.ID == 0 && fw.Title == "" {
+		return Window{}, fmt.Errorf("could not get foreground window")
+	}
+
+	w := Window{ID: fw.ID, Title: fw.Title, ProcessID: fw.PID, Class: fw.WMClass}
+	if fw.PID != 0 {
+		w.ProcessName = processName(fw.PID)
+		w.ExePath, w.CommandLine = processDetails(fw.PID)
+	}
+	if w.ProcessName == "" {
+		w.ProcessName = fw.WMClass
+	}
+	return w, nil
+}
+
+func (g *gnomeSource) IdleTime() (time.Duration, error) {
+	return g.idle.IdleTime()
+}
+
+const (
+	kwinBusName    = "io.github.vinistoisr.Timewarp"
+	kwinObjectPath = "/Timewarp"
+	kwinPluginName = "timewarp-focus"
+)
+
+// kwinScript runs inside KWin and calls back into kwinReceiver whenever the
+// active window or its caption changes. It handles both the KWin 5
+// (clientActivated/activeClient) and KWin 6 (windowActivated/activeWindow) APIs.
+const kwinScript = `
+function send(w) {
+    if (!w) return;
+    callDBus("` + kwinBusName + `", "` + kwinObjectPath + `", "` + kwinBusName + `",
+        "UpdateActiveWindow", String(w.internalId), w.caption, w.resourceClass, w.pid);
+}
+function active() { return workspace.activeWindow || workspace.activeClient; }
+var watched = {};
+function onActivated(w) {
+    send(w);
+    if (w && !watched[w.internalId]) {
+        watched[w.internalId] = true;
+        w.captionChanged.connect(function() { if (active() === w) send(w); });
+    }
+}
+(workspace.windowActivated || workspace.clientActivated).connect(onActivated);
+onActivated(active());
+`
+
+// kwinSource receives focus changes pushed from a KWin script loaded through
+// org.kde.kwin.Scripting.
+type kwinSource struct {
+	logind
+	conn *dbus.Conn
+	idle idler
+
+	mu     sync.Mutex
+	win    Window
+	hasWin bool
+}
+
+// kwinReceiver is the object exported to KWin. It is separate from kwinSource
+// so that only UpdateActiveWindow is visible on the bus.
+type kwinReceiver struct{ src *kwinSource }
+
+func (r kwinReceiver) UpdateActiveWindow(id, caption, class string, pid int32) *dbus.Error {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	w := Window{ID: h.Sum64(), Title: caption, ProcessID: uint32(pid), Class: class}
+	if pid > 0 {
+		w.ProcessName = processName(uint32(pid))
+		w.ExePath, w.CommandLine = processDetails(uint32(pid))
+	}
+	if w.ProcessName == "" {
+		w.ProcessName = class
+	}
+
+	r.src.mu.Lock()
+	r.src.win = w
+	r.src.hasWin = true
+	r.src.mu.Unlock()
+	return nil
+}
+
+func newKWinSource(idle idler) (*kwinSource, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("capture: session bus: %w", err)
+	}
+	k := &kwinSource{conn: conn, idle: idle}
+
+	if err := conn.Export(kwinReceiver{k}, kwinObjectPath, kwinBusName); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("capture: export KWin receiver: %w", err)
+	}
+	reply, err := conn.RequestName(kwinBusName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("capture: bus name %s is taken (is another Timewarp running?)", kwinBusName)
+	}
+
+	if err := loadKWinScript(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return k, nil
+}
+
+func loadKWinScript(conn *dbus.Conn) error {
+	path := filepath.Join(os.TempDir(), kwinPluginName+".js")
+	if err := os.WriteFile(path, []byte(kwinScript), 0600); err != nil {
+		return fmt.Errorf("capture: write KWin script: %w", err)
+	}
+
+	scripting := conn.Object("org.kde.KWin", "/Scripting")
+	scripting.Call("org.kde.kwin.Scripting.unloadScript", 0, kwinPluginName)
+
+	var id int32
+	if err := scripting.Call("org.kde.kwin.Scripting.loadScript", 0, path, kwinPluginName).Store(&id); err != nil {
+		return fmt.Errorf("capture: load KWin script: %w", err)
+	}
+
+	// KWin 6 puts scripts under /Scripting/ScriptN, KWin 5 under /N.
+	for _, p := range []string{fmt.Sprintf("/Scripting/Script%d", id), fmt.Sprintf("/%d", id)} {
+		if conn.Object("org.kde.KWin", dbus.ObjectPath(p)).Call("org.kde.kwin.Script.run", 0).Err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("capture: could not start KWin script %d", id)
+}
+
+func (k *kwinSource) ActiveWindow() (Window, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.hasWin {
+		return Window{}, fmt.Errorf("could not get foreground window")
+	}
+	return k.win, nil
+}
+
+func (k *kwinSource) IdleTime() (time.Duration, error) {
+	return k.idle.IdleTime()
+}
@@ -0,0 +1,37 @@
+package capture
+
+import (
+	"os"
+	"strings"
+)
+
+// newPlatformSource picks a backend for the running desktop. Wayland sessions
+// are checked first because XWayland also sets DISPLAY but only sees X11
+// clients. TIMEWARP_CAPTURE (x11, sway, gnome or kwin) overrides detection.
+func newPlatformSource() (Source, error) {
+	backend := os.Getenv("TIMEWARP_CAPTURE")
+	if backend == "" {
+		desktop := strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP"))
+		switch {
+		case os.Getenv("SWAYSOCK") != "":
+			backend = "sway"
+		case os.Getenv("WAYLAND_DISPLAY") != "" && strings.Contains(desktop, "GNOME"):
+			backend = "gnome"
+		case os.Getenv("WAYLAND_DISPLAY") != "" && strings.Contains(desktop, "KDE"):
+			backend = "kwin"
+		default:
+			backend = "x11"
+		}
+	}
+
+	switch backend {
+	case "sway":
+		return newSwaySource(os.Getenv("SWAYSOCK"), &dbusIdle{})
+	case "gnome":
+		return newGnomeSource(&dbusIdle{})
+	case "kwin":
+		return newKWinSource(&dbusIdle{})
+	default:
+		return newX11Source(os.Getenv("DISPLAY"))
+	}
+}
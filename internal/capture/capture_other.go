@@ -0,0 +1,12 @@
+//go:build !windows && !linux
+
+package capture
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newPlatformSource() (Source, error) {
+	return nil, fmt.Errorf("capture: no window capture backend for %s", runtime.GOOS)
+}
@@ -0,0 +1,257 @@
+//go:build linux
+
+package capture
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// i3/Sway IPC message types.
+const (
+	ipcGetTree     = 4
+	ipcSubscribe   = 2
+	ipcEventWindow = 0x80000003
+)
+
+var ipcMagic = []byte("i3-ipc")
+
+// swayNode is the subset of a Sway tree node / window event container we use.
+type swayNode struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Focused bool   `json:"focused"`
+	PID     uint32 `json:"pid"`
+	AppID   string `json:"app_id"`
+	// WindowProperties are those of an XWayland window
+	WindowProperties struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Nodes         []swayNode `json:"nodes"`
+	FloatingNodes []swayNode `json:"floating_nodes"`
+}
+
+type swayWindowEvent struct {
+	Change    string   `json:"change"`
+	Container swayNode `json:"container"`
+}
+
+// swaySource follows focus through Sway's i3-compatible IPC. It reads the
+// tree once for the initial window and then tracks window events, so
+// ActiveWindow never blocks on the compositor.
+type swaySource struct {
+	logind
+	idle idler
+
+	mu       sync.Mutex
+	win      Window
+	hasWin   bool
+	err      error
+	watchers map[*func(Window, time.Time)]bool
+}
+
+func newSwaySource(socket string, idle idler) (*swaySource, error) {
+	if socket == "" {
+		return nil, fmt.Errorf("capture: SWAYSOCK is not set")
+	}
+
+	s := &swaySource{idle: idle}
+
+	tree, err := swayRequest(socket, ipcGetTree, nil)
+	if err != nil {
+		return nil, err
+	}
+	var root swayNode
+	if err := json.Unmarshal(tree, &root); err != nil {
+		return nil, fmt.Errorf("capture: parse sway tree: %w", err)
+	}
+	if n := findFocused(&root); n != nil {
+		s.setWindow(n)
+	}
+
+	events, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("capture: connect to sway: %w", err)
+	}
+	if err := writeIPC(events, ipcSubscribe, []byte(`["window"]`)); err != nil {
+		events.Close()
+		return nil, err
+	}
+	_, reply, err := readIPC(events)
+	if err != nil {
+		events.Close()
+		return nil, err
+	}
+	var ack struct {
+		Success bool `json:"success"`
+	}
+	if json.Unmarshal(reply, &ack) != nil || !ack.Success {
+		events.Close()
+		return nil, fmt.Errorf("capture: sway subscribe failed: %s", reply)
+	}
+
+	go s.follow(events)
+	return s, nil
+}
+
+func (s *swaySource) follow(conn net.Conn) {
+	defer conn.Close()
+	for {
+		typ, payload, err := readIPC(conn)
+		if err != nil {
+			s.mu.Lock()
+			s.err = fmt.Errorf("capture: sway event stream closed: %w", err)
+			s.mu.Unlock()
+			return
+		}
+		if typ != ipcEventWindow {
+			continue
+		}
+		var ev swayWindowEvent
+		if json.Unmarshal(payload, &ev) != nil {
+			continue
+		}
+		switch ev.Change {
+		case "focus":
+			s.setWindow(&ev.Container)
+		case "title":
+			if ev.Container.Focused {
+				s.setWindow(&ev.Container)
+			}
+		case "close":
+			s.mu.Lock()
+			if s.hasWin && s.win.ID == uint64(ev.Container.ID) {
+				s.hasWin = false
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *swaySource) setWindow(n *swayNode) {
+	w := Window{ID: uint64(n.ID), Title: n.Name, ProcessID: n.PID, Class: n.AppID}
+	if w.Class == "" {
+		w.Class = n.WindowProperties.Class
+	}
+	if n.PID != 0 {
+		w.ProcessName = processName(n.PID)
+		w.ExePath, w.CommandLine = processDetails(n.PID)
+	}
+	if w.ProcessName == "" {
+		w.ProcessName = n.AppID
+	}
+
+	s.mu.Lock()
+	s.win = w
+	s.hasWin = true
+	var fns []func(Window, time.Time)
+	for fn := range s.watchers {
+		fns = append(fns, *fn)
+	}
+	s.mu.Unlock()
+
+	now := time.Now()
+	for _, fn := range fns {
+		fn(w, now)
+	}
+}
+
+// Watch forwards the window events the source already follows.
+func (s *swaySource) Watch(ctx context.Context, fn func(Window, time.Time)) error {
+	s.mu.Lock()
+	if s.watchers == nil {
+		s.watchers = map[*func(Window, time.Time)]bool{}
+	}
+	s.watchers[&fn] = true
+	s.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.mu.Lock()
+	delete(s.watchers, &fn)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *swaySource) ActiveWindow() (Window, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return Window{}, s.err
+	}
+	if !s.hasWin {
+		return Window{}, fmt.Errorf("could not get foreground window")
+	}
+	return s.win, nil
+}
+
+func (s *swaySource) IdleTime() (time.Duration, error) {
+	return s.idle.IdleTime()
+}
+
+// findFocused returns the focused leaf of a Sway tree.
+func findFocused(n *swayNode) *swayNode {
+	if n.Focused {
+		return n
+	}
+	for i := range n.Nodes {
+		if f := findFocused(&n.Nodes[i]); f != nil {
+			return f
+		}
+	}
+	for i := range n.FloatingNodes {
+		if f := findFocused(&n.FloatingNodes[i]); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// swayRequest sends one IPC message on a fresh connection and returns the reply.
+func swayRequest(socket string, typ uint32, payload []byte) ([]byte, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("capture: connect to sway: %w", err)
+	}
+	defer conn.Close()
+	if err := writeIPC(conn, typ, payload); err != nil {
+		return nil, err
+	}
+	_, reply, err := readIPC(conn)
+	return reply, err
+}
+
+func writeIPC(w io.Writer, typ uint32, payload []byte) error {
+	buf := make([]byte, 0, len(ipcMagic)+8+len(payload))
+	buf = append(buf, ipcMagic...)
+	buf = binary.NativeEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = binary.NativeEndian.AppendUint32(buf, typ)
+	buf = append(buf, payload...)
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("capture: sway ipc write: %w", err)
+	}
+	return nil
+}
+
+func readIPC(r io.Reader) (uint32, []byte, error) {
+	hdr := make([]byte, len(ipcMagic)+8)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return 0, nil, err
+	}
+	if string(hdr[:len(ipcMagic)]) != string(ipcMagic) {
+		return 0, nil, fmt.Errorf("capture: bad sway ipc magic")
+	}
+	n := binary.NativeEndian.Uint32(hdr[len(ipcMagic):])
+	typ := binary.NativeEndian.Uint32(hdr[len(ipcMagic)+4:])
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return typ, payload, nil
+}
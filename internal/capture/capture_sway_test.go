@@ -0,0 +1,213 @@
+//go:build linux
+
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSway serves GET_TREE and SUBSCRIBE on a unix socket and lets the test
+// push window events to subscribers.
+type fakeSway struct {
+	t      *testing.T
+	socket string
+	tree   swayNode
+	events chan []byte
+}
+
+func startFakeSway(t *testing.T, tree swayNode) *fakeSway {
+	t.Helper()
+	f := &fakeSway{
+		t:      t,
+		socket: filepath.Join(t.TempDir(), "sway-ipc.sock"),
+		tree:   tree,
+		events: make(chan []byte, 8),
+	}
+	ln, err := net.Listen("unix", f.socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSway) serve(conn net.Conn) {
+	defer conn.Close()
+	typ, _, err := readIPC(conn)
+	if err != nil {
+		return
+	}
+	switch typ {
+	case ipcGetTree:
+		tree, _ := json.Marshal(f.tree)
+		writeIPC(conn, ipcGetTree, tree)
+	case ipcSubscribe:
+		writeIPC(conn, ipcSubscribe, []byte(`{"success": true}`))
+		for ev := range f.events {
+			if writeIPC(conn, ipcEventWindow, ev) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (f *fakeSway) push(change string, n swayNode) {
+	ev, _ := json.Marshal(swayWindowEvent{Change: change, Container: n})
+	f.events <- ev
+}
+
+type fakeIdle time.Duration
+
+func (d fakeIdle) IdleTime() (time.Duration, error) { return time.Duration(d), nil }
+
+// waitFor polls the source until cond holds, so the test doesn't race the
+// event goroutine.
+func waitFor(t *testing.T, s Source, cond func(Window, error) bool) (Window, error) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w, err := s.ActiveWindow()
+		if cond(w, err) {
+			return w, err
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for window state; last: %+v, %v", w, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func testTree() swayNode {
+	return swayNode{ID: 1, Nodes: []swayNode{
+		{ID: 2, Nodes: []swayNode{
+			{ID: 10, Name: "Terminal", AppID: "foot"},
+			{ID: 11, Name: "25-125 Site Plan - LibreCAD", AppID: "librecad", Focused: true},
+		}},
+	}}
+}
+
+func TestSway_InitialWindowFromTree(t *testing.T) {
+	f := startFakeSway(t, testTree())
+	s, err := newSwaySource(f.socket, fakeIdle(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := s.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.ID != 11 || w.Title != "25-125 Site Plan - LibreCAD" {
+		t.Errorf("unexpected initial window: %+v", w)
+	}
+	if w.ProcessName != "librecad" {
+		t.Errorf("expected app_id fallback for process name, got %q", w.ProcessName)
+	}
+}
+
+func TestSway_FocusAndTitleEvents(t *testing.T) {
+	f := startFakeSway(t, testTree())
+	s, err := newSwaySource(f.socket, fakeIdle(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid := uint32(os.Getpid())
+	f.push("focus", swayNode{ID: 20, Name: "Inbox - Thunderbird", PID: pid, Focused: true})
+	w, _ := waitFor(t, s, func(w Window, err error) bool { return w.ID == 20 })
+	if w.ProcessID != pid || w.ProcessName != processName(pid) {
+		t.Errorf("expected process from /proc for pid %d, got %+v", pid, w)
+	}
+
+	// Title changes on unfocused windows are ignored.
+	f.push("title", swayNode{ID: 10, Name: "vim", AppID: "foot"})
+	f.push("title", swayNode{ID: 20, Name: "RE: 25-125 RFI #12 - Thunderbird", PID: pid, Focused: true})
+	w, _ = waitFor(t, s, func(w Window, err error) bool { return w.Title != "Inbox - Thunderbird" })
+	if w.ID != 20 || w.Title != "RE: 25-125 RFI #12 - Thunderbird" {
+		t.Errorf("unexpected window after title change: %+v", w)
+	}
+
+	f.push("close", swayNode{ID: 20})
+	waitFor(t, s, func(w Window, err error) bool { return err != nil })
+}
+
+func TestSway_StreamClosed(t *testing.T) {
+	f := startFakeSway(t, testTree())
+	s, err := newSwaySource(f.socket, fakeIdle(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(f.events)
+	_, err = waitFor(t, s, func(w Window, err error) bool { return err != nil })
+	if err == nil {
+		t.Error("expected error after the event stream closed")
+	}
+}
+
+func TestSway_IdleDelegates(t *testing.T) {
+	f := startFakeSway(t, testTree())
+	s, err := newSwaySource(f.socket, fakeIdle(90*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle, err := s.IdleTime()
+	if err != nil || idle != 90*time.Second {
+		t.Errorf("expected 90s idle, got %v, %v", idle, err)
+	}
+}
+
+func TestSway_NoSocket(t *testing.T) {
+	if _, err := newSwaySource(filepath.Join(t.TempDir(), "missing.sock"), fakeIdle(0)); err == nil {
+		t.Error("expected error for missing socket")
+	}
+}
+
+func TestSway_Watch(t *testing.T) {
+	f := startFakeSway(t, testTree())
+	s, err := newSwaySource(f.socket, fakeIdle(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got := make(chan Window, 4)
+	done := make(chan error)
+	go func() { done <- s.Watch(ctx, func(w Window, _ time.Time) { got <- w }) }()
+
+	// Watch registers asynchronously; keep focusing until an event arrives.
+	deadline := time.After(2 * time.Second)
+	for {
+		f.push("focus", swayNode{ID: 30, Name: "Untitled - gedit", AppID: "gedit", Focused: true})
+		select {
+		case w := <-got:
+			if w.ID != 30 || w.ProcessName != "gedit" {
+				t.Errorf("unexpected watched window: %+v", w)
+			}
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("Watch returned %v", err)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no window event delivered to watcher")
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32                  = syscall.NewLazyDLL("user32.dll")
+	kernel32                = syscall.NewLazyDLL("kernel32.dll")
+	psapi                   = syscall.NewLazyDLL("psapi.dll")
+	procGetForegroundWindow = user32.NewProc("GetForegroundWindow")
+	procGetWindowTextW      = user32.NewProc("GetWindowTextW")
+	procGetLastInputInfo    = user32.NewProc("GetLastInputInfo")
+	procSetWinEventHook     = user32.NewProc("SetWinEventHook")
+	procUnhookWinEvent      = user32.NewProc("UnhookWinEvent")
+	procGetMessageW         = user32.NewProc("GetMessageW")
+	procPostThreadMessageW  = user32.NewProc("PostThreadMessageW")
+	procGetCurrentThreadId  = kernel32.NewProc("GetCurrentThreadId")
+	procGetModuleBaseNameW  = psapi.NewProc("GetModuleBaseNameW")
+	procGetTickCount64      = kernel32.NewProc("GetTickCount64")
+	openProcess             = kernel32.NewProc("OpenProcess")
+	closeHandle             = kernel32.NewProc("CloseHandle")
+)
+
+const (
+	eventSystemForeground  = 0x0003
+	eventObjectNameChange  = 0x800C
+	winEventOutOfContext   = 0x0000
+	winEventSkipOwnProcess = 0x0002
+	objidWindow            = 0
+	wmQuit                 = 0x0012
+)
+
+// winMsg mirrors the Win32 MSG structure.
+type winMsg struct {
+	hwnd     uintptr
+	message  uint32
+	wParam   uintptr
+	lParam   uintptr
+	time     uint32
+	pt       struct{ x, y int32 }
+	lPrivate uint32
+}
+
+type lastInputInfo struct {
+	cbSize uint32
+	dwTime uint32
+}
+
+// windowsSource reads the foreground window and idle time through user32.
+type windowsSource struct{}
+
+func newPlatformSource() (Source, error) {
+	return windowsSource{}, nil
+}
+
+func (windowsSource) ActiveWindow() (Window, error) {
+	return windowFromHWND(getForegroundWindow())
+}
+
+func windowFromHWND(hwnd windows.HWND) (Window, error) {
+	if hwnd == 0 {
+		return Window{}, fmt.Errorf("could not get foreground window")
+	}
+
+	var processID uint32
+	if _, err := windows.GetWindowThreadProcessId(hwnd, &processID); err != nil {
+		return Window{}, fmt.Errorf("could not get process ID: %w", err)
+	}
+
+	w := Window{
+		ID:          uint64(hwnd),
+		Title:       getWindowText(hwnd),
+		ProcessID:   processID,
+		ProcessName: getProcessName(processID),
+		Class:       getClassName(hwnd),
+	}
+	w.ExePath, w.CommandLine = getProcessDetails(processID)
+	return w, nil
+}
+
+func (windowsSource) IdleTime() (time.Duration, error) {
+	var info lastInputInfo
+	info.cbSize = uint32(unsafe.Sizeof(info))
+
+	ret, _, _ := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info)))
+	if ret == 0 {
+		return 0, fmt.Errorf("GetLastInputInfo failed")
+	}
+
+	tick, _, _ := procGetTickCount64.Call()
+	elapsed := uint64(tick) - uint64(info.dwTime)
+	return time.Duration(elapsed) * time.Millisecond, nil
+}
+
+// The WinEvent callback is created once for the process (callbacks are a
+// limited resource) and forwards to whichever Watch is running.
+var (
+	watchMu          sync.Mutex
+	watchFn          func(Window, time.Time)
+	winEventCallback = syscall.NewCallback(winEventProc)
+)
+
+func winEventProc(hook, event, hwnd, idObject, idChild, eventThread, eventTime uintptr) uintptr {
+	if int32(idObject) != objidWindow {
+		return 0
+	}
+	// Title changes fire for every window; only the foreground one matters.
+	if event == eventObjectNameChange && windows.HWND(hwnd) != getForegroundWindow() {
+		return 0
+	}
+	w, err := windowFromHWND(windows.HWND(hwnd))
+	if err != nil {
+		return 0
+	}
+	watchMu.Lock()
+	fn := watchFn
+	watchMu.Unlock()
+	if fn != nil {
+		fn(w, time.Now())
+	}
+	return 0
+}
+
+// Watch installs out-of-context WinEvent hooks for foreground and title
+// changes and pumps messages on a locked OS thread until ctx is done.
+func (windowsSource) Watch(ctx context.Context, fn func(Window, time.Time)) error {
+	watchMu.Lock()
+	if watchFn != nil {
+		watchMu.Unlock()
+		return fmt.Errorf("capture: already watching")
+	}
+	watchFn = fn
+	watchMu.Unlock()
+	defer func() {
+		watchMu.Lock()
+		watchFn = nil
+		watchMu.Unlock()
+	}()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	flags := uintptr(winEventOutOfContext | winEventSkipOwnProcess)
+	var hooks []uintptr
+	for _, ev := range []uintptr{eventSystemForeground, eventObjectNameChange} {
+		h, _, err := procSetWinEventHook.Call(ev, ev, 0, winEventCallback, 0, 0, flags)
+		if h == 0 {
+			for _, prev := range hooks {
+				procUnhookWinEvent.Call(prev)
+			}
+			return fmt.Errorf("capture: SetWinEventHook: %w", err)
+		}
+		hooks = append(hooks, h)
+	}
+	defer func() {
+		for _, h := range hooks {
+			procUnhookWinEvent.Call(h)
+		}
+	}()
+
+	tid, _, _ := procGetCurrentThreadId.Call()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			procPostThreadMessageW.Call(tid, wmQuit, 0, 0)
+		case <-done:
+		}
+	}()
+
+	// Hook callbacks are dispatched from inside GetMessage.
+	var msg winMsg
+	for {
+		ret, _, err := procGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+		switch int32(ret) {
+		case 0:
+			return nil
+		case -1:
+			return fmt.Errorf("capture: GetMessage: %w", err)
+		}
+	}
+}
+
+func getForegroundWindow() windows.HWND {
+	ret, _, _ := procGetForegroundWindow.Call()
+	return windows.HWND(ret)
+}
+
+func getWindowText(hwnd windows.HWND) string {
+	const maxChars = 256
+	text := make([]uint16, maxChars)
+	ret, _, _ := procGetWindowTextW.Call(
+		uintptr(hwnd),
+		uintptr(unsafe.Pointer(&text[0])),
+		uintptr(maxChars),
+	)
+	if ret == 0 {
+		return "" // Empty string if no title
+	}
+	return windows.UTF16ToString(text)
+}
+
+func getClassName(hwnd windows.HWND) string {
+	const maxChars = 256
+	class := make([]uint16, maxChars)
+	n, err := windows.GetClassName(hwnd, &class[0], maxChars)
+	if err != nil || n == 0 {
+		return ""
+	}
+	return windows.UTF16ToString(class[:n])
+}
+
+// getProcessDetails returns the full executable path and the command line
+// of a process, or "" for those it can't read. Limited access is enough
+// for both, so they can be read for elevated processes too.
+func getProcessDetails(processID uint32) (exe, commandLine string) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, processID)
+	if err != nil {
+		return "", ""
+	}
+	defer windows.CloseHandle(h)
+
+	path := make([]uint16, 1024)
+	size := uint32(len(path))
+	if windows.QueryFullProcessImageName(h, 0, &path[0], &size) == nil {
+		exe = windows.UTF16ToString(path[:size])
+	}
+
+	// ProcessCommandLineInformation (Windows 8.1 and later) answers with a
+	// UNICODE_STRING whose text follows it in the buffer
+	var n uint32
+	windows.NtQueryInformationProcess(h, windows.ProcessCommandLineInformation, nil, 0, &n)
+	if n == 0 {
+		return exe, ""
+	}
+	buf := make([]byte, n)
+	if windows.NtQueryInformationProcess(h, windows.ProcessCommandLineInformation, unsafe.Pointer(&buf[0]), n, &n) != nil {
+		return exe, ""
+	}
+	return exe, (*windows.NTUnicodeString)(unsafe.Pointer(&buf[0])).String()
+}
+
+func getProcessName(processID uint32) string {
+	// PROCESS_QUERY_INFORMATION | PROCESS_VM_READ
+	desiredAccess := uint32(0x0400 | 0x0010)
+	handle, _, _ := openProcess.Call(uintptr(desiredAccess), 0, uintptr(processID))
+	if handle == 0 {
+		return ""
+	}
+	defer closeHandle.Call(handle)
+
+	const maxPath = 260
+	var processName [maxPath]uint16
+	ret, _, _ := procGetModuleBaseNameW.Call(
+		handle,
+		0,
+		uintptr(unsafe.Pointer(&processName[0])),
+		uintptr(maxPath),
+	)
+	if ret == 0 {
+		return ""
+	}
+	return windows.UTF16ToString(processName[:])
+}
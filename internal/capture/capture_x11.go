@@ -0,0 +1,231 @@
+//go:build linux
+
+package capture
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/screensaver"
+	"github.com/jezek/xgb/xproto"
+
+	"github.com/vinistoisr/timewarp/internal/appid"
+)
+
+// x11Source reads the EWMH active window from the root window and idle time
+// from the MIT-SCREEN-SAVER extension.
+type x11Source struct {
+	logind
+	mu      sync.Mutex
+	display string
+	conn    *xgb.Conn
+	root    xproto.Window
+
+	atomActiveWindow xproto.Atom
+	atomWMPid        xproto.Atom
+	atomWMName       xproto.Atom
+	atomUTF8         xproto.Atom
+}
+
+func newX11Source(display string) (*x11Source, error) {
+	if display == "" {
+		return nil, fmt.Errorf("capture: DISPLAY is not set")
+	}
+	conn, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		return nil, fmt.Errorf("capture: connect to X display %s: %w", display, err)
+	}
+	if err := screensaver.Init(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("capture: MIT-SCREEN-SAVER extension: %w", err)
+	}
+
+	s := &x11Source{
+		display: display,
+		conn:    conn,
+		root:    xproto.Setup(conn).DefaultScreen(conn).Root,
+	}
+	for name, dst := range map[string]*xproto.Atom{
+		"_NET_ACTIVE_WINDOW": &s.atomActiveWindow,
+		"_NET_WM_PID":        &s.atomWMPid,
+		"_NET_WM_NAME":       &s.atomWMName,
+		"UTF8_STRING":        &s.atomUTF8,
+	} {
+		reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("capture: intern atom %s: %w", name, err)
+		}
+		*dst = reply.Atom
+	}
+	return s, nil
+}
+
+func (s *x11Source) ActiveWindow() (Window, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	win, err := s.activeWindow()
+	if err != nil {
+		return Window{}, err
+	}
+	return s.window(win), nil
+}
+
+// Watch listens for PropertyNotify on the root window (_NET_ACTIVE_WINDOW
+// changes) and on the active window (title changes). It uses its own
+// connection so polling isn't blocked behind the event loop.
+func (s *x11Source) Watch(ctx context.Context, fn func(Window, time.Time)) error {
+	w, err := newX11Source(s.display)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		w.conn.Close()
+	}()
+
+	mask := []uint32{xproto.EventMaskPropertyChange}
+	if err := xproto.ChangeWindowAttributesChecked(w.conn, w.root, xproto.CwEventMask, mask).Check(); err != nil {
+		return fmt.Errorf("capture: select root property events: %w", err)
+	}
+
+	var active xproto.Window
+	follow := func() bool {
+		win, err := w.activeWindow()
+		if err != nil {
+			active = 0
+			return false
+		}
+		if win != active {
+			active = win
+			xproto.ChangeWindowAttributes(w.conn, win, xproto.CwEventMask, mask)
+		}
+		return true
+	}
+	follow()
+
+	for {
+		ev, xerr := w.conn.WaitForEvent()
+		if ev == nil && xerr == nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("capture: X connection closed")
+		}
+		// Errors are usually BadWindow for a window that closed between
+		// the event and our property read.
+		pn, ok := ev.(xproto.PropertyNotifyEvent)
+		if xerr != nil || !ok {
+			continue
+		}
+		switch {
+		case pn.Window == w.root && pn.Atom == w.atomActiveWindow:
+		case pn.Window == active && (pn.Atom == w.atomWMName || pn.Atom == xproto.AtomWmName):
+		default:
+			continue
+		}
+		if follow() {
+			fn(w.window(active), time.Now())
+		}
+	}
+}
+
+func (s *x11Source) window(win xproto.Window) Window {
+	w := Window{ID: uint64(win), Title: s.windowTitle(win), Class: s.windowClass(win)}
+	if pid, ok := s.windowPID(win); ok {
+		w.ProcessID = pid
+		w.ProcessName = processName(pid)
+		w.ExePath, w.CommandLine = processDetails(pid)
+	}
+	return w
+}
+
+func (s *x11Source) IdleTime() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := screensaver.QueryInfo(s.conn, xproto.Drawable(s.root)).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("capture: screensaver query: %w", err)
+	}
+	return time.Duration(info.MsSinceUserInput) * time.Millisecond, nil
+}
+
+func (s *x11Source) activeWindow() (xproto.Window, error) {
+	prop, err := xproto.GetProperty(s.conn, false, s.root, s.atomActiveWindow, xproto.AtomWindow, 0, 1).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("capture: read _NET_ACTIVE_WINDOW: %w", err)
+	}
+	if prop.Format != 32 || len(prop.Value) < 4 {
+		return 0, fmt.Errorf("capture: window manager does not publish _NET_ACTIVE_WINDOW")
+	}
+	win := xproto.Window(xgb.Get32(prop.Value))
+	if win == 0 {
+		return 0, fmt.Errorf("could not get foreground window")
+	}
+	return win, nil
+}
+
+func (s *x11Source) windowTitle(win xproto.Window) string {
+	prop, err := xproto.GetProperty(s.conn, false, win, s.atomWMName, s.atomUTF8, 0, 1024).Reply()
+	if err == nil && len(prop.Value) > 0 {
+		return string(prop.Value)
+	}
+	prop, err = xproto.GetProperty(s.conn, false, win, xproto.AtomWmName, xproto.GetPropertyTypeAny, 0, 1024).Reply()
+	if err == nil {
+		return string(prop.Value)
+	}
+	return ""
+}
+
+// windowClass returns the class half of WM_CLASS, which names the
+// application rather than the window.
+func (s *x11Source) windowClass(win xproto.Window) string {
+	prop, err := xproto.GetProperty(s.conn, false, win, xproto.AtomWmClass, xproto.AtomString, 0, 256).Reply()
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.TrimRight(string(prop.Value), "\x00"), "\x00")
+	return parts[len(parts)-1]
+}
+
+func (s *x11Source) windowPID(win xproto.Window) (uint32, bool) {
+	prop, err := xproto.GetProperty(s.conn, false, win, s.atomWMPid, xproto.AtomCardinal, 0, 1).Reply()
+	if err != nil || prop.Format != 32 || len(prop.Value) < 4 {
+		return 0, false
+	}
+	return xgb.Get32(prop.Value), true
+}
+
+// processName returns the command name of pid from /proc, or "" if the
+// process has already exited.
+func processName(pid uint32) string {
+	b, err := os.ReadFile("/proc/" + strconv.FormatUint(uint64(pid), 10) + "/comm")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// processDetails returns the executable and command line of pid from
+// /proc, or "" for those it can't read.
+func processDetails(pid uint32) (exe, commandLine string) {
+	dir := "/proc/" + strconv.FormatUint(uint64(pid), 10)
+	exe, _ = os.Readlink(dir + "/exe")
+	if b, err := os.ReadFile(dir + "/cmdline"); err == nil {
+		commandLine = appid.JoinCommandLine(strings.Split(strings.TrimRight(string(b), "\x00"), "\x00"))
+	}
+	return exe, commandLine
+}
@@ -0,0 +1,146 @@
+//go:build linux
+
+package capture
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// These tests need an X server; CI runs them under Xvfb. A bare Xvfb has no
+// window manager, so the test publishes _NET_ACTIVE_WINDOW itself.
+
+func x11TestSource(t *testing.T) *x11Source {
+	t.Helper()
+	display := os.Getenv("DISPLAY")
+	if display == "" {
+		t.Skip("DISPLAY not set; run under xvfb-run")
+	}
+	s, err := newX11Source(display)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.conn.Close)
+	return s
+}
+
+func createTestWindow(t *testing.T, s *x11Source, title string, pid uint32) xproto.Window {
+	t.Helper()
+	screen := xproto.Setup(s.conn).DefaultScreen(s.conn)
+	win, err := xproto.NewWindowId(s.conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = xproto.CreateWindowChecked(s.conn, screen.RootDepth, win, s.root,
+		0, 0, 100, 100, 0, xproto.WindowClassInputOutput, screen.RootVisual, 0, nil).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	xproto.ChangeProperty(s.conn, xproto.PropModeReplace, win, s.atomWMName, s.atomUTF8, 8, uint32(len(title)), []byte(title))
+
+	pidBuf := make([]byte, 4)
+	xgb.Put32(pidBuf, pid)
+	xproto.ChangeProperty(s.conn, xproto.PropModeReplace, win, s.atomWMPid, xproto.AtomCardinal, 32, 1, pidBuf)
+	return win
+}
+
+func setActiveWindow(t *testing.T, s *x11Source, win xproto.Window) {
+	t.Helper()
+	buf := make([]byte, 4)
+	xgb.Put32(buf, uint32(win))
+	err := xproto.ChangePropertyChecked(s.conn, xproto.PropModeReplace, s.root, s.atomActiveWindow, xproto.AtomWindow, 32, 1, buf).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestX11_ActiveWindow(t *testing.T) {
+	s := x11TestSource(t)
+
+	win := createTestWindow(t, s, "25-125_SLD-E101.dwg - AutoCAD", uint32(os.Getpid()))
+	setActiveWindow(t, s, win)
+
+	w, err := s.ActiveWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.ID != uint64(win) {
+		t.Errorf("expected window %d, got %d", win, w.ID)
+	}
+	if w.Title != "25-125_SLD-E101.dwg - AutoCAD" {
+		t.Errorf("unexpected title: %q", w.Title)
+	}
+	if w.ProcessID != uint32(os.Getpid()) {
+		t.Errorf("expected pid %d, got %d", os.Getpid(), w.ProcessID)
+	}
+	if want := processName(uint32(os.Getpid())); w.ProcessName != want || want == "" {
+		t.Errorf("expected process name %q, got %q", want, w.ProcessName)
+	}
+}
+
+func TestX11_NoActiveWindow(t *testing.T) {
+	s := x11TestSource(t)
+	setActiveWindow(t, s, 0)
+
+	if _, err := s.ActiveWindow(); err == nil {
+		t.Error("expected error when no window is active")
+	}
+}
+
+func TestX11_IdleTime(t *testing.T) {
+	s := x11TestSource(t)
+	if _, err := s.IdleTime(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestX11_Watch(t *testing.T) {
+	s := x11TestSource(t)
+	a := createTestWindow(t, s, "Untitled - gedit", uint32(os.Getpid()))
+	b := createTestWindow(t, s, "25-019_E201.dwg - AutoCAD", uint32(os.Getpid()))
+	setActiveWindow(t, s, a)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan Window, 8)
+	go s.Watch(ctx, func(w Window, _ time.Time) { got <- w })
+
+	next := func() Window {
+		t.Helper()
+		select {
+		case w := <-got:
+			return w
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event from watcher")
+			return Window{}
+		}
+	}
+
+	// The watcher selects events asynchronously; switch until it notices.
+	var w Window
+	deadline := time.Now().Add(2 * time.Second)
+	for w.ID != uint64(b) {
+		if time.Now().After(deadline) {
+			t.Fatal("no focus change event from watcher")
+		}
+		setActiveWindow(t, s, b)
+		select {
+		case w = <-got:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if w.Title != "25-019_E201.dwg - AutoCAD" {
+		t.Errorf("unexpected title on focus change: %q", w.Title)
+	}
+
+	title := "25-125_SLD-E101.dwg - AutoCAD"
+	xproto.ChangeProperty(s.conn, xproto.PropModeReplace, b, s.atomWMName, s.atomUTF8, 8, uint32(len(title)), []byte(title))
+	if w := next(); w.ID != uint64(b) || w.Title != title {
+		t.Errorf("expected title change event, got %+v", w)
+	}
+}
@@ -0,0 +1,146 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/clock"
+)
+
+// ReplayEvent is one entry in a replay timeline. The state it describes holds
+// from At until the next event.
+type ReplayEvent struct {
+	At time.Time `json:"at"`
+	// Window fields. When Process and Title are both empty the previous
+	// window stays in front, so an idle event doesn't need to repeat it.
+	Process string `json:"process,omitempty"`
+	Title   string `json:"title,omitempty"`
+	PID     uint32 `json:"pid,omitempty"`
+	Window  uint64 `json:"window,omitempty"`
+	ExePath string `json:"exe_path,omitempty"`
+	Class   string `json:"class,omitempty"`
+	// CommandLine is the process's command line, as one string
+	CommandLine string `json:"command_line,omitempty"`
+	// Idle means no user input since At.
+	Idle bool `json:"idle,omitempty"`
+	// Off means the machine is off or asleep: there is no foreground window
+	// and the tracker isn't running.
+	Off bool `json:"off,omitempty"`
+	// System is a system event the platform reports at At. Suspend and
+	// shutdown also stop the tracker, as Off does, until the next event.
+	System SystemEvent `json:"system,omitempty"`
+}
+
+// Replay is a scripted Source that plays back a timeline against a clock.
+type Replay struct {
+	clk    clock.Clock
+	events []ReplayEvent
+}
+
+// NewReplay returns a Replay for events, which are sorted by time.
+func NewReplay(events []ReplayEvent, clk clock.Clock) (*Replay, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("capture: replay timeline is empty")
+	}
+	events = append([]ReplayEvent(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+
+	// Carry the window forward into events that don't name one.
+	for i := range events {
+		e := &events[i]
+		if e.Process == "" && e.Title == "" && !e.off() && i > 0 {
+			prev := events[i-1]
+			e.Process, e.Title, e.PID, e.Window = prev.Process, prev.Title, prev.PID, prev.Window
+			e.ExePath, e.Class, e.CommandLine = prev.ExePath, prev.Class, prev.CommandLine
+		}
+		if e.Window == 0 && (e.Process != "" || e.Title != "") {
+			h := fnv.New64a()
+			h.Write([]byte(e.Process + "\x00" + e.Title))
+			e.Window = h.Sum64()
+		}
+	}
+	return &Replay{clk: clk, events: events}, nil
+}
+
+// LoadReplay reads a timeline from a JSON file of the form {"events": [...]}.
+func LoadReplay(path string, clk clock.Clock) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("capture: read replay: %w", err)
+	}
+	var f struct {
+		Events []ReplayEvent `json:"events"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("capture: parse replay %s: %w", path, err)
+	}
+	return NewReplay(f.Events, clk)
+}
+
+// Start returns the time of the first event.
+func (r *Replay) Start() time.Time {
+	return r.events[0].At
+}
+
+// End returns the time of the last event.
+func (r *Replay) End() time.Time {
+	return r.events[len(r.events)-1].At
+}
+
+// Off reports whether the machine is off at t.
+func (r *Replay) Off(t time.Time) bool {
+	e, ok := r.at(t)
+	return !ok || e.off()
+}
+
+// SystemEvents returns the events in the timeline that carry a system
+// event, in order.
+func (r *Replay) SystemEvents() []ReplayEvent {
+	var events []ReplayEvent
+	for _, e := range r.events {
+		if e.System != "" {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func (e ReplayEvent) off() bool {
+	return e.Off || e.System == Suspend || e.System == Shutdown
+}
+
+// at returns the event in effect at t.
+func (r *Replay) at(t time.Time) (ReplayEvent, bool) {
+	i := sort.Search(len(r.events), func(i int) bool { return r.events[i].At.After(t) })
+	if i == 0 {
+		return ReplayEvent{}, false
+	}
+	return r.events[i-1], true
+}
+
+func (r *Replay) ActiveWindow() (Window, error) {
+	e, ok := r.at(r.clk.Now())
+	if !ok || e.off() || (e.Process == "" && e.Title == "") {
+		return Window{}, fmt.Errorf("could not get foreground window")
+	}
+	return Window{ID: e.Window, Title: e.Title, ProcessID: e.PID, ProcessName: e.Process,
+		ExePath: e.ExePath, Class: e.Class, CommandLine: e.CommandLine}, nil
+}
+
+func (r *Replay) IdleTime() (time.Duration, error) {
+	now := r.clk.Now()
+	i := sort.Search(len(r.events), func(i int) bool { return r.events[i].At.After(now) })
+	if i == 0 || !r.events[i-1].Idle {
+		return 0, nil
+	}
+	// Consecutive idle events extend the same idle period.
+	start := i - 1
+	for start > 0 && r.events[start-1].Idle {
+		start--
+	}
+	return now.Sub(r.events[start].At), nil
+}
@@ -0,0 +1,112 @@
+//go:build linux
+
+package capture
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// logind reports system events from systemd-logind, which every Linux
+// desktop runs on, so each Linux Source embeds it. Suspend, resume and
+// shutdown come from the manager's PrepareForSleep and PrepareForShutdown
+// signals; lock and unlock from the LockedHint of this session, which the
+// screen locker sets.
+type logind struct{}
+
+const (
+	logindManager = "org.freedesktop.login1.Manager"
+	logindSession = "org.freedesktop.login1.Session"
+)
+
+func (logind) WatchSystem(ctx context.Context, fn func(SystemEvent, time.Time)) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("capture: system bus: %w", err)
+	}
+	defer conn.Close()
+
+	matches := [][]dbus.MatchOption{
+		{dbus.WithMatchInterface(logindManager), dbus.WithMatchMember("PrepareForSleep")},
+		{dbus.WithMatchInterface(logindManager), dbus.WithMatchMember("PrepareForShutdown")},
+	}
+	// Signals come from the session's real path, not session/auto.
+	if path, err := logindSessionPath(conn); err == nil {
+		matches = append(matches, []dbus.MatchOption{
+			dbus.WithMatchObjectPath(path),
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+		})
+	}
+	for _, m := range matches {
+		if err := conn.AddMatchSignal(m...); err != nil {
+			return fmt.Errorf("capture: logind signals: %w", err)
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig, ok := <-signals:
+			if !ok {
+				return fmt.Errorf("capture: logind connection closed")
+			}
+			if ev, ok := logindEvent(sig); ok {
+				fn(ev, time.Now())
+			}
+		}
+	}
+}
+
+// logindSessionPath returns the object path of the session timewarp runs in.
+func logindSessionPath(conn *dbus.Conn) (dbus.ObjectPath, error) {
+	id, err := conn.Object("org.freedesktop.login1", "/org/freedesktop/login1/session/auto").GetProperty(logindSession + ".Id")
+	if err != nil {
+		return "", err
+	}
+	var path dbus.ObjectPath
+	err = conn.Object("org.freedesktop.login1", "/org/freedesktop/login1").
+		Call(logindManager+".GetSession", 0, id.Value()).Store(&path)
+	return path, err
+}
+
+// logindEvent translates a logind signal into a system event.
+func logindEvent(sig *dbus.Signal) (SystemEvent, bool) {
+	switch sig.Name {
+	case logindManager + ".PrepareForSleep":
+		if len(sig.Body) == 0 {
+			return "", false
+		}
+		if start, _ := sig.Body[0].(bool); start {
+			return Suspend, true
+		}
+		return Resume, true
+	case logindManager + ".PrepareForShutdown":
+		if len(sig.Body) == 0 {
+			return "", false
+		}
+		if start, _ := sig.Body[0].(bool); start {
+			return Shutdown, true
+		}
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		if len(sig.Body) < 2 {
+			return "", false
+		}
+		changed, _ := sig.Body[1].(map[string]dbus.Variant)
+		v, ok := changed["LockedHint"]
+		if !ok {
+			return "", false
+		}
+		if locked, _ := v.Value().(bool); locked {
+			return Lock, true
+		}
+		return Unlock, true
+	}
+	return "", false
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package capture
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestLogindEvent(t *testing.T) {
+	locked := func(v bool) []interface{} {
+		return []interface{}{logindSession, map[string]dbus.Variant{"LockedHint": dbus.MakeVariant(v)}, []string{}}
+	}
+	cases := []struct {
+		name string
+		body []interface{}
+		want SystemEvent
+		ok   bool
+	}{
+		{logindManager + ".PrepareForSleep", []interface{}{true}, Suspend, true},
+		{logindManager + ".PrepareForSleep", []interface{}{false}, Resume, true},
+		{logindManager + ".PrepareForShutdown", []interface{}{true}, Shutdown, true},
+		// A cancelled shutdown is not an event
+		{logindManager + ".PrepareForShutdown", []interface{}{false}, "", false},
+		{"org.freedesktop.DBus.Properties.PropertiesChanged", locked(true), Lock, true},
+		{"org.freedesktop.DBus.Properties.PropertiesChanged", locked(false), Unlock, true},
+		{"org.freedesktop.DBus.Properties.PropertiesChanged",
+			[]interface{}{logindSession, map[string]dbus.Variant{"IdleHint": dbus.MakeVariant(true)}, []string{}}, "", false},
+		{logindManager + ".PrepareForSleep", nil, "", false},
+	}
+	for _, c := range cases {
+		got, ok := logindEvent(&dbus.Signal{Name: c.name, Body: c.body})
+		if got != c.want || ok != c.ok {
+			t.Errorf("%s %v: got %q, %v; want %q, %v", c.name, c.body, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	wtsapi32                             = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSRegisterSessionNotification   = wtsapi32.NewProc("WTSRegisterSessionNotification")
+	procWTSUnRegisterSessionNotification = wtsapi32.NewProc("WTSUnRegisterSessionNotification")
+	procRegisterClassExW                 = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW                  = user32.NewProc("CreateWindowExW")
+	procDestroyWindow                    = user32.NewProc("DestroyWindow")
+	procDefWindowProcW                   = user32.NewProc("DefWindowProcW")
+	procDispatchMessageW                 = user32.NewProc("DispatchMessageW")
+)
+
+const (
+	wmEndSession          = 0x0016
+	wmPowerBroadcast      = 0x0218
+	wmWTSSessionChange    = 0x02B1
+	pbtAPMSuspend         = 0x0004
+	pbtAPMResumeAutomatic = 0x0012
+	wtsSessionLock        = 0x7
+	wtsSessionUnlock      = 0x8
+	notifyForThisSession  = 0
+)
+
+// wndClassEx mirrors the Win32 WNDCLASSEXW structure.
+type wndClassEx struct {
+	cbSize        uint32
+	style         uint32
+	lpfnWndProc   uintptr
+	cbClsExtra    int32
+	cbWndExtra    int32
+	hInstance     uintptr
+	hIcon         uintptr
+	hCursor       uintptr
+	hbrBackground uintptr
+	lpszMenuName  *uint16
+	lpszClassName *uint16
+	hIconSm       uintptr
+}
+
+// Like the WinEvent callback, the window procedure is created once and
+// forwards to whichever WatchSystem is running.
+var (
+	systemMu       sync.Mutex
+	systemFn       func(SystemEvent, time.Time)
+	systemCallback = syscall.NewCallback(systemWndProc)
+)
+
+func systemWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	var ev SystemEvent
+	switch msg {
+	case wmPowerBroadcast:
+		// PBT_APMRESUMESUSPEND also follows a resume, but only once the user
+		// is back; the automatic one always arrives.
+		switch wParam {
+		case pbtAPMSuspend:
+			ev = Suspend
+		case pbtAPMResumeAutomatic:
+			ev = Resume
+		}
+	case wmWTSSessionChange:
+		switch wParam {
+		case wtsSessionLock:
+			ev = Lock
+		case wtsSessionUnlock:
+			ev = Unlock
+		}
+	case wmEndSession:
+		if wParam != 0 {
+			ev = Shutdown
+		}
+	}
+	if ev != "" {
+		systemMu.Lock()
+		fn := systemFn
+		systemMu.Unlock()
+		if fn != nil {
+			fn(ev, time.Now())
+		}
+	}
+	ret, _, _ := procDefWindowProcW.Call(hwnd, msg, wParam, lParam)
+	return ret
+}
+
+// WatchSystem creates a hidden window to receive power broadcasts, session
+// change notifications and WM_ENDSESSION, and pumps its messages on a locked
+// OS thread until ctx is done. It has to be a top-level window, as
+// message-only windows don't get broadcasts.
+func (windowsSource) WatchSystem(ctx context.Context, fn func(SystemEvent, time.Time)) error {
+	systemMu.Lock()
+	if systemFn != nil {
+		systemMu.Unlock()
+		return fmt.Errorf("capture: already watching system events")
+	}
+	systemFn = fn
+	systemMu.Unlock()
+	defer func() {
+		systemMu.Lock()
+		systemFn = nil
+		systemMu.Unlock()
+	}()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	className, _ := windows.UTF16PtrFromString("TimewarpSystemEvents")
+	wc := wndClassEx{lpfnWndProc: systemCallback, lpszClassName: className}
+	wc.cbSize = uint32(unsafe.Sizeof(wc))
+	// The class outlives an earlier WatchSystem, so it may already exist.
+	if ret, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 && err != windows.ERROR_CLASS_ALREADY_EXISTS {
+		return fmt.Errorf("capture: RegisterClassEx: %w", err)
+	}
+
+	hwnd, _, err := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(className)), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	if hwnd == 0 {
+		return fmt.Errorf("capture: CreateWindowEx: %w", err)
+	}
+	defer procDestroyWindow.Call(hwnd)
+
+	if ret, _, err := procWTSRegisterSessionNotification.Call(hwnd, notifyForThisSession); ret == 0 {
+		return fmt.Errorf("capture: WTSRegisterSessionNotification: %w", err)
+	}
+	defer procWTSUnRegisterSessionNotification.Call(hwnd)
+
+	tid, _, _ := procGetCurrentThreadId.Call()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			procPostThreadMessageW.Call(tid, wmQuit, 0, 0)
+		case <-done:
+		}
+	}()
+
+	var msg winMsg
+	for {
+		ret, _, err := procGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+		switch int32(ret) {
+		case 0:
+			return nil
+		case -1:
+			return fmt.Errorf("capture: GetMessage: %w", err)
+		}
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}
@@ -0,0 +1,97 @@
+// Package clock abstracts the wall clock so the tracking pipeline can run on
+// simulated time in tests and replays.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates tickers.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) *Ticker
+}
+
+// Ticker delivers ticks on C, like time.Ticker.
+type Ticker struct {
+	C    <-chan time.Time
+	stop func()
+}
+
+// Stop turns off the ticker. No more ticks are sent after it returns.
+func (t *Ticker) Stop() {
+	t.stop()
+}
+
+// Real is the system clock.
+type Real struct{}
+
+func (Real) Now() time.Time { return time.Now() }
+
+func (Real) NewTicker(d time.Duration) *Ticker {
+	t := time.NewTicker(d)
+	return &Ticker{C: t.C, stop: t.Stop}
+}
+
+// Sim is a manually driven clock. Time only moves when Set or Advance is
+// called; tickers fire at most once per move, dropping ticks the same way
+// time.Ticker does for a slow receiver.
+type Sim struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers map[*simTicker]bool
+}
+
+type simTicker struct {
+	c    chan time.Time
+	d    time.Duration
+	next time.Time
+}
+
+// NewSim returns a simulated clock set to start.
+func NewSim(start time.Time) *Sim {
+	return &Sim{now: start, tickers: map[*simTicker]bool{}}
+}
+
+func (s *Sim) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+func (s *Sim) NewTicker(d time.Duration) *Ticker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := &simTicker{c: make(chan time.Time, 1), d: d, next: s.now.Add(d)}
+	s.tickers[st] = true
+	return &Ticker{C: st.c, stop: func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.tickers, st)
+	}}
+}
+
+// Set moves the clock to t and fires any tickers that came due.
+func (s *Sim) Set(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = t
+	for st := range s.tickers {
+		if t.Before(st.next) {
+			continue
+		}
+		select {
+		case st.c <- t:
+		default:
+		}
+		for !t.Before(st.next) {
+			st.next = st.next.Add(st.d)
+		}
+	}
+}
+
+// Advance moves the clock forward by d.
+func (s *Sim) Advance(d time.Duration) {
+	s.Set(s.Now().Add(d))
+}
@@ -0,0 +1,78 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSim_NowOnlyMovesWhenSet(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	c := NewSim(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("expected %v, got %v", start, c.Now())
+	}
+	c.Advance(90 * time.Second)
+	if want := start.Add(90 * time.Second); !c.Now().Equal(want) {
+		t.Errorf("expected %v, got %v", want, c.Now())
+	}
+}
+
+func TestSim_TickerFiresWhenDue(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	c := NewSim(start)
+	tk := c.NewTicker(time.Second)
+	defer tk.Stop()
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-tk.C:
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case got := <-tk.C:
+		if want := start.Add(time.Second); !got.Equal(want) {
+			t.Errorf("expected tick at %v, got %v", want, got)
+		}
+	default:
+		t.Fatal("ticker did not fire")
+	}
+}
+
+func TestSim_JumpDropsTicks(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	c := NewSim(start)
+	tk := c.NewTicker(time.Second)
+	defer tk.Stop()
+
+	// A long jump (e.g. overnight) delivers a single tick, not one per second.
+	c.Advance(10 * time.Hour)
+	<-tk.C
+	select {
+	case <-tk.C:
+		t.Fatal("expected dropped ticks after a jump")
+	default:
+	}
+
+	// The schedule continues from the new time.
+	c.Advance(time.Second)
+	select {
+	case <-tk.C:
+	default:
+		t.Fatal("ticker did not resume after jump")
+	}
+}
+
+func TestSim_StoppedTickerIsSilent(t *testing.T) {
+	c := NewSim(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	tk := c.NewTicker(time.Second)
+	tk.Stop()
+	c.Advance(5 * time.Second)
+	select {
+	case <-tk.C:
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
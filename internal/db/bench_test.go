@@ -0,0 +1,194 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The benchmarks query a year of synthetic sessions from several machines,
+// to track how the queries scale with a folder that has been in use for a
+// while. Run them with
+//
+//	go test ./internal/db -run '^$' -bench . -benchtime 20x
+//
+// and compare runs with benchstat.
+
+var (
+	benchHosts = []string{"DESKTOP-A", "LAPTOP-B", "WORKSTATION-C"}
+	benchStart = time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+)
+
+// seedYear writes a year of workdays to a file per host in dir, as the
+// tracker would: about 40 sessions a day across a handful of apps and
+// projects, with meetings and inactivity.
+func seedYear(b testing.TB, dir string) {
+	b.Helper()
+	apps := []struct{ process, app, title string }{
+		{"acad.exe", "", "%s_SLD-E101.dwg - AutoCAD"},
+		{"OUTLOOK.EXE", "", "RE: %s RFI #12"},
+		{"chrome.exe", "", "%s - Google Drive"},
+		{"code.exe", "", "main.go - %s"},
+		{"ms-teams.exe", "Teams", "Chat | %s | Microsoft Teams"},
+		{"explorer.exe", "", "%s"},
+	}
+	projects := []string{"25-019", "25-125", "25-200", "26-004", "26-031", ""}
+
+	for i, host := range benchHosts {
+		d, err := openMigrated(filepath.Join(dir, "timewarp-"+host+".db"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		tx, err := d.Begin()
+		if err != nil {
+			b.Fatal(err)
+		}
+		rng := rand.New(rand.NewSource(int64(i)))
+		for day := benchStart; day.Before(benchStart.AddDate(1, 0, 0)); day = day.AddDate(0, 0, 1) {
+			if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+				continue
+			}
+			at := day.Add(13 * time.Hour)
+			for n := 0; n < 40; n++ {
+				a, project := apps[rng.Intn(len(apps))], projects[rng.Intn(len(projects))]
+				dur := time.Duration(30+rng.Intn(900)) * time.Second
+				res, err := tx.Exec(`INSERT INTO focus_events (hostname, username, process_name, process_key, app, window_title, project_number, started_at, ended_at, duration_seconds, idle_seconds) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+					host, "user", a.process, processKey(a.process), nullable(a.app), fmt.Sprintf(a.title, project), nullable(project), at, at.Add(dur), dur.Seconds(), dur.Seconds()*rng.Float64()/5)
+				if err != nil {
+					b.Fatal(err)
+				}
+				id, _ := res.LastInsertId()
+				if err := rollupRow(tx, id, 1); err != nil {
+					b.Fatal(err)
+				}
+				at = at.Add(dur + time.Duration(rng.Intn(120))*time.Second)
+			}
+			if _, err := tx.Exec(`INSERT INTO meeting_sessions (hostname, username, process_name, app, subject, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?,?)`,
+				host, "user", "ms-teams.exe", "Teams", "Stand-up", at, at.Add(15*time.Minute), 900.0); err != nil {
+				b.Fatal(err)
+			}
+			at = at.Add(20 * time.Minute)
+			if _, err := tx.Exec(`INSERT INTO inactivity_periods (hostname, username, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?)`,
+				host, "user", at, at.Add(10*time.Minute), 600.0); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			b.Fatal(err)
+		}
+		d.Close()
+	}
+}
+
+// benchDir returns a folder seeded by seedYear, with the timer stopped
+// while it is written.
+func benchDir(b *testing.B) string {
+	b.Helper()
+	b.StopTimer()
+	dir := b.TempDir()
+	seedYear(b, dir)
+	b.StartTimer()
+	return dir
+}
+
+func BenchmarkWeeklySummary(b *testing.B) {
+	dir := benchDir(b)
+	week := benchStart.AddDate(0, 6, 0)
+	for i := 0; i < b.N; i++ {
+		if _, err := GetWeeklySummary(dir, week, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDailyBreakdown_Month(b *testing.B) {
+	dir := benchDir(b)
+	from := benchStart.AddDate(0, 6, 0)
+	for i := 0; i < b.N; i++ {
+		if _, err := GetDailyBreakdown(dir, from, from.AddDate(0, 1, 0), false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDailyBreakdown_Quarter(b *testing.B) {
+	dir := benchDir(b)
+	from := benchStart.AddDate(0, 6, 0)
+	for i := 0; i < b.N; i++ {
+		if _, err := GetDailyBreakdown(dir, from, from.AddDate(0, 3, 0), false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFocusTime_Year(b *testing.B) {
+	dir := benchDir(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := GetFocusTime(dir, "acad.exe", benchStart, benchStart.AddDate(1, 0, 0)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListSessions_Day(b *testing.B) {
+	dir := benchDir(b)
+	day := benchStart.AddDate(0, 9, 1)
+	for i := 0; i < b.N; i++ {
+		if _, err := ListSessions(dir, day, day.AddDate(0, 0, 1), ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPurge_ProcessDryRun(b *testing.B) {
+	dir := benchDir(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := Purge(dir, PurgeFilter{Process: "CHROME.EXE"}, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestProcessFilter_UsesIndexes checks that the reads of one process's
+// sessions over a year of them look the rows up by its indexes, rather than
+// scanning every row.
+func TestProcessFilter_UsesIndexes(t *testing.T) {
+	dir := t.TempDir()
+	seedYear(t, dir)
+	d, err := openReadOnly(filepath.Join(dir, "timewarp-"+benchHosts[0]+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	from, to := benchStart.Format("2006-01-02 15:04:05"), benchStart.AddDate(1, 0, 0).Format("2006-01-02 15:04:05")
+	for _, f := range []processFilter{{process: "acad.exe"}, {process: "Teams", merged: []int64{3, 40}}} {
+		for _, query := range []string{
+			`SELECT f.id FROM focus_events f WHERE f.started_at < ? AND f.ended_at > ? AND `,
+			`SELECT t.focus_event_id FROM focus_event_titles t JOIN focus_events f ON f.id = t.focus_event_id WHERE f.started_at < ? AND f.ended_at > ? AND `,
+		} {
+			where, args := f.where(d, "f.")
+			rows, err := d.Query(`EXPLAIN QUERY PLAN `+query+where, append([]any{to, from}, args...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var plan []string
+			for rows.Next() {
+				var (
+					id, parent, unused int
+					detail             string
+				)
+				rows.Scan(&id, &parent, &unused, &detail)
+				plan = append(plan, detail)
+			}
+			rows.Close()
+			joined := strings.Join(plan, "\n")
+			if !strings.Contains(joined, "focus_events_process") || !strings.Contains(joined, "focus_events_app") || strings.Contains(joined, "SCAN f") {
+				t.Errorf("%s: expected lookups by the process and app indexes, got plan\n%s", f.process, joined)
+			}
+		}
+	}
+}
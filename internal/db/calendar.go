@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/calendar"
+	"github.com/vinistoisr/timewarp/internal/rules"
+)
+
+// calendarHorizon is how far past now recurring calendar events with no end
+// are expanded.
+const calendarHorizon = 366 * 24 * time.Hour
+
+// CalendarImport reports what ImportCalendar read.
+type CalendarImport struct {
+	Files  int `json:"files"`
+	Events int `json:"events"`
+}
+
+// CalendarEvent is a calendar event in a summary's period, with the minutes
+// of it that were spent in a meeting app and the meetings they were in.
+type CalendarEvent struct {
+	Subject          string   `json:"subject"`
+	ProjectNumber    string   `json:"project_number,omitempty"`
+	Start            string   `json:"start"`
+	End              string   `json:"end"`
+	ScheduledMinutes float64  `json:"scheduled_minutes"`
+	AttendedMinutes  float64  `json:"attended_minutes"`
+	Meetings         []string `json:"meetings,omitempty"`
+}
+
+// ImportCalendar stores the events of the .ics file at path, or of the .ics
+// files in the folder at path, in this machine's DB, replacing those last
+// imported from the same files; the events of files since removed from the
+// folder are deleted. A file that can't be read keeps its earlier events.
+//
+// Each event's project is taken from its subject by the attribution rules,
+// or failing that from its description.
+func (t *Tracker) ImportCalendar(path string) (CalendarImport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return importCalendar(t.db, t.rules, t.seal, path, t.clock.Now())
+}
+
+func importCalendar(d *sql.DB, r *rules.Set, s *sealer, path string, now time.Time) (CalendarImport, error) {
+	var report CalendarImport
+	files, err := calendar.Files(path)
+	if err != nil {
+		return report, err
+	}
+	path = filepath.Clean(path)
+	present := map[string]bool{}
+	parsed := map[string][]calendar.Event{}
+	for _, f := range files {
+		f = filepath.Clean(f)
+		present[f] = true
+		events, err := calendar.ParseFile(f, now.Add(calendarHorizon))
+		if err != nil {
+			log.Printf("db: %v", err)
+			continue
+		}
+		parsed[f] = events
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT DISTINCT source FROM calendar_events`)
+	if err != nil {
+		return report, err
+	}
+	var gone []string
+	for rows.Next() {
+		var src string
+		if err := rows.Scan(&src); err != nil {
+			rows.Close()
+			return report, err
+		}
+		if (src == path || filepath.Dir(src) == path) && !present[src] {
+			gone = append(gone, src)
+		}
+	}
+	rows.Close()
+	for _, src := range gone {
+		if _, err := tx.Exec(`DELETE FROM calendar_events WHERE source = ?`, src); err != nil {
+			return report, err
+		}
+	}
+
+	sealed := s.batch()
+	for f, events := range parsed {
+		if _, err := tx.Exec(`DELETE FROM calendar_events WHERE source = ?`, f); err != nil {
+			return report, err
+		}
+		for _, e := range events {
+			if _, err := tx.Exec(
+				`INSERT INTO calendar_events (source, uid, subject, description, location, project_number, started_at, ended_at) VALUES (?,?,?,?,?,?,?,?)`,
+				f, e.UID, sealed.seal(e.Subject), nullable(sealed.seal(e.Description)), nullable(sealed.seal(e.Location)), nullable(calendarProject(r, e)),
+				e.Start.UTC(), e.End.UTC(),
+			); err != nil {
+				return report, err
+			}
+		}
+		report.Files++
+		report.Events += len(events)
+	}
+	if sealed.err != nil {
+		return report, sealed.err
+	}
+	return report, tx.Commit()
+}
+
+func calendarProject(r *rules.Set, e calendar.Event) string {
+	if p := r.Evaluate(rules.Input{Title: e.Subject}).Project; p != "" {
+		return p
+	}
+	return r.Evaluate(rules.Input{Title: e.Description}).Project
+}
+
+// span is a stretch of time, such as one meeting session.
+type span struct {
+	start, end time.Time
+}
+
+// calEvent is a stored calendar event, as read by loadPeriod.
+type calEvent struct {
+	subject, project string
+	start, end       time.Time
+}
+
+// calendarList matches the calendar events added by addPeriod to the meeting
+// sessions overlapping them, counting the part of each inside [from, to).
+func (a *summaryAgg) calendarList(from, to time.Time) []CalendarEvent {
+	var list []CalendarEvent
+	for _, e := range a.events {
+		event := clip(span{e.start, e.end}, span{from, to})
+		var (
+			attended []span
+			subjects []string
+		)
+		for subj, m := range a.meetings {
+			n := len(attended)
+			for _, s := range m.spans {
+				if c := clip(s, event); c.end.After(c.start) {
+					attended = append(attended, c)
+				}
+			}
+			if len(attended) > n {
+				subjects = append(subjects, subj)
+			}
+		}
+		sort.Strings(subjects)
+		list = append(list, CalendarEvent{
+			Subject:          e.subject,
+			ProjectNumber:    e.project,
+			Start:            e.start.UTC().Format(time.RFC3339),
+			End:              e.end.UTC().Format(time.RFC3339),
+			ScheduledMinutes: round1(event.end.Sub(event.start).Minutes()),
+			AttendedMinutes:  round1(unionMinutes(attended)),
+			Meetings:         subjects,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Start != list[j].Start {
+			return list[i].Start < list[j].Start
+		}
+		return list[i].Subject < list[j].Subject
+	})
+	return list
+}
+
+// bestEvent returns the calendar event the spans overlap most, if any.
+func (a *summaryAgg) bestEvent(spans []span) *calEvent {
+	var (
+		best    *calEvent
+		bestDur time.Duration
+	)
+	for _, e := range a.events {
+		var d time.Duration
+		for _, s := range spans {
+			if c := clip(s, span{e.start, e.end}); c.end.After(c.start) {
+				d += c.end.Sub(c.start)
+			}
+		}
+		if d > bestDur || (d == bestDur && d > 0 && e.start.Before(best.start)) {
+			best, bestDur = e, d
+		}
+	}
+	return best
+}
+
+// clip returns the part of s inside within, which is empty if they don't
+// overlap.
+func clip(s, within span) span {
+	if s.start.Before(within.start) {
+		s.start = within.start
+	}
+	if s.end.After(within.end) {
+		s.end = within.end
+	}
+	return s
+}
+
+// unionMinutes returns the minutes covered by spans, counting time covered
+// by more than one, such as the same meeting on two machines, once.
+func unionMinutes(spans []span) float64 {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+	var (
+		total time.Duration
+		cur   span
+	)
+	for i, s := range spans {
+		if i > 0 && !s.start.After(cur.end) {
+			if s.end.After(cur.end) {
+				cur.end = s.end
+			}
+			continue
+		}
+		if i > 0 {
+			total += cur.end.Sub(cur.start)
+		}
+		cur = s
+	}
+	if len(spans) > 0 {
+		total += cur.end.Sub(cur.start)
+	}
+	return total.Minutes()
+}
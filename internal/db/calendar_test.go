@@ -0,0 +1,90 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const workCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:review
+SUMMARY:25-019 Design Review
+DTSTART:20260302T140000Z
+DTEND:20260302T150000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:coordination
+SUMMARY:Coordination
+DESCRIPTION:Weekly check-in for project 25-125
+DTSTART:20260302T160000Z
+DTEND:20260302T163000Z
+RRULE:FREQ=WEEKLY;COUNT=3
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestCalendar_ScheduledVsAttended(t *testing.T) {
+	dir := t.TempDir()
+	cal := filepath.Join(dir, "calendars")
+	os.Mkdir(cal, 0o755)
+	os.WriteFile(filepath.Join(cal, "work.ics"), []byte(workCalendar), 0o644)
+	os.WriteFile(filepath.Join(cal, "old.ics"), []byte(workCalendar), 0o644)
+
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := tr.ImportCalendar(cal)
+	if err != nil || n.Files != 2 || n.Events != 8 {
+		t.Fatalf("import: got %+v, %v", n, err)
+	}
+
+	// Joined the review five minutes late and left early, on a title
+	// that only names the organiser
+	base := time.Date(2026, 3, 2, 14, 5, 0, 0, time.UTC)
+	for i := 0; i <= 40*60; i++ {
+		tr.RecordFocus("HOST", "user", "ms-teams.exe", "Meeting with Ann Lee | Microsoft Teams", base.Add(time.Duration(i)*time.Second))
+	}
+	tr.RecordFocus("HOST", "user", "acad.exe", "25-125_SLD-E101.dwg - AutoCAD", base.Add(41*time.Minute))
+
+	// Importing again once a file is gone drops its events
+	os.Remove(filepath.Join(cal, "old.ics"))
+	if n, err := tr.ImportCalendar(cal); err != nil || n.Events != 4 {
+		t.Fatalf("reimport: got %+v, %v", n, err)
+	}
+	if got := countRows(t, tr.db, "calendar_events"); got != 4 {
+		t.Errorf("expected 4 stored events, got %d", got)
+	}
+	tr.Close()
+
+	raw, err := GetWeeklySummary(dir, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary WeeklySummary
+	json.Unmarshal(raw, &summary)
+
+	if len(summary.Calendar) != 2 {
+		t.Fatalf("expected 2 calendar events this week, got %+v", summary.Calendar)
+	}
+	review, coord := summary.Calendar[0], summary.Calendar[1]
+	if review.Subject != "25-019 Design Review" || review.ProjectNumber != "25-019" ||
+		review.ScheduledMinutes != 60 || review.AttendedMinutes != 40 ||
+		len(review.Meetings) != 1 || review.Meetings[0] != "Meeting with Ann Lee" {
+		t.Errorf("review: got %+v", review)
+	}
+	if coord.ProjectNumber != "25-125" || coord.ScheduledMinutes != 30 || coord.AttendedMinutes != 0 {
+		t.Errorf("coordination: got %+v", coord)
+	}
+
+	if len(summary.Meetings) != 1 {
+		t.Fatalf("expected 1 meeting, got %+v", summary.Meetings)
+	}
+	if m := summary.Meetings[0]; m.CalendarSubject != "25-019 Design Review" || m.ProjectNumber != "25-019" {
+		t.Errorf("meeting not matched to its calendar event: %+v", m)
+	}
+}
@@ -10,48 +10,100 @@ import (
 	"sync"
 	"time"
 
+	"github.com/vinistoisr/timewarp/internal/appid"
+	"github.com/vinistoisr/timewarp/internal/clock"
+	"github.com/vinistoisr/timewarp/internal/meeting"
+	"github.com/vinistoisr/timewarp/internal/profiles"
+	"github.com/vinistoisr/timewarp/internal/rules"
 	_ "modernc.org/sqlite"
 )
 
 const (
-	bridgeThreshold = 30 * time.Second
-	minimumDuration = 10 * time.Second
-)
-
-var suppressedProcesses = map[string]bool{
-	"mstsc.exe":                true,
-	"applicationframehost.exe": true,
-	"shellexperiencehost.exe":  true,
-	"lockapp.exe":              true,
-	"logonui.exe":              true,
-}
+	// checkpointInterval is how often the pending session is saved as it
+	// grows, and so the most of it a crash can lose.
+	checkpointInterval = 15 * time.Second
 
-var projectNumberRe = regexp.MustCompile(`(?:^|\D)(\d{2}-\d{3})(?:\D|$)`)
+	// maxSessionTitles caps the distinct titles kept for a session, for
+	// windows such as browsers and mail whose title changes all the time.
+	maxSessionTitles = 20
+)
 
 // Tracker holds the database connection and in-memory pending session state.
 type Tracker struct {
-	db *sql.DB
-	mu sync.Mutex
+	db       *sql.DB
+	mu       sync.Mutex
+	clock    clock.Clock
+	rules    *rules.Set
+	profiles *profiles.Set
+	// urls looks up the URL shown in a browser window; see SetURLs
+	urls func(processName, windowTitle string) string
+	// keepCommandLines and redact are how command lines are recorded; see
+	// SetCommandLines
+	keepCommandLines bool
+	redact           []*regexp.Regexp
 
 	pending *pendingSession
+	// lastSeen of pending when it was last checkpointed
+	checkpointed time.Time
 
 	// inactivity tracking
 	inactiveStart *time.Time
+
+	// suspended or locked, from RecordSystemEvent
+	system awayState
+
+	raw       rawLog
+	retention retention
+
+	// seal seals free text before it is written, if the file is encrypted;
+	// see checkSeal
+	seal        *sealer
+	sealChecked time.Time
 }
 
 type pendingSession struct {
 	hostname    string
 	username    string
 	processName string
+	exePath     string
+	class       string
+	commandLine string
+	app         string
 	windowTitle string
-	startedAt   time.Time
-	lastSeen    time.Time
+	url         string
+	// titles are the distinct titles seen, in order, up to maxSessionTitles,
+	// and urls the URL last seen with each, if any
+	titles    []string
+	urls      []string
+	startedAt time.Time
+	lastSeen  time.Time
+	// idle is the inactivity already ended within the session; see idleIn
+	idle time.Duration
 }
 
 func Open(dbpath string) (*Tracker, error) {
+	db, _, err := openLocal(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	t := newTracker(db)
+	// An encrypted file must not be written to without its key
+	if t.seal, err = fileSealer(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	t.restorePending()
+	return t, nil
+}
+
+// openLocal opens this machine's DB file in dbpath for writing, creating it
+// if needed, and returns it with the hostname it is named after. Tracking
+// and hand-made edits such as manual entries only ever write here, so that
+// a synced folder does not end up with two machines writing one file.
+func openLocal(dbpath string) (*sql.DB, string, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
-		return nil, fmt.Errorf("db: hostname: %w", err)
+		return nil, "", fmt.Errorf("db: hostname: %w", err)
 	}
 
 	dbFile := fmt.Sprintf("timewarp-%s.db", hostname)
@@ -59,111 +111,294 @@ func Open(dbpath string) (*Tracker, error) {
 
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
-		return nil, fmt.Errorf("db: open: %w", err)
+		return nil, "", fmt.Errorf("db: open: %w", err)
 	}
 
 	db.SetMaxOpenConns(1)
 
-	if err := initSchema(db); err != nil {
+	if err := migrate(db); err != nil {
 		db.Close()
-		return nil, err
+		return nil, "", err
 	}
 
-	return &Tracker{db: db}, nil
-}
-
-func initSchema(db *sql.DB) error {
-	stmts := []string{
-		`CREATE TABLE IF NOT EXISTS focus_events (
-			id              INTEGER PRIMARY KEY AUTOINCREMENT,
-			hostname        TEXT NOT NULL,
-			username        TEXT NOT NULL,
-			process_name    TEXT NOT NULL,
-			window_title    TEXT NOT NULL,
-			project_number  TEXT,
-			started_at      DATETIME NOT NULL,
-			ended_at        DATETIME NOT NULL,
-			duration_seconds REAL NOT NULL
-		)`,
-		`CREATE TABLE IF NOT EXISTS inactivity_periods (
-			id              INTEGER PRIMARY KEY AUTOINCREMENT,
-			hostname        TEXT NOT NULL,
-			username        TEXT NOT NULL,
-			started_at      DATETIME NOT NULL,
-			ended_at        DATETIME NOT NULL,
-			duration_seconds REAL NOT NULL
-		)`,
-		`CREATE TABLE IF NOT EXISTS meeting_sessions (
-			id              INTEGER PRIMARY KEY AUTOINCREMENT,
-			hostname        TEXT NOT NULL,
-			username        TEXT NOT NULL,
-			process_name    TEXT NOT NULL,
-			subject         TEXT NOT NULL,
-			started_at      DATETIME NOT NULL,
-			ended_at        DATETIME NOT NULL,
-			duration_seconds REAL NOT NULL
-		)`,
-	}
-	for _, s := range stmts {
-		if _, err := db.Exec(s); err != nil {
-			return fmt.Errorf("db: schema: %w", err)
-		}
+	return db, hostname, nil
+}
+
+// CurrentUser returns the login name from USERNAME (Windows) or USER
+// (Unix), which rows are recorded under.
+func CurrentUser() string {
+	if u := os.Getenv("USERNAME"); u != "" {
+		return u
 	}
-	return nil
+	return os.Getenv("USER")
+}
+
+func newTracker(db *sql.DB) *Tracker {
+	return &Tracker{db: db, clock: clock.Real{}, rules: rules.Default(), profiles: profiles.Default()}
+}
+
+// SetRules replaces the attribution rules applied to sessions as they are
+// written. Rows already in the DB keep their attribution until ReapplyRules.
+func (t *Tracker) SetRules(r *rules.Set) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = r
+}
+
+// SetProfiles replaces the sessionization profiles. They apply from the
+// next sample on, including to the session in progress.
+func (t *Tracker) SetProfiles(p *profiles.Set) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.profiles = p
+}
+
+// SetURLs sets how the URL of the page in a browser window is found, given
+// the window's process and title; it returns "" for other windows or when
+// the URL is not known. URLs are matched by attribution rules and kept with
+// the session's titles.
+func (t *Tracker) SetURLs(f func(processName, windowTitle string) string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.urls = f
+}
+
+// urlFor returns the URL shown in the window, if known.
+func (t *Tracker) urlFor(processName, windowTitle string) string {
+	if t.urls == nil {
+		return ""
+	}
+	return t.urls(processName, windowTitle)
+}
+
+// SetCommandLines sets whether the command lines of the processes in front
+// are recorded, and the patterns blanked out of them first as by
+// appid.Redact, on top of appid.Secrets. They are left out by default; the
+// application identity is taken from them either way.
+func (t *Tracker) SetCommandLines(keep bool, redact []*regexp.Regexp) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keepCommandLines = keep
+	t.redact = append(append([]*regexp.Regexp(nil), appid.Secrets...), redact...)
+}
+
+// Window is a window in front, as the tracker records it. Process and
+// Title are always given; the rest only where capture reports them.
+type Window struct {
+	Process     string
+	Title       string
+	ExePath     string
+	Class       string
+	CommandLine string
+	// App is the application identity; when empty it is derived from the
+	// rest by appid.Identify
+	App string
+}
+
+// prepare fills in w's application identity, then drops or redacts its
+// command line as SetCommandLines says.
+func (t *Tracker) prepare(w Window) Window {
+	if w.App == "" {
+		w.App = appid.Identify(w.Process, w.ExePath, w.Class, w.CommandLine)
+	}
+	switch {
+	case !t.keepCommandLines:
+		w.CommandLine = ""
+	case w.CommandLine != "":
+		w.CommandLine = appid.Redact(w.CommandLine, t.redact)
+	}
+	return w
+}
+
+// SetClock replaces the clock used for times the Tracker takes itself,
+// such as the final flush in Close.
+func (t *Tracker) SetClock(c clock.Clock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clock = c
 }
 
 // RecordFocus is called every tick with the current window info.
 // It handles session stitching and writes completed sessions to the DB.
 func (t *Tracker) RecordFocus(hostname, username, processName, windowTitle string, now time.Time) {
-	procLower := strings.ToLower(processName)
-	if suppressedProcesses[procLower] {
-		return
-	}
+	t.RecordWindow(hostname, username, Window{Process: processName, Title: windowTitle}, now)
+}
 
+// RecordFocusChange is called when the platform reports a focus or title
+// change as it happens. Unlike RecordFocus, it ends the previous session at
+// the moment of the switch instead of at its last tick, and starts the next
+// one there too. Polling ticks keep arriving in between and extend the
+// session as usual.
+func (t *Tracker) RecordFocusChange(hostname, username, processName, windowTitle string, at time.Time) {
+	t.RecordWindowChange(hostname, username, Window{Process: processName, Title: windowTitle}, at)
+}
+
+// RecordWindow is RecordFocus with everything capture knows of the window.
+// Samples of one application identity are stitched into one session.
+func (t *Tracker) RecordWindow(hostname, username string, w Window, now time.Time) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	if t.system.away() {
+		return
+	}
+	t.checkSeal(now)
+	t.checkRetention(now)
+	w = t.prepare(w)
+	url := t.urlFor(w.Process, w.Title)
+	t.logRaw(hostname, username, w, url, now, false)
+	if t.profiles.For(w.Process).Suppress {
+		return
+	}
 
 	if t.pending == nil {
-		t.pending = &pendingSession{
-			hostname:    hostname,
-			username:    username,
-			processName: processName,
-			windowTitle: windowTitle,
-			startedAt:   now,
-			lastSeen:    now,
-		}
+		t.startPending(hostname, username, w, url, now)
 		return
 	}
 
-	sameProcess := strings.EqualFold(t.pending.processName, processName)
+	sameApp := strings.EqualFold(t.pending.app, w.App)
 	gap := now.Sub(t.pending.lastSeen)
 
-	if sameProcess && gap <= bridgeThreshold {
+	if sameApp && gap <= t.bridge(t.pending) && !t.changesAttribution(t.pending, w.Title, url) {
 		// Bridge the gap — extend the session, update title to latest
 		t.pending.lastSeen = now
-		t.pending.windowTitle = windowTitle
+		t.pending.seeTitle(w.Title, url)
+		t.checkpoint()
 		return
 	}
 
-	// Different process or gap too large — flush pending session
+	// Different app or project, or gap too large — flush pending session
 	t.flushPending(now)
+	t.startPending(hostname, username, w, url, now)
+}
+
+// RecordWindowChange is RecordFocusChange with everything capture knows of
+// the window.
+func (t *Tracker) RecordWindowChange(hostname, username string, w Window, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.system.away() {
+		return
+	}
+	t.checkSeal(at)
+	t.checkRetention(at)
+	w = t.prepare(w)
+	url := t.urlFor(w.Process, w.Title)
+	t.logRaw(hostname, username, w, url, at, true)
+	if t.profiles.For(w.Process).Suppress {
+		return
+	}
+
+	if p := t.pending; p != nil && strings.EqualFold(p.app, w.App) {
+		if at.Before(p.startedAt) {
+			// A tick saw the new window before its event was delivered.
+			p.startedAt = at
+			return
+		}
+		if at.Sub(p.lastSeen) <= t.bridge(p) && !t.changesAttribution(p, w.Title, url) {
+			// Title change (or a return within the bridge) — same session
+			if at.After(p.lastSeen) {
+				p.lastSeen = at
+			}
+			p.seeTitle(w.Title, url)
+			t.checkpoint()
+			return
+		}
+	}
+
+	// The user was in the previous window right up to the switch, unless
+	// ticks had already stopped (paused, asleep) for longer than the bridge.
+	if p := t.pending; p != nil && at.After(p.lastSeen) && at.Sub(p.lastSeen) <= t.bridge(p) {
+		p.lastSeen = at
+	}
+	t.flushPending(at)
+	t.startPending(hostname, username, w, url, at)
+}
+
+// bridge is the longest gap in p's samples that p survives, from the
+// profile for its process.
+func (t *Tracker) bridge(p *pendingSession) time.Duration {
+	return t.profiles.For(p.processName).Bridge
+}
 
-	// Start new pending session
+// startPending starts a new pending session at now and checkpoints it.
+func (t *Tracker) startPending(hostname, username string, w Window, url string, now time.Time) {
 	t.pending = &pendingSession{
 		hostname:    hostname,
 		username:    username,
-		processName: processName,
-		windowTitle: windowTitle,
+		processName: w.Process,
+		exePath:     w.ExePath,
+		class:       w.Class,
+		commandLine: w.CommandLine,
+		app:         w.App,
+		windowTitle: w.Title,
+		url:         url,
+		titles:      []string{w.Title},
+		urls:        []string{url},
 		startedAt:   now,
 		lastSeen:    now,
 	}
+	t.checkpoint()
+}
+
+// changesAttribution reports whether switching p to title, showing url,
+// moves to other work, so that p should end: title is attributed, and not
+// as p is. A title the rules say nothing about, such as a dialog or an
+// untitled document, stays in the session.
+func (t *Tracker) changesAttribution(p *pendingSession, title, url string) bool {
+	if title == p.windowTitle && url == p.url {
+		return false
+	}
+	in := p.input()
+	in.Title, in.URL = title, url
+	a := t.rules.Evaluate(in)
+	return a != (rules.Attribution{}) && a != sessionAttribution(t.rules, p.input(), p.titles, p.urls)
+}
+
+// input is what the rules match p on, less the title and URL.
+func (p *pendingSession) input() rules.Input {
+	return rules.Input{Process: p.processName, ExePath: p.exePath, Class: p.class, App: p.app}
+}
+
+// seeTitle makes title and url the session's latest and records them among
+// its titles.
+func (p *pendingSession) seeTitle(title, url string) {
+	p.windowTitle, p.url = title, url
+	for i, seen := range p.titles {
+		if seen == title {
+			if url != "" {
+				p.urls[i] = url
+			}
+			return
+		}
+	}
+	if len(p.titles) < maxSessionTitles {
+		p.titles = append(p.titles, title)
+		p.urls = append(p.urls, url)
+	}
+}
+
+// sessionAttribution is the attribution of a session of the application in
+// in with the given titles and the URLs seen with them, if any: that of the
+// first title the rules attribute. Sessions are split when the attribution
+// changes, so later titles either agree or say nothing.
+func sessionAttribution(r *rules.Set, in rules.Input, titles, urls []string) rules.Attribution {
+	for i, title := range titles {
+		in.Title, in.URL = title, ""
+		if i < len(urls) {
+			in.URL = urls[i]
+		}
+		if a := r.Evaluate(in); a != (rules.Attribution{}) {
+			return a
+		}
+	}
+	return rules.Attribution{}
 }
 
 // RecordInactivityStart marks the beginning of an inactivity period.
 func (t *Tracker) RecordInactivityStart(now time.Time) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	if t.inactiveStart == nil {
+	if t.inactiveStart == nil && !t.system.away() {
 		t.inactiveStart = &now
 	}
 }
@@ -172,10 +407,60 @@ func (t *Tracker) RecordInactivityStart(now time.Time) {
 func (t *Tracker) RecordInactivityEnd(hostname, username string, now time.Time) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.endInactivity(hostname, username, now)
+}
+
+// RecordSystemEvent records a suspend, resume, lock, unlock or shutdown.
+// Going away ends the pending session and any inactivity at the event
+// rather than at the last tick, and until the user is back, focus and idle
+// ticks are ignored: neither the lock screen nor a window a screen locker
+// leaves in front is work.
+func (t *Tracker) RecordSystemEvent(hostname, username, event string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wasAway := t.system.away()
+	if !t.system.apply(event) {
+		log.Printf("db: unknown system event %q", event)
+		return
+	}
+	if _, err := t.db.Exec(
+		`INSERT INTO system_events (hostname, username, event, occurred_at) VALUES (?,?,?,?)`,
+		hostname, username, event, at.UTC(),
+	); err != nil {
+		log.Printf("db: system event insert: %v", err)
+	}
+	if wasAway || !t.system.away() {
+		return
+	}
+
+	// The user was in the pending window up to the event, unless ticks had
+	// already stopped for longer than the bridge; and not after it, if a
+	// tick got in before the event was delivered.
+	if p := t.pending; p != nil {
+		if at.After(p.lastSeen) && at.Sub(p.lastSeen) <= t.bridge(p) {
+			p.lastSeen = at
+		} else if at.Before(p.lastSeen) && at.After(p.startedAt) {
+			p.lastSeen = at
+		}
+	}
+	t.flushPending(at)
+	t.endRawRun(at)
+	t.endInactivity(hostname, username, at)
+}
+
+// endInactivity writes the inactivity period in progress, if any, ending at
+// now, and takes the part of it in the pending session out of its active
+// time. The session itself carries on, so a pause at the same window is not
+// split in two.
+func (t *Tracker) endInactivity(hostname, username string, now time.Time) {
 	if t.inactiveStart == nil {
 		return
 	}
 	start := *t.inactiveStart
+	if p := t.pending; p != nil {
+		p.idle += overlap(start, now, p.startedAt, p.lastSeen)
+	}
 	t.inactiveStart = nil
 	dur := now.Sub(start).Seconds()
 	if dur < 1 {
@@ -189,62 +474,184 @@ func (t *Tracker) RecordInactivityEnd(hostname, username string, now time.Time)
 	}
 }
 
+// flushPending writes the pending session, unless it is too short to keep,
+// and clears its checkpoint in the same transaction.
 func (t *Tracker) flushPending(now time.Time) {
-	if t.pending == nil {
+	p := t.pending
+	if p == nil {
 		return
 	}
-	dur := t.pending.lastSeen.Sub(t.pending.startedAt)
-	if dur < minimumDuration {
-		t.pending = nil
+	t.pending = nil
+	t.checkpointed = time.Time{}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		log.Printf("db: focus_events insert: %v", err)
 		return
 	}
+	defer tx.Rollback()
 
-	var projNum *string
-	if matches := projectNumberRe.FindStringSubmatch(t.pending.windowTitle); len(matches) > 1 {
-		projNum = &matches[1]
+	if _, err := tx.Exec(`DELETE FROM pending_session`); err != nil {
+		log.Printf("db: pending session clear: %v", err)
+		return
 	}
 
-	// Check if this is a meeting
-	isMeeting := (strings.EqualFold(t.pending.processName, "ms-teams.exe") ||
-		strings.EqualFold(t.pending.processName, "zoom.exe")) &&
-		strings.Contains(t.pending.windowTitle, "Meeting")
+	sealed := t.seal.batch()
+	dur := p.lastSeen.Sub(p.startedAt)
+	if dur >= t.profiles.For(p.processName).Minimum {
+		attr := sessionAttribution(t.rules, p.input(), p.titles, p.urls)
+
+		mtg, isMeeting := meeting.Detect(p.processName, p.windowTitle)
+
+		// Listening in a meeting without touching the keyboard is not idle
+		idle := t.idleIn(p)
+		if isMeeting {
+			idle = 0
+		}
+
+		if isMeeting {
+			if _, err := tx.Exec(
+				`INSERT INTO meeting_sessions (hostname, username, process_name, app, subject, attendees, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?,?,?)`,
+				p.hostname, p.username, p.processName, mtg.App, sealed.seal(mtg.Subject), nullable(sealed.seal(strings.Join(mtg.Attendees, "\n"))),
+				p.startedAt.UTC(), p.lastSeen.UTC(), dur.Seconds(),
+			); err != nil {
+				log.Printf("db: meeting insert: %v", err)
+			}
+		}
 
-	if isMeeting {
-		subject := extractMeetingSubject(t.pending.windowTitle)
-		if _, err := t.db.Exec(
-			`INSERT INTO meeting_sessions (hostname, username, process_name, subject, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?)`,
-			t.pending.hostname, t.pending.username, t.pending.processName, subject,
-			t.pending.startedAt.UTC(), t.pending.lastSeen.UTC(), dur.Seconds(),
-		); err != nil {
-			log.Printf("db: meeting insert: %v", err)
+		res, err := tx.Exec(
+			`INSERT INTO focus_events (hostname, username, process_name, process_key, exe_path, window_class, command_line, app, window_title, url, project_number, client, task, category, started_at, ended_at, duration_seconds, idle_seconds) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+			p.hostname, p.username, p.processName, processKey(p.processName), nullable(p.exePath), nullable(p.class), nullable(sealed.seal(p.commandLine)), nullable(p.app),
+			sealed.seal(p.windowTitle), nullable(sealed.seal(p.url)), nullable(attr.Project), nullable(attr.Client), nullable(attr.Task), nullable(attr.Category),
+			p.startedAt.UTC(), p.lastSeen.UTC(), dur.Seconds(), idle.Seconds(),
+		)
+		if err != nil {
+			log.Printf("db: focus_events insert: %v", err)
+			return
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			log.Printf("db: focus_events insert: %v", err)
+			return
+		}
+		for i, title := range p.titles {
+			if _, err := tx.Exec(`INSERT INTO focus_event_titles (focus_event_id, title, url) VALUES (?,?,?)`, id, sealed.seal(title), nullable(sealed.seal(p.urls[i]))); err != nil {
+				log.Printf("db: focus_event_titles insert: %v", err)
+				return
+			}
+		}
+		if err := rollupRow(tx, id, 1); err != nil {
+			log.Printf("db: %v", err)
+			return
 		}
 	}
+	if sealed.err != nil {
+		log.Printf("db: focus_events insert: %v", sealed.err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("db: focus_events insert: %v", err)
+	}
+}
 
+// checkpoint saves the pending session so that it survives a crash. A new
+// session is saved at once; after that, at most every checkpointInterval.
+func (t *Tracker) checkpoint() {
+	p := t.pending
+	if !t.checkpointed.IsZero() && p.lastSeen.Sub(t.checkpointed) < checkpointInterval {
+		return
+	}
+	sealed := t.seal.batch()
+	cmd, title, url := sealed.seal(p.commandLine), sealed.seal(p.windowTitle), sealed.seal(p.url)
+	titles, urls := sealed.seal(strings.Join(p.titles, "\n")), sealed.seal(strings.Join(p.urls, "\n"))
+	if sealed.err != nil {
+		log.Printf("db: pending session checkpoint: %v", sealed.err)
+		return
+	}
 	if _, err := t.db.Exec(
-		`INSERT INTO focus_events (hostname, username, process_name, window_title, project_number, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?,?)`,
-		t.pending.hostname, t.pending.username, t.pending.processName,
-		t.pending.windowTitle, projNum,
-		t.pending.startedAt.UTC(), t.pending.lastSeen.UTC(), dur.Seconds(),
+		`INSERT OR REPLACE INTO pending_session (id, hostname, username, process_name, exe_path, window_class, command_line, app, window_title, url, titles, urls, started_at, last_seen, idle_seconds) VALUES (1,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		p.hostname, p.username, p.processName, nullable(p.exePath), nullable(p.class), nullable(cmd), nullable(p.app), title, nullable(url), titles, urls, p.startedAt.UTC(), p.lastSeen.UTC(), t.idleIn(p).Seconds(),
 	); err != nil {
-		log.Printf("db: focus_events insert: %v", err)
+		log.Printf("db: pending session checkpoint: %v", err)
+		return
 	}
+	t.checkpointed = p.lastSeen
+}
 
-	t.pending = nil
+// restorePending picks up the session that was in progress when the
+// tracker last stopped without a Close, as after a crash or power loss.
+// It becomes the pending session again: if tracking resumes in the same
+// window within the bridge it carries on, and otherwise it is written out
+// ending at its last checkpoint, with the rules in force by then.
+func (t *Tracker) restorePending() {
+	var (
+		p            pendingSession
+		titles, urls string
+		idle         float64
+	)
+	err := t.db.QueryRow(`SELECT hostname, username, process_name, COALESCE(exe_path, ''), COALESCE(window_class, ''), COALESCE(unseal(command_line), ''), COALESCE(app, process_name), unseal(window_title), COALESCE(unseal(url), ''), COALESCE(unseal(titles), ''), COALESCE(unseal(urls), ''), started_at, last_seen, COALESCE(idle_seconds, 0) FROM pending_session WHERE id = 1`).
+		Scan(&p.hostname, &p.username, &p.processName, &p.exePath, &p.class, &p.commandLine, &p.app, &p.windowTitle, &p.url, &titles, &urls, &p.startedAt, &p.lastSeen, &idle)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		log.Printf("db: pending session restore: %v", err)
+		return
+	}
+	log.Printf("db: restoring %s session from %s that was not closed", p.processName, p.startedAt.Local().Format(time.RFC3339))
+	p.idle = time.Duration(idle * float64(time.Second))
+	p.titles = []string{p.windowTitle}
+	if titles != "" {
+		p.titles = strings.Split(titles, "\n")
+	}
+	// Checkpoints from before URLs were kept have none
+	p.urls = make([]string, len(p.titles))
+	if urls != "" {
+		copy(p.urls, strings.Split(urls, "\n"))
+	}
+	t.pending = &p
+	t.checkpointed = p.lastSeen
+}
+
+// idleIn returns how much of p has been idle, counting any inactivity still
+// in progress up to p's last tick.
+func (t *Tracker) idleIn(p *pendingSession) time.Duration {
+	idle := p.idle
+	if t.inactiveStart != nil {
+		idle += overlap(*t.inactiveStart, p.lastSeen, p.startedAt, p.lastSeen)
+	}
+	return idle
+}
+
+// overlap returns how much of [start, end) falls in [from, to).
+func overlap(start, end, from, to time.Time) time.Duration {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
 }
 
-func extractMeetingSubject(title string) string {
-	re := regexp.MustCompile(`Meeting\s*(.*)`)
-	matches := re.FindStringSubmatch(title)
-	if len(matches) > 1 {
-		return strings.TrimSpace(matches[1])
+// nullable stores empty attribution fields as NULL, as project_number
+// always has been.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
 	}
-	return title
+	return &s
 }
 
 // Close flushes any pending session and closes the database.
 func (t *Tracker) Close() error {
 	t.mu.Lock()
-	t.flushPending(time.Now())
+	t.flushPending(t.clock.Now())
+	t.closeRawRun()
 	t.mu.Unlock()
 	return t.db.Close()
 }
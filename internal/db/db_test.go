@@ -2,9 +2,17 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/vinistoisr/timewarp/internal/profiles"
+	"github.com/vinistoisr/timewarp/internal/rules"
 )
 
 func tempTracker(t *testing.T) (*Tracker, string) {
@@ -17,10 +25,10 @@ func tempTracker(t *testing.T) (*Tracker, string) {
 		t.Fatal(err)
 	}
 	d.SetMaxOpenConns(1)
-	if err := initSchema(d); err != nil {
+	if err := migrate(d); err != nil {
 		t.Fatal(err)
 	}
-	return &Tracker{db: d}, dir
+	return newTracker(d), dir
 }
 
 func countRows(t *testing.T, d *sql.DB, table string) int {
@@ -201,6 +209,69 @@ func TestProjectNumberExtraction_NoMatch(t *testing.T) {
 	}
 }
 
+func TestRules_StoredOnFocusEvents(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	r, err := rules.New([]rules.Rule{
+		{Title: `\b(PRJ-\d+)\b`, Project: "$1", Client: "Contoso"},
+		{Process: `^chrome\.exe$`, Category: "Browsing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetRules(r)
+
+	base := time.Now().Truncate(time.Second)
+	for i := 0; i < 12; i++ {
+		tr.RecordFocus("HOST", "user", "chrome.exe", "PRJ-4412 Board - Jira", base.Add(time.Duration(i)*time.Second))
+	}
+	tr.RecordFocus("HOST", "user", "notepad.exe", "Untitled", base.Add(15*time.Second))
+
+	var proj, client, task, category sql.NullString
+	tr.db.QueryRow("SELECT project_number, client, task, category FROM focus_events").Scan(&proj, &client, &task, &category)
+	if proj.String != "PRJ-4412" || client.String != "Contoso" || task.Valid || category.String != "Browsing" {
+		t.Errorf("got project=%v client=%v task=%v category=%v", proj, client, task, category)
+	}
+}
+
+func TestReapplyRules(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Now().Truncate(time.Second)
+	for i := 0; i < 12; i++ {
+		tr.RecordFocus("HOST", "user", "chrome.exe", "PRJ-4412 Board - Jira", base.Add(time.Duration(i)*time.Second))
+	}
+	for i := 0; i < 12; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "25-125_SLD-E101.dwg - AutoCAD", base.Add(20*time.Second+time.Duration(i)*time.Second))
+	}
+	tr.RecordFocus("HOST", "user", "notepad.exe", "Untitled", base.Add(40*time.Second))
+
+	r, err := rules.New([]rules.Rule{{Title: `\b(PRJ-\d+)\b`, Project: "$1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetRules(r)
+	n, err := tr.ReapplyRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Jira row gains a project; the AutoCAD row loses 25-125 under the new rules
+	if n != 2 {
+		t.Errorf("expected 2 rows updated, got %d", n)
+	}
+	var proj sql.NullString
+	tr.db.QueryRow("SELECT project_number FROM focus_events WHERE process_name = 'chrome.exe'").Scan(&proj)
+	if proj.String != "PRJ-4412" {
+		t.Errorf("expected PRJ-4412, got %v", proj)
+	}
+
+	if n, _ := tr.ReapplyRules(); n != 0 {
+		t.Errorf("expected second pass to change nothing, got %d", n)
+	}
+}
+
 func TestMeetingDetection(t *testing.T) {
 	tr, _ := tempTracker(t)
 	defer tr.Close()
@@ -224,6 +295,48 @@ func TestMeetingDetection(t *testing.T) {
 	}
 }
 
+func TestMeetingDetection_AppAndAttendees(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return base.Add(time.Duration(s) * time.Second) }
+
+	// The same call twice, then a Meet tab and a Slack window that is not
+	// a huddle
+	for i := 0; i <= 60; i++ {
+		tr.RecordFocus("HOST", "user", "ms-teams.exe", "Call with Ann Lee | Microsoft Teams", at(i))
+	}
+	tr.RecordFocus("HOST", "user", "acad.exe", "25-125 Plan.dwg", at(100))
+	for i := 200; i <= 260; i++ {
+		tr.RecordFocus("HOST", "user", "ms-teams.exe", "Call with Ann Lee | Microsoft Teams", at(i))
+	}
+	tr.RecordFocus("HOST", "user", "acad.exe", "25-125 Plan.dwg", at(300))
+	for i := 400; i <= 460; i++ {
+		tr.RecordFocus("HOST", "user", "chrome.exe", "Meet - abc-defg-hij - Google Chrome", at(i))
+	}
+	for i := 500; i <= 560; i++ {
+		tr.RecordFocus("HOST", "user", "slack.exe", "Slack | general | Acme", at(i))
+	}
+	tr.flushPending(at(600))
+
+	agg := newSummaryAgg(false)
+	agg.addPeriod(loadPeriod([]*sql.DB{tr.db}, base, base.Add(time.Hour)), base, base.Add(time.Hour))
+	got := map[string]MeetingSummary{}
+	for _, m := range agg.meetingList() {
+		got[m.Subject] = m
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 meetings, got %+v", got)
+	}
+	if m := got["Call with Ann Lee"]; m.App != "Teams" || m.Sessions != 2 || len(m.Attendees) != 1 || m.Attendees[0] != "Ann Lee" {
+		t.Errorf("Teams call: got %+v", m)
+	}
+	if m := got["abc-defg-hij"]; m.App != "Google Meet" || m.Sessions != 1 || m.Attendees != nil {
+		t.Errorf("Meet: got %+v", m)
+	}
+}
+
 func TestInactivity(t *testing.T) {
 	tr, _ := tempTracker(t)
 	defer tr.Close()
@@ -326,3 +439,646 @@ func TestBridgeThreshold_ExactlyAtBoundary(t *testing.T) {
 		t.Errorf("expected 1 session (30s gap should bridge), got %d", n)
 	}
 }
+
+func TestFocusChange_EndsSessionAtSwitch(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Now().Truncate(time.Second)
+
+	// Ticks at 0..11s, then the switch event arrives between ticks at 11.6s
+	for i := 0; i < 12; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", base.Add(time.Duration(i)*time.Second))
+	}
+	switchAt := base.Add(11600 * time.Millisecond)
+	tr.RecordFocusChange("HOST", "user", "chrome.exe", "Google", switchAt)
+	for i := 12; i < 25; i++ {
+		tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(time.Duration(i)*time.Second))
+	}
+	tr.RecordFocusChange("HOST", "user", "notepad.exe", "Untitled", base.Add(24300*time.Millisecond))
+
+	rows, err := tr.db.Query("SELECT process_name, duration_seconds FROM focus_events ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	want := []struct {
+		proc string
+		dur  float64
+	}{{"acad.exe", 11.6}, {"chrome.exe", 12.7}}
+	i := 0
+	for rows.Next() {
+		var proc string
+		var dur float64
+		rows.Scan(&proc, &dur)
+		if i >= len(want) || proc != want[i].proc || math.Abs(dur-want[i].dur) > 1e-6 {
+			t.Errorf("session %d: got %s %.3fs", i, proc, dur)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Errorf("expected %d sessions, got %d", len(want), i)
+	}
+}
+
+func TestFocusChange_TitleChangeKeepsSession(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Now().Truncate(time.Second)
+
+	tr.RecordFocusChange("HOST", "user", "acad.exe", "drawing.dwg", base)
+	tr.RecordFocusChange("HOST", "user", "acad.exe", "drawing.dwg *", base.Add(5*time.Second))
+	tr.RecordFocusChange("HOST", "user", "chrome.exe", "Google", base.Add(15*time.Second))
+
+	n := countRows(t, tr.db, "focus_events")
+	if n != 1 {
+		t.Fatalf("expected 1 session, got %d", n)
+	}
+	var dur float64
+	var title string
+	tr.db.QueryRow("SELECT duration_seconds, window_title FROM focus_events").Scan(&dur, &title)
+	if dur != 15 || title != "drawing.dwg *" {
+		t.Errorf("expected 15s ending with latest title, got %.1fs %q", dur, title)
+	}
+}
+
+func TestFocusChange_LateEventMovesStart(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Now().Truncate(time.Second)
+
+	// The tick sees chrome first; the event for the switch 400ms earlier lands after it
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base)
+	tr.RecordFocusChange("HOST", "user", "chrome.exe", "Google", base.Add(-400*time.Millisecond))
+	for i := 1; i < 12; i++ {
+		tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(time.Duration(i)*time.Second))
+	}
+	tr.RecordFocus("HOST", "user", "notepad.exe", "Untitled", base.Add(20*time.Second))
+
+	var dur float64
+	tr.db.QueryRow("SELECT duration_seconds FROM focus_events").Scan(&dur)
+	if math.Abs(dur-11.4) > 1e-6 {
+		t.Errorf("expected 11.4s session, got %f", dur)
+	}
+}
+
+// kill drops a Tracker without Close, as a crash or power loss would.
+func kill(tr *Tracker) {
+	tr.db.Close()
+}
+
+func TestPendingCheckpoint_RecoveredAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	// 100 seconds of acad; checkpoints at 0, 15, ..., 90
+	for i := 0; i <= 100; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "25-125_SLD-E101.dwg - AutoCAD", base.Add(time.Duration(i)*time.Second))
+	}
+	kill(tr)
+
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Back much later in another window: the old session ends at its checkpoint
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(time.Hour))
+	defer tr.Close()
+
+	var (
+		ended   time.Time
+		dur     float64
+		projNum sql.NullString
+	)
+	if err := tr.db.QueryRow(`SELECT ended_at, duration_seconds, project_number FROM focus_events`).Scan(&ended, &dur, &projNum); err != nil {
+		t.Fatal(err)
+	}
+	if !ended.Equal(base.Add(90*time.Second)) || dur != 90 || projNum.String != "25-125" {
+		t.Errorf("unexpected recovered session: ended %v, %.0fs, project %q", ended, dur, projNum.String)
+	}
+}
+
+func TestPendingCheckpoint_ContinuesAfterQuickRestart(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for i := 0; i <= 20; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", base.Add(time.Duration(i)*time.Second))
+	}
+	kill(tr)
+
+	// Restarted within the bridge of the last checkpoint (15s)
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 40; i <= 60; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", base.Add(time.Duration(i)*time.Second))
+	}
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(61*time.Second))
+
+	if n := countRows(t, tr.db, "focus_events"); n != 1 {
+		t.Errorf("expected the session to carry on, got %d rows", n)
+	}
+	tr.Close()
+}
+
+func TestPendingCheckpoint_ClearedOnFlush(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for i := 0; i <= 30; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", base.Add(time.Duration(i)*time.Second))
+	}
+	if n := countRows(t, tr.db, "pending_session"); n != 1 {
+		t.Errorf("expected a checkpoint while the session is open, got %d", n)
+	}
+	tr.Close()
+
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	if tr.pending != nil || countRows(t, tr.db, "pending_session") != 0 {
+		t.Error("checkpoint left behind after Close")
+	}
+	if n := countRows(t, tr.db, "focus_events"); n != 1 {
+		t.Errorf("expected 1 session, got %d", n)
+	}
+}
+
+func TestSystemEvents_LockEndsSessionAndInactivity(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+
+	for i := 0; i < 600; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", at(i))
+	}
+	tr.RecordInactivityStart(at(300))
+	tr.RecordSystemEvent("HOST", "user", "lock", at(600))
+
+	// A screen locker that leaves the window in front is ignored until unlock
+	for i := 601; i < 2400; i += 10 {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", at(i))
+		tr.RecordInactivityStart(at(i))
+	}
+	tr.RecordSystemEvent("HOST", "user", "unlock", at(2400))
+	for i := 2400; i <= 2460; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", at(i))
+		tr.RecordInactivityEnd("HOST", "user", at(i))
+	}
+	tr.RecordFocus("HOST", "user", "OUTLOOK.EXE", "Inbox", at(2461))
+
+	var durations []float64
+	rows, err := tr.db.Query("SELECT duration_seconds FROM focus_events ORDER BY started_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var d float64
+		rows.Scan(&d)
+		durations = append(durations, d)
+	}
+	rows.Close()
+	if len(durations) != 2 || durations[0] != 600 || durations[1] != 60 {
+		t.Errorf("expected sessions of 600s (ending at the lock) and 60s, got %v", durations)
+	}
+
+	var inactive float64
+	tr.db.QueryRow("SELECT SUM(duration_seconds) FROM inactivity_periods").Scan(&inactive)
+	if inactive != 300 {
+		t.Errorf("expected 300s of inactivity ending at the lock, got %v", inactive)
+	}
+	if n := countRows(t, tr.db, "system_events"); n != 2 {
+		t.Errorf("expected 2 system events, got %d", n)
+	}
+}
+
+func TestSystemEvents_AwayUntilUnlockedAndAwake(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tr.RecordSystemEvent("HOST", "user", "lock", base)
+	tr.RecordSystemEvent("HOST", "user", "suspend", base.Add(time.Minute))
+	tr.RecordSystemEvent("HOST", "user", "resume", base.Add(time.Hour))
+
+	// Still at the lock screen after resuming
+	for i := 0; i < 30; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", base.Add(time.Hour+time.Duration(i)*time.Second))
+	}
+	if tr.pending != nil {
+		t.Fatalf("session started while locked: %+v", tr.pending)
+	}
+
+	tr.RecordSystemEvent("HOST", "user", "unlock", base.Add(2*time.Hour))
+	tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", base.Add(2*time.Hour))
+	if tr.pending == nil || !tr.pending.startedAt.Equal(base.Add(2*time.Hour)) {
+		t.Errorf("expected a session from the unlock, got %+v", tr.pending)
+	}
+
+	tr.RecordSystemEvent("HOST", "user", "hibernate", base.Add(3*time.Hour))
+	if n := countRows(t, tr.db, "system_events"); n != 4 {
+		t.Errorf("expected 4 system events (unknown one skipped), got %d", n)
+	}
+}
+
+func TestIdle_SubtractedFromSession(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+
+	// Idle 120–300 and again from 500 until the switch at 600
+	for i := 0; i < 600; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", at(i))
+		if (i >= 120 && i < 300) || i >= 500 {
+			tr.RecordInactivityStart(at(i))
+		} else {
+			tr.RecordInactivityEnd("HOST", "user", at(i))
+		}
+	}
+	tr.RecordFocus("HOST", "user", "ms-teams.exe", "Meeting 25-019 Design Review", at(600))
+	tr.RecordInactivityStart(at(600))
+	for i := 601; i <= 700; i++ {
+		tr.RecordFocus("HOST", "user", "ms-teams.exe", "Meeting 25-019 Design Review", at(i))
+	}
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", at(701))
+
+	var dur, idle float64
+	tr.db.QueryRow("SELECT duration_seconds, idle_seconds FROM focus_events WHERE process_name = 'acad.exe'").Scan(&dur, &idle)
+	if dur != 599 || idle != 180+99 {
+		t.Errorf("expected 599s with 279s idle, got %.0fs with %.0fs idle", dur, idle)
+	}
+	tr.db.QueryRow("SELECT idle_seconds FROM focus_events WHERE process_name = 'ms-teams.exe'").Scan(&idle)
+	if idle != 0 {
+		t.Errorf("meetings should not count idle time, got %.0fs", idle)
+	}
+}
+
+func TestIdle_KeptInCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+	for i := 0; i <= 90; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", at(i))
+		if i == 30 {
+			tr.RecordInactivityStart(at(i))
+		}
+		if i == 60 {
+			tr.RecordInactivityEnd("HOST", "user", at(i))
+		}
+	}
+	kill(tr)
+
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(time.Hour))
+
+	var idle float64
+	tr.db.QueryRow("SELECT idle_seconds FROM focus_events").Scan(&idle)
+	if idle != 30 {
+		t.Errorf("expected 30s idle to survive the crash, got %.0fs", idle)
+	}
+}
+
+func TestTitles_SplitOnAttributionChange(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Now().Truncate(time.Second)
+	titles := []string{
+		"25-125_SLD-E101.dwg - AutoCAD",
+		"Open - AutoCAD", // no project: stays in the 25-125 session
+		"25-125_SLD-E102.dwg - AutoCAD",
+		"25-130_PLAN.dwg - AutoCAD",
+	}
+	for i, title := range titles {
+		for j := 0; j < 12; j++ {
+			tr.RecordFocus("HOST", "user", "acad.exe", title, base.Add(time.Duration(i*12+j)*time.Second))
+		}
+	}
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(50*time.Second))
+
+	rows, err := tr.db.Query("SELECT id, project_number, duration_seconds FROM focus_events ORDER BY started_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type session struct {
+		id   int64
+		proj string
+		dur  float64
+	}
+	var got []session
+	for rows.Next() {
+		var s session
+		rows.Scan(&s.id, &s.proj, &s.dur)
+		got = append(got, s)
+	}
+	rows.Close()
+	if len(got) != 2 || got[0].proj != "25-125" || got[0].dur != 35 || got[1].proj != "25-130" || got[1].dur != 11 {
+		t.Fatalf("expected 35s on 25-125 then 11s on 25-130, got %+v", got)
+	}
+
+	var seen []string
+	rows, err = tr.db.Query("SELECT title FROM focus_event_titles WHERE focus_event_id = ? ORDER BY id", got[0].id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var title string
+		rows.Scan(&title)
+		seen = append(seen, title)
+	}
+	rows.Close()
+	if len(seen) != 3 || seen[0] != titles[0] || seen[1] != titles[1] || seen[2] != titles[2] {
+		t.Errorf("expected the first three titles, got %q", seen)
+	}
+}
+
+func TestTitles_FocusChangeSplitsAtSwitch(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Now().Truncate(time.Second)
+	tr.RecordFocusChange("HOST", "user", "acad.exe", "25-125_SLD-E101.dwg - AutoCAD", base)
+	tr.RecordFocusChange("HOST", "user", "acad.exe", "25-130_PLAN.dwg - AutoCAD", base.Add(20*time.Second))
+	tr.RecordFocusChange("HOST", "user", "chrome.exe", "Google", base.Add(45*time.Second))
+
+	var durs []float64
+	rows, err := tr.db.Query("SELECT duration_seconds FROM focus_events ORDER BY started_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var d float64
+		rows.Scan(&d)
+		durs = append(durs, d)
+	}
+	rows.Close()
+	if len(durs) != 2 || durs[0] != 20 || durs[1] != 25 {
+		t.Errorf("expected sessions of 20s and 25s, got %v", durs)
+	}
+}
+
+func TestTitles_KeptInCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for i := 0; i <= 30; i++ {
+		title := "25-125_SLD-E101.dwg - AutoCAD"
+		if i >= 10 {
+			title = "25-125_SLD-E102.dwg - AutoCAD"
+		}
+		tr.RecordFocus("HOST", "user", "acad.exe", title, base.Add(time.Duration(i)*time.Second))
+	}
+	kill(tr)
+
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(time.Hour))
+
+	if n := countRows(t, tr.db, "focus_event_titles"); n != 2 {
+		t.Errorf("expected both titles to survive the crash, got %d", n)
+	}
+}
+
+func TestProfiles_SameStreamDifferentSessions(t *testing.T) {
+	// 60s of AutoCAD, 45s in a plot dialog's host process that is never
+	// tracked, 60s more AutoCAD, a 5s call in the dialer, then OneDrive's
+	// sign-in window for 15s
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	play := func(tr *Tracker) {
+		at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+		for i := 0; i < 60; i++ {
+			tr.RecordFocus("HOST", "user", "acad.exe", "25-125_SLD-E101.dwg - AutoCAD", at(i))
+		}
+		for i := 60; i < 105; i++ {
+			tr.RecordFocus("HOST", "user", "applicationframehost.exe", "Plot", at(i))
+		}
+		for i := 105; i < 165; i++ {
+			tr.RecordFocus("HOST", "user", "acad.exe", "25-125_SLD-E101.dwg - AutoCAD", at(i))
+		}
+		for i := 165; i < 171; i++ {
+			tr.RecordFocus("HOST", "user", "phone.exe", "Call", at(i))
+		}
+		for i := 171; i < 186; i++ {
+			tr.RecordFocus("HOST", "user", "OneDrive.exe", "Sign in", at(i))
+		}
+		tr.RecordFocus("HOST", "user", "chrome.exe", "Google", at(200))
+	}
+	sessions := func(tr *Tracker) []string {
+		rows, err := tr.db.Query("SELECT process_name, duration_seconds FROM focus_events ORDER BY started_at")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var got []string
+		for rows.Next() {
+			var proc string
+			var dur float64
+			rows.Scan(&proc, &dur)
+			got = append(got, fmt.Sprintf("%s %.0fs", proc, dur))
+		}
+		return got
+	}
+	d := func(v time.Duration) *profiles.Duration { x := profiles.Duration(v); return &x }
+	custom, err := profiles.New([]profiles.Profile{
+		{Name: "cad", Process: `^acad\.exe$`, Bridge: d(2 * time.Minute)},
+		{Name: "dialer", Process: `^phone\.exe$`, Minimum: d(2 * time.Second)},
+		{Name: "helpers", Process: `^onedrive\.exe$`, Suppress: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name string
+		set  *profiles.Set
+		want []string
+	}{
+		// The dialog gap splits AutoCAD, the call is too short to keep
+		{"default", profiles.Default(), []string{"acad.exe 59s", "acad.exe 59s", "OneDrive.exe 14s"}},
+		// AutoCAD bridges the dialog; the call is kept and OneDrive is not
+		{"custom", custom, []string{"acad.exe 164s", "phone.exe 5s"}},
+	} {
+		tr, _ := tempTracker(t)
+		tr.SetProfiles(c.set)
+		play(tr)
+		got := sessions(tr)
+		tr.Close()
+		if strings.Join(got, ", ") != strings.Join(c.want, ", ") {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestURLs_AttributeBrowserSessions(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := rules.New([]rules.Rule{{Domain: `\.atlassian\.net$`, URL: `/browse/([A-Z]+)-\d+`, Project: "$1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two issues in different projects whose tabs have the same title
+	url := "https://acme.atlassian.net/browse/OPS-17"
+	urls := func(process, title string) string {
+		if process != "chrome.exe" {
+			return ""
+		}
+		return url
+	}
+	tr.SetRules(r)
+	tr.SetURLs(urls)
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for i := 0; i <= 30; i++ {
+		if i == 15 {
+			url = "https://acme.atlassian.net/browse/PRJ-4412"
+		}
+		tr.RecordFocus("HOST", "user", "chrome.exe", "Jira - Google Chrome", base.Add(time.Duration(i)*time.Second))
+	}
+	// The second survives a crash with its URL
+	kill(tr)
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	tr.SetRules(r)
+	tr.SetURLs(urls)
+	tr.RecordFocus("HOST", "user", "acad.exe", "Drawing1.dwg - AutoCAD", base.Add(31*time.Second))
+
+	rows, err := tr.db.Query("SELECT f.project_number, f.url, t.url FROM focus_events f JOIN focus_event_titles t ON t.focus_event_id = f.id ORDER BY f.started_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		var proj, url, titleURL string
+		rows.Scan(&proj, &url, &titleURL)
+		got = append(got, proj+" "+url+" "+titleURL)
+	}
+	rows.Close()
+	want := []string{
+		"OPS https://acme.atlassian.net/browse/OPS-17 https://acme.atlassian.net/browse/OPS-17",
+		"PRJ https://acme.atlassian.net/browse/PRJ-4412 https://acme.atlassian.net/browse/PRJ-4412",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWindowDetails_SessionsByApp(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetRawLog(true, 0)
+	r, err := rules.New([]rules.Rule{{App: `^java:loadcalc\.jar$`, Project: "25-125"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetRules(r)
+
+	// Two Java tools in a row, with the same process and window class
+	loadCalc := Window{
+		Process: "javaw.exe", Title: "Load Calculator", ExePath: `C:\Java\bin\javaw.exe`, Class: "SunAwtFrame",
+		CommandLine: `javaw.exe -Ddb.password=hunter2 -jar C:\Tools\loadcalc.jar --token abc123`,
+	}
+	drafting := loadCalc
+	drafting.Title, drafting.CommandLine = "Drafting", `javaw.exe -jar C:\Tools\draft.jar`
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		w := loadCalc
+		if i >= 30 {
+			w = drafting
+		}
+		if i == 30 {
+			tr.SetCommandLines(true, []*regexp.Regexp{regexp.MustCompile(`C:\\Tools\\`)})
+		}
+		tr.RecordWindow("HOST", "user", w, base.Add(time.Duration(i)*time.Second))
+	}
+	tr.RecordFocus("HOST", "user", "acad.exe", "Drawing1.dwg - AutoCAD", base.Add(time.Minute))
+	tr.Close()
+
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, table := range []string{"focus_events", "raw_events"} {
+		rows, err := tr.db.Query(`SELECT app, exe_path, window_class, COALESCE(command_line, '') FROM ` + table + ` WHERE process_name = 'javaw.exe' ORDER BY started_at`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var app, exe, class, cmd string
+			rows.Scan(&app, &exe, &class, &cmd)
+			got = append(got, fmt.Sprintf("%s %s %s %q", app, exe, class, cmd))
+		}
+		rows.Close()
+	}
+	tr.Close()
+	want := []string{
+		`java:loadcalc.jar C:\Java\bin\javaw.exe SunAwtFrame ""`,
+		`java:draft.jar C:\Java\bin\javaw.exe SunAwtFrame "javaw.exe -jar ***draft.jar"`,
+	}
+	want = append(want, want...)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	raw, err := GetWeeklySummary(dir, base, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary WeeklySummary
+	json.Unmarshal(raw, &summary)
+	if len(summary.Attributed) != 1 || summary.Attributed[0].ProjectNumber != "25-125" ||
+		len(summary.Attributed[0].Processes) != 1 || summary.Attributed[0].Processes[0] != "java:loadcalc.jar" {
+		t.Errorf("attributed: got %+v", summary.Attributed)
+	}
+	if len(summary.Unattributed) != 1 || summary.Unattributed[0].Process != "java:draft.jar" {
+		t.Errorf("unattributed: got %+v", summary.Unattributed)
+	}
+
+	// Rebuilding from the raw log keeps the two apps apart
+	report, err := Resessionize(dir, profiles.Default(), r, time.Time{}, time.Time{}, true)
+	if err != nil || report.After.Sessions != 2 {
+		t.Errorf("resessionize: got %+v, %v", report, err)
+	}
+}
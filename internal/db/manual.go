@@ -0,0 +1,300 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ManualEntry is time logged by hand rather than tracked, such as a site
+// visit or a phone call. Entries live in the file of the machine they were
+// logged on and are identified across files as "hostname:mN", the m keeping
+// them apart from session IDs.
+type ManualEntry struct {
+	ID          string  `json:"id"`
+	Project     string  `json:"project_number"`
+	Client      string  `json:"client,omitempty"`
+	Task        string  `json:"task,omitempty"`
+	Category    string  `json:"category,omitempty"`
+	Description string  `json:"description"`
+	Start       string  `json:"start"`
+	Minutes     float64 `json:"minutes"`
+
+	start, end time.Time
+}
+
+// ManualInput describes a new entry, or the fields to change in an existing
+// one. For edits, nil fields are left as they are.
+type ManualInput struct {
+	Project     *string
+	Client      *string
+	Task        *string
+	Category    *string
+	Description *string
+	Start       *time.Time
+	Minutes     *float64
+}
+
+const manualColumns = `id, hostname, project_number, COALESCE(client, ''), COALESCE(task, ''), COALESCE(category, ''), unseal(description), started_at, duration_seconds`
+
+// AddManualEntry logs a manual entry in this machine's DB file. Project,
+// Start and Minutes are required.
+func AddManualEntry(dbpath string, in ManualInput) (*ManualEntry, error) {
+	if in.Project == nil || *in.Project == "" {
+		return nil, fmt.Errorf("project is required")
+	}
+	if in.Start == nil {
+		return nil, fmt.Errorf("start is required")
+	}
+	if in.Minutes == nil {
+		return nil, fmt.Errorf("minutes is required")
+	}
+	if err := validMinutes(*in.Minutes); err != nil {
+		return nil, err
+	}
+
+	d, hostname, err := openLocal(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	s, err := fileSealer(d)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := s.seal(deref(in.Description))
+	if err != nil {
+		return nil, err
+	}
+
+	start := in.Start.UTC()
+	dur := time.Duration(*in.Minutes * float64(time.Minute))
+	now := time.Now().UTC()
+	res, err := d.Exec(
+		`INSERT INTO manual_entries (hostname, username, project_number, client, task, category, description, started_at, ended_at, duration_seconds, created_at, updated_at) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
+		hostname, CurrentUser(), *in.Project, nullable(deref(in.Client)), nullable(deref(in.Task)), nullable(deref(in.Category)),
+		desc, start, start.Add(dur), dur.Seconds(), now, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("db: manual insert: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("db: manual insert: %w", err)
+	}
+	return getManualEntry(d, id)
+}
+
+// EditManualEntry changes the fields set in in on the entry with the given
+// "hostname:mN" ID. Only entries logged on this machine can be edited.
+func EditManualEntry(dbpath, id string, in ManualInput) (*ManualEntry, error) {
+	if in.Project != nil && *in.Project == "" {
+		return nil, fmt.Errorf("project cannot be empty")
+	}
+	if in.Minutes != nil {
+		if err := validMinutes(*in.Minutes); err != nil {
+			return nil, err
+		}
+	}
+
+	d, n, err := openManualEntry(dbpath, id)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	cur, err := getManualEntry(d, n)
+	if err != nil {
+		return nil, err
+	}
+	s, err := fileSealer(d)
+	if err != nil {
+		return nil, err
+	}
+	start, err := time.Parse(time.RFC3339, cur.Start)
+	if err != nil {
+		return nil, fmt.Errorf("db: manual entry %s: %w", id, err)
+	}
+	minutes := cur.Minutes
+	if in.Start != nil {
+		start = in.Start.UTC()
+	}
+	if in.Minutes != nil {
+		minutes = *in.Minutes
+	}
+	dur := time.Duration(minutes * float64(time.Minute))
+
+	set := func(p *string, old string) string {
+		if p != nil {
+			return *p
+		}
+		return old
+	}
+	desc, err := s.seal(set(in.Description, cur.Description))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Exec(
+		`UPDATE manual_entries SET project_number = ?, client = ?, task = ?, category = ?, description = ?, started_at = ?, ended_at = ?, duration_seconds = ?, updated_at = ? WHERE id = ?`,
+		set(in.Project, cur.Project), nullable(set(in.Client, cur.Client)), nullable(set(in.Task, cur.Task)), nullable(set(in.Category, cur.Category)),
+		desc, start, start.Add(dur), dur.Seconds(), time.Now().UTC(), n,
+	); err != nil {
+		return nil, fmt.Errorf("db: manual update: %w", err)
+	}
+	return getManualEntry(d, n)
+}
+
+// DeleteManualEntry removes the entry with the given "hostname:mN" ID. Only
+// entries logged on this machine can be deleted.
+func DeleteManualEntry(dbpath, id string) error {
+	d, n, err := openManualEntry(dbpath, id)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if _, err := d.Exec(`DELETE FROM manual_entries WHERE id = ?`, n); err != nil {
+		return fmt.Errorf("db: manual delete: %w", err)
+	}
+	return nil
+}
+
+// ListManualEntries returns the manual entries from every machine that
+// overlap [dateFrom, dateTo), oldest first.
+func ListManualEntries(dbpath string, dateFrom, dateTo time.Time) ([]ManualEntry, error) {
+	dbs, err := openAllDBs(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(dbs)
+
+	fromStr := dateFrom.UTC().Format("2006-01-02 15:04:05")
+	toStr := dateTo.UTC().Format("2006-01-02 15:04:05")
+
+	entries := []ManualEntry{}
+	for _, db := range dbs {
+		entries = append(entries, queryManualEntries(db, fromStr, toStr)...)
+	}
+	sortManualEntries(entries)
+	return entries, nil
+}
+
+// queryManualEntries reads the entries in one file that overlap
+// [startStr, endStr).
+func queryManualEntries(d *sql.DB, startStr, endStr string) []ManualEntry {
+	rows, err := d.Query(`SELECT `+manualColumns+` FROM manual_entries WHERE started_at < ? AND ended_at > ?`, endStr, startStr)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var entries []ManualEntry
+	for rows.Next() {
+		if e, err := scanManualEntry(rows); err == nil {
+			entries = append(entries, *e)
+		}
+	}
+	return entries
+}
+
+func getManualEntry(d *sql.DB, id int64) (*ManualEntry, error) {
+	e, err := scanManualEntry(d.QueryRow(`SELECT `+manualColumns+` FROM manual_entries WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("db: manual entry %d: %w", id, err)
+	}
+	return e, nil
+}
+
+func scanManualEntry(row interface{ Scan(...any) error }) (*ManualEntry, error) {
+	var (
+		e        ManualEntry
+		n        int64
+		hostname string
+		start    time.Time
+		secs     float64
+	)
+	if err := row.Scan(&n, &hostname, &e.Project, &e.Client, &e.Task, &e.Category, &e.Description, &start, &secs); err != nil {
+		return nil, err
+	}
+	e.ID = fmt.Sprintf("%s:m%d", hostname, n)
+	e.start = start.UTC()
+	e.end = e.start.Add(time.Duration(secs * float64(time.Second)))
+	e.Start = e.start.Format(time.RFC3339)
+	e.Minutes = round1(secs / 60.0)
+	return &e, nil
+}
+
+// openManualEntry opens this machine's file for an edit to the entry with
+// the given ID, refusing entries logged elsewhere.
+func openManualEntry(dbpath, id string) (*sql.DB, int64, error) {
+	host, n, err := parseEntryID(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	d, hostname, err := openLocal(dbpath)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !strings.EqualFold(host, hostname) {
+		d.Close()
+		return nil, 0, fmt.Errorf("entry %s was logged on %s; edit it from that machine", id, host)
+	}
+	var exists int
+	if err := d.QueryRow(`SELECT COUNT(*) FROM manual_entries WHERE id = ?`, n).Scan(&exists); err != nil || exists == 0 {
+		d.Close()
+		return nil, 0, fmt.Errorf("no manual entry %s", id)
+	}
+	return d, n, nil
+}
+
+// ParseEntryStart combines an ISO date and an optional HH:MM start time in
+// loc into the start of a manual entry. Without a time the entry starts at
+// midnight.
+func ParseEntryStart(date, clock string, loc *time.Location) (time.Time, error) {
+	d, err := ParseDate(date, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if clock == "" {
+		return d, nil
+	}
+	c, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time (want HH:MM): %w", err)
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), c.Hour(), c.Minute(), 0, 0, loc), nil
+}
+
+// parseEntryID splits a "hostname:mN" entry ID.
+func parseEntryID(id string) (string, int64, error) {
+	i := strings.LastIndex(id, ":")
+	if i <= 0 || !strings.HasPrefix(id[i+1:], "m") {
+		return "", 0, fmt.Errorf("invalid entry id %q (want hostname:mN)", id)
+	}
+	n, err := strconv.ParseInt(id[i+2:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid entry id %q (want hostname:mN)", id)
+	}
+	return id[:i], n, nil
+}
+
+func validMinutes(m float64) error {
+	if m <= 0 || m > 24*60 {
+		return fmt.Errorf("minutes must be between 0 and 1440, got %g", m)
+	}
+	return nil
+}
+
+func sortManualEntries(entries []ManualEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Start < entries[j].Start })
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
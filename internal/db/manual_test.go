@@ -0,0 +1,136 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func strp(s string) *string        { return &s }
+func minp(m float64) *float64      { return &m }
+func timep(t time.Time) *time.Time { return &t }
+
+func TestManualEntry_AddEditDelete(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+
+	e, err := AddManualEntry(dir, ManualInput{
+		Project:     strp("25-125"),
+		Description: strp("Site visit"),
+		Start:       timep(start),
+		Minutes:     minp(90),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	if !strings.HasPrefix(e.ID, hostname+":m") || e.Minutes != 90 || e.Start != "2026-03-03T08:00:00Z" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	e, err = EditManualEntry(dir, e.ID, ManualInput{Minutes: minp(120), Task: strp("Inspection")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Minutes != 120 || e.Task != "Inspection" || e.Description != "Site visit" || e.Project != "25-125" {
+		t.Errorf("edit did not keep unchanged fields: %+v", e)
+	}
+
+	entries, err := ListManualEntries(dir, start.Add(-time.Hour), start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != e.ID {
+		t.Errorf("expected the edited entry, got %+v", entries)
+	}
+
+	if err := DeleteManualEntry(dir, e.ID); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = ListManualEntries(dir, start.Add(-time.Hour), start.Add(time.Hour))
+	if len(entries) != 0 {
+		t.Errorf("expected no entries after delete, got %d", len(entries))
+	}
+	if err := DeleteManualEntry(dir, e.ID); err == nil {
+		t.Error("expected error deleting a missing entry")
+	}
+}
+
+func TestManualEntry_Validation(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+
+	if _, err := AddManualEntry(dir, ManualInput{Start: timep(start), Minutes: minp(30)}); err == nil {
+		t.Error("expected error without project")
+	}
+	if _, err := AddManualEntry(dir, ManualInput{Project: strp("25-125"), Start: timep(start), Minutes: minp(0)}); err == nil {
+		t.Error("expected error for zero minutes")
+	}
+	if _, err := EditManualEntry(dir, "not-an-id", ManualInput{}); err == nil {
+		t.Error("expected error for malformed id")
+	}
+}
+
+func TestManualEntry_OtherMachineReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "LAPTOP-TEST")
+
+	err := DeleteManualEntry(dir, "LAPTOP-TEST:m1")
+	if err == nil || !strings.Contains(err.Error(), "LAPTOP-TEST") {
+		t.Errorf("expected refusal naming the other machine, got %v", err)
+	}
+}
+
+func TestManualEntry_MergedIntoSummaries(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	// Tuesday: a site visit on the seeded project and a call on a new one
+	tue := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+	if _, err := AddManualEntry(dir, ManualInput{Project: strp("25-125"), Description: strp("Site visit"), Start: timep(tue), Minutes: minp(60)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddManualEntry(dir, ManualInput{Project: strp("26-001"), Description: strp("Phone call"), Start: timep(tue.Add(2 * time.Hour)), Minutes: minp(15)}); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := GetWeeklySummary(dir, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary WeeklySummary
+	json.Unmarshal(raw, &summary)
+
+	byProj := map[string]AttributedProject{}
+	for _, a := range summary.Attributed {
+		byProj[a.ProjectNumber] = a
+	}
+	// 120 + 30 tracked, 60 manual
+	if p := byProj["25-125"]; p.TotalMinutes != 210 || p.ManualMinutes != 60 {
+		t.Errorf("25-125: expected 210 total / 60 manual, got %+v", p)
+	}
+	if p := byProj["26-001"]; p.TotalMinutes != 15 || p.ManualMinutes != 15 {
+		t.Errorf("26-001: expected 15 manual minutes, got %+v", p)
+	}
+	if len(summary.Manual) != 2 || summary.Manual[0].Description != "Site visit" {
+		t.Errorf("expected manual entries listed, got %+v", summary.Manual)
+	}
+
+	raw, err = GetDailyBreakdown(dir, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var daily DailyBreakdown
+	json.Unmarshal(raw, &daily)
+	if len(daily.Days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(daily.Days))
+	}
+	if len(daily.Days[0].Manual) != 0 || len(daily.Days[1].Manual) != 2 {
+		t.Errorf("manual entries on wrong day: %+v", daily.Days)
+	}
+	if daily.Days[1].TotalMinutes != 75 {
+		t.Errorf("expected Tuesday total 75, got %.1f", daily.Days[1].TotalMinutes)
+	}
+}
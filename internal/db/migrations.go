@@ -0,0 +1,657 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A DB folder is shared by every machine, and each may run a different
+// version of timewarp. Files are brought up to date in two ways:
+//
+//   - openLocal applies the migrations below to this machine's own file,
+//     recording each in schema_version.
+//   - openAllDBs never writes to other machines' files. Instead, compat
+//     shadows any tables or columns an older file lacks with empty
+//     temporary ones, so queries can rely on the latest schema.
+//
+// Migrations are only ever appended. Each must also cope with a file that
+// already has some of its changes, as files from before schema_version
+// have no record of what they contain.
+
+// migration is one step of the schema. version is its position in
+// migrations, starting at 1.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{1, "focus, inactivity and meeting tables", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS focus_events (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				username        TEXT NOT NULL,
+				process_name    TEXT NOT NULL,
+				window_title    TEXT NOT NULL,
+				project_number  TEXT,
+				started_at      DATETIME NOT NULL,
+				ended_at        DATETIME NOT NULL,
+				duration_seconds REAL NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS inactivity_periods (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				username        TEXT NOT NULL,
+				started_at      DATETIME NOT NULL,
+				ended_at        DATETIME NOT NULL,
+				duration_seconds REAL NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS meeting_sessions (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				username        TEXT NOT NULL,
+				process_name    TEXT NOT NULL,
+				subject         TEXT NOT NULL,
+				started_at      DATETIME NOT NULL,
+				ended_at        DATETIME NOT NULL,
+				duration_seconds REAL NOT NULL
+			)`,
+		)
+	}},
+	{2, "attribution columns and audit table", func(tx *sql.Tx) error {
+		for _, col := range []string{"client", "task", "category"} {
+			if err := addColumn(tx, "focus_events", col, "TEXT"); err != nil {
+				return err
+			}
+		}
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS attribution_changes (
+				id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+				focus_event_id     INTEGER NOT NULL,
+				changed_at         DATETIME NOT NULL,
+				old_project_number TEXT,
+				old_client         TEXT,
+				old_task           TEXT,
+				old_category       TEXT,
+				new_project_number TEXT,
+				new_client         TEXT,
+				new_task           TEXT,
+				new_category       TEXT
+			)`,
+		)
+	}},
+	{3, "session overrides", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS session_overrides (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				kind            TEXT NOT NULL,
+				target          TEXT NOT NULL,
+				project_number  TEXT,
+				split_at        DATETIME,
+				merge_into      TEXT,
+				created_at      DATETIME NOT NULL
+			)`,
+		)
+	}},
+	{4, "manual entries", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS manual_entries (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				username        TEXT NOT NULL,
+				project_number  TEXT NOT NULL,
+				client          TEXT,
+				task            TEXT,
+				category        TEXT,
+				description     TEXT NOT NULL,
+				started_at      DATETIME NOT NULL,
+				ended_at        DATETIME NOT NULL,
+				duration_seconds REAL NOT NULL,
+				created_at      DATETIME NOT NULL,
+				updated_at      DATETIME NOT NULL
+			)`,
+		)
+	}},
+	{5, "pending session checkpoint", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS pending_session (
+				id              INTEGER PRIMARY KEY CHECK (id = 1),
+				hostname        TEXT NOT NULL,
+				username        TEXT NOT NULL,
+				process_name    TEXT NOT NULL,
+				window_title    TEXT NOT NULL,
+				started_at      DATETIME NOT NULL,
+				last_seen       DATETIME NOT NULL
+			)`,
+		)
+	}},
+	{6, "system events", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS system_events (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				username        TEXT NOT NULL,
+				event           TEXT NOT NULL,
+				occurred_at     DATETIME NOT NULL
+			)`,
+		)
+	}},
+	{7, "idle time within sessions", func(tx *sql.Tx) error {
+		for _, table := range []string{"focus_events", "pending_session"} {
+			if err := addColumn(tx, table, "idle_seconds", "REAL"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{8, "session titles", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "pending_session", "titles", "TEXT"); err != nil {
+			return err
+		}
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS focus_event_titles (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				focus_event_id  INTEGER NOT NULL,
+				title           TEXT NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS focus_event_titles_event ON focus_event_titles (focus_event_id)`,
+		)
+	}},
+	{9, "raw focus log", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS raw_events (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				username        TEXT NOT NULL,
+				process_name    TEXT NOT NULL,
+				window_title    TEXT NOT NULL,
+				started_at      DATETIME NOT NULL,
+				ended_at        DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS raw_events_started ON raw_events (started_at)`,
+		)
+	}},
+	{10, "meeting app and attendees", func(tx *sql.Tx) error {
+		for _, col := range []string{"app", "attendees"} {
+			if err := addColumn(tx, "meeting_sessions", col, "TEXT"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{11, "calendar events", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS calendar_events (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				source          TEXT NOT NULL,
+				uid             TEXT NOT NULL,
+				subject         TEXT NOT NULL,
+				description     TEXT,
+				location        TEXT,
+				project_number  TEXT,
+				started_at      DATETIME NOT NULL,
+				ended_at        DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS calendar_events_started ON calendar_events (started_at)`,
+		)
+	}},
+	{12, "browser URLs", func(tx *sql.Tx) error {
+		for _, c := range []struct{ table, column string }{
+			{"focus_events", "url"},
+			{"focus_event_titles", "url"},
+			{"pending_session", "url"},
+			{"pending_session", "urls"},
+			{"raw_events", "url"},
+		} {
+			if err := addColumn(tx, c.table, c.column, "TEXT"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{13, "window details", func(tx *sql.Tx) error {
+		for _, table := range []string{"focus_events", "pending_session", "raw_events"} {
+			for _, col := range []string{"exe_path", "window_class", "command_line", "app"} {
+				if err := addColumn(tx, table, col, "TEXT"); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}},
+	{14, "encryption", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS encryption (
+				id          INTEGER PRIMARY KEY CHECK (id = 1),
+				key_id      TEXT NOT NULL,
+				enabled_at  DATETIME NOT NULL
+			)`,
+		)
+	}},
+	{15, "rollups", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS rollups (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				hour            DATETIME NOT NULL,
+				process_name    TEXT NOT NULL,
+				app             TEXT NOT NULL,
+				project_number  TEXT NOT NULL,
+				seconds         REAL NOT NULL,
+				idle_seconds    REAL NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS rollups_key ON rollups (hour, hostname, process_name, app, project_number)`,
+		)
+	}},
+	{16, "rollups of every session", func(tx *sql.Tx) error {
+		if err := execAll(tx,
+			`CREATE TABLE IF NOT EXISTS rollup_state (
+				id               INTEGER PRIMARY KEY CHECK (id = 1),
+				archived_before  DATETIME NOT NULL
+			)`,
+		); err != nil {
+			return err
+		}
+		// The rollups so far hold only sessions past their retention, which
+		// end before the hour after the last of them
+		var last time.Time
+		err := tx.QueryRow(`SELECT hour FROM rollups ORDER BY hour DESC LIMIT 1`).Scan(&last)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// Every stored session is added to the rollups, shared out over the
+		// hours it spans. This and the archiving below are written out here
+		// as they were at version 16, rather than calling the code that now
+		// keeps the rollups, so that later changes to it can't change what
+		// this migration does.
+		type session struct {
+			host, process, app, project string
+			start, end                  time.Time
+			seconds, idle               float64
+		}
+		var sessions []session
+		rows, err := tx.Query(`SELECT hostname, process_name, COALESCE(app, process_name), COALESCE(project_number, ''), started_at, ended_at, duration_seconds, COALESCE(idle_seconds, 0) FROM focus_events`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var s session
+			if err := rows.Scan(&s.host, &s.process, &s.app, &s.project, &s.start, &s.end, &s.seconds, &s.idle); err != nil {
+				rows.Close()
+				return err
+			}
+			sessions = append(sessions, s)
+		}
+		rows.Close()
+		for _, s := range sessions {
+			start, end := s.start.UTC(), s.end.UTC()
+			for h := start.Truncate(time.Hour); h.Before(end) || h.Equal(start); h = h.Add(time.Hour) {
+				share := 1.0
+				if end.After(start) {
+					from, to := start, end
+					if from.Before(h) {
+						from = h
+					}
+					if to.After(h.Add(time.Hour)) {
+						to = h.Add(time.Hour)
+					}
+					share = to.Sub(from).Seconds() / end.Sub(start).Seconds()
+				}
+				if share <= 0 {
+					continue
+				}
+				if _, err := tx.Exec(
+					`INSERT INTO rollups (hostname, hour, process_name, app, project_number, seconds, idle_seconds) VALUES (?,?,?,?,?,?,?)
+					ON CONFLICT (hour, hostname, process_name, app, project_number) DO UPDATE SET seconds = seconds + excluded.seconds, idle_seconds = idle_seconds + excluded.idle_seconds`,
+					s.host, h, s.process, s.app, s.project, s.seconds*share, s.idle*share); err != nil {
+					return err
+				}
+			}
+		}
+		if last.IsZero() {
+			return nil
+		}
+
+		// The sessions before the marker are then left to the rollups: those
+		// running over it are cut to start at it, and the rest deleted
+		marker := last.UTC().Add(time.Hour)
+		m := marker.Format("2006-01-02 15:04:05")
+		type cut struct {
+			id            int64
+			start, end    time.Time
+			seconds, idle float64
+		}
+		var cuts []cut
+		rows, err = tx.Query(`SELECT id, started_at, ended_at, duration_seconds, COALESCE(idle_seconds, 0) FROM focus_events WHERE started_at < ? AND ended_at > ?`, m, m)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var c cut
+			if err := rows.Scan(&c.id, &c.start, &c.end, &c.seconds, &c.idle); err != nil {
+				rows.Close()
+				return err
+			}
+			cuts = append(cuts, c)
+		}
+		rows.Close()
+		for _, c := range cuts {
+			keep := c.end.Sub(marker).Seconds() / c.end.Sub(c.start).Seconds()
+			if _, err := tx.Exec(`UPDATE focus_events SET started_at = ?, duration_seconds = ?, idle_seconds = ? WHERE id = ?`,
+				marker, c.seconds*keep, c.idle*keep, c.id); err != nil {
+				return err
+			}
+		}
+		for _, stmt := range []string{
+			`DELETE FROM focus_event_titles WHERE focus_event_id IN (SELECT id FROM focus_events WHERE started_at < ?)`,
+			`DELETE FROM attribution_changes WHERE focus_event_id IN (SELECT id FROM focus_events WHERE started_at < ?)`,
+			`DELETE FROM focus_events WHERE started_at < ?`,
+		} {
+			if _, err := tx.Exec(stmt, m); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(`INSERT INTO rollup_state (id, archived_before) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET archived_before = excluded.archived_before`, marker)
+		return err
+	}},
+	{17, "indexes and process keys", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "focus_events", "process_key", "TEXT"); err != nil {
+			return err
+		}
+		// Lowercased in Go rather than by SQLite, whose LOWER only folds
+		// ASCII, to match the tracker. The key is written out here as it was
+		// at version 17, rather than calling processKey, so that later
+		// changes to it can't change what this migration does.
+		rows, err := tx.Query(`SELECT id, process_name FROM focus_events WHERE process_key IS NULL`)
+		if err != nil {
+			return err
+		}
+		keys := map[int64]string{}
+		for rows.Next() {
+			var (
+				id      int64
+				process string
+			)
+			if err := rows.Scan(&id, &process); err != nil {
+				rows.Close()
+				return err
+			}
+			keys[id] = strings.ToLower(process)
+		}
+		rows.Close()
+		for id, key := range keys {
+			if _, err := tx.Exec(`UPDATE focus_events SET process_key = ? WHERE id = ?`, key, id); err != nil {
+				return err
+			}
+		}
+		return execAll(tx,
+			`CREATE INDEX IF NOT EXISTS focus_events_started ON focus_events (started_at)`,
+			`CREATE INDEX IF NOT EXISTS focus_events_ended ON focus_events (ended_at)`,
+			`CREATE INDEX IF NOT EXISTS focus_events_project ON focus_events (project_number)`,
+			`CREATE INDEX IF NOT EXISTS focus_events_process ON focus_events (process_key)`,
+			`CREATE INDEX IF NOT EXISTS focus_events_app ON focus_events (LOWER(app))`,
+			`CREATE INDEX IF NOT EXISTS meeting_sessions_started ON meeting_sessions (started_at)`,
+			`CREATE INDEX IF NOT EXISTS inactivity_periods_started ON inactivity_periods (started_at)`,
+			`CREATE INDEX IF NOT EXISTS system_events_occurred ON system_events (occurred_at)`,
+			`CREATE INDEX IF NOT EXISTS manual_entries_started ON manual_entries (started_at)`,
+		)
+	}},
+	{18, "meeting and inactivity rollups", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS meeting_rollups (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				hour            DATETIME NOT NULL,
+				process_name    TEXT NOT NULL,
+				app             TEXT NOT NULL,
+				seconds         REAL NOT NULL,
+				meetings        INTEGER NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS meeting_rollups_key ON meeting_rollups (hour, hostname, process_name, app)`,
+			`CREATE TABLE IF NOT EXISTS inactivity_rollups (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				hostname        TEXT NOT NULL,
+				hour            DATETIME NOT NULL,
+				seconds         REAL NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS inactivity_rollups_key ON inactivity_rollups (hour, hostname)`,
+		)
+	}},
+}
+
+// processKey is the form of a process name matched against, stored in
+// focus_events.process_key so that lookups by process can use its index.
+func processKey(process string) string {
+	return strings.ToLower(process)
+}
+
+// migrate applies the migrations db has not had yet, each in its own
+// transaction. It refuses a file from a newer version, which this one
+// could write rows to that the newer version does not expect.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version     INTEGER PRIMARY KEY,
+		name        TEXT NOT NULL,
+		applied_at  DATETIME NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("db: schema: %w", err)
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("db: schema version %d is newer than this build supports (%d); update timewarp", current, len(migrations))
+	}
+
+	for _, m := range migrations[current:] {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("db: migration %d: %w", m.version, err)
+		}
+		if err := m.up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("db: migration %d (%s): %w", m.version, m.name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?,?,?)`,
+			m.version, m.name, time.Now().UTC()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("db: migration %d: %w", m.version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("db: migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the last migration applied to db, or 0 for a file
+// from before schema_version.
+func schemaVersion(db querier) (int, error) {
+	var v sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&v); err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("db: schema version: %w", err)
+	}
+	return int(v.Int64), nil
+}
+
+// tableSchema is a table as the latest migration leaves it.
+type tableSchema struct {
+	create  string
+	columns []string
+}
+
+var (
+	latestOnce   sync.Once
+	latestTables map[string]tableSchema
+	latestErr    error
+)
+
+// latestSchema returns the tables of a fully migrated file, by migrating an
+// empty in-memory database.
+func latestSchema() (map[string]tableSchema, error) {
+	latestOnce.Do(func() {
+		d, err := sql.Open("sqlite", "file::memory:")
+		if err != nil {
+			latestErr = err
+			return
+		}
+		defer d.Close()
+		d.SetMaxOpenConns(1)
+		if latestErr = migrate(d); latestErr != nil {
+			return
+		}
+		latestTables, latestErr = tables(d, "main")
+	})
+	return latestTables, latestErr
+}
+
+// compat makes a read-only file at an older schema version look like the
+// latest one to queries on its connection. Missing tables are created empty
+// in the temp schema, and a table missing columns is shadowed by a temp view
+// that adds them as NULL; unqualified names resolve to temp objects first.
+// The file itself is not touched. d must be limited to one connection, as
+// temp objects belong to the connection that made them.
+func compat(d *sql.DB) error {
+	want, err := latestSchema()
+	if err != nil {
+		return fmt.Errorf("db: compat: %w", err)
+	}
+	have, err := tables(d, "main")
+	if err != nil {
+		return fmt.Errorf("db: compat: %w", err)
+	}
+
+	for name, t := range want {
+		if name == "schema_version" {
+			continue
+		}
+		existing, ok := have[name]
+		if !ok {
+			create := strings.Replace(t.create, "CREATE TABLE", "CREATE TEMP TABLE", 1)
+			if _, err := d.Exec(create); err != nil {
+				return fmt.Errorf("db: compat: %s: %w", name, err)
+			}
+			continue
+		}
+
+		var missing []string
+		for _, c := range t.columns {
+			if !containsFold(existing.columns, c) {
+				missing = append(missing, "NULL AS "+c)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		view := fmt.Sprintf("CREATE TEMP VIEW %s AS SELECT *, %s FROM main.%s", name, strings.Join(missing, ", "), name)
+		if _, err := d.Exec(view); err != nil {
+			return fmt.Errorf("db: compat: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// tables returns the tables in one schema of d with their columns.
+func tables(d querier, schema string) (map[string]tableSchema, error) {
+	rows, err := d.Query(fmt.Sprintf(`SELECT name, sql FROM %s.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%%'`, schema))
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]tableSchema{}
+	for rows.Next() {
+		var name, create string
+		if err := rows.Scan(&name, &create); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result[name] = tableSchema{create: create}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for name, t := range result {
+		if t.columns, err = columns(d, name); err != nil {
+			return nil, err
+		}
+		result[name] = t
+	}
+	return result, nil
+}
+
+// querier is what the schema helpers need from a *sql.DB or *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func execAll(q querier, stmts ...string) error {
+	for _, s := range stmts {
+		if _, err := q.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to table unless it is already there.
+func addColumn(q querier, table, column, typ string) error {
+	cols, err := columns(q, table)
+	if err != nil {
+		return err
+	}
+	if containsFold(cols, column) {
+		return nil
+	}
+	if _, err := q.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, typ)); err != nil {
+		return fmt.Errorf("add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// columns returns the names of table's columns.
+func columns(q querier, table string) ([]string, error) {
+	rows, err := q.Query(fmt.Sprintf("PRAGMA main.table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			ctype   string
+			notnull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name)
+	}
+	return cols, rows.Err()
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
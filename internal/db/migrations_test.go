@@ -0,0 +1,171 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/rules"
+)
+
+// loadFixture creates timewarp-<hostname>.db in dir from a testdata layout
+// and returns its path.
+func loadFixture(t *testing.T, dir, layout, hostname string) string {
+	t.Helper()
+	script, err := os.ReadFile(filepath.Join("testdata", layout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "timewarp-"+hostname+".db")
+	d, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.Exec(string(script)); err != nil {
+		t.Fatalf("%s: %v", layout, err)
+	}
+	return path
+}
+
+func TestMigrate_Fixtures(t *testing.T) {
+	want, err := latestSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, layout := range []string{"layout-original.sql", "layout-unversioned.sql"} {
+		t.Run(layout, func(t *testing.T) {
+			path := loadFixture(t, t.TempDir(), layout, "HOST")
+			d, err := sql.Open("sqlite", "file:"+path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+			d.SetMaxOpenConns(1)
+			before := countRows(t, d, "focus_events")
+
+			if err := migrate(d); err != nil {
+				t.Fatal(err)
+			}
+			if v, _ := schemaVersion(d); v != len(migrations) {
+				t.Errorf("expected version %d, got %d", len(migrations), v)
+			}
+			have, err := tables(d, "main")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, tbl := range want {
+				for _, c := range tbl.columns {
+					if !containsFold(have[name].columns, c) {
+						t.Errorf("missing %s.%s", name, c)
+					}
+				}
+			}
+			if n := countRows(t, d, "focus_events"); n != before {
+				t.Errorf("expected %d sessions kept, got %d", before, n)
+			}
+
+			if err := migrate(d); err != nil {
+				t.Errorf("second migrate: %v", err)
+			}
+			if n := countRows(t, d, "schema_version"); n != len(migrations) {
+				t.Errorf("expected %d schema_version rows, got %d", len(migrations), n)
+			}
+		})
+	}
+}
+
+func TestMigrate_RefusesNewerFile(t *testing.T) {
+	d, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "new.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+	if err := migrate(d); err != nil {
+		t.Fatal(err)
+	}
+	d.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, 'from the future', ?)`, len(migrations)+1, time.Now())
+
+	if err := migrate(d); err == nil {
+		t.Error("expected error for a file from a newer version")
+	}
+}
+
+func TestOpen_MigratesLocalFile(t *testing.T) {
+	dir := t.TempDir()
+	hostname, _ := os.Hostname()
+	loadFixture(t, dir, "layout-original.sql", hostname)
+
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	if v, _ := schemaVersion(tr.DB()); v != len(migrations) {
+		t.Errorf("expected version %d, got %d", len(migrations), v)
+	}
+	if n := countRows(t, tr.DB(), "focus_events"); n != 2 {
+		t.Errorf("expected fixture sessions kept, got %d", n)
+	}
+}
+
+func TestQueries_MixedSchemaVersions(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := loadFixture(t, dir, "layout-original.sql", "LAPTOP-OLD")
+	loadFixture(t, dir, "layout-unversioned.sql", "DESKTOP-NEW")
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	raw, err := GetWeeklySummary(dir, monday, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary WeeklySummary
+	json.Unmarshal(raw, &summary)
+
+	if len(summary.Machines) != 3 {
+		t.Errorf("expected 3 machines, got %v", summary.Machines)
+	}
+	byProj := map[string]AttributedProject{}
+	for _, a := range summary.Attributed {
+		byProj[a.ProjectNumber] = a
+	}
+	// 150 seeded + 60 from the original layout
+	if byProj["25-125"].TotalMinutes != 210 {
+		t.Errorf("25-125: expected 210, got %+v", byProj["25-125"])
+	}
+	// 60 tracked, 30 assigned by override, 60 manual
+	if p := byProj["26-001"]; p.TotalMinutes != 150 || p.ManualMinutes != 60 {
+		t.Errorf("26-001: expected 150 with 60 manual, got %+v", p)
+	}
+	if len(summary.Meetings) != 1 || summary.Meetings[0].Sessions != 2 || summary.InactivityMinutes != 50 {
+		t.Errorf("unexpected meetings/inactivity: %+v %.1f", summary.Meetings, summary.InactivityMinutes)
+	}
+
+	report, err := Reattribute(dir, rules.Default(), monday, monday.AddDate(0, 0, 7), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Examined != 7 {
+		t.Errorf("expected 7 sessions examined, got %d", report.Examined)
+	}
+
+	// Reading never upgrades another machine's file
+	d, err := sql.Open("sqlite", "file:"+oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if v, _ := schemaVersion(d); v != 0 {
+		t.Errorf("original layout was migrated to %d", v)
+	}
+	cols, _ := columns(d, "focus_events")
+	if containsFold(cols, "client") {
+		t.Error("original layout gained columns")
+	}
+}
@@ -0,0 +1,245 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Overrides correct tracked sessions without touching focus_events: they are
+// rows in the session_overrides table of the machine that made them, and
+// every query replays them over the sessions it loads (see applyOverrides).
+// A machine can therefore correct sessions recorded on another without
+// writing to that machine's file.
+
+// ListSessions returns the sessions from every machine that start in
+// [dateFrom, dateTo), with their IDs for use in overrides. If process is
+// set, only that process's sessions are returned.
+func ListSessions(dbpath string, dateFrom, dateTo time.Time, process string) ([]Session, error) {
+	dbs, err := openAllDBs(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(dbs)
+
+	result := []Session{}
+	for _, s := range loadSessions(dbs, dateFrom, dateTo, process) {
+		if startsIn(s, dateFrom, dateTo) {
+			result = append(result, *s)
+		}
+	}
+	return result, nil
+}
+
+// AssignProject attributes sessions to project, overriding their rules-based
+// attribution. The sessions are those named in ids, or if ids is empty,
+// those starting in [from, to), optionally only for one process. An empty
+// project marks them unattributed.
+func AssignProject(dbpath string, ids []string, from, to time.Time, process, project string) ([]Session, error) {
+	dbs, err := openAllDBs(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(dbs)
+
+	var targets []*Session
+	if len(ids) > 0 {
+		found, err := lookupSessions(dbs, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			targets = append(targets, found[id])
+		}
+	} else {
+		if from.IsZero() || to.IsZero() {
+			return nil, fmt.Errorf("session ids or a time range are required")
+		}
+		for _, s := range loadSessions(dbs, from, to, process) {
+			if startsIn(s, from, to) {
+				targets = append(targets, s)
+			}
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no sessions between %s and %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+		}
+	}
+
+	var rows []override
+	for _, s := range targets {
+		rows = append(rows, override{kind: "assign", target: s.ID, project: project})
+	}
+	if err := writeOverrides(dbpath, rows); err != nil {
+		return nil, err
+	}
+
+	result := make([]Session, len(targets))
+	for i, s := range targets {
+		s.Project = project
+		s.Overridden = true
+		result[i] = *s
+	}
+	return result, nil
+}
+
+// SplitSession splits the session id at t and returns the two parts.
+func SplitSession(dbpath, id string, t time.Time) ([]Session, error) {
+	dbs, err := openAllDBs(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(dbs)
+
+	found, err := lookupSessions(dbs, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	s := found[id]
+	t = t.UTC()
+	if !s.Start.Before(t) || !t.Before(s.End) {
+		return nil, fmt.Errorf("%s is not within session %s (%s to %s)", t.Format(time.RFC3339), id,
+			s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339))
+	}
+
+	if err := writeOverrides(dbpath, []override{{kind: "split", target: id, splitAt: t}}); err != nil {
+		return nil, err
+	}
+
+	rest := cut(s, t)
+	s.setMinutes()
+	rest.setMinutes()
+	return []Session{*s, *rest}, nil
+}
+
+// MergeSessions folds the sessions in ids into the first one. The merged
+// session keeps the first session's ID, process, title and project, spans
+// all of them, and counts the sum of their durations.
+func MergeSessions(dbpath string, ids []string) (*Session, error) {
+	if len(ids) < 2 {
+		return nil, fmt.Errorf("at least two session ids are required")
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("session %s listed twice", id)
+		}
+		seen[id] = true
+	}
+
+	dbs, err := openAllDBs(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(dbs)
+
+	found, err := lookupSessions(dbs, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []override
+	for _, id := range ids[1:] {
+		rows = append(rows, override{kind: "merge", target: id, mergeInto: ids[0]})
+	}
+	if err := writeOverrides(dbpath, rows); err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, id := range ids {
+		sessions = append(sessions, found[id])
+	}
+	merged := applyOverrides(sessions, rows)[0]
+	merged.setMinutes()
+	return merged, nil
+}
+
+// lookupSessions finds the current state of the sessions with the given IDs
+// by loading the time around their focus_events rows.
+func lookupSessions(dbs []*sql.DB, ids []string) (map[string]*Session, error) {
+	var from, to time.Time
+	for _, id := range ids {
+		host, n, err := parseSessionID(id)
+		if err != nil {
+			return nil, err
+		}
+		var start, end time.Time
+		found := false
+		for _, db := range dbs {
+			if db.QueryRow(`SELECT started_at, ended_at FROM focus_events WHERE hostname = ? AND id = ?`, host, n).Scan(&start, &end) == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no session %s", id)
+		}
+		if from.IsZero() || start.Before(from) {
+			from = start
+		}
+		if end.After(to) {
+			to = end
+		}
+	}
+
+	// Merges can pull in sessions from either side
+	byID := map[string]*Session{}
+	for _, s := range loadSessions(dbs, from.UTC().AddDate(0, 0, -1), to.UTC().AddDate(0, 0, 1), "") {
+		byID[s.ID] = s
+	}
+	for _, id := range ids {
+		if byID[id] == nil {
+			return nil, fmt.Errorf("no session %s (it may have been merged into another)", id)
+		}
+	}
+	return byID, nil
+}
+
+// writeOverrides stores overrides in this machine's file.
+func writeOverrides(dbpath string, rows []override) error {
+	d, _, err := openLocal(dbpath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	tx, err := d.Begin()
+	if err != nil {
+		return fmt.Errorf("db: override insert: %w", err)
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	for _, o := range rows {
+		var splitAt any
+		if !o.splitAt.IsZero() {
+			splitAt = o.splitAt
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO session_overrides (kind, target, project_number, split_at, merge_into, created_at) VALUES (?,?,?,?,?,?)`,
+			o.kind, o.target, nullable(o.project), splitAt, nullable(o.mergeInto), now,
+		); err != nil {
+			return fmt.Errorf("db: override insert: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("db: override insert: %w", err)
+	}
+	return nil
+}
+
+// ParseTime parses an ISO date or date and time, as accepted by the MCP
+// write tools. Times without a zone are in loc.
+func ParseTime(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (want e.g. 2026-03-02T14:30:00Z)", s)
+}
+
+func closeAll(dbs []*sql.DB) {
+	for _, d := range dbs {
+		d.Close()
+	}
+}
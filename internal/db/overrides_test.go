@@ -0,0 +1,202 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Sessions seeded by seedTestDB for DESKTOP-TEST on Monday 2026-03-02:
+//
+//	DESKTOP-TEST:1  acad.exe     09:00-11:00  25-125
+//	DESKTOP-TEST:2  OUTLOOK.EXE  11:00-11:30  25-125
+//	DESKTOP-TEST:3  chrome.exe   12:00-12:45  (none)
+var overrideMonday = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+func weeklyByProject(t *testing.T, dir string) (map[string]AttributedProject, map[string]UnattributedApp) {
+	t.Helper()
+	raw, err := GetWeeklySummary(dir, overrideMonday, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary WeeklySummary
+	json.Unmarshal(raw, &summary)
+	attr := map[string]AttributedProject{}
+	for _, a := range summary.Attributed {
+		attr[a.ProjectNumber] = a
+	}
+	unattr := map[string]UnattributedApp{}
+	for _, u := range summary.Unattributed {
+		unattr[u.Process] = u
+	}
+	return attr, unattr
+}
+
+func TestAssignProject_ByID(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	got, err := AssignProject(dir, []string{"DESKTOP-TEST:3"}, time.Time{}, time.Time{}, "", "25-125")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Project != "25-125" || !got[0].Overridden {
+		t.Errorf("unexpected result: %+v", got)
+	}
+
+	attr, unattr := weeklyByProject(t, dir)
+	if attr["25-125"].TotalMinutes != 195 || len(attr["25-125"].SessionIDs) != 3 {
+		t.Errorf("expected 195 minutes over 3 sessions on 25-125, got %+v", attr["25-125"])
+	}
+	if _, ok := unattr["chrome.exe"]; ok {
+		t.Error("chrome.exe should no longer be unattributed")
+	}
+
+	// The raw row is untouched
+	d, _ := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-DESKTOP-TEST.db"))
+	defer d.Close()
+	var projNum sql.NullString
+	d.QueryRow("SELECT project_number FROM focus_events WHERE id = 3").Scan(&projNum)
+	if projNum.Valid {
+		t.Errorf("focus_events was modified: %v", projNum)
+	}
+}
+
+func TestAssignProject_ByRange(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	from := overrideMonday.Add(9 * time.Hour)
+	to := overrideMonday.Add(13 * time.Hour)
+	got, err := AssignProject(dir, nil, from, to, "outlook.exe", "26-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "DESKTOP-TEST:2" {
+		t.Fatalf("expected only the Outlook session, got %+v", got)
+	}
+
+	attr, _ := weeklyByProject(t, dir)
+	if attr["25-125"].TotalMinutes != 120 || attr["26-001"].TotalMinutes != 30 {
+		t.Errorf("unexpected totals: %+v", attr)
+	}
+
+	if _, err := AssignProject(dir, nil, time.Time{}, time.Time{}, "", "x"); err == nil {
+		t.Error("expected error without ids or range")
+	}
+}
+
+func TestSplitSession(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	parts, err := SplitSession(dir, "DESKTOP-TEST:1", overrideMonday.Add(10*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].ID != "DESKTOP-TEST:1" || parts[1].ID != "DESKTOP-TEST:1+3600" ||
+		parts[0].Minutes != 60 || parts[1].Minutes != 60 {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
+
+	if _, err := AssignProject(dir, []string{"DESKTOP-TEST:1+3600"}, time.Time{}, time.Time{}, "", "26-001"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 10:30 is in the second part, not the first
+	if _, err := SplitSession(dir, "DESKTOP-TEST:1", overrideMonday.Add(10*time.Hour+30*time.Minute)); err == nil {
+		t.Error("expected error splitting outside the named part")
+	}
+	parts, err = SplitSession(dir, "DESKTOP-TEST:1+3600", overrideMonday.Add(10*time.Hour+30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts[1].ID != "DESKTOP-TEST:1+5400" || parts[1].Project != "26-001" {
+		t.Errorf("new part should keep the assignment: %+v", parts[1])
+	}
+
+	attr, _ := weeklyByProject(t, dir)
+	if attr["25-125"].TotalMinutes != 90 || attr["26-001"].TotalMinutes != 60 {
+		t.Errorf("unexpected totals: 25-125=%+v 26-001=%+v", attr["25-125"], attr["26-001"])
+	}
+
+	sessions, err := ListSessions(dir, overrideMonday, overrideMonday.AddDate(0, 0, 1), "acad.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Errorf("expected 3 acad parts, got %+v", sessions)
+	}
+}
+
+func TestMergeSessions(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	merged, err := MergeSessions(dir, []string{"DESKTOP-TEST:2", "DESKTOP-TEST:3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.ID != "DESKTOP-TEST:2" || merged.Minutes != 75 || merged.Project != "25-125" {
+		t.Errorf("unexpected merged session: %+v", merged)
+	}
+
+	attr, unattr := weeklyByProject(t, dir)
+	if attr["25-125"].TotalMinutes != 195 || len(unattr) != 0 {
+		t.Errorf("unexpected totals: %+v %+v", attr, unattr)
+	}
+
+	listed, err := ListSessions(dir, overrideMonday, overrideMonday.AddDate(0, 0, 1), "OUTLOOK.EXE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Minutes != 75 {
+		t.Errorf("expected the merged outlook session, got %+v", listed)
+	}
+
+	raw, err := GetFocusTime(dir, "chrome.exe", overrideMonday, overrideMonday.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ft FocusTimeResult
+	json.Unmarshal(raw, &ft)
+	if ft.TotalMinutes != 0 {
+		t.Errorf("merged-away chrome session still counted: %.1f", ft.TotalMinutes)
+	}
+
+	if _, err := AssignProject(dir, []string{"DESKTOP-TEST:3"}, time.Time{}, time.Time{}, "", "x"); err == nil {
+		t.Error("expected error assigning a merged-away session")
+	}
+	if _, err := MergeSessions(dir, []string{"DESKTOP-TEST:1"}); err == nil {
+		t.Error("expected error merging a single session")
+	}
+}
+
+func TestApplyOverrides_Order(t *testing.T) {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	newSession := func(id string, start time.Time, mins int) *Session {
+		return &Session{ID: id, Machine: "H", Process: "p", Start: start, End: start.Add(time.Duration(mins) * time.Minute),
+			seconds: float64(mins * 60), base: id, baseStart: start}
+	}
+	sessions := []*Session{newSession("H:1", base, 60), newSession("H:2", base.Add(time.Hour), 30)}
+	at := func(m int) time.Time { return base.Add(time.Duration(m) * time.Minute) }
+
+	got := applyOverrides(sessions, []override{
+		{kind: "assign", target: "H:1", project: "A", createdAt: at(0)},
+		{kind: "split", target: "H:1", splitAt: at(20), createdAt: at(1)},
+		{kind: "merge", target: "H:2", mergeInto: "H:1+1200", createdAt: at(2)},
+		// Names the merged-away session, so applies to what it was merged into
+		{kind: "assign", target: "H:2", project: "B", createdAt: at(3)},
+	})
+	if len(got) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(got))
+	}
+	if got[0].ID != "H:1" || got[0].Project != "A" || got[0].seconds != 20*60 {
+		t.Errorf("first part: %+v", got[0])
+	}
+	if got[1].ID != "H:1+1200" || got[1].Project != "B" || got[1].seconds != 70*60 || !got[1].End.Equal(at(90)) {
+		t.Errorf("merged part: %+v", got[1])
+	}
+}
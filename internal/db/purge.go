@@ -0,0 +1,230 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PurgeFilter picks what Purge deletes: what started in [From, To), of
+// Process if set, with a title matching TitleMatch if set. A zero From or
+// To leaves that end open.
+type PurgeFilter struct {
+	From, To   time.Time
+	Process    string
+	TitleMatch *regexp.Regexp
+}
+
+// PurgeReport is the result of Purge, counted across every file.
+type PurgeReport struct {
+	DryRun     bool     `json:"dry_run"`
+	Files      []string `json:"files"`
+	Skipped    []string `json:"skipped,omitempty"`
+	Sessions   int      `json:"sessions"`
+	Meetings   int      `json:"meetings"`
+	RawEvents  int      `json:"raw_events"`
+	Rollups    int      `json:"rollups"`
+	Inactivity int      `json:"inactivity_periods"`
+	System     int      `json:"system_events"`
+}
+
+// Purge deletes the tracked time f picks from every timewarp-*.db file in
+// dbpath, and compacts the files so that nothing deleted is left in them.
+// Sessions, meetings, the raw log and rollups are matched on their process
+// or app and, for TitleMatch, on any of their titles or subjects; rollups,
+// which have no titles, are left alone by a TitleMatch. Inactivity and
+// system events only go when neither Process nor TitleMatch is set. Manual
+// entries, calendar imports and corrections are kept. Unless dryRun is set
+// the rows are deleted, except in files at another schema version than this
+// build's, which are listed in Skipped; either way the report counts them.
+// Files sealed with a key this machine lacks are skipped even in a dry run.
+func Purge(dbpath string, f PurgeFilter, dryRun bool) (*PurgeReport, error) {
+	if f.From.IsZero() && f.To.IsZero() && f.Process == "" && f.TitleMatch == nil {
+		return nil, fmt.Errorf("a time range, process or title pattern is required")
+	}
+	matches, err := filepath.Glob(filepath.Join(dbpath, "timewarp-*.db"))
+	if err != nil {
+		return nil, fmt.Errorf("glob: %w", err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no timewarp-*.db files found in %s", dbpath)
+	}
+
+	report := &PurgeReport{DryRun: dryRun, Files: []string{}}
+	for _, m := range matches {
+		d, err := openForUpdate(m, dryRun)
+		if isSkip(err) {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("%s: %v", filepath.Base(m), err))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(m), err)
+		}
+		err = purgeDB(d, f, dryRun, report)
+		d.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(m), err)
+		}
+		report.Files = append(report.Files, filepath.Base(m))
+	}
+	return report, nil
+}
+
+// purgeTable is how Purge finds the rows to delete in one table.
+type purgeTable struct {
+	table string
+	// timeCol is the column matched against the range; process and title
+	// select the process and app, and the free text matched by TitleMatch
+	timeCol        string
+	process, title string
+	// keyed tables are narrowed by a processFilter before the exact match
+	keyed bool
+	count func(*PurgeReport) *int
+}
+
+var purgeTables = []purgeTable{
+	{"focus_events", "started_at", "process_name, COALESCE(app, '')", "unseal(window_title)", true, func(r *PurgeReport) *int { return &r.Sessions }},
+	{"meeting_sessions", "started_at", "process_name, COALESCE(app, '')", "unseal(subject)", false, func(r *PurgeReport) *int { return &r.Meetings }},
+	{"raw_events", "started_at", "process_name, COALESCE(app, '')", "unseal(window_title)", false, func(r *PurgeReport) *int { return &r.RawEvents }},
+	{"rollups", "hour", "process_name, app", "", false, func(r *PurgeReport) *int { return &r.Rollups }},
+	{"meeting_rollups", "hour", "process_name, app", "", false, func(r *PurgeReport) *int { return &r.Rollups }},
+	{"inactivity_periods", "started_at", "", "", false, func(r *PurgeReport) *int { return &r.Inactivity }},
+	{"inactivity_rollups", "hour", "", "", false, func(r *PurgeReport) *int { return &r.Rollups }},
+	{"system_events", "occurred_at", "", "", false, func(r *PurgeReport) *int { return &r.System }},
+}
+
+func purgeDB(d *sql.DB, f PurgeFilter, dryRun bool, report *PurgeReport) error {
+	ids := map[string][]int64{}
+	for _, t := range purgeTables {
+		if t.process == "" && (f.Process != "" || f.TitleMatch != nil) || t.title == "" && f.TitleMatch != nil {
+			continue
+		}
+		found, err := purgeMatches(d, t, f)
+		if err != nil {
+			return fmt.Errorf("purge %s: %w", t.table, err)
+		}
+		ids[t.table] = found
+		*t.count(report) += len(found)
+	}
+	if dryRun {
+		return nil
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	deleted := 0
+	for table, found := range ids {
+		for _, id := range found {
+			if table == "focus_events" {
+				if err := rollupRow(tx, id, -1); err != nil {
+					return fmt.Errorf("purge %s: %w", table, err)
+				}
+				for _, q := range []string{
+					`DELETE FROM focus_event_titles WHERE focus_event_id = ?`,
+					`DELETE FROM attribution_changes WHERE focus_event_id = ?`,
+				} {
+					if _, err := tx.Exec(q, id); err != nil {
+						return fmt.Errorf("purge %s: %w", table, err)
+					}
+				}
+			}
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("purge %s: %w", table, err)
+			}
+			deleted++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return nil
+	}
+	return compact(d)
+}
+
+// purgeMatches returns the ids of the rows in t that f picks.
+func purgeMatches(d *sql.DB, t purgeTable, f PurgeFilter) ([]int64, error) {
+	where, args := "1=1", []any{}
+	if !f.From.IsZero() {
+		where += " AND " + t.timeCol + " >= ?"
+		args = append(args, f.From.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !f.To.IsZero() {
+		where += " AND " + t.timeCol + " < ?"
+		args = append(args, f.To.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if t.keyed {
+		// Narrowed here using the indexes, and matched exactly below
+		cond, more := processFilter{process: f.Process}.where(d, "")
+		where += " AND " + cond
+		args = append(args, more...)
+	}
+	if t.table == "rollups" {
+		// The rollups of stored sessions go with them; only the archived
+		// hours are rows of their own
+		where += " AND hour < ?"
+		args = append(args, archivedBefore(d).Format("2006-01-02 15:04:05"))
+	}
+	cols := "id"
+	if t.process != "" {
+		cols += ", " + t.process
+	}
+	if t.title != "" {
+		cols += ", " + t.title
+	}
+
+	var titles map[int64][]string
+	if t.table == "focus_events" && f.TitleMatch != nil {
+		var err error
+		if titles, _, err = loadTitles(d, strings.ReplaceAll(where, t.timeCol, "f."+t.timeCol), args...); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := d.Query(`SELECT `+cols+` FROM `+t.table+` WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var (
+			id                  int64
+			process, app, title string
+		)
+		dest := []any{&id}
+		if t.process != "" {
+			dest = append(dest, &process, &app)
+		}
+		if t.title != "" {
+			dest = append(dest, &title)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if f.Process != "" && !strings.EqualFold(process, f.Process) && !strings.EqualFold(app, f.Process) {
+			continue
+		}
+		if f.TitleMatch != nil && !f.TitleMatch.MatchString(title) && !anyMatch(f.TitleMatch, titles[id]) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func anyMatch(re *regexp.Regexp, list []string) bool {
+	for _, s := range list {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurge_ProcessAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+	seedTestDB(t, dir, "LAPTOP-TEST")
+
+	report, err := Purge(dir, PurgeFilter{Process: "CHROME.EXE"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 2 || report.Sessions != 2 || report.Inactivity != 0 {
+		t.Errorf("unexpected dry run: %+v", report)
+	}
+	if _, unattr := weeklyByProject(t, dir); unattr["chrome.exe"].TotalMinutes != 90 {
+		t.Errorf("dry run deleted sessions: %+v", unattr)
+	}
+
+	if _, err := Purge(dir, PurgeFilter{Process: "CHROME.EXE"}, false); err != nil {
+		t.Fatal(err)
+	}
+	attr, unattr := weeklyByProject(t, dir)
+	if _, ok := unattr["chrome.exe"]; ok || attr["25-125"].TotalMinutes != 300 {
+		t.Errorf("expected only chrome deleted, got %+v %+v", attr, unattr)
+	}
+	for _, host := range []string{"DESKTOP-TEST", "LAPTOP-TEST"} {
+		b, _ := os.ReadFile(filepath.Join(dir, "timewarp-"+host+".db"))
+		if strings.Contains(string(b), "ESPN") {
+			t.Errorf("%s: deleted title left in the file", host)
+		}
+	}
+}
+
+func TestPurge_TitleMatch(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+	d, err := openForUpdate(filepath.Join(dir, "timewarp-DESKTOP-TEST.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A match on any title the session had counts
+	d.Exec(`INSERT INTO focus_event_titles (focus_event_id, title) VALUES (1, 'Contoso site plan.dwg')`)
+	d.Close()
+
+	report, err := Purge(dir, PurgeFilter{TitleMatch: regexp.MustCompile(`(?i)rfi|contoso|design review`)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sessions != 2 || report.Meetings != 1 || report.Inactivity != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	sessions, _ := ListSessions(dir, overrideMonday, overrideMonday.AddDate(0, 0, 7), "")
+	if len(sessions) != 1 || sessions[0].Process != "chrome.exe" {
+		t.Errorf("expected only chrome left, got %+v", sessions)
+	}
+}
+
+func TestPurge_Range(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	if _, err := Purge(dir, PurgeFilter{}, false); err == nil {
+		t.Error("expected an error purging with no filter")
+	}
+	report, err := Purge(dir, PurgeFilter{From: base.Add(3 * time.Hour), To: base.Add(6 * time.Hour)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sessions != 1 || report.Meetings != 1 || report.Inactivity != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if sessions, _ := ListSessions(dir, base, base.Add(24*time.Hour), ""); len(sessions) != 2 {
+		t.Errorf("expected the 2 sessions before the range kept, got %+v", sessions)
+	}
+}
@@ -15,30 +15,51 @@ import (
 
 type WeeklySummary struct {
 	Week               string              `json:"week"`
+	Timezone           string              `json:"timezone"`
 	Machines           []string            `json:"machines"`
 	Attributed         []AttributedProject `json:"attributed"`
 	Unattributed       []UnattributedApp   `json:"unattributed"`
 	Meetings           []MeetingSummary    `json:"meetings"`
+	Calendar           []CalendarEvent     `json:"calendar,omitempty"`
+	Manual             []ManualEntry       `json:"manual_entries,omitempty"`
 	InactivityMinutes  float64             `json:"inactivity_minutes"`
+	AwayMinutes        float64             `json:"away_minutes"`
 }
 
+// AttributedProject is the time on one project. TotalMinutes is active
+// time: sessions less the inactivity within them, plus manual entries.
+// GrossMinutes, when asked for, counts sessions whole.
 type AttributedProject struct {
 	ProjectNumber string   `json:"project_number"`
 	TotalMinutes  float64  `json:"total_minutes"`
+	GrossMinutes  float64  `json:"gross_minutes,omitempty"`
+	ManualMinutes float64  `json:"manual_minutes,omitempty"`
 	Processes     []string `json:"processes"`
 	SampleTitles  []string `json:"sample_titles"`
+	SessionIDs    []string `json:"session_ids,omitempty"`
 }
 
+// UnattributedApp is the time in one app on no project, counted as for
+// AttributedProject. Process is the app's identity (see Session.App), as
+// are AttributedProject's Processes.
 type UnattributedApp struct {
 	Process      string   `json:"process"`
 	TotalMinutes float64  `json:"total_minutes"`
+	GrossMinutes float64  `json:"gross_minutes,omitempty"`
 	SampleTitles []string `json:"sample_titles"`
+	SessionIDs   []string `json:"session_ids,omitempty"`
 }
 
+// MeetingSummary is the time in meetings with one subject. The calendar
+// event the meetings overlap most, if any, gives its subject and project.
 type MeetingSummary struct {
-	Subject      string  `json:"subject"`
-	TotalMinutes float64 `json:"total_minutes"`
-	Sessions     int     `json:"sessions"`
+	Subject         string   `json:"subject"`
+	App             string   `json:"app,omitempty"`
+	TotalMinutes    float64  `json:"total_minutes"`
+	Sessions        int      `json:"sessions"`
+	Attendees       []string `json:"attendees,omitempty"`
+	CalendarSubject string   `json:"calendar_subject,omitempty"`
+	ProjectNumber   string   `json:"project_number,omitempty"`
 }
 
 type FocusTimeResult struct {
@@ -48,6 +69,7 @@ type FocusTimeResult struct {
 	DateTo       string  `json:"date_to"`
 }
 
+// TopApp is the time in one app, by its identity (see Session.App).
 type TopApp struct {
 	ProcessName  string  `json:"process_name"`
 	TotalMinutes float64 `json:"total_minutes"`
@@ -66,22 +88,43 @@ func openAllDBs(dbpath string) ([]*sql.DB, error) {
 
 	var dbs []*sql.DB
 	for _, m := range matches {
-		dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&mode=ro", m)
-		d, err := sql.Open("sqlite", dsn)
+		d, err := openReadOnly(m)
 		if err != nil {
-			d.Close()
 			for _, prev := range dbs {
 				prev.Close()
 			}
 			return nil, fmt.Errorf("open %s: %w", m, err)
 		}
-		d.SetMaxOpenConns(1)
 		dbs = append(dbs, d)
 	}
 	return dbs, nil
 }
 
-func GetWeeklySummary(dbpath string, weekStart time.Time) (json.RawMessage, error) {
+// openReadOnly opens a DB file, possibly another machine's, for reading.
+// Files from older versions are presented with the latest schema (see
+// compat).
+func openReadOnly(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&mode=ro", path)
+	d, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	d.SetMaxOpenConns(1)
+	if err := compat(d); err != nil {
+		d.Close()
+		return nil, err
+	}
+	// Fail now, with the key to import, rather than in the middle of a query
+	if _, err := fileSealer(d); err != nil {
+		d.Close()
+		return nil, keyError{err}
+	}
+	return d, nil
+}
+
+// GetWeeklySummary totals the week from weekStart across every machine.
+// With gross, projects and apps also report their gross minutes.
+func GetWeeklySummary(dbpath string, weekStart time.Time, gross bool) (json.RawMessage, error) {
 	dbs, err := openAllDBs(dbpath)
 	if err != nil {
 		return nil, err
@@ -96,155 +139,356 @@ func GetWeeklySummary(dbpath string, weekStart time.Time) (json.RawMessage, erro
 	weekStr := fmt.Sprintf("%s/%s", weekStart.Format("2006-01-02"), weekEnd.Format("2006-01-02"))
 
 	machineSet := map[string]bool{}
-	// project_number -> aggregation
-	type projAgg struct {
-		minutes    float64
-		processes  map[string]bool
-		titles     []string
+	agg := newSummaryAgg(gross)
+
+	for _, s := range loadSessions(dbs, weekStart, weekEnd, "") {
+		machineSet[s.Machine] = true
+		agg.addSession(s, weekStart, weekEnd)
 	}
-	attributed := map[string]*projAgg{}
+	agg.addPeriod(loadPeriod(dbs, weekStart, weekEnd), weekStart, weekEnd)
 
-	// process -> aggregation (unattributed)
-	type appAgg struct {
-		minutes float64
-		titles  []string
+	// Build result
+	var machines []string
+	for m := range machineSet {
+		machines = append(machines, m)
+	}
+
+	summary := WeeklySummary{
+		Week:              weekStr,
+		Timezone:          zoneLabel(weekStart),
+		Machines:          machines,
+		Attributed:        agg.attributedList(),
+		Unattributed:      agg.unattributedList(),
+		Meetings:          agg.meetingList(),
+		Calendar:          agg.calendarList(weekStart, weekEnd),
+		Manual:            agg.manualList(),
+		InactivityMinutes: round1(agg.inactivity),
+		AwayMinutes:       round1(agg.away),
 	}
-	unattributed := map[string]*appAgg{}
 
+	return json.Marshal(summary)
+}
+
+// summaryAgg accumulates one period's sessions into the attributed,
+// unattributed, meeting, inactivity and away totals shared by the weekly and
+// daily queries.
+type summaryAgg struct {
+	// project_number -> aggregation
+	attributed map[string]*projAgg
+	// process -> aggregation (unattributed)
+	unattributed map[string]*appAgg
 	// meeting subject -> aggregation
-	type mtgAgg struct {
-		minutes  float64
-		sessions int
+	meetings map[string]*mtgAgg
+	// calendar events by uid and start, as every machine sharing the
+	// folder may have imported the same calendar
+	events     map[string]*calEvent
+	manual     []ManualEntry
+	inactivity float64
+	away       float64
+	// report gross minutes as well as active
+	gross bool
+}
+
+type projAgg struct {
+	minutes       float64
+	grossMinutes  float64
+	manualMinutes float64
+	processes     map[string]bool
+	titles        []string
+	ids           []string
+}
+
+type appAgg struct {
+	minutes      float64
+	grossMinutes float64
+	titles       []string
+	ids          []string
+}
+
+// maxMeetingAttendees caps the distinct attendees listed for a meeting.
+const maxMeetingAttendees = 10
+
+type mtgAgg struct {
+	app       string
+	minutes   float64
+	sessions  int
+	attendees []string
+	spans     []span
+}
+
+func newSummaryAgg(gross bool) *summaryAgg {
+	return &summaryAgg{
+		attributed:   map[string]*projAgg{},
+		unattributed: map[string]*appAgg{},
+		meetings:     map[string]*mtgAgg{},
+		events:       map[string]*calEvent{},
+		gross:        gross,
 	}
-	meetings := map[string]*mtgAgg{}
+}
+
+// period is what the summaries count besides focus sessions: the meetings,
+// calendar events, manual entries, inactivity and away time in every file
+// that overlap a range. It is read once however many days are totalled
+// from it.
+type period struct {
+	meetings   []meetingRow
+	events     map[string]*calEvent
+	manual     []ManualEntry
+	inactivity []timedSpan
+	away       []span
+}
+
+type meetingRow struct {
+	subject, app, attendees string
+	timedSpan
+	// sessions is how many meetings the row counts as
+	sessions int
+}
 
-	var totalInactivity float64
+// timedSpan is a stored span with its recorded duration.
+type timedSpan struct {
+	start, end time.Time
+	seconds    float64
+}
 
-	startStr := weekStart.Format("2006-01-02 15:04:05")
-	endStr := weekEnd.Format("2006-01-02 15:04:05")
+// overlaps reports whether [start, end) overlaps [from, to).
+func overlaps(start, end, from, to time.Time) bool {
+	return start.Before(to) && end.After(from)
+}
+
+// loadPeriod reads what overlaps [from, to) in dbs, row by row for any
+// range. The meetings and inactivity that have been archived are read from
+// their rollups, an hour's time as if it ran from the top of the hour, and
+// archived meetings under their app.
+func loadPeriod(dbs []*sql.DB, from, to time.Time) *period {
+	startStr := from.UTC().Format("2006-01-02 15:04:05")
+	endStr := to.UTC().Format("2006-01-02 15:04:05")
+	hourStr := from.UTC().Add(-time.Hour).Format("2006-01-02 15:04:05")
+	p := &period{events: map[string]*calEvent{}}
 
 	for _, db := range dbs {
-		// Machines
-		rows, err := db.Query(`SELECT DISTINCT hostname FROM focus_events WHERE started_at >= ? AND started_at < ?`, startStr, endStr)
+		// Meetings
+		rows, err := db.Query(`SELECT unseal(subject), COALESCE(app, ''), COALESCE(unseal(attendees), ''), started_at, ended_at, duration_seconds FROM meeting_sessions WHERE started_at < ? AND ended_at > ?`, endStr, startStr)
 		if err == nil {
 			for rows.Next() {
-				var h string
-				rows.Scan(&h)
-				machineSet[h] = true
+				m := meetingRow{sessions: 1}
+				if rows.Scan(&m.subject, &m.app, &m.attendees, &m.start, &m.end, &m.seconds) == nil {
+					p.meetings = append(p.meetings, m)
+				}
 			}
 			rows.Close()
 		}
-
-		// Focus events
-		rows, err = db.Query(`SELECT process_name, window_title, project_number, duration_seconds FROM focus_events WHERE started_at >= ? AND started_at < ?`, startStr, endStr)
+		rows, err = db.Query(`SELECT app, hour, seconds, meetings FROM meeting_rollups WHERE hour < ? AND hour > ?`, endStr, hourStr)
 		if err == nil {
 			for rows.Next() {
-				var proc, title string
-				var projNum sql.NullString
-				var dur float64
-				rows.Scan(&proc, &title, &projNum, &dur)
-				mins := dur / 60.0
-
-				if projNum.Valid && projNum.String != "" {
-					agg, ok := attributed[projNum.String]
-					if !ok {
-						agg = &projAgg{processes: map[string]bool{}}
-						attributed[projNum.String] = agg
-					}
-					agg.minutes += mins
-					agg.processes[proc] = true
-					if len(agg.titles) < 3 {
-						agg.titles = append(agg.titles, title)
-					}
-				} else {
-					agg, ok := unattributed[proc]
-					if !ok {
-						agg = &appAgg{}
-						unattributed[proc] = agg
-					}
-					agg.minutes += mins
-					if len(agg.titles) < 3 {
-						agg.titles = append(agg.titles, title)
-					}
+				var m meetingRow
+				if rows.Scan(&m.app, &m.start, &m.seconds, &m.sessions) == nil {
+					m.subject = m.app
+					m.start, m.end = m.start.UTC(), m.start.UTC().Add(time.Duration(m.seconds*float64(time.Second)))
+					p.meetings = append(p.meetings, m)
 				}
 			}
 			rows.Close()
 		}
 
-		// Meetings
-		rows, err = db.Query(`SELECT subject, duration_seconds FROM meeting_sessions WHERE started_at >= ? AND started_at < ?`, startStr, endStr)
+		// Calendar events
+		rows, err = db.Query(`SELECT uid, unseal(subject), COALESCE(project_number, ''), started_at, ended_at FROM calendar_events WHERE started_at < ? AND ended_at > ?`, endStr, startStr)
 		if err == nil {
 			for rows.Next() {
-				var subj string
-				var dur float64
-				rows.Scan(&subj, &dur)
-				agg, ok := meetings[subj]
-				if !ok {
-					agg = &mtgAgg{}
-					meetings[subj] = agg
+				var uid string
+				e := &calEvent{}
+				if rows.Scan(&uid, &e.subject, &e.project, &e.start, &e.end) != nil {
+					continue
 				}
-				agg.minutes += dur / 60.0
-				agg.sessions++
+				p.events[fmt.Sprintf("%s|%d", uid, e.start.Unix())] = e
 			}
 			rows.Close()
 		}
 
+		p.manual = append(p.manual, queryManualEntries(db, startStr, endStr)...)
+
 		// Inactivity
-		row := db.QueryRow(`SELECT COALESCE(SUM(duration_seconds), 0) FROM inactivity_periods WHERE started_at >= ? AND started_at < ?`, startStr, endStr)
-		var inact float64
-		if row.Scan(&inact) == nil {
-			totalInactivity += inact / 60.0
+		rows, err = db.Query(`SELECT started_at, ended_at, duration_seconds FROM inactivity_periods WHERE started_at < ? AND ended_at > ?`, endStr, startStr)
+		if err == nil {
+			for rows.Next() {
+				var t timedSpan
+				if rows.Scan(&t.start, &t.end, &t.seconds) == nil {
+					p.inactivity = append(p.inactivity, t)
+				}
+			}
+			rows.Close()
+		}
+		rows, err = db.Query(`SELECT hour, seconds FROM inactivity_rollups WHERE hour < ? AND hour > ?`, endStr, hourStr)
+		if err == nil {
+			for rows.Next() {
+				var t timedSpan
+				if rows.Scan(&t.start, &t.seconds) == nil {
+					t.start, t.end = t.start.UTC(), t.start.UTC().Add(time.Duration(t.seconds*float64(time.Second)))
+					p.inactivity = append(p.inactivity, t)
+				}
+			}
+			rows.Close()
 		}
+
+		p.away = append(p.away, awayPeriods(db, from, to)...)
 	}
+	return p
+}
 
-	// Build result
-	var machines []string
-	for m := range machineSet {
-		machines = append(machines, m)
+// addPeriod adds the meetings, manual entries, inactivity and away time in
+// p that overlap [from, to), counting only the part inside it. Focus
+// sessions are added by addSession.
+func (a *summaryAgg) addPeriod(p *period, from, to time.Time) {
+	for _, m := range p.meetings {
+		if !overlaps(m.start, m.end, from, to) {
+			continue
+		}
+		agg, ok := a.meetings[m.subject]
+		if !ok {
+			agg = &mtgAgg{}
+			a.meetings[m.subject] = agg
+		}
+		if agg.app == "" {
+			agg.app = m.app
+		}
+		if m.attendees != "" {
+			agg.attendees = addTitles(agg.attendees, strings.Split(m.attendees, "\n"), maxMeetingAttendees)
+		}
+		agg.minutes += m.seconds * within(m.start, m.end, from, to) / 60.0
+		agg.sessions += m.sessions
+		agg.spans = append(agg.spans, span{m.start, m.end})
+	}
+
+	for k, e := range p.events {
+		if overlaps(e.start, e.end, from, to) {
+			a.events[k] = e
+		}
+	}
+
+	// Manual entries count towards their project like tracked time
+	for _, e := range p.manual {
+		if !overlaps(e.start, e.end, from, to) {
+			continue
+		}
+		mins := e.Minutes * within(e.start, e.end, from, to)
+		agg := a.project(e.Project)
+		agg.minutes += mins
+		agg.grossMinutes += mins
+		agg.manualMinutes += mins
+		a.manual = append(a.manual, e)
 	}
 
+	for _, t := range p.inactivity {
+		a.inactivity += t.seconds * within(t.start, t.end, from, to) / 60.0
+	}
+	for _, s := range p.away {
+		a.away += s.end.Sub(s.start).Minutes() * within(s.start, s.end, from, to)
+	}
+}
+
+// addSession adds the part of s that falls in [from, to). Idle time is
+// taken to be spread evenly over the session.
+func (a *summaryAgg) addSession(s *Session, from, to time.Time) {
+	share := within(s.Start, s.End, from, to)
+	if share == 0 {
+		return
+	}
+	gross := s.seconds * share / 60.0
+	mins := s.activeSeconds() * share / 60.0
+	if s.Project != "" {
+		agg := a.project(s.Project)
+		agg.minutes += mins
+		agg.grossMinutes += gross
+		agg.processes[s.App] = true
+		if !s.RolledUp {
+			agg.ids = append(agg.ids, s.ID)
+			agg.titles = addTitles(agg.titles, s.Titles, 3)
+		}
+		return
+	}
+	agg, ok := a.unattributed[s.App]
+	if !ok {
+		agg = &appAgg{}
+		a.unattributed[s.App] = agg
+	}
+	agg.minutes += mins
+	agg.grossMinutes += gross
+	if !s.RolledUp {
+		agg.ids = append(agg.ids, s.ID)
+		agg.titles = addTitles(agg.titles, s.Titles, 3)
+	}
+}
+
+func (a *summaryAgg) project(projNum string) *projAgg {
+	agg, ok := a.attributed[projNum]
+	if !ok {
+		agg = &projAgg{processes: map[string]bool{}}
+		a.attributed[projNum] = agg
+	}
+	return agg
+}
+
+func (a *summaryAgg) attributedList() []AttributedProject {
 	var attrList []AttributedProject
-	for pn, agg := range attributed {
-		var procs []string
+	for pn, agg := range a.attributed {
+		procs := []string{}
 		for p := range agg.processes {
 			procs = append(procs, p)
 		}
-		attrList = append(attrList, AttributedProject{
+		p := AttributedProject{
 			ProjectNumber: pn,
 			TotalMinutes:  round1(agg.minutes),
+			ManualMinutes: round1(agg.manualMinutes),
 			Processes:     procs,
 			SampleTitles:  agg.titles,
-		})
+			SessionIDs:    agg.ids,
+		}
+		if a.gross {
+			p.GrossMinutes = round1(agg.grossMinutes)
+		}
+		attrList = append(attrList, p)
 	}
+	return attrList
+}
 
+func (a *summaryAgg) unattributedList() []UnattributedApp {
 	var unattrList []UnattributedApp
-	for proc, agg := range unattributed {
-		unattrList = append(unattrList, UnattributedApp{
+	for proc, agg := range a.unattributed {
+		u := UnattributedApp{
 			Process:      proc,
 			TotalMinutes: round1(agg.minutes),
 			SampleTitles: agg.titles,
-		})
+			SessionIDs:   agg.ids,
+		}
+		if a.gross {
+			u.GrossMinutes = round1(agg.grossMinutes)
+		}
+		unattrList = append(unattrList, u)
 	}
+	return unattrList
+}
 
+func (a *summaryAgg) meetingList() []MeetingSummary {
 	var mtgList []MeetingSummary
-	for subj, agg := range meetings {
-		mtgList = append(mtgList, MeetingSummary{
+	for subj, agg := range a.meetings {
+		m := MeetingSummary{
 			Subject:      subj,
+			App:          agg.app,
 			TotalMinutes: round1(agg.minutes),
 			Sessions:     agg.sessions,
-		})
-	}
-
-	summary := WeeklySummary{
-		Week:              weekStr,
-		Machines:          machines,
-		Attributed:        attrList,
-		Unattributed:      unattrList,
-		Meetings:          mtgList,
-		InactivityMinutes: round1(totalInactivity),
+			Attendees:    agg.attendees,
+		}
+		if e := a.bestEvent(agg.spans); e != nil {
+			m.CalendarSubject, m.ProjectNumber = e.subject, e.project
+		}
+		mtgList = append(mtgList, m)
 	}
+	return mtgList
+}
 
-	return json.Marshal(summary)
+func (a *summaryAgg) manualList() []ManualEntry {
+	sortManualEntries(a.manual)
+	return a.manual
 }
 
 func GetFocusTime(dbpath string, processName string, dateFrom, dateTo time.Time) (json.RawMessage, error) {
@@ -258,20 +502,9 @@ func GetFocusTime(dbpath string, processName string, dateFrom, dateTo time.Time)
 		}
 	}()
 
-	fromStr := dateFrom.Format("2006-01-02 15:04:05")
-	toStr := dateTo.Format("2006-01-02 15:04:05")
-	procLower := strings.ToLower(processName)
-
 	var totalSeconds float64
-	for _, db := range dbs {
-		row := db.QueryRow(
-			`SELECT COALESCE(SUM(duration_seconds), 0) FROM focus_events WHERE LOWER(process_name) = ? AND started_at >= ? AND started_at < ?`,
-			procLower, fromStr, toStr,
-		)
-		var s float64
-		if row.Scan(&s) == nil {
-			totalSeconds += s
-		}
+	for _, s := range sessionsFor(dbs, dateFrom, dateTo, processName) {
+		totalSeconds += s.activeSeconds() * within(s.Start, s.End, dateFrom, dateTo)
 	}
 
 	result := FocusTimeResult{
@@ -295,25 +528,10 @@ func ListTopApps(dbpath string, weekStart time.Time) (json.RawMessage, error) {
 	}()
 
 	weekEnd := weekStart.AddDate(0, 0, 7)
-	startStr := weekStart.Format("2006-01-02 15:04:05")
-	endStr := weekEnd.Format("2006-01-02 15:04:05")
 
 	totals := map[string]float64{}
-	for _, db := range dbs {
-		rows, err := db.Query(
-			`SELECT process_name, SUM(duration_seconds) FROM focus_events WHERE started_at >= ? AND started_at < ? GROUP BY process_name`,
-			startStr, endStr,
-		)
-		if err != nil {
-			continue
-		}
-		for rows.Next() {
-			var proc string
-			var dur float64
-			rows.Scan(&proc, &dur)
-			totals[proc] += dur
-		}
-		rows.Close()
+	for _, s := range loadSessions(dbs, weekStart, weekEnd, "") {
+		totals[s.App] += s.activeSeconds() * within(s.Start, s.End, weekStart, weekEnd)
 	}
 
 	// Sort by total and take top 10
@@ -348,27 +566,84 @@ func ListTopApps(dbpath string, weekStart time.Time) (json.RawMessage, error) {
 	return json.Marshal(result)
 }
 
-// currentWeekMonday returns the Monday of the current week.
-func CurrentWeekMonday() time.Time {
-	now := time.Now().UTC()
+// CurrentWeekMonday returns midnight at the start of the current week in
+// loc.
+func CurrentWeekMonday(loc *time.Location) time.Time {
+	now := time.Now().In(loc)
 	weekday := now.Weekday()
 	if weekday == time.Sunday {
 		weekday = 7
 	}
 	monday := now.AddDate(0, 0, -int(weekday-time.Monday))
-	return time.Date(monday.Year(), monday.Month(), monday.Day(), 0, 0, 0, 0, time.UTC)
+	return time.Date(monday.Year(), monday.Month(), monday.Day(), 0, 0, 0, 0, loc)
 }
 
-// ParseWeekStart parses an ISO date string and ensures it's a Monday.
-func ParseWeekStart(s string) (time.Time, error) {
-	t, err := time.Parse("2006-01-02", s)
+// ParseWeekStart parses an ISO date string and ensures it's a Monday. The
+// week starts at midnight in loc.
+func ParseWeekStart(s string, loc *time.Location) (time.Time, error) {
+	t, err := ParseDate(s, loc)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid date: %w", err)
+		return time.Time{}, err
 	}
 	if t.Weekday() != time.Monday {
 		return time.Time{}, fmt.Errorf("date %s is not a Monday", s)
 	}
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+	return t, nil
+}
+
+// ParseDate parses an ISO date as midnight in loc. Day and week boundaries
+// are always local midnights, so a day can be 23 or 25 hours long when the
+// clocks change; step through days with AddDate rather than adding hours.
+func ParseDate(s string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %w", err)
+	}
+	return t, nil
+}
+
+// LoadLocation returns the IANA time zone name, e.g. America/Toronto. An
+// empty name or "Local" is the system's zone.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q (want an IANA name such as America/Toronto)", name)
+	}
+	return loc, nil
+}
+
+// zoneLabel names the zone of t for results: its IANA name, or for the
+// system zone, which may not have one, its offset at t.
+func zoneLabel(t time.Time) string {
+	if name := t.Location().String(); name != "Local" {
+		return name
+	}
+	return t.Format("UTC-07:00")
+}
+
+// within returns the share of [start, end) that falls in [from, to), so
+// that sessions crossing midnight are split between the days.
+func within(start, end, from, to time.Time) float64 {
+	if !end.After(start) {
+		if !start.Before(from) && start.Before(to) {
+			return 1
+		}
+		return 0
+	}
+	total := end.Sub(start)
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Seconds() / total.Seconds()
 }
 
 // ExeDir returns the directory of the running executable.
@@ -383,6 +658,7 @@ func ExeDir() string {
 type DailyBreakdown struct {
 	DateFrom string             `json:"date_from"`
 	DateTo   string             `json:"date_to"`
+	Timezone string             `json:"timezone"`
 	Days     []DayEntry         `json:"days"`
 }
 
@@ -391,11 +667,16 @@ type DayEntry struct {
 	Attributed        []AttributedProject `json:"attributed"`
 	Unattributed      []UnattributedApp   `json:"unattributed"`
 	Meetings          []MeetingSummary    `json:"meetings"`
+	Manual            []ManualEntry       `json:"manual_entries,omitempty"`
 	InactivityMinutes float64             `json:"inactivity_minutes"`
+	AwayMinutes       float64             `json:"away_minutes"`
 	TotalMinutes      float64             `json:"total_minutes"`
+	GrossMinutes      float64             `json:"gross_minutes,omitempty"`
 }
 
-func GetDailyBreakdown(dbpath string, dateFrom, dateTo time.Time) (json.RawMessage, error) {
+// GetDailyBreakdown totals each day in [dateFrom, dateTo) across every
+// machine. With gross, days, projects and apps also report gross minutes.
+func GetDailyBreakdown(dbpath string, dateFrom, dateTo time.Time, gross bool) (json.RawMessage, error) {
 	dbs, err := openAllDBs(dbpath)
 	if err != nil {
 		return nil, err
@@ -406,133 +687,37 @@ func GetDailyBreakdown(dbpath string, dateFrom, dateTo time.Time) (json.RawMessa
 		}
 	}()
 
+	sessions := sessionsFor(dbs, dateFrom, dateTo, "")
+	p := loadPeriod(dbs, dateFrom, dateTo)
+
 	var days []DayEntry
 	for d := dateFrom; d.Before(dateTo); d = d.AddDate(0, 0, 1) {
-		dayStart := d.Format("2006-01-02 15:04:05")
-		dayEnd := d.AddDate(0, 0, 1).Format("2006-01-02 15:04:05")
+		next := d.AddDate(0, 0, 1)
 
-		type projAgg struct {
-			minutes   float64
-			processes map[string]bool
-			titles    []string
+		agg := newSummaryAgg(gross)
+		for _, s := range sessions {
+			agg.addSession(s, d, next)
 		}
-		attributed := map[string]*projAgg{}
+		agg.addPeriod(p, d, next)
 
-		type appAgg struct {
-			minutes float64
-			titles  []string
-		}
-		unattributed := map[string]*appAgg{}
-
-		type mtgAgg struct {
-			minutes  float64
-			sessions int
-		}
-		meetings := map[string]*mtgAgg{}
-
-		var totalInactivity float64
-
-		for _, db := range dbs {
-			rows, err := db.Query(`SELECT process_name, window_title, project_number, duration_seconds FROM focus_events WHERE started_at >= ? AND started_at < ?`, dayStart, dayEnd)
-			if err == nil {
-				for rows.Next() {
-					var proc, title string
-					var projNum sql.NullString
-					var dur float64
-					rows.Scan(&proc, &title, &projNum, &dur)
-					mins := dur / 60.0
-
-					if projNum.Valid && projNum.String != "" {
-						agg, ok := attributed[projNum.String]
-						if !ok {
-							agg = &projAgg{processes: map[string]bool{}}
-							attributed[projNum.String] = agg
-						}
-						agg.minutes += mins
-						agg.processes[proc] = true
-						if len(agg.titles) < 3 {
-							agg.titles = append(agg.titles, title)
-						}
-					} else {
-						agg, ok := unattributed[proc]
-						if !ok {
-							agg = &appAgg{}
-							unattributed[proc] = agg
-						}
-						agg.minutes += mins
-						if len(agg.titles) < 3 {
-							agg.titles = append(agg.titles, title)
-						}
-					}
-				}
-				rows.Close()
-			}
+		attrList := agg.attributedList()
+		unattrList := agg.unattributedList()
+		mtgList := agg.meetingList()
 
-			rows, err = db.Query(`SELECT subject, duration_seconds FROM meeting_sessions WHERE started_at >= ? AND started_at < ?`, dayStart, dayEnd)
-			if err == nil {
-				for rows.Next() {
-					var subj string
-					var dur float64
-					rows.Scan(&subj, &dur)
-					agg, ok := meetings[subj]
-					if !ok {
-						agg = &mtgAgg{}
-						meetings[subj] = agg
-					}
-					agg.minutes += dur / 60.0
-					agg.sessions++
-				}
-				rows.Close()
-			}
-
-			row := db.QueryRow(`SELECT COALESCE(SUM(duration_seconds), 0) FROM inactivity_periods WHERE started_at >= ? AND started_at < ?`, dayStart, dayEnd)
-			var inact float64
-			if row.Scan(&inact) == nil {
-				totalInactivity += inact / 60.0
-			}
-		}
-
-		var attrList []AttributedProject
-		for pn, agg := range attributed {
-			var procs []string
-			for p := range agg.processes {
-				procs = append(procs, p)
-			}
-			attrList = append(attrList, AttributedProject{
-				ProjectNumber: pn,
-				TotalMinutes:  round1(agg.minutes),
-				Processes:     procs,
-				SampleTitles:  agg.titles,
-			})
-		}
-
-		var unattrList []UnattributedApp
-		for proc, agg := range unattributed {
-			unattrList = append(unattrList, UnattributedApp{
-				Process:      proc,
-				TotalMinutes: round1(agg.minutes),
-				SampleTitles: agg.titles,
-			})
-		}
-
-		var mtgList []MeetingSummary
-		for subj, agg := range meetings {
-			mtgList = append(mtgList, MeetingSummary{
-				Subject:      subj,
-				TotalMinutes: round1(agg.minutes),
-				Sessions:     agg.sessions,
-			})
-		}
-
-		var totalMins float64
+		var totalMins, grossMins float64
 		for _, a := range attrList {
 			totalMins += a.TotalMinutes
+			grossMins += a.GrossMinutes
 		}
 		for _, u := range unattrList {
 			totalMins += u.TotalMinutes
+			grossMins += u.GrossMinutes
 		}
 		for _, m := range mtgList {
 			totalMins += m.TotalMinutes
+			if gross {
+				grossMins += m.TotalMinutes
+			}
 		}
 
 		days = append(days, DayEntry{
@@ -540,14 +725,18 @@ func GetDailyBreakdown(dbpath string, dateFrom, dateTo time.Time) (json.RawMessa
 			Attributed:        attrList,
 			Unattributed:      unattrList,
 			Meetings:          mtgList,
-			InactivityMinutes: round1(totalInactivity),
+			Manual:            agg.manualList(),
+			InactivityMinutes: round1(agg.inactivity),
+			AwayMinutes:       round1(agg.away),
 			TotalMinutes:      round1(totalMins),
+			GrossMinutes:      round1(grossMins),
 		})
 	}
 
 	result := DailyBreakdown{
 		DateFrom: dateFrom.Format("2006-01-02"),
 		DateTo:   dateTo.Format("2006-01-02"),
+		Timezone: zoneLabel(dateFrom),
 		Days:     days,
 	}
 	return json.Marshal(result)
@@ -19,7 +19,7 @@ func seedTestDB(t *testing.T, dir string, hostname string) {
 	}
 	defer d.Close()
 	d.SetMaxOpenConns(1)
-	initSchema(d)
+	migrate(d)
 
 	// Monday 2026-03-02 through Friday 2026-03-06
 	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
@@ -38,6 +38,8 @@ func seedTestDB(t *testing.T, dir string, hostname string) {
 	d.Exec(`INSERT INTO focus_events (hostname, username, process_name, window_title, project_number, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?,?)`,
 		hostname, "user", "chrome.exe", "ESPN - NBA Scores", nil,
 		base.Add(3*time.Hour), base.Add(225*time.Minute), 2700.0)
+	// Keyed by process, as the tracker writes them
+	d.Exec(`UPDATE focus_events SET process_key = LOWER(process_name)`)
 
 	// Meeting: 1 hour
 	d.Exec(`INSERT INTO meeting_sessions (hostname, username, process_name, subject, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?)`,
@@ -55,7 +57,7 @@ func TestGetWeeklySummary(t *testing.T) {
 	seedTestDB(t, dir, "DESKTOP-TEST")
 
 	weekStart := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
-	raw, err := GetWeeklySummary(dir, weekStart)
+	raw, err := GetWeeklySummary(dir, weekStart, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -109,7 +111,7 @@ func TestGetWeeklySummary_MultiMachine(t *testing.T) {
 	seedTestDB(t, dir, "LAPTOP-B")
 
 	weekStart := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
-	raw, err := GetWeeklySummary(dir, weekStart)
+	raw, err := GetWeeklySummary(dir, weekStart, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -194,7 +196,7 @@ func TestListTopApps(t *testing.T) {
 }
 
 func TestParseWeekStart_Valid(t *testing.T) {
-	ws, err := ParseWeekStart("2026-03-02")
+	ws, err := ParseWeekStart("2026-03-02", time.UTC)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -204,7 +206,7 @@ func TestParseWeekStart_Valid(t *testing.T) {
 }
 
 func TestParseWeekStart_NotMonday(t *testing.T) {
-	_, err := ParseWeekStart("2026-03-03") // Tuesday
+	_, err := ParseWeekStart("2026-03-03", time.UTC) // Tuesday
 	if err == nil {
 		t.Error("expected error for non-Monday date")
 	}
@@ -217,7 +219,7 @@ func TestGetDailyBreakdown(t *testing.T) {
 	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC) // 2 days: Mon + Tue
 
-	raw, err := GetDailyBreakdown(dir, from, to)
+	raw, err := GetDailyBreakdown(dir, from, to, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -275,8 +277,206 @@ func TestGetDailyBreakdown(t *testing.T) {
 func TestGetWeeklySummary_EmptyDir(t *testing.T) {
 	dir := t.TempDir()
 	weekStart := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
-	_, err := GetWeeklySummary(dir, weekStart)
+	_, err := GetWeeklySummary(dir, weekStart, false)
 	if err == nil {
 		t.Error("expected error for empty directory")
 	}
 }
+
+// seedSessions writes unattributed focus sessions, given as start/end pairs,
+// to timewarp-HOST.db in dir.
+func seedSessions(t *testing.T, dir string, times ...time.Time) {
+	t.Helper()
+	d, err := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-HOST.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+	if err := migrate(d); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(times); i += 2 {
+		start, end := times[i].UTC(), times[i+1].UTC()
+		if _, err := d.Exec(`INSERT INTO focus_events (hostname, username, process_name, window_title, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?)`,
+			"HOST", "user", "acad.exe", "plan.dwg", start, end, end.Sub(start).Seconds()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// dailyMinutes returns the total minutes of each day in the breakdown.
+func dailyMinutes(t *testing.T, dir string, from, to time.Time) map[string]float64 {
+	t.Helper()
+	raw, err := GetDailyBreakdown(dir, from, to, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result DailyBreakdown
+	json.Unmarshal(raw, &result)
+	days := map[string]float64{}
+	for _, d := range result.Days {
+		days[d.Date] = d.TotalMinutes
+	}
+	return days
+}
+
+func TestGetDailyBreakdown_LocalDays(t *testing.T) {
+	toronto, err := LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	// Tuesday evening in Toronto, Wednesday morning in UTC
+	seedSessions(t, dir, time.Date(2026, 3, 3, 22, 0, 0, 0, toronto), time.Date(2026, 3, 3, 23, 0, 0, 0, toronto))
+
+	local := dailyMinutes(t, dir, time.Date(2026, 3, 2, 0, 0, 0, 0, toronto), time.Date(2026, 3, 5, 0, 0, 0, 0, toronto))
+	if local["2026-03-03"] != 60 || local["2026-03-04"] != 0 {
+		t.Errorf("Toronto days: %v", local)
+	}
+	utc := dailyMinutes(t, dir, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC))
+	if utc["2026-03-03"] != 0 || utc["2026-03-04"] != 60 {
+		t.Errorf("UTC days: %v", utc)
+	}
+}
+
+func TestGetDailyBreakdown_SplitsAtMidnight(t *testing.T) {
+	dir := t.TempDir()
+	// Sunday 23:30 to Monday 00:30, across the week boundary too
+	seedSessions(t, dir, time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC), time.Date(2026, 3, 2, 0, 30, 0, 0, time.UTC))
+
+	days := dailyMinutes(t, dir, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC))
+	if days["2026-03-01"] != 30 || days["2026-03-02"] != 30 {
+		t.Errorf("expected 30 minutes each side of midnight, got %v", days)
+	}
+
+	raw, err := GetWeeklySummary(dir, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary WeeklySummary
+	json.Unmarshal(raw, &summary)
+	if len(summary.Unattributed) != 1 || summary.Unattributed[0].TotalMinutes != 30 {
+		t.Errorf("expected only the Monday half in the week, got %+v", summary.Unattributed)
+	}
+}
+
+func TestGetDailyBreakdown_DST(t *testing.T) {
+	toronto, err := LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	seedSessions(t, dir,
+		// Saturday 23:00 to Monday 01:00 across the spring-forward Sunday,
+		// which is 23 hours long
+		time.Date(2026, 3, 7, 23, 0, 0, 0, toronto), time.Date(2026, 3, 9, 1, 0, 0, 0, toronto),
+		// Sunday 1 November 01:30 EDT to 01:30 EST, the repeated hour
+		time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC),
+	)
+
+	spring := dailyMinutes(t, dir, time.Date(2026, 3, 7, 0, 0, 0, 0, toronto), time.Date(2026, 3, 10, 0, 0, 0, 0, toronto))
+	if spring["2026-03-07"] != 60 || spring["2026-03-08"] != 23*60 || spring["2026-03-09"] != 60 {
+		t.Errorf("spring forward: %v", spring)
+	}
+
+	fall := dailyMinutes(t, dir, time.Date(2026, 10, 31, 0, 0, 0, 0, toronto), time.Date(2026, 11, 3, 0, 0, 0, 0, toronto))
+	if len(fall) != 3 || fall["2026-11-01"] != 60 {
+		t.Errorf("fall back: %v", fall)
+	}
+}
+
+func TestCurrentWeekMonday_InLocation(t *testing.T) {
+	loc := time.FixedZone("UTC-10", -10*3600)
+	m := CurrentWeekMonday(loc)
+	if m.Location() != loc || m.Weekday() != time.Monday || m.Hour() != 0 {
+		t.Errorf("expected local Monday midnight, got %v", m)
+	}
+	if now := time.Now(); now.Before(m) || !now.Before(m.AddDate(0, 0, 7)) {
+		t.Errorf("%v is not in the week starting %v", now, m)
+	}
+}
+
+func TestSummaries_ActiveAndGross(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	// 15 of chrome's 45 minutes were idle
+	d, err := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-DESKTOP-TEST.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Exec(`UPDATE focus_events SET idle_seconds = 900 WHERE process_name = 'chrome.exe'`)
+	d.Close()
+
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	for _, gross := range []bool{false, true} {
+		raw, err := GetWeeklySummary(dir, monday, gross)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s WeeklySummary
+		json.Unmarshal(raw, &s)
+		if len(s.Unattributed) != 1 || s.Unattributed[0].TotalMinutes != 30 {
+			t.Fatalf("expected 30 active chrome minutes, got %+v", s.Unattributed)
+		}
+		wantGross := 0.0
+		if gross {
+			wantGross = 45
+		}
+		if s.Unattributed[0].GrossMinutes != wantGross {
+			t.Errorf("gross=%v: expected gross minutes %v, got %v", gross, wantGross, s.Unattributed[0].GrossMinutes)
+		}
+	}
+
+	raw, err := GetDailyBreakdown(dir, monday, monday.AddDate(0, 0, 1), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var daily DailyBreakdown
+	json.Unmarshal(raw, &daily)
+	// 150 on 25-125, chrome and the 60 minute meeting
+	if day := daily.Days[0]; day.TotalMinutes != 240 || day.GrossMinutes != 255 {
+		t.Errorf("expected 240 active and 255 gross minutes, got %v and %v", day.TotalMinutes, day.GrossMinutes)
+	}
+
+	raw, err = GetFocusTime(dir, "chrome.exe", monday, monday.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ft FocusTimeResult
+	json.Unmarshal(raw, &ft)
+	if ft.TotalMinutes != 30 {
+		t.Errorf("expected 30 active chrome minutes, got %v", ft.TotalMinutes)
+	}
+}
+
+func TestSummaries_SampleTitlesFromSessionTitles(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	// The AutoCAD session went through three drawings; the older Outlook
+	// row has no titles of its own
+	d, err := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-DESKTOP-TEST.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"25-125_SLD-E100.dwg - AutoCAD", "25-125_SLD-E101.dwg - AutoCAD", "Open - AutoCAD"} {
+		d.Exec(`INSERT INTO focus_event_titles (focus_event_id, title) SELECT id, ? FROM focus_events WHERE process_name = 'acad.exe'`, title)
+	}
+	d.Close()
+
+	raw, err := GetWeeklySummary(dir, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s WeeklySummary
+	json.Unmarshal(raw, &s)
+	if len(s.Attributed) != 1 {
+		t.Fatalf("expected 1 project, got %+v", s.Attributed)
+	}
+	got := s.Attributed[0].SampleTitles
+	if len(got) != 3 || got[0] != "25-125_SLD-E100.dwg - AutoCAD" || got[2] != "Open - AutoCAD" {
+		t.Errorf("expected the AutoCAD session's titles, got %q", got)
+	}
+}
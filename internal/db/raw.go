@@ -0,0 +1,155 @@
+package db
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// rawGap is the longest gap between samples of one window that still
+	// continues its run in raw_events. It is above the default heartbeat, so
+	// polling alone doesn't break runs up; gaps shorter than it are lost to
+	// a later Resessionize.
+	rawGap = 10 * time.Second
+
+	// rawPruneInterval is how often raw_events older than the retention
+	// period are deleted.
+	rawPruneInterval = time.Hour
+)
+
+// rawLog is the state of the raw focus log. Only changes of state are
+// written: a row per run of samples of one window, whose end is moved along
+// as the run goes on, at most every checkpointInterval.
+type rawLog struct {
+	on     bool
+	keep   time.Duration
+	run    *rawRun
+	pruned time.Time
+}
+
+type rawRun struct {
+	id         int64
+	hostname   string
+	username   string
+	win        Window
+	url        string
+	start, end time.Time
+	saved      time.Time
+}
+
+// SetRawLog turns the raw focus log on or off. Rows older than keep are
+// deleted as tracking goes on, whether or not the log is on; a keep of zero
+// keeps them all.
+func (t *Tracker) SetRawLog(on bool, keep time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !on {
+		t.closeRawRun()
+	}
+	t.raw.on, t.raw.keep = on, keep
+}
+
+// logRaw adds a sample to the raw focus log. A switch is a focus change as
+// it happened rather than a tick, so the previous window was in front right
+// up to it. Samples of suppressed processes are logged too, so that
+// changing the profiles later can bring them back.
+func (t *Tracker) logRaw(hostname, username string, w Window, url string, at time.Time, switched bool) {
+	if t.raw.keep > 0 && at.Sub(t.raw.pruned) >= rawPruneInterval {
+		t.pruneRaw(at)
+	}
+	if !t.raw.on {
+		return
+	}
+
+	r := t.raw.run
+	if r != nil && r.win == w && r.url == url {
+		switch {
+		case at.Before(r.start):
+			// A tick saw the window before its event was delivered
+			r.start = at
+			t.saveRawRun()
+			return
+		case at.Sub(r.end) <= rawGap:
+			if at.After(r.end) {
+				r.end = at
+			}
+			if r.end.Sub(r.saved) >= checkpointInterval {
+				t.saveRawRun()
+			}
+			return
+		}
+	}
+
+	if r != nil && switched && at.After(r.end) && at.Sub(r.end) <= rawGap {
+		r.end = at
+	}
+	t.closeRawRun()
+
+	sealed := t.seal.batch()
+	cmd, title, sealedURL := sealed.seal(w.CommandLine), sealed.seal(w.Title), sealed.seal(url)
+	if sealed.err != nil {
+		log.Printf("db: raw_events insert: %v", sealed.err)
+		return
+	}
+	res, err := t.db.Exec(`INSERT INTO raw_events (hostname, username, process_name, exe_path, window_class, command_line, app, window_title, url, started_at, ended_at) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		hostname, username, w.Process, nullable(w.ExePath), nullable(w.Class), nullable(cmd), nullable(w.App), title, nullable(sealedURL), at.UTC(), at.UTC())
+	if err != nil {
+		log.Printf("db: raw_events insert: %v", err)
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		log.Printf("db: raw_events insert: %v", err)
+		return
+	}
+	t.raw.run = &rawRun{
+		id:       id,
+		hostname: hostname,
+		username: username,
+		win:      w,
+		url:      url,
+		start:    at,
+		end:      at,
+		saved:    at,
+	}
+}
+
+// endRawRun ends the run in progress at the moment the user went away, as
+// for the pending session in RecordSystemEvent.
+func (t *Tracker) endRawRun(at time.Time) {
+	if r := t.raw.run; r != nil {
+		if at.After(r.end) && at.Sub(r.end) <= rawGap {
+			r.end = at
+		} else if at.Before(r.end) && at.After(r.start) {
+			r.end = at
+		}
+	}
+	t.closeRawRun()
+}
+
+// closeRawRun writes the final state of the run in progress, if any.
+func (t *Tracker) closeRawRun() {
+	if t.raw.run == nil {
+		return
+	}
+	t.saveRawRun()
+	t.raw.run = nil
+}
+
+func (t *Tracker) saveRawRun() {
+	r := t.raw.run
+	if _, err := t.db.Exec(`UPDATE raw_events SET started_at = ?, ended_at = ? WHERE id = ?`, r.start.UTC(), r.end.UTC(), r.id); err != nil {
+		log.Printf("db: raw_events update: %v", err)
+		return
+	}
+	r.saved = r.end
+}
+
+// pruneRaw deletes raw_events that ended more than the retention period
+// before now.
+func (t *Tracker) pruneRaw(now time.Time) {
+	t.raw.pruned = now
+	if _, err := t.db.Exec(`DELETE FROM raw_events WHERE ended_at < ?`, now.Add(-t.raw.keep).UTC().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("db: raw_events prune: %v", err)
+	}
+}
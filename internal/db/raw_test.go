@@ -0,0 +1,89 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRawLog_StateChangesOnly(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+	tr.SetRawLog(true, 0)
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+	for i := 0; i < 60; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", at(i))
+	}
+	// Heartbeat ticks 5s apart continue the run; 20s apart they don't
+	for i := 60; i < 90; i += 5 {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", at(i))
+	}
+	tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", at(105))
+	tr.RecordFocusChange("HOST", "user", "chrome.exe", "Google", at(108))
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", at(110))
+	tr.RecordFocus("HOST", "user", "lockapp.exe", "", at(111))
+
+	rows, err := tr.db.Query("SELECT process_name, started_at, ended_at FROM raw_events ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type run struct {
+		process    string
+		start, end int
+	}
+	var got []run
+	for rows.Next() {
+		var r run
+		var start, end time.Time
+		rows.Scan(&r.process, &start, &end)
+		r.start, r.end = int(start.Sub(base).Seconds()), int(end.Sub(base).Seconds())
+		got = append(got, r)
+	}
+	rows.Close()
+	// The switch to chrome ends the second AutoCAD run at the switch;
+	// suppressed processes are logged too
+	want := []run{{"acad.exe", 0, 85}, {"acad.exe", 105, 108}, {"chrome.exe", 108, 110}, {"lockapp.exe", 111, 111}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("run %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRawLog_OffByDefault(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Now().Truncate(time.Second)
+	for i := 0; i < 20; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", base.Add(time.Duration(i)*time.Second))
+	}
+	if n := countRows(t, tr.db, "raw_events"); n != 0 {
+		t.Errorf("expected no raw events, got %d", n)
+	}
+}
+
+func TestRawLog_Retention(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tr.db.Exec(`INSERT INTO raw_events (hostname, username, process_name, window_title, started_at, ended_at) VALUES (?,?,?,?,?,?)`,
+		"HOST", "user", "acad.exe", "old.dwg", base.AddDate(0, 0, -40), base.AddDate(0, 0, -40).Add(time.Hour))
+	tr.db.Exec(`INSERT INTO raw_events (hostname, username, process_name, window_title, started_at, ended_at) VALUES (?,?,?,?,?,?)`,
+		"HOST", "user", "acad.exe", "recent.dwg", base.AddDate(0, 0, -10), base.AddDate(0, 0, -10).Add(time.Hour))
+
+	// Old rows go even with the log turned off
+	tr.SetRawLog(false, 30*24*time.Hour)
+	tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", base)
+
+	var title string
+	tr.db.QueryRow("SELECT window_title FROM raw_events").Scan(&title)
+	if n := countRows(t, tr.db, "raw_events"); n != 1 || title != "recent.dwg" {
+		t.Errorf("expected only the recent row to be kept, got %d rows", n)
+	}
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/rules"
+)
+
+// AttributionChange is one stored session whose attribution differs under
+// the current rules.
+type AttributionChange struct {
+	Machine   string            `json:"machine"`
+	SessionID int64             `json:"session_id"`
+	Process   string            `json:"process"`
+	Title     string            `json:"title"`
+	StartedAt string            `json:"started_at"`
+	Minutes   float64           `json:"minutes"`
+	Old       rules.Attribution `json:"old"`
+	New       rules.Attribution `json:"new"`
+}
+
+// ReattributeReport is the result of Reattribute.
+type ReattributeReport struct {
+	DryRun   bool                `json:"dry_run"`
+	DateFrom string              `json:"date_from,omitempty"`
+	DateTo   string              `json:"date_to,omitempty"`
+	Files    []string            `json:"files"`
+	Skipped  []string            `json:"skipped,omitempty"`
+	Examined int                 `json:"sessions_examined"`
+	Changes  []AttributionChange `json:"changes"`
+}
+
+// Reattribute re-evaluates r over the sessions in every timewarp-*.db file
+// in dbpath that started in [from, to). A zero from or to leaves that end
+// open. Unless dryRun is set, changed rows are updated and each change is
+// written to the file's attribution_changes table; files at another schema
+// version than this build's are left alone and listed in Skipped, as are
+// files sealed with a key this machine lacks, dry run or not.
+func Reattribute(dbpath string, r *rules.Set, from, to time.Time, dryRun bool) (*ReattributeReport, error) {
+	matches, err := filepath.Glob(filepath.Join(dbpath, "timewarp-*.db"))
+	if err != nil {
+		return nil, fmt.Errorf("glob: %w", err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no timewarp-*.db files found in %s", dbpath)
+	}
+
+	report := &ReattributeReport{DryRun: dryRun, Files: []string{}, Changes: []AttributionChange{}}
+	if !from.IsZero() {
+		report.DateFrom = from.Format("2006-01-02")
+	}
+	if !to.IsZero() {
+		report.DateTo = to.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+
+	for _, m := range matches {
+		d, err := openForUpdate(m, dryRun)
+		if isSkip(err) {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("%s: %v", filepath.Base(m), err))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(m), err)
+		}
+		examined, changes, err := reattributeDB(d, r, from, to, dryRun)
+		d.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(m), err)
+		}
+		report.Files = append(report.Files, filepath.Base(m))
+		report.Examined += examined
+		report.Changes = append(report.Changes, changes...)
+	}
+	return report, nil
+}
+
+// openForUpdate opens a DB file for a change across the folder, such as
+// Reattribute or Purge. A dry run reads it like the queries do. Otherwise
+// a file at any schema version but this build's is returned as a
+// versionError for the caller to skip: other machines' files are never
+// migrated, as the timewarp on their machine would then refuse them. Either
+// way a file sealed with a key this machine lacks is returned as a
+// keyError, as its titles could not be read to match or rewrite.
+func openForUpdate(path string, dryRun bool) (*sql.DB, error) {
+	if dryRun {
+		return openReadOnly(path)
+	}
+	d, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, err
+	}
+	d.SetMaxOpenConns(1)
+	v, err := schemaVersion(d)
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+	if v != len(migrations) {
+		d.Close()
+		return nil, versionError{v}
+	}
+	if _, err := fileSealer(d); err != nil {
+		d.Close()
+		return nil, keyError{err}
+	}
+	return d, nil
+}
+
+// versionError is a file openForUpdate will not change, at schema version
+// version.
+type versionError struct {
+	version int
+}
+
+func (e versionError) Error() string {
+	return fmt.Sprintf("schema version %d, not %d; run this version of Timewarp on its machine first", e.version, len(migrations))
+}
+
+// keyError is a file whose sealed values this machine cannot open.
+type keyError struct {
+	err error
+}
+
+func (e keyError) Error() string {
+	return e.err.Error()
+}
+
+// isSkip reports whether err is a file openForUpdate leaves for the
+// caller to skip.
+func isSkip(err error) bool {
+	switch err.(type) {
+	case versionError, keyError:
+		return true
+	}
+	return false
+}
+
+// ReapplyRules re-evaluates the current rules against every session in this
+// machine's DB file and updates the rows whose attribution changed. It
+// returns the number of rows updated.
+func (t *Tracker) ReapplyRules() (int, error) {
+	t.mu.Lock()
+	r := t.rules
+	t.mu.Unlock()
+
+	_, changes, err := reattributeDB(t.db, r, time.Time{}, time.Time{}, false)
+	return len(changes), err
+}
+
+// reattributeDB evaluates r over the sessions in d that started in
+// [from, to), returning how many it looked at and those whose attribution
+// changed. Unless dryRun is set the changes are applied and audited in one
+// transaction.
+func reattributeDB(d *sql.DB, r *rules.Set, from, to time.Time, dryRun bool) (int, []AttributionChange, error) {
+	where := `1=1`
+	var args []any
+	if !from.IsZero() {
+		where += ` AND started_at >= ?`
+		args = append(args, from.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !to.IsZero() {
+		where += ` AND started_at < ?`
+		args = append(args, to.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	// Read before the sessions, as d may have only one connection
+	titles, urls, err := loadTitles(d, where, args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+	}
+	rows, err := d.Query(`SELECT id, hostname, process_name, COALESCE(exe_path, ''), COALESCE(window_class, ''), COALESCE(app, process_name), unseal(window_title), COALESCE(unseal(url), ''), started_at, duration_seconds, COALESCE(project_number, ''), COALESCE(client, ''), COALESCE(task, ''), COALESCE(category, '') FROM focus_events WHERE `+where+` ORDER BY started_at`, args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+	}
+	var (
+		examined int
+		changes  []AttributionChange
+	)
+	for rows.Next() {
+		var (
+			c       AttributionChange
+			in      rules.Input
+			url     string
+			started time.Time
+			secs    float64
+		)
+		if err := rows.Scan(&c.SessionID, &c.Machine, &c.Process, &in.ExePath, &in.Class, &in.App, &c.Title, &url, &started, &secs,
+			&c.Old.Project, &c.Old.Client, &c.Old.Task, &c.Old.Category); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+		}
+		examined++
+		seen, seenURLs := titles[c.SessionID], urls[c.SessionID]
+		if len(seen) == 0 {
+			seen, seenURLs = []string{c.Title}, []string{url}
+		}
+		in.Process = c.Process
+		c.New = sessionAttribution(r, in, seen, seenURLs)
+		if c.New == c.Old {
+			continue
+		}
+		c.StartedAt = started.UTC().Format(time.RFC3339)
+		c.Minutes = round1(secs / 60.0)
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+	}
+	if dryRun || len(changes) == 0 {
+		return examined, changes, nil
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	for _, c := range changes {
+		// The session's time moves to its new project in the rollups
+		if err := rollupRow(tx, c.SessionID, -1); err != nil {
+			return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE focus_events SET project_number = ?, client = ?, task = ?, category = ? WHERE id = ?`,
+			nullable(c.New.Project), nullable(c.New.Client), nullable(c.New.Task), nullable(c.New.Category), c.SessionID); err != nil {
+			return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+		}
+		if err := rollupRow(tx, c.SessionID, 1); err != nil {
+			return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO attribution_changes (focus_event_id, changed_at,
+				old_project_number, old_client, old_task, old_category,
+				new_project_number, new_client, new_task, new_category) VALUES (?,?,?,?,?,?,?,?,?,?)`,
+			c.SessionID, now,
+			nullable(c.Old.Project), nullable(c.Old.Client), nullable(c.Old.Task), nullable(c.Old.Category),
+			nullable(c.New.Project), nullable(c.New.Client), nullable(c.New.Task), nullable(c.New.Category),
+		); err != nil {
+			return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("db: reattribute: %w", err)
+	}
+	return examined, changes, nil
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/rules"
+)
+
+func testRules(t *testing.T) *rules.Set {
+	t.Helper()
+	r, err := rules.New([]rules.Rule{
+		{Title: rules.DefaultProjectPattern, Project: "$1"},
+		{Title: `RFI #(\d+)`, Task: "RFI $1"},
+		{Process: `^chrome\.exe$`, Category: "Browsing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReattribute_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+	seedTestDB(t, dir, "LAPTOP-TEST")
+
+	report, err := Reattribute(dir, testRules(t), time.Time{}, time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 2 || report.Examined != 6 {
+		t.Errorf("expected 6 sessions in 2 files, got %d in %v", report.Examined, report.Files)
+	}
+	if len(report.Changes) != 4 {
+		t.Fatalf("expected 4 changes, got %d: %+v", len(report.Changes), report.Changes)
+	}
+	for _, c := range report.Changes {
+		switch c.Process {
+		case "OUTLOOK.EXE":
+			if c.New != (rules.Attribution{Project: "25-125", Task: "RFI 12"}) {
+				t.Errorf("outlook: got %+v", c.New)
+			}
+		case "chrome.exe":
+			if c.Old != (rules.Attribution{}) || c.New != (rules.Attribution{Category: "Browsing"}) {
+				t.Errorf("chrome: got %+v -> %+v", c.Old, c.New)
+			}
+		default:
+			t.Errorf("unexpected change for %s", c.Process)
+		}
+	}
+
+	// Nothing written
+	d, _ := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-DESKTOP-TEST.db"))
+	defer d.Close()
+	var n int
+	d.QueryRow("SELECT COUNT(*) FROM focus_events WHERE task IS NOT NULL OR category IS NOT NULL").Scan(&n)
+	if n != 0 {
+		t.Errorf("dry run wrote %d rows", n)
+	}
+}
+
+func TestReattribute_AppliesAndAudits(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	report, err := Reattribute(dir, testRules(t), time.Time{}, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(report.Changes))
+	}
+
+	d, _ := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-DESKTOP-TEST.db"))
+	defer d.Close()
+	var task sql.NullString
+	d.QueryRow("SELECT task FROM focus_events WHERE process_name = 'OUTLOOK.EXE'").Scan(&task)
+	if task.String != "RFI 12" {
+		t.Errorf("expected task 'RFI 12', got %v", task)
+	}
+	var oldCat, newCat sql.NullString
+	if err := d.QueryRow(`SELECT a.old_category, a.new_category FROM attribution_changes a
+		JOIN focus_events f ON f.id = a.focus_event_id WHERE f.process_name = 'chrome.exe'`).Scan(&oldCat, &newCat); err != nil {
+		t.Fatal(err)
+	}
+	if oldCat.Valid || newCat.String != "Browsing" {
+		t.Errorf("audit row: %v -> %v", oldCat, newCat)
+	}
+	if n := countRows(t, d, "attribution_changes"); n != 2 {
+		t.Errorf("expected 2 audit rows, got %d", n)
+	}
+
+	report, err = Reattribute(dir, testRules(t), time.Time{}, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 0 {
+		t.Errorf("expected second run to change nothing, got %d", len(report.Changes))
+	}
+}
+
+func TestReattribute_DateRange(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+
+	// All seeded sessions are on Monday 2026-03-02
+	from := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+	report, err := Reattribute(dir, testRules(t), from, time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Examined != 0 || len(report.Changes) != 0 {
+		t.Errorf("expected nothing after %s, got %d examined", from, report.Examined)
+	}
+
+	to := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	report, err = Reattribute(dir, testRules(t), time.Time{}, to, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Examined != 1 {
+		t.Errorf("expected only the 09:00 session before %s, got %d", to, report.Examined)
+	}
+}
+
+func TestReattribute_OldFileDryRun(t *testing.T) {
+	dir := t.TempDir()
+	d, err := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-OLD.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Exec(`CREATE TABLE focus_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, hostname TEXT NOT NULL, username TEXT NOT NULL,
+		process_name TEXT NOT NULL, window_title TEXT NOT NULL, project_number TEXT,
+		started_at DATETIME NOT NULL, ended_at DATETIME NOT NULL, duration_seconds REAL NOT NULL)`)
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	d.Exec(`INSERT INTO focus_events (hostname, username, process_name, window_title, project_number, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?,?)`,
+		"OLD", "user", "chrome.exe", "Google", nil, base, base.Add(time.Minute), 60.0)
+	d.Close()
+
+	report, err := Reattribute(dir, testRules(t), time.Time{}, time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].New.Category != "Browsing" {
+		t.Errorf("got %+v", report.Changes)
+	}
+}
+
+func TestReattribute_SkipsOtherVersions(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+	seedTestDB(t, dir, "LAPTOP-TEST")
+	// The laptop still runs an older timewarp
+	laptop := filepath.Join(dir, "timewarp-LAPTOP-TEST.db")
+	d, _ := sql.Open("sqlite", "file:"+laptop)
+	d.Exec(`DELETE FROM schema_version WHERE version = ?`, len(migrations))
+	d.Close()
+
+	report, err := Reattribute(dir, testRules(t), time.Time{}, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 1 || len(report.Skipped) != 1 || !strings.HasPrefix(report.Skipped[0], "timewarp-LAPTOP-TEST.db") {
+		t.Errorf("expected the laptop's file skipped, got %+v", report)
+	}
+	d, _ = sql.Open("sqlite", "file:"+laptop)
+	defer d.Close()
+	if v, _ := schemaVersion(d); v != len(migrations)-1 {
+		t.Errorf("expected the laptop's file left at version %d, got %d", len(migrations)-1, v)
+	}
+	if n := countRows(t, d, "attribution_changes"); n != 0 {
+		t.Errorf("expected the laptop's file untouched, got %d changes", n)
+	}
+}
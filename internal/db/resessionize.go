@@ -0,0 +1,456 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/profiles"
+	"github.com/vinistoisr/timewarp/internal/rules"
+)
+
+// SessionCount totals the sessions in a span of a DB file.
+type SessionCount struct {
+	Sessions int     `json:"sessions"`
+	Meetings int     `json:"meetings"`
+	Minutes  float64 `json:"minutes"`
+}
+
+// ResessionizeReport is the result of Resessionize.
+type ResessionizeReport struct {
+	DryRun bool         `json:"dry_run"`
+	From   time.Time    `json:"from"`
+	To     time.Time    `json:"to"`
+	Runs   int          `json:"raw_runs"`
+	Before SessionCount `json:"before"`
+	After  SessionCount `json:"after"`
+	// Dropped lists the corrections in this machine's file that named
+	// replaced sessions, which are deleted with them
+	Dropped []string `json:"dropped_corrections,omitempty"`
+}
+
+// Resessionize rebuilds the focus sessions and meetings in this machine's
+// DB file from its raw focus log, stitching them with profiles p and
+// attributing them with r. Only [from, to) is rebuilt (a zero from or to
+// leaves that end open), narrowed to what the raw log covers and so that no
+// stored session is cut in two. Inactivity and system events are kept as
+// recorded and replayed along with the raw log. The session in progress, if
+// tracking is running, is left alone, as is everything after its start.
+//
+// Rebuilt sessions get new IDs, so corrections made to the old ones no
+// longer apply: those in this machine's file are deleted along with them and
+// listed in the report's Dropped. Unless dryRun is set the old sessions are
+// replaced in one transaction.
+func Resessionize(dbpath string, p *profiles.Set, r *rules.Set, from, to time.Time, dryRun bool) (*ResessionizeReport, error) {
+	d, _, err := openLocal(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	from, to, err = resessionizeSpan(d, from, to)
+	if err != nil {
+		return nil, err
+	}
+	report := &ResessionizeReport{DryRun: dryRun, From: from, To: to}
+	if report.Before, err = countSessions(d, from, to); err != nil {
+		return nil, fmt.Errorf("db: resessionize: %w", err)
+	}
+
+	scratch, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		return nil, fmt.Errorf("db: resessionize: %w", err)
+	}
+	defer scratch.Close()
+	scratch.SetMaxOpenConns(1)
+	if err := migrate(scratch); err != nil {
+		return nil, err
+	}
+	tr := newTracker(scratch)
+	tr.rules, tr.profiles = r, p
+	// Command lines were redacted, or left out, as they were logged
+	tr.keepCommandLines = true
+	if report.Runs, err = replayRaw(d, tr, from, to); err != nil {
+		return nil, fmt.Errorf("db: resessionize: %w", err)
+	}
+	if report.After, err = countSessions(scratch, from, to); err != nil {
+		return nil, fmt.Errorf("db: resessionize: %w", err)
+	}
+	if dryRun {
+		if _, report.Dropped, err = replacedOverrides(d, from, to); err != nil {
+			return nil, fmt.Errorf("db: resessionize: %w", err)
+		}
+		return report, nil
+	}
+	s, err := fileSealer(d)
+	if err != nil {
+		return nil, fmt.Errorf("db: resessionize: %w", err)
+	}
+	if report.Dropped, err = replaceSessions(d, scratch, s, from, to); err != nil {
+		return nil, fmt.Errorf("db: resessionize: %w", err)
+	}
+	return report, nil
+}
+
+// replacedOverrides returns the overrides in d that name a session
+// starting in [from, to), which replaceSessions replaces: their row IDs and
+// a description of each.
+func replacedOverrides(q querier, from, to time.Time) ([]int64, []string, error) {
+	replaced := map[string]bool{}
+	rows, err := q.Query(`SELECT hostname, id FROM focus_events WHERE started_at >= ? AND started_at < ?`,
+		from.UTC().Format("2006-01-02 15:04:05"), to.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var (
+			host string
+			id   int64
+		)
+		if err := rows.Scan(&host, &id); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		replaced[fmt.Sprintf("%s:%d", host, id)] = true
+	}
+	rows.Close()
+	if len(replaced) == 0 {
+		return nil, nil, nil
+	}
+
+	rows, err = q.Query(`SELECT id, kind, target, COALESCE(project_number, ''), split_at, COALESCE(merge_into, '') FROM session_overrides ORDER BY id`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var (
+		ids  []int64
+		desc []string
+	)
+	for rows.Next() {
+		var (
+			id      int64
+			o       override
+			splitAt sql.NullTime
+		)
+		if err := rows.Scan(&id, &o.kind, &o.target, &o.project, &splitAt, &o.mergeInto); err != nil {
+			return nil, nil, err
+		}
+		named := false
+		for _, s := range []string{o.target, o.mergeInto} {
+			if host, n, err := parseSessionID(s); err == nil && replaced[fmt.Sprintf("%s:%d", host, n)] {
+				named = true
+			}
+		}
+		if !named {
+			continue
+		}
+		ids = append(ids, id)
+		switch o.kind {
+		case "assign":
+			desc = append(desc, fmt.Sprintf("assign %s to %q", o.target, o.project))
+		case "split":
+			desc = append(desc, fmt.Sprintf("split %s at %s", o.target, splitAt.Time.UTC().Format(time.RFC3339)))
+		default:
+			desc = append(desc, fmt.Sprintf("%s %s into %s", o.kind, o.target, o.mergeInto))
+		}
+	}
+	return ids, desc, rows.Err()
+}
+
+// resessionizeSpan narrows [from, to) to the raw log in d and the time
+// before any session in progress, then moves each end off any stored
+// session or meeting that straddles it.
+func resessionizeSpan(d *sql.DB, from, to time.Time) (time.Time, time.Time, error) {
+	var first, last time.Time
+	if err := d.QueryRow(`SELECT started_at FROM raw_events ORDER BY started_at LIMIT 1`).Scan(&first); err == sql.ErrNoRows {
+		return from, to, fmt.Errorf("db: resessionize: there is no raw focus log; run Timewarp with -raw-log to keep one")
+	} else if err != nil {
+		return from, to, fmt.Errorf("db: resessionize: %w", err)
+	}
+	if err := d.QueryRow(`SELECT ended_at FROM raw_events ORDER BY ended_at DESC LIMIT 1`).Scan(&last); err != nil {
+		return from, to, fmt.Errorf("db: resessionize: %w", err)
+	}
+	last = last.Add(time.Second) // so that the last run's end is in range
+	var pending time.Time
+	if err := d.QueryRow(`SELECT started_at FROM pending_session WHERE id = 1`).Scan(&pending); err == nil && pending.Before(last) {
+		last = pending
+	}
+
+	if from.IsZero() || from.Before(first) {
+		from = first
+	}
+	if to.IsZero() || to.After(last) {
+		to = last
+	}
+	// Sessions past their retention are only in the rollups now
+	if a := archivedBefore(d); from.Before(a) {
+		from = a
+	}
+
+	const stored = `SELECT started_at, ended_at FROM focus_events UNION ALL SELECT started_at, ended_at FROM meeting_sessions`
+	var end, start time.Time
+	fromStr, toStr := from.UTC().Format("2006-01-02 15:04:05"), to.UTC().Format("2006-01-02 15:04:05")
+	if d.QueryRow(`SELECT ended_at FROM (`+stored+`) WHERE started_at < ? AND ended_at > ? ORDER BY ended_at DESC LIMIT 1`, fromStr, fromStr).Scan(&end) == nil {
+		from = end
+	}
+	if d.QueryRow(`SELECT started_at FROM (`+stored+`) WHERE started_at < ? AND ended_at > ? ORDER BY started_at LIMIT 1`, toStr, toStr).Scan(&start) == nil {
+		to = start
+	}
+	from, to = from.UTC(), to.UTC()
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("db: resessionize: the raw focus log does not cover the requested days")
+	}
+	return from, to, nil
+}
+
+// replayRaw feeds the raw focus log in d for [from, to), with the
+// inactivity and system events recorded alongside it, through tr, and
+// returns how many runs it replayed. Runs are replayed as a sample every
+// second, as the tracker would have seen them when polling.
+func replayRaw(d *sql.DB, tr *Tracker, from, to time.Time) (int, error) {
+	clamp := func(t time.Time) time.Time {
+		if t.Before(from) {
+			return from
+		}
+		if t.After(to) {
+			return to
+		}
+		return t
+	}
+	fromStr, toStr := from.Format("2006-01-02 15:04:05"), to.Format("2006-01-02 15:04:05")
+
+	// Inactivity and system events, in time order. A system event goes
+	// ahead of a sample at the same time and inactivity after it, as a lock
+	// ends the session at once while idle time runs up to the sample.
+	type event struct {
+		at    time.Time
+		order int
+		do    func()
+	}
+	var events []event
+	rows, err := d.Query(`SELECT hostname, username, event, occurred_at FROM system_events WHERE occurred_at >= ? AND occurred_at < ?`, fromStr, toStr)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var host, user, name string
+		var at time.Time
+		if err := rows.Scan(&host, &user, &name, &at); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event{at, 0, func() { tr.RecordSystemEvent(host, user, name, at) }})
+	}
+	rows.Close()
+	rows, err = d.Query(`SELECT hostname, username, started_at, ended_at FROM inactivity_periods WHERE started_at < ? AND ended_at > ?`, toStr, fromStr)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var host, user string
+		var start, end time.Time
+		if err := rows.Scan(&host, &user, &start, &end); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		start, end = clamp(start), clamp(end)
+		events = append(events,
+			event{start, 1, func() { tr.RecordInactivityStart(start) }},
+			event{end, 1, func() { tr.RecordInactivityEnd(host, user, end) }})
+	}
+	rows.Close()
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].order < events[j].order
+	})
+	until := func(t time.Time, order int) {
+		for len(events) > 0 && (events[0].at.Before(t) || events[0].at.Equal(t) && events[0].order <= order) {
+			events[0].do()
+			events = events[1:]
+		}
+	}
+
+	type run struct {
+		host, user string
+		win        Window
+		url        string
+		start, end time.Time
+	}
+	var runs []run
+	rows, err = d.Query(`SELECT hostname, username, process_name, COALESCE(exe_path, ''), COALESCE(window_class, ''), COALESCE(unseal(command_line), ''), COALESCE(app, ''), unseal(window_title), COALESCE(unseal(url), ''), started_at, ended_at FROM raw_events WHERE started_at < ? AND ended_at >= ? ORDER BY started_at, id`, toStr, fromStr)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var r run
+		if err := rows.Scan(&r.host, &r.user, &r.win.Process, &r.win.ExePath, &r.win.Class, &r.win.CommandLine, &r.win.App, &r.win.Title, &r.url, &r.start, &r.end); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		r.start, r.end = clamp(r.start), clamp(r.end)
+		if r.start.Before(to) {
+			runs = append(runs, r)
+		}
+	}
+	rows.Close()
+
+	// Each run is seen with the URL it was logged with
+	var url string
+	tr.SetURLs(func(string, string) string { return url })
+	for _, r := range runs {
+		url = r.url
+		for at := r.start; ; at = at.Add(time.Second) {
+			if at.After(r.end) {
+				at = r.end
+			}
+			until(at, 0)
+			tr.RecordWindow(r.host, r.user, r.win, at)
+			until(at, 1)
+			if !at.Before(r.end) {
+				break
+			}
+		}
+	}
+	until(to, 1)
+	tr.mu.Lock()
+	tr.flushPending(to)
+	tr.mu.Unlock()
+	return len(runs), nil
+}
+
+// countSessions totals the sessions and meetings in d that start in
+// [from, to).
+func countSessions(d *sql.DB, from, to time.Time) (SessionCount, error) {
+	var c SessionCount
+	fromStr, toStr := from.UTC().Format("2006-01-02 15:04:05"), to.UTC().Format("2006-01-02 15:04:05")
+	var secs float64
+	if err := d.QueryRow(`SELECT COUNT(*), COALESCE(SUM(duration_seconds), 0) FROM focus_events WHERE started_at >= ? AND started_at < ?`, fromStr, toStr).
+		Scan(&c.Sessions, &secs); err != nil {
+		return c, err
+	}
+	// Meetings are focus sessions too, so they are already in the minutes
+	if err := d.QueryRow(`SELECT COUNT(*) FROM meeting_sessions WHERE started_at >= ? AND started_at < ?`, fromStr, toStr).
+		Scan(&c.Meetings); err != nil {
+		return c, err
+	}
+	c.Minutes = round1(secs / 60.0)
+	return c, nil
+}
+
+// replaceSessions replaces the sessions, their titles and the meetings in
+// d that start in [from, to) with those in scratch, sealing their free
+// text with s. The overrides naming the old sessions are deleted, and
+// returned as replacedOverrides describes them.
+func replaceSessions(d, scratch *sql.DB, s *sealer, from, to time.Time) ([]string, error) {
+	type session struct {
+		id                           int64
+		host, user, process, title   string
+		url, exe, class, cmd, app    sql.NullString
+		project, client, task, categ sql.NullString
+		start, end                   time.Time
+		secs, idle                   float64
+	}
+	var sessions []session
+	rows, err := scratch.Query(`SELECT id, hostname, username, process_name, window_title, url, exe_path, window_class, command_line, app, project_number, client, task, category, started_at, ended_at, duration_seconds, COALESCE(idle_seconds, 0) FROM focus_events ORDER BY started_at`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s session
+		if err := rows.Scan(&s.id, &s.host, &s.user, &s.process, &s.title, &s.url, &s.exe, &s.class, &s.cmd, &s.app, &s.project, &s.client, &s.task, &s.categ, &s.start, &s.end, &s.secs, &s.idle); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	rows.Close()
+	titles, urls, err := loadTitles(scratch, `1=1`)
+	if err != nil {
+		return nil, err
+	}
+
+	type meeting struct {
+		host, user, process, subject string
+		app, attendees               sql.NullString
+		start, end                   time.Time
+		secs                         float64
+	}
+	var meetings []meeting
+	rows, err = scratch.Query(`SELECT hostname, username, process_name, subject, app, attendees, started_at, ended_at, duration_seconds FROM meeting_sessions ORDER BY started_at`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m meeting
+		if err := rows.Scan(&m.host, &m.user, &m.process, &m.subject, &m.app, &m.attendees, &m.start, &m.end, &m.secs); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		meetings = append(meetings, m)
+	}
+	rows.Close()
+
+	tx, err := d.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	ids, dropped, err := replacedOverrides(tx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, err := tx.Exec(`DELETE FROM session_overrides WHERE id = ?`, id); err != nil {
+			return nil, err
+		}
+	}
+	fromStr, toStr := from.UTC().Format("2006-01-02 15:04:05"), to.UTC().Format("2006-01-02 15:04:05")
+	if err := rollupRows(tx, -1, `started_at >= ? AND started_at < ?`, fromStr, toStr); err != nil {
+		return nil, err
+	}
+	for _, q := range []string{
+		`DELETE FROM focus_event_titles WHERE focus_event_id IN (SELECT id FROM focus_events WHERE started_at >= ? AND started_at < ?)`,
+		`DELETE FROM focus_events WHERE started_at >= ? AND started_at < ?`,
+		`DELETE FROM meeting_sessions WHERE started_at >= ? AND started_at < ?`,
+	} {
+		if _, err := tx.Exec(q, fromStr, toStr); err != nil {
+			return nil, err
+		}
+	}
+	sealed := s.batch()
+	for _, e := range sessions {
+		res, err := tx.Exec(
+			`INSERT INTO focus_events (hostname, username, process_name, process_key, window_title, url, exe_path, window_class, command_line, app, project_number, client, task, category, started_at, ended_at, duration_seconds, idle_seconds) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+			e.host, e.user, e.process, processKey(e.process), sealed.seal(e.title), sealed.sealNull(e.url), e.exe, e.class, sealed.sealNull(e.cmd), e.app, e.project, e.client, e.task, e.categ, e.start.UTC(), e.end.UTC(), e.secs, e.idle)
+		if err != nil {
+			return nil, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		for i, title := range titles[e.id] {
+			if _, err := tx.Exec(`INSERT INTO focus_event_titles (focus_event_id, title, url) VALUES (?,?,?)`, id, sealed.seal(title), nullable(sealed.seal(urls[e.id][i]))); err != nil {
+				return nil, err
+			}
+		}
+		if err := rollupRow(tx, id, 1); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range meetings {
+		if _, err := tx.Exec(
+			`INSERT INTO meeting_sessions (hostname, username, process_name, app, subject, attendees, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?,?,?)`,
+			m.host, m.user, m.process, m.app, sealed.seal(m.subject), sealed.sealNull(m.attendees), m.start.UTC(), m.end.UTC(), m.secs); err != nil {
+			return nil, err
+		}
+	}
+	if sealed.err != nil {
+		return nil, sealed.err
+	}
+	return dropped, tx.Commit()
+}
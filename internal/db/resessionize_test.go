@@ -0,0 +1,193 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/profiles"
+	"github.com/vinistoisr/timewarp/internal/rules"
+)
+
+// recordRaw tracks a morning into dir with the raw log on: 60s of AutoCAD,
+// 45s in a dialog host that is never tracked, 60s more AutoCAD with 20s idle
+// in it, a 5s call, then a Teams meeting.
+func recordRaw(t *testing.T, dir string, base time.Time) *Tracker {
+	t.Helper()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetRawLog(true, 0)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+	for i := 0; i < 60; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "25-125_SLD-E101.dwg - AutoCAD", at(i))
+	}
+	for i := 60; i < 105; i++ {
+		tr.RecordFocus("HOST", "user", "applicationframehost.exe", "Plot", at(i))
+	}
+	for i := 105; i < 165; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "25-125_SLD-E102.dwg - AutoCAD", at(i))
+		if i == 120 {
+			tr.RecordInactivityStart(at(i))
+		}
+		if i == 140 {
+			tr.RecordInactivityEnd("HOST", "user", at(i))
+		}
+	}
+	for i := 165; i < 171; i++ {
+		tr.RecordFocus("HOST", "user", "phone.exe", "Call", at(i))
+	}
+	for i := 171; i < 300; i++ {
+		tr.RecordFocus("HOST", "user", "ms-teams.exe", "Meeting 25-019 Design Review", at(i))
+	}
+	return tr
+}
+
+func storedSessions(t *testing.T, tr *Tracker) string {
+	t.Helper()
+	rows, err := tr.db.Query(`SELECT process_name, COALESCE(project_number, ''), duration_seconds, COALESCE(idle_seconds, 0) FROM focus_events ORDER BY started_at`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var proc, proj string
+		var dur, idle float64
+		rows.Scan(&proc, &proj, &dur, &idle)
+		got = append(got, fmt.Sprintf("%s %s %.0fs idle %.0fs", proc, proj, dur, idle))
+	}
+	return strings.Join(got, ", ")
+}
+
+func TestResessionize_NewProfiles(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tr := recordRaw(t, dir, base)
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(310*time.Second))
+	tr.Close()
+
+	d := func(v time.Duration) *profiles.Duration { x := profiles.Duration(v); return &x }
+	p, err := profiles.New([]profiles.Profile{
+		{Process: `^acad\.exe$`, Bridge: d(2 * time.Minute)},
+		{Process: `^phone\.exe$`, Minimum: d(2 * time.Second)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Resessionize(dir, p, rules.Default(), time.Time{}, time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Before.Sessions != 3 || report.After.Sessions != 3 || report.Before.Meetings != 1 || report.After.Meetings != 1 {
+		t.Errorf("unexpected dry run report: %+v", report)
+	}
+
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := "acad.exe 25-125 59s idle 0s, acad.exe 25-125 59s idle 20s, ms-teams.exe 25-019 128s idle 0s"
+	if got := storedSessions(t, tr); got != before {
+		t.Fatalf("dry run changed the sessions, or they were recorded unexpectedly: %s", got)
+	}
+	tr.Close()
+
+	if _, err := Resessionize(dir, p, rules.Default(), time.Time{}, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	// AutoCAD is bridged over the dialog and keeps its idle time and both
+	// titles, the call is kept, and the meeting is rebuilt as it was
+	if got, want := storedSessions(t, tr), "acad.exe 25-125 164s idle 20s, phone.exe  5s idle 0s, ms-teams.exe 25-019 128s idle 0s"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if n := countRows(t, tr.db, "focus_event_titles"); n != 4 {
+		t.Errorf("expected 4 titles, got %d", n)
+	}
+	if n := countRows(t, tr.db, "meeting_sessions"); n != 1 {
+		t.Errorf("expected 1 meeting, got %d", n)
+	}
+}
+
+func TestResessionize_LeavesSessionInProgress(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tr := recordRaw(t, dir, base)
+	kill(tr) // the meeting is still pending
+
+	report, err := Resessionize(dir, profiles.Default(), rules.Default(), time.Time{}, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.To.Equal(base.Add(171 * time.Second)) {
+		t.Errorf("expected the rebuild to stop where the meeting started, got %v", report.To)
+	}
+
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(time.Hour))
+	defer tr.Close()
+	if n := countRows(t, tr.db, "meeting_sessions"); n != 1 {
+		t.Errorf("expected the pending meeting to be written once, got %d", n)
+	}
+}
+
+func TestResessionize_NoRawLog(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Close()
+	if _, err := Resessionize(dir, profiles.Default(), rules.Default(), time.Time{}, time.Time{}, true); err == nil {
+		t.Error("expected an error without a raw log")
+	}
+}
+
+func TestResessionize_DropsCorrections(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tr := recordRaw(t, dir, base)
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Google", base.Add(310*time.Second))
+	tr.Close()
+	if _, err := AssignProject(dir, []string{"HOST:1"}, time.Time{}, time.Time{}, "", "25-200"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MergeSessions(dir, []string{"HOST:2", "HOST:3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Resessionize(dir, profiles.Default(), rules.Default(), time.Time{}, time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `assign HOST:1 to "25-200"|merge HOST:3 into HOST:2`; strings.Join(report.Dropped, "|") != want {
+		t.Errorf("dry run: got %q, want %s", report.Dropped, want)
+	}
+
+	report, err = Resessionize(dir, profiles.Default(), rules.Default(), time.Time{}, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Dropped) != 2 {
+		t.Errorf("expected 2 corrections dropped, got %q", report.Dropped)
+	}
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	if n := countRows(t, tr.db, "session_overrides"); n != 0 {
+		t.Errorf("expected the corrections deleted, got %d", n)
+	}
+}
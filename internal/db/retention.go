@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// retentionInterval is how often the retention settings are enforced.
+const retentionInterval = time.Hour
+
+// Retention is how long this machine's file keeps detail. After TitleDays
+// days a session's titles, URLs and command line are dropped and its title
+// collapses to its process name, as do meeting subjects; after
+// SessionMonths months sessions, meetings and inactivity are folded into
+// hourly rollups, which the queries count in their place, and deleted with
+// their titles. Zero keeps them for good.
+type Retention struct {
+	TitleDays     int
+	SessionMonths int
+}
+
+// retention is the Tracker's Retention and when it was last enforced.
+type retention struct {
+	Retention
+	enforced time.Time
+}
+
+// SetRetention sets how long detail is kept. It is enforced as tracking
+// goes on, at most every retentionInterval.
+func (t *Tracker) SetRetention(r Retention) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retention.Retention = r
+	t.retention.enforced = time.Time{}
+}
+
+// checkRetention enforces the retention settings if they have not been for
+// retentionInterval.
+func (t *Tracker) checkRetention(now time.Time) {
+	r := t.retention
+	if r.TitleDays <= 0 && r.SessionMonths <= 0 || now.Sub(r.enforced) < retentionInterval {
+		return
+	}
+	t.retention.enforced = now
+	if r.TitleDays > 0 {
+		if err := collapseTitles(t.db, now.AddDate(0, 0, -r.TitleDays)); err != nil {
+			log.Printf("db: retention: %v", err)
+		}
+	}
+	if r.SessionMonths > 0 {
+		if err := rollUp(t.db, now.AddDate(0, -r.SessionMonths, 0)); err != nil {
+			log.Printf("db: retention: %v", err)
+		}
+	}
+}
+
+// collapseTitles drops the free text of the sessions, meetings and raw log
+// rows in d that ended before cutoff, leaving their process names.
+func collapseTitles(d *sql.DB, cutoff time.Time) error {
+	c := cutoff.UTC().Format("2006-01-02 15:04:05")
+	for _, q := range []string{
+		`DELETE FROM focus_event_titles WHERE focus_event_id IN (SELECT id FROM focus_events WHERE ended_at < ?)`,
+		`UPDATE focus_events SET window_title = process_name, url = NULL, command_line = NULL WHERE ended_at < ? AND (window_title <> process_name OR url IS NOT NULL OR command_line IS NOT NULL)`,
+		`UPDATE meeting_sessions SET subject = COALESCE(app, process_name), attendees = NULL WHERE ended_at < ? AND (subject <> COALESCE(app, process_name) OR attendees IS NOT NULL)`,
+		`UPDATE raw_events SET window_title = process_name, url = NULL, command_line = NULL WHERE ended_at < ? AND (window_title <> process_name OR url IS NOT NULL OR command_line IS NOT NULL)`,
+	} {
+		if _, err := d.Exec(q, c); err != nil {
+			return fmt.Errorf("collapse titles: %w", err)
+		}
+	}
+	return nil
+}
+
+// rollUp archives the time in d before the hour of cutoff: the sessions
+// there are deleted with their titles, and their time in the rollups is
+// swapped for their time with the overrides made on this machine applied. A
+// session that runs past the hour is cut there, keeping the rest. Meetings
+// and inactivity are moved to their own rollups the same way (see archive).
+// System events are kept, as away time is worked out from them.
+func rollUp(d *sql.DB, cutoff time.Time) error {
+	marker := cutoff.UTC().Truncate(time.Hour)
+	if !archivedBefore(d).Before(marker) {
+		return nil
+	}
+	recorded := loadFocusSessions(d, time.Time{}, marker, processFilter{})
+	corrected := applyOverrides(loadFocusSessions(d, time.Time{}, marker, processFilter{}), loadOverrides([]*sql.DB{d}))
+
+	tx, err := d.Begin()
+	if err != nil {
+		return fmt.Errorf("roll up: %w", err)
+	}
+	defer tx.Rollback()
+	for _, s := range recorded {
+		if err := addRollupBefore(tx, s, marker, -1); err != nil {
+			return fmt.Errorf("roll up: %w", err)
+		}
+	}
+	for _, s := range corrected {
+		if err := addRollupBefore(tx, s, marker, 1); err != nil {
+			return fmt.Errorf("roll up: %w", err)
+		}
+	}
+	if err := archive(tx, marker); err != nil {
+		return fmt.Errorf("roll up: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("roll up: %w", err)
+	}
+	return nil
+}
+
+// addRollupBefore adds sign times the part of s before marker to the
+// rollups.
+func addRollupBefore(q querier, s *Session, marker time.Time, sign float64) error {
+	share := within(s.Start, s.End, s.Start, marker)
+	if share == 0 {
+		return nil
+	}
+	end := s.End
+	if end.After(marker) {
+		end = marker
+	}
+	return addRollup(q, s.Machine, s.Process, s.App, s.Project, s.Start, end, s.seconds*share, s.idle*share, sign)
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetention_CollapsesTitles(t *testing.T) {
+	tr, _ := tempTracker(t)
+	defer tr.Close()
+
+	now := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	old, recent := now.AddDate(0, 0, -40), now.AddDate(0, 0, -10)
+	for _, at := range []time.Time{old, recent} {
+		res, _ := tr.db.Exec(`INSERT INTO focus_events (hostname, username, process_name, window_title, url, command_line, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?,?,?)`,
+			"HOST", "user", "chrome.exe", "Contoso - Inbox", "https://mail.example.com/", "chrome.exe --profile=work", at, at.Add(time.Hour), 3600.0)
+		id, _ := res.LastInsertId()
+		tr.db.Exec(`INSERT INTO focus_event_titles (focus_event_id, title) VALUES (?,?)`, id, "Contoso - Inbox")
+		tr.db.Exec(`INSERT INTO meeting_sessions (hostname, username, process_name, app, subject, attendees, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?,?,?)`,
+			"HOST", "user", "ms-teams.exe", "Teams", "Contoso kickoff", "Ann Lee", at, at.Add(time.Hour), 3600.0)
+	}
+
+	tr.SetRetention(Retention{TitleDays: 30})
+	tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", now)
+
+	var title, subject string
+	var url, cmd, attendees *string
+	tr.db.QueryRow(`SELECT window_title, url, command_line FROM focus_events ORDER BY started_at LIMIT 1`).Scan(&title, &url, &cmd)
+	if title != "chrome.exe" || url != nil || cmd != nil {
+		t.Errorf("expected the old session collapsed to its process, got %q %v %v", title, url, cmd)
+	}
+	tr.db.QueryRow(`SELECT subject, attendees FROM meeting_sessions ORDER BY started_at LIMIT 1`).Scan(&subject, &attendees)
+	if subject != "Teams" || attendees != nil {
+		t.Errorf("expected the old meeting collapsed to its app, got %q %v", subject, attendees)
+	}
+	if n := countRows(t, tr.db, "focus_event_titles"); n != 1 {
+		t.Errorf("expected only the recent session's titles kept, got %d", n)
+	}
+	tr.db.QueryRow(`SELECT window_title FROM focus_events ORDER BY started_at DESC LIMIT 1`).Scan(&title)
+	if title != "Contoso - Inbox" {
+		t.Errorf("expected the recent session untouched, got %q", title)
+	}
+}
+
+func TestRetention_RollsUpSessions(t *testing.T) {
+	dir := t.TempDir()
+	hostname, _ := os.Hostname()
+	seedTestDB(t, dir, hostname)
+	// A correction made before the sessions are rolled up is kept
+	if _, err := AssignProject(dir, []string{hostname + ":3"}, time.Time{}, time.Time{}, "", "25-200"); err != nil {
+		t.Fatal(err)
+	}
+	wantAttr, wantUnattr := weeklyByProject(t, dir)
+
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.db.Exec(`INSERT INTO focus_event_titles (focus_event_id, title) VALUES (1, '25-125_SLD-E101.dwg - AutoCAD')`)
+	for _, e := range []struct {
+		event string
+		at    time.Duration
+	}{{"lock", 17 * time.Hour}, {"unlock", 18 * time.Hour}} {
+		tr.db.Exec(`INSERT INTO system_events (hostname, username, event, occurred_at) VALUES (?,?,?,?)`,
+			hostname, "user", e.event, overrideMonday.Add(e.at))
+	}
+	from, to := overrideMonday, overrideMonday.AddDate(0, 0, 1)
+	wantPeriods := periodTotals(t, dir, from, to)
+	if wantPeriods["2026-03-02 away"] != 60 {
+		t.Fatalf("expected an hour away, got %v", wantPeriods)
+	}
+	tr.SetRetention(Retention{SessionMonths: 3})
+	tr.RecordFocus("HOST", "user", "acad.exe", "drawing.dwg", overrideMonday.AddDate(0, 4, 0))
+	for _, table := range []string{"focus_events", "focus_event_titles", "meeting_sessions", "inactivity_periods"} {
+		if n := countRows(t, tr.db, table); n != 0 {
+			t.Errorf("expected old %s deleted, got %d", table, n)
+		}
+	}
+	if n := countRows(t, tr.db, "system_events"); n != 2 {
+		t.Errorf("expected the system events kept, got %d", n)
+	}
+	tr.Close()
+	// Meetings, inactivity and away time still count
+	compareTotals(t, periodTotals(t, dir, from, to), wantPeriods)
+
+	attr, unattr := weeklyByProject(t, dir)
+	if len(attr) != len(wantAttr) || len(unattr) != len(wantUnattr) {
+		t.Fatalf("got %+v %+v, want %+v %+v", attr, unattr, wantAttr, wantUnattr)
+	}
+	for p, want := range wantAttr {
+		if attr[p].TotalMinutes != want.TotalMinutes {
+			t.Errorf("%s: got %v minutes, want %v", p, attr[p].TotalMinutes, want.TotalMinutes)
+		}
+		if len(attr[p].SampleTitles) != 0 {
+			t.Errorf("%s: expected no titles for rolled-up time, got %q", p, attr[p].SampleTitles)
+		}
+	}
+	if attr["25-200"].TotalMinutes != 45 {
+		t.Errorf("expected the reassigned session in its rollup, got %+v", attr["25-200"])
+	}
+
+	// The rollups are listed as hour-long sessions
+	sessions, err := ListSessions(dir, overrideMonday, overrideMonday.AddDate(0, 0, 1), "acad.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || !sessions[0].RolledUp || sessions[0].Minutes != 60 || sessions[0].End.Sub(sessions[0].Start) != time.Hour {
+		t.Errorf("unexpected sessions: %+v", sessions)
+	}
+	if _, err := SplitSession(dir, sessions[0].ID, sessions[0].Start.Add(time.Minute)); err == nil {
+		t.Error("expected an error splitting a rolled-up hour")
+	}
+}
+
+// periodTotals returns each day's meeting minutes and sessions, and
+// inactivity and away minutes, in [from, to), from GetDailyBreakdown.
+func periodTotals(t *testing.T, dir string, from, to time.Time) map[string]float64 {
+	t.Helper()
+	raw, err := GetDailyBreakdown(dir, from, to, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b DailyBreakdown
+	json.Unmarshal(raw, &b)
+	totals := map[string]float64{}
+	for _, day := range b.Days {
+		for _, m := range day.Meetings {
+			totals[day.Date+" meeting minutes"] += m.TotalMinutes
+			totals[day.Date+" meetings"] += float64(m.Sessions)
+		}
+		totals[day.Date+" inactivity"] = day.InactivityMinutes
+		totals[day.Date+" away"] = day.AwayMinutes
+	}
+	return totals
+}
+
+func TestRetention_RollsUpMeetingsAndInactivity(t *testing.T) {
+	dir := t.TempDir()
+	seedTestDB(t, dir, "DESKTOP-TEST")
+	path := filepath.Join(dir, "timewarp-DESKTOP-TEST.db")
+	d, err := openForUpdate(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A meeting and inactivity that run over the hour archived up to
+	base := overrideMonday.Add(9 * time.Hour)
+	d.Exec(`INSERT INTO meeting_sessions (hostname, username, process_name, subject, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?)`,
+		"DESKTOP-TEST", "user", "ms-teams.exe", "Contoso call", base.Add(330*time.Minute), base.Add(390*time.Minute), 3600.0)
+	d.Exec(`INSERT INTO inactivity_periods (hostname, username, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?)`,
+		"DESKTOP-TEST", "user", base.Add(345*time.Minute), base.Add(405*time.Minute), 3600.0)
+	d.Close()
+	from, to := overrideMonday, overrideMonday.AddDate(0, 0, 2)
+	want := periodTotals(t, dir, from, to)
+	if want["2026-03-02 meetings"] != 2 || want["2026-03-02 inactivity"] != 90 {
+		t.Fatalf("unexpected totals before: %v", want)
+	}
+
+	d, err = openForUpdate(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rollUp(d, base.Add(6*time.Hour+10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for table, want := range map[string]int{"meeting_sessions": 1, "inactivity_periods": 1, "meeting_rollups": 2, "inactivity_rollups": 1} {
+		if n := countRows(t, d, table); n != want {
+			t.Errorf("expected %d %s left, got %d", want, table, n)
+		}
+	}
+	d.Close()
+	compareTotals(t, periodTotals(t, dir, from, to), want)
+}
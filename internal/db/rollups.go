@@ -0,0 +1,508 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The rollups table holds each file's focus time by the hour: one row per
+// UTC hour, machine, process, app and project, with the seconds and idle
+// seconds of the sessions in it. Hours rather than days are kept so that
+// days and weeks in any time zone add up from them. The tracker adds each
+// session as it is written, and everything that changes stored sessions
+// adjusts them to match, so a long range can be totalled from a few rows
+// per hour instead of every session (see rollupSessions).
+//
+// Rollups hold sessions as they were recorded; corrections are applied at
+// query time. Sessions past their retention are deleted and live on only in
+// the rollups, which then hold them with this machine's corrections applied
+// (see rollUp). The hours before rollup_state's archived_before are those.
+//
+// Meetings and inactivity are read row by row for any range, and go into
+// meeting_rollups and inactivity_rollups only when they are archived, by
+// the hour as sessions are. Archived meetings are kept by app, as their
+// subjects go with them.
+
+const (
+	// rollupRange is the longest range totalled from the sessions; longer
+	// ones are totalled from the rollups.
+	rollupRange = 31 * 24 * time.Hour
+
+	// rollupsVersion is the schema version from which the rollups hold
+	// every session.
+	rollupsVersion = 16
+
+	// rollupEpsilon is the fewest seconds kept in a rollup row; taking a
+	// session back out can leave rounding errors behind.
+	rollupEpsilon = 1e-6
+)
+
+// addRollup adds sign (1 or -1) times a session's time to the rollups,
+// sharing it out over the hours the session spans with idle time spread
+// evenly.
+func addRollup(q querier, host, process, app, project string, start, end time.Time, seconds, idle, sign float64) error {
+	return byHour(start, end, func(h time.Time, share float64) error {
+		if _, err := q.Exec(
+			`INSERT INTO rollups (hostname, hour, process_name, app, project_number, seconds, idle_seconds) VALUES (?,?,?,?,?,?,?)
+			ON CONFLICT (hour, hostname, process_name, app, project_number) DO UPDATE SET seconds = seconds + excluded.seconds, idle_seconds = idle_seconds + excluded.idle_seconds`,
+			host, h, process, app, project, sign*seconds*share, sign*idle*share); err != nil {
+			return fmt.Errorf("rollups: %w", err)
+		}
+		if sign < 0 {
+			if _, err := q.Exec(`DELETE FROM rollups WHERE hour = ? AND hostname = ? AND process_name = ? AND app = ? AND project_number = ? AND seconds < ?`,
+				h, host, process, app, project, rollupEpsilon); err != nil {
+				return fmt.Errorf("rollups: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// byHour calls f with each UTC hour that [start, end) spans and the share
+// of it in that hour.
+func byHour(start, end time.Time, f func(h time.Time, share float64) error) error {
+	start, end = start.UTC(), end.UTC()
+	for h := start.Truncate(time.Hour); h.Before(end) || h.Equal(start); h = h.Add(time.Hour) {
+		share := within(start, end, h, h.Add(time.Hour))
+		if share == 0 {
+			continue
+		}
+		if err := f(h, share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollupRow adds sign times the focus_events row id to the rollups, as
+// stored.
+func rollupRow(q querier, id int64, sign float64) error {
+	var (
+		host, process, app, project string
+		start, end                  time.Time
+		seconds, idle               float64
+	)
+	if err := q.QueryRow(`SELECT hostname, process_name, COALESCE(app, process_name), COALESCE(project_number, ''), started_at, ended_at, duration_seconds, COALESCE(idle_seconds, 0) FROM focus_events WHERE id = ?`, id).
+		Scan(&host, &process, &app, &project, &start, &end, &seconds, &idle); err != nil {
+		return fmt.Errorf("rollups: session %d: %w", id, err)
+	}
+	return addRollup(q, host, process, app, project, start, end, seconds, idle, sign)
+}
+
+// rollupRows adds sign times every focus_events row matching where.
+func rollupRows(q querier, sign float64, where string, args ...any) error {
+	rows, err := q.Query(`SELECT id FROM focus_events WHERE `+where, args...)
+	if err != nil {
+		return fmt.Errorf("rollups: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("rollups: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		if err := rollupRow(q, id, sign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archivedBefore returns the time before which d's sessions were deleted
+// for retention, leaving only their rollups. Until rollupsVersion the
+// rollups held nothing else.
+func archivedBefore(d querier) time.Time {
+	if v, _ := schemaVersion(d); v < rollupsVersion {
+		return time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	var t time.Time
+	d.QueryRow(`SELECT archived_before FROM rollup_state WHERE id = 1`).Scan(&t)
+	return t.UTC()
+}
+
+// archive deletes the sessions in q that started before the hour marker,
+// which the rollups hold by then, and cuts those that run over it so that
+// they start at it. Their time before it stays in the rollups only. The
+// meetings and inactivity before it are moved to their rollups the same way
+// (see archivePeriods).
+func archive(q querier, marker time.Time) error {
+	if err := archivePeriods(q, marker); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	m := marker.UTC().Format("2006-01-02 15:04:05")
+	type cutRow struct {
+		id            int64
+		start, end    time.Time
+		seconds, idle float64
+	}
+	var cuts []cutRow
+	rows, err := q.Query(`SELECT id, started_at, ended_at, duration_seconds, COALESCE(idle_seconds, 0) FROM focus_events WHERE started_at < ? AND ended_at > ?`, m, m)
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	for rows.Next() {
+		var c cutRow
+		if err := rows.Scan(&c.id, &c.start, &c.end, &c.seconds, &c.idle); err != nil {
+			rows.Close()
+			return fmt.Errorf("archive: %w", err)
+		}
+		cuts = append(cuts, c)
+	}
+	rows.Close()
+	for _, c := range cuts {
+		keep := within(c.start, c.end, marker, c.end)
+		if _, err := q.Exec(`UPDATE focus_events SET started_at = ?, duration_seconds = ?, idle_seconds = ? WHERE id = ?`,
+			marker.UTC(), c.seconds*keep, c.idle*keep, c.id); err != nil {
+			return fmt.Errorf("archive: %w", err)
+		}
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM focus_event_titles WHERE focus_event_id IN (SELECT id FROM focus_events WHERE started_at < ?)`,
+		`DELETE FROM attribution_changes WHERE focus_event_id IN (SELECT id FROM focus_events WHERE started_at < ?)`,
+		`DELETE FROM focus_events WHERE started_at < ?`,
+	} {
+		if _, err := q.Exec(stmt, m); err != nil {
+			return fmt.Errorf("archive: %w", err)
+		}
+	}
+	if _, err := q.Exec(`INSERT INTO rollup_state (id, archived_before) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET archived_before = excluded.archived_before`, marker.UTC()); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return nil
+}
+
+// periodRollups are the tables archivePeriods moves to rollups: each row's
+// process and app, selected by key, and how its time is added to the hour
+// h of its rollup, with the number of rows that started in that hour.
+var periodRollups = []struct {
+	table, key string
+	add        func(q querier, host, process, app string, h time.Time, seconds float64, started int) error
+}{
+	{"meeting_sessions", "process_name, COALESCE(app, process_name)", func(q querier, host, process, app string, h time.Time, seconds float64, started int) error {
+		_, err := q.Exec(`INSERT INTO meeting_rollups (hostname, hour, process_name, app, seconds, meetings) VALUES (?,?,?,?,?,?)
+			ON CONFLICT (hour, hostname, process_name, app) DO UPDATE SET seconds = seconds + excluded.seconds, meetings = meetings + excluded.meetings`,
+			host, h, process, app, seconds, started)
+		return err
+	}},
+	{"inactivity_periods", "'', ''", func(q querier, host, _, _ string, h time.Time, seconds float64, _ int) error {
+		_, err := q.Exec(`INSERT INTO inactivity_rollups (hostname, hour, seconds) VALUES (?,?,?)
+			ON CONFLICT (hour, hostname) DO UPDATE SET seconds = seconds + excluded.seconds`,
+			host, h, seconds)
+		return err
+	}},
+}
+
+// archivePeriods moves the meetings and inactivity in q that started
+// before the hour marker to their rollups, shared out over the hours they
+// span: those that end by it are deleted, and those that run over it are
+// cut to start at it. A meeting is counted in the hour it started once it
+// is deleted, so that one cut in two is counted once.
+func archivePeriods(q querier, marker time.Time) error {
+	m := marker.UTC().Format("2006-01-02 15:04:05")
+	type periodRow struct {
+		id                 int64
+		host, process, app string
+		start, end         time.Time
+		seconds            float64
+	}
+	for _, t := range periodRollups {
+		var archived []periodRow
+		rows, err := q.Query(`SELECT id, hostname, `+t.key+`, started_at, ended_at, duration_seconds FROM `+t.table+` WHERE started_at < ?`, m)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.table, err)
+		}
+		for rows.Next() {
+			var r periodRow
+			if err := rows.Scan(&r.id, &r.host, &r.process, &r.app, &r.start, &r.end, &r.seconds); err != nil {
+				rows.Close()
+				return fmt.Errorf("%s: %w", t.table, err)
+			}
+			archived = append(archived, r)
+		}
+		rows.Close()
+
+		for _, r := range archived {
+			keep := within(r.start, r.end, marker, r.end)
+			end, started := r.end, 0
+			if keep > 0 {
+				end = marker
+			} else {
+				started = 1
+			}
+			first := r.start.UTC().Truncate(time.Hour)
+			if err := byHour(r.start, end, func(h time.Time, share float64) error {
+				n := 0
+				if h.Equal(first) {
+					n = started
+				}
+				return t.add(q, r.host, r.process, r.app, h, r.seconds*(1-keep)*share, n)
+			}); err != nil {
+				return fmt.Errorf("%s: %w", t.table, err)
+			}
+			if keep > 0 {
+				_, err = q.Exec(`UPDATE `+t.table+` SET started_at = ?, duration_seconds = ? WHERE id = ?`, marker.UTC(), r.seconds*keep, r.id)
+			} else {
+				_, err = q.Exec(`DELETE FROM `+t.table+` WHERE id = ?`, r.id)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", t.table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadArchived reads the rollups in d for the archived hours that overlap
+// [start, end), each as a session spanning its hour with no titles. Their
+// IDs are "hostname:rN".
+func loadArchived(d *sql.DB, start, end time.Time) []*Session {
+	if a := archivedBefore(d); a.Before(end) {
+		end = a
+	}
+	return loadRollups(d, start, end)
+}
+
+// loadRollups reads the rollups in d for the hours that overlap
+// [start, end), as loadArchived does.
+func loadRollups(d *sql.DB, start, end time.Time) []*Session {
+	startStr := start.UTC().Add(-time.Hour).Format("2006-01-02 15:04:05")
+	endStr := end.UTC().Format("2006-01-02 15:04:05")
+
+	rows, err := d.Query(`SELECT id, hostname, process_name, app, project_number, hour, seconds, idle_seconds FROM rollups WHERE hour < ? AND hour > ?`, endStr, startStr)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var sessions []*Session
+	for rows.Next() {
+		var (
+			id int64
+			s  Session
+		)
+		if rows.Scan(&id, &s.Machine, &s.Process, &s.App, &s.Project, &s.Start, &s.seconds, &s.idle) != nil {
+			continue
+		}
+		s.ID = fmt.Sprintf("%s:r%d", s.Machine, id)
+		s.Start = s.Start.UTC()
+		s.End = s.Start.Add(time.Hour)
+		s.RolledUp = true
+		s.base, s.baseStart = s.ID, s.Start
+		sessions = append(sessions, &s)
+	}
+	return sessions
+}
+
+// sessionsFor returns the sessions to total [start, end) from, only those
+// of process if it is set: those of loadSessions, or for a range longer
+// than rollupRange, rollupSessions.
+func sessionsFor(dbs []*sql.DB, start, end time.Time, process string) []*Session {
+	if end.Sub(start) > rollupRange {
+		return rollupSessions(dbs, start, end, process)
+	}
+	return loadSessions(dbs, start, end, process)
+}
+
+// rollupSessions returns sessions totalling the same as loadSessions over
+// [start, end), mostly from the rollups: an hour-long session per rollup
+// row. The hours touched by corrections, and files from before the rollups
+// held every session, come from the sessions instead, those in corrected
+// hours cut to fit them. All but the older files' sessions are marked
+// RolledUp, as they have no titles or IDs of use. If process is set, only
+// its sessions are returned.
+func rollupSessions(dbs []*sql.DB, start, end time.Time, process string) []*Session {
+	overrides := loadOverrides(dbs)
+	dirty := overriddenHours(dbs, overrides, start, end)
+	f := newProcessFilter(process, overrides)
+
+	var (
+		sessions, stored []*Session
+		// stored sessions to cut to the corrected hours
+		cut = map[string]bool{}
+	)
+	for _, d := range dbs {
+		if v, _ := schemaVersion(d); v < rollupsVersion {
+			stored = append(stored, loadFocusSessions(d, start, end, f)...)
+			stored = append(stored, loadArchived(d, start, end)...)
+			continue
+		}
+		for _, s := range loadRollups(d, start, end) {
+			if !inSpans(dirty, s.Start) {
+				sessions = append(sessions, s)
+			}
+		}
+		for _, h := range dirty {
+			for _, s := range append(loadFocusSessions(d, h.start, h.end, f), loadArchived(d, h.start, h.end)...) {
+				if !cut[s.ID] {
+					cut[s.ID] = true
+					stored = append(stored, s)
+				}
+			}
+		}
+	}
+
+	// Overrides can name sessions in any file, so they are replayed over
+	// all the stored sessions at once
+	for _, s := range applyOverrides(stored, overrides) {
+		if !cut[s.base] {
+			sessions = append(sessions, s)
+			continue
+		}
+		for _, h := range dirty {
+			if share := within(s.Start, s.End, h.start, h.end); share > 0 {
+				c, in := *s, clip(span{s.Start, s.End}, h)
+				c.Start, c.End = in.start, in.end
+				c.seconds *= share
+				c.idle *= share
+				c.RolledUp = true
+				sessions = append(sessions, &c)
+			}
+		}
+	}
+	var result []*Session
+	for _, s := range sessions {
+		if process == "" || s.isApp(process) {
+			s.setMinutes()
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// overriddenHours returns the whole hours spanned by the sessions that
+// overrides name, where they overlap [start, end), merged and in order.
+func overriddenHours(dbs []*sql.DB, overrides []override, start, end time.Time) []span {
+	var ids []string
+	for _, o := range overrides {
+		ids = append(ids, o.target)
+		if o.mergeInto != "" {
+			ids = append(ids, o.mergeInto)
+		}
+	}
+	covered := sessionSpans(dbs, ids)
+
+	var spans []span
+	for _, o := range overrides {
+		var s span
+		for _, id := range []string{o.target, o.mergeInto} {
+			if t, ok := covered[id]; ok {
+				if s.start.IsZero() || t.start.Before(s.start) {
+					s.start = t.start
+				}
+				if t.end.After(s.end) {
+					s.end = t.end
+				}
+			}
+		}
+		if s.start.IsZero() || !s.start.Before(end) || !s.end.After(start) {
+			continue
+		}
+		s.start = s.start.Truncate(time.Hour)
+		if t := s.end.Truncate(time.Hour); t.Equal(s.end) {
+			s.end = t
+		} else {
+			s.end = t.Add(time.Hour)
+		}
+		spans = append(spans, s)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+	var merged []span
+	for _, s := range spans {
+		if n := len(merged); n > 0 && !s.start.After(merged[n-1].end) {
+			if s.end.After(merged[n-1].end) {
+				merged[n-1].end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// sessionSpans returns the time each stored session or rollup row named by
+// ids covers, keyed by the ID as given. Each file is read once for all of
+// them.
+func sessionSpans(dbs []*sql.DB, ids []string) map[string]span {
+	// IDs by the row they name, as "hostname:n" or "hostname:rn" without
+	// any split offset
+	named := map[string][]string{}
+	var sessions, rollups []any
+	for _, id := range ids {
+		i := strings.LastIndex(id, ":")
+		if i <= 0 {
+			continue
+		}
+		num, _, _ := strings.Cut(id[i+1:], "+")
+		n, err := strconv.ParseInt(strings.TrimPrefix(num, "r"), 10, 64)
+		if err != nil {
+			continue
+		}
+		row := id[:i] + ":" + num
+		if len(named[row]) == 0 {
+			if strings.HasPrefix(num, "r") {
+				rollups = append(rollups, n)
+			} else {
+				sessions = append(sessions, n)
+			}
+		}
+		named[row] = append(named[row], id)
+	}
+	covered := map[string]span{}
+	if len(named) == 0 {
+		return covered
+	}
+
+	in := func(n int) string {
+		if n == 0 {
+			return "NULL"
+		}
+		return "?" + strings.Repeat(", ?", n-1)
+	}
+	query := `SELECT hostname, id, '', started_at, ended_at FROM focus_events WHERE id IN (` + in(len(sessions)) + `)
+		UNION ALL SELECT hostname, id, 'r', hour, hour FROM rollups WHERE id IN (` + in(len(rollups)) + `)`
+	args := append(append([]any{}, sessions...), rollups...)
+	for _, d := range dbs {
+		rows, err := d.Query(query, args...)
+		if err != nil {
+			continue
+		}
+		for rows.Next() {
+			var (
+				host, kind string
+				n          int64
+				s          span
+			)
+			if rows.Scan(&host, &n, &kind, &s.start, &s.end) != nil {
+				continue
+			}
+			if kind == "r" {
+				s.end = s.start.Add(time.Hour)
+			}
+			for _, id := range named[fmt.Sprintf("%s:%s%d", host, kind, n)] {
+				if _, ok := covered[id]; !ok {
+					covered[id] = span{s.start.UTC(), s.end.UTC()}
+				}
+			}
+		}
+		rows.Close()
+	}
+	return covered
+}
+
+func inSpans(spans []span, t time.Time) bool {
+	for _, s := range spans {
+		if !t.Before(s.start) && t.Before(s.end) {
+			return true
+		}
+	}
+	return false
+}
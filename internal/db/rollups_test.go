@@ -0,0 +1,296 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// seedMonths writes three months of sessions from 2026-01-05 for host,
+// some crossing hours and midnights, with their rollups, as the tracker
+// would.
+func seedMonths(t *testing.T, dir, host string, seed int64) {
+	t.Helper()
+	d, err := openMigrated(filepath.Join(dir, "timewarp-"+host+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	rng := rand.New(rand.NewSource(seed))
+	apps := []struct{ process, title, project string }{
+		{"acad.exe", "25-125_SLD-E101.dwg - AutoCAD", "25-125"},
+		{"acad.exe", "25-019 Site plan.dwg - AutoCAD", "25-019"},
+		{"OUTLOOK.EXE", "RE: 25-125 RFI #12", "25-125"},
+		{"chrome.exe", "ESPN - NBA Scores", ""},
+		{"code.exe", "main.go - timewarp", ""},
+	}
+	at := time.Date(2026, 1, 5, 13, 0, 0, 0, time.UTC)
+	for at.Before(time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)) {
+		a := apps[rng.Intn(len(apps))]
+		dur := time.Duration(5+rng.Intn(150)) * time.Minute
+		idle := dur.Seconds() * rng.Float64() / 4
+		if _, err := d.Exec(`INSERT INTO focus_events (hostname, username, process_name, window_title, project_number, started_at, ended_at, duration_seconds, idle_seconds) VALUES (?,?,?,?,?,?,?,?,?)`,
+			host, "user", a.process, a.title, nullable(a.project), at, at.Add(dur), dur.Seconds(), idle); err != nil {
+			t.Fatal(err)
+		}
+		at = at.Add(dur + time.Duration(rng.Intn(12*60))*time.Minute)
+	}
+	if err := rollupRows(d, 1, `1=1`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openMigrated opens the DB file at path for writing, creating it at the
+// latest schema version if needed.
+func openMigrated(path string) (*sql.DB, error) {
+	d, err := sql.Open("sqlite", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	d.SetMaxOpenConns(1)
+	if err := migrate(d); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// dayTotals totals sessions into minutes by local day and project, or app
+// for unattributed time.
+func dayTotals(sessions []*Session, from, to time.Time) map[string]float64 {
+	totals := map[string]float64{}
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		next := d.AddDate(0, 0, 1)
+		for _, s := range sessions {
+			key := s.Project
+			if key == "" {
+				key = s.App
+			}
+			if mins := s.activeSeconds() * within(s.Start, s.End, d, next) / 60; mins > 0 {
+				totals[d.Format("2006-01-02")+" "+key] += mins
+			}
+		}
+	}
+	return totals
+}
+
+func compareTotals(t *testing.T, got, want map[string]float64) {
+	t.Helper()
+	for k, w := range want {
+		if math.Abs(got[k]-w) > 0.01 {
+			t.Errorf("%s: got %.2f minutes, want %.2f", k, got[k], w)
+		}
+	}
+	for k, g := range got {
+		if _, ok := want[k]; !ok && g > 0.01 {
+			t.Errorf("%s: got %.2f minutes, want none", k, g)
+		}
+	}
+}
+
+func TestRollups_MatchSessions(t *testing.T) {
+	dir := t.TempDir()
+	seedMonths(t, dir, "HOST-A", 1)
+	seedMonths(t, dir, "HOST-B", 2)
+
+	// Corrections of every kind, some across machines
+	if _, err := AssignProject(dir, []string{"HOST-A:3", "HOST-B:40"}, time.Time{}, time.Time{}, "", "25-200"); err != nil {
+		t.Fatal(err)
+	}
+	sessions, _ := ListSessions(dir, time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 12, 0, 0, 0, 0, time.UTC), "")
+	if len(sessions) < 3 {
+		t.Fatalf("expected sessions to correct, got %d", len(sessions))
+	}
+	s := sessions[0]
+	if _, err := SplitSession(dir, s.ID, s.Start.Add(s.End.Sub(s.Start)/2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MergeSessions(dir, []string{sessions[1].ID, sessions[2].ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// One machine has archived its first month
+	d, err := openForUpdate(filepath.Join(dir, "timewarp-HOST-A.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rollUp(d, time.Date(2026, 2, 1, 10, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+
+	loc, _ := time.LoadLocation("America/Toronto")
+	from, to := time.Date(2026, 1, 1, 0, 0, 0, 0, loc), time.Date(2026, 4, 10, 0, 0, 0, 0, loc)
+	dbs, err := openAllDBs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeAll(dbs)
+
+	want := dayTotals(loadSessions(dbs, from, to, ""), from, to)
+	if len(want) < 100 {
+		t.Fatalf("expected a few months of totals, got %d", len(want))
+	}
+	compareTotals(t, dayTotals(rollupSessions(dbs, from, to, ""), from, to), want)
+}
+
+func TestRollups_LongRange(t *testing.T) {
+	dir := t.TempDir()
+	seedMonths(t, dir, "HOST-A", 1)
+	loc, _ := time.LoadLocation("America/Toronto")
+	from, to := time.Date(2026, 1, 1, 0, 0, 0, 0, loc), time.Date(2026, 4, 1, 0, 0, 0, 0, loc)
+
+	var short float64
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 7) {
+		for _, m := range dailyMinutes(t, dir, d, minTime(d.AddDate(0, 0, 7), to)) {
+			short += m
+		}
+	}
+	var long float64
+	for _, m := range dailyMinutes(t, dir, from, to) {
+		long += m
+	}
+	if short == 0 || math.Abs(short-long) > 1 {
+		t.Errorf("expected the rollups to total what the sessions do, got %.1f and %.1f", long, short)
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// checkRollups fails unless the rollups of d's stored sessions total the
+// same as the sessions, by project.
+func checkRollups(t *testing.T, d *sql.DB) {
+	t.Helper()
+	want, got := map[string]float64{}, map[string]float64{}
+	scan := func(into map[string]float64, query string, args ...any) {
+		rows, err := d.Query(query, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				project string
+				seconds float64
+			)
+			rows.Scan(&project, &seconds)
+			into[project] = seconds
+		}
+	}
+	scan(want, `SELECT COALESCE(project_number, ''), SUM(duration_seconds) FROM focus_events GROUP BY 1`)
+	scan(got, `SELECT project_number, SUM(seconds) FROM rollups WHERE hour >= ? GROUP BY 1`, archivedBefore(d))
+	for p, w := range want {
+		if math.Abs(got[p]-w) > 0.01 {
+			t.Errorf("%q: rollups hold %.2f seconds, sessions %.2f", p, got[p], w)
+		}
+	}
+	if len(got) > len(want) {
+		t.Errorf("rollups left behind: %v, sessions %v", got, want)
+	}
+}
+
+func TestRollups_KeptInStep(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "timewarp-HOST.db")
+	d, err := openMigrated(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := newTracker(d)
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		at := base.Add(time.Duration(i) * 50 * time.Minute)
+		title := fmt.Sprintf("25-12%d plan.dwg", i)
+		for s := 0; s <= 45*60; s += 5 {
+			tr.RecordFocus("HOST", "user", "acad.exe", title, at.Add(time.Duration(s)*time.Second))
+		}
+		for s := 0; s <= 5*60; s += 5 {
+			tr.RecordFocus("HOST", "user", "chrome.exe", "ESPN", at.Add(45*time.Minute+time.Duration(s)*time.Second))
+		}
+	}
+	tr.Close()
+	d, err = openMigrated(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, d, "rollups"); n == 0 {
+		t.Error("expected sessions rolled up as they were written")
+	}
+	checkRollups(t, d)
+	d.Close()
+
+	if _, err := Reattribute(dir, testRules(t), time.Time{}, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Purge(dir, PurgeFilter{From: base, To: base.Add(time.Hour)}, false); err != nil {
+		t.Fatal(err)
+	}
+	d, err = openMigrated(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	checkRollups(t, d)
+	if n := countRows(t, d, "focus_events"); n != 5 {
+		t.Errorf("expected 5 sessions left, got %d", n)
+	}
+}
+
+func TestMigrate_RollupsOfEverySession(t *testing.T) {
+	d, err := openMigrated(filepath.Join(t.TempDir(), "timewarp-HOST.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	// Back to version 15, when the rollups held only what retention had
+	// rolled up: here an hour of acad.exe until 11:00
+	if err := execAll(d,
+		`DROP TABLE rollup_state`,
+		`DELETE FROM schema_version WHERE version >= 16`,
+	); err != nil {
+		t.Fatal(err)
+	}
+	hour := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	d.Exec(`INSERT INTO rollups (hostname, hour, process_name, app, project_number, seconds, idle_seconds) VALUES (?,?,?,?,?,?,?)`,
+		"HOST", hour, "acad.exe", "acad.exe", "25-125", 600.0, 0.0)
+	for _, start := range []time.Time{hour.Add(30 * time.Minute), time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)} {
+		d.Exec(`INSERT INTO focus_events (hostname, username, process_name, window_title, project_number, started_at, ended_at, duration_seconds, idle_seconds) VALUES (?,?,?,?,?,?,?,?,?)`,
+			"HOST", "user", "acad.exe", "25-125_SLD-E101.dwg - AutoCAD", "25-125", start, start.Add(time.Hour), 3600.0, 600.0)
+	}
+
+	if err := migrate(d); err != nil {
+		t.Fatal(err)
+	}
+	if a := archivedBefore(d); !a.Equal(hour.Add(time.Hour)) {
+		t.Errorf("expected sessions archived before %s, got %s", hour.Add(time.Hour), a)
+	}
+	var (
+		start   time.Time
+		seconds float64
+	)
+	d.QueryRow(`SELECT started_at, duration_seconds FROM focus_events ORDER BY started_at LIMIT 1`).Scan(&start, &seconds)
+	if !start.Equal(hour.Add(time.Hour)) || seconds != 1800 {
+		t.Errorf("expected the session over the marker cut to start at it, got %s for %.0fs", start, seconds)
+	}
+	for h, want := range map[time.Time]float64{
+		hour:                600 + 1800,
+		hour.Add(time.Hour): 1800,
+		time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC): 3600,
+	} {
+		var got float64
+		d.QueryRow(`SELECT seconds FROM rollups WHERE hour = ?`, h).Scan(&got)
+		if math.Abs(got-want) > 0.01 {
+			t.Errorf("%s: expected %.0fs rolled up, got %.0f", h, want, got)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+
+	"github.com/vinistoisr/timewarp/internal/keyring"
+)
+
+// A DB file can be encrypted at rest, column by column. The free text that
+// says what was worked on (window titles, URLs, command lines, meeting and
+// calendar subjects, manual entry descriptions) is sealed with AES-GCM
+// before it is written, while times, process names and attributions stay
+// readable, so that queries can select and group rows without the key.
+//
+// A sealed value is sealPrefix, the ID of its key, ":" and the base64 nonce
+// and ciphertext; anything else is plaintext, so a file can hold both while
+// it is being encrypted or decrypted. Keys live in the OS keyring, never in
+// the DB folder, under keyName. Queries read sealed columns through the
+// unseal SQL function, which finds the key by the ID in the value.
+
+const (
+	sealPrefix = "tw1:"
+	keySize    = 32
+
+	// sealCheckInterval is how often a Tracker checks whether its file has
+	// been encrypted or decrypted since it was opened.
+	sealCheckInterval = time.Minute
+)
+
+// sealedColumns are the columns that are sealed in an encrypted file.
+var sealedColumns = []struct{ table, column string }{
+	{"focus_events", "window_title"},
+	{"focus_events", "url"},
+	{"focus_events", "command_line"},
+	{"focus_event_titles", "title"},
+	{"focus_event_titles", "url"},
+	{"pending_session", "window_title"},
+	{"pending_session", "url"},
+	{"pending_session", "command_line"},
+	{"pending_session", "titles"},
+	{"pending_session", "urls"},
+	{"raw_events", "window_title"},
+	{"raw_events", "url"},
+	{"raw_events", "command_line"},
+	{"meeting_sessions", "subject"},
+	{"meeting_sessions", "attendees"},
+	{"calendar_events", "subject"},
+	{"calendar_events", "description"},
+	{"calendar_events", "location"},
+	{"manual_entries", "description"},
+}
+
+func init() {
+	sqlite.MustRegisterScalarFunction("unseal", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch v := args[0].(type) {
+		case string:
+			return unseal(v), nil
+		case []byte:
+			return unseal(string(v)), nil
+		}
+		return args[0], nil
+	})
+	// seal leaves values it can already open as they are, so that Encrypt
+	// can be run again
+	sqlite.MustRegisterScalarFunction("seal", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		v, ok := args[0].(string)
+		id, _ := args[1].(string)
+		if !ok {
+			return args[0], nil
+		}
+		if _, ok := opened(v); ok {
+			return v, nil
+		}
+		s, err := sealerFor(id)
+		if err != nil {
+			return nil, err
+		}
+		return s.seal(v)
+	})
+}
+
+var (
+	keysMu sync.Mutex
+	// keys is the keyring DB keys are kept in, keyring.Default unless a
+	// test has set it
+	keys    keyring.Keyring
+	sealers = map[string]*sealer{}
+)
+
+func keyName(id string) string {
+	return "db-key-" + id
+}
+
+func keyringLocked() (keyring.Keyring, error) {
+	if keys == nil {
+		k, err := keyring.Default()
+		if err != nil {
+			return nil, err
+		}
+		keys = k
+	}
+	return keys, nil
+}
+
+// sealer seals values with one key.
+type sealer struct {
+	id   string
+	key  []byte
+	aead cipher.AEAD
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("db: key must be %d bytes, not %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &sealer{id: hex.EncodeToString(sum[:8]), key: key, aead: aead}, nil
+}
+
+// sealerFor returns the sealer for the key with id, from the keyring.
+func sealerFor(id string) (*sealer, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if s, ok := sealers[id]; ok {
+		return s, nil
+	}
+	k, err := keyringLocked()
+	if err != nil {
+		return nil, err
+	}
+	key, err := k.Get(keyName(id))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("db: data is encrypted with key %s, which is not in this machine's keyring; import it with timewarp encryption-key -import", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSealer(key)
+	if err != nil {
+		return nil, err
+	}
+	if s.id != id {
+		return nil, fmt.Errorf("db: keyring entry %s holds key %s", keyName(id), s.id)
+	}
+	sealers[id] = s
+	return s, nil
+}
+
+// storeKey keeps key in the keyring and returns its sealer.
+func storeKey(key []byte) (*sealer, error) {
+	s, err := newSealer(key)
+	if err != nil {
+		return nil, err
+	}
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	k, err := keyringLocked()
+	if err != nil {
+		return nil, err
+	}
+	if err := k.Set(keyName(s.id), key); err != nil {
+		return nil, err
+	}
+	sealers[s.id] = s
+	return s, nil
+}
+
+// seal returns v sealed, or v itself if it is empty. Anything else is
+// sealed, even plaintext that starts with sealPrefix, so that no plaintext
+// is written as it is. A nil sealer leaves everything as it is, for files
+// that are not encrypted.
+func (s *sealer) seal(v string) (string, error) {
+	if s == nil || v == "" {
+		return v, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("db: seal: %w", err)
+	}
+	return sealPrefix + s.id + ":" + base64.RawStdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(v), nil)), nil
+}
+
+// sealBatch seals the values of the rows in one write, keeping the first
+// error for the writer to check before it commits them.
+type sealBatch struct {
+	s   *sealer
+	err error
+}
+
+func (s *sealer) batch() *sealBatch {
+	return &sealBatch{s: s}
+}
+
+// seal is sealer.seal, returning "" once an error has been kept.
+func (b *sealBatch) seal(v string) string {
+	if b.err != nil {
+		return ""
+	}
+	sealed, err := b.s.seal(v)
+	if err != nil {
+		b.err = err
+	}
+	return sealed
+}
+
+// sealNull is seal for a nullable column.
+func (b *sealBatch) sealNull(v sql.NullString) sql.NullString {
+	v.String = b.seal(v.String)
+	return v
+}
+
+// unseal returns the plaintext of a sealed value, or v itself if it is not
+// one this machine can open. That includes plaintext that happens to start
+// with sealPrefix, so one such value doesn't fail the query reading it; a
+// file whose key is missing is refused when it is opened instead.
+func unseal(v string) string {
+	if plain, ok := opened(v); ok {
+		return plain
+	}
+	return v
+}
+
+// opened returns the plaintext of v and true if v is a sealed value this
+// machine has the key for.
+func opened(v string) (string, bool) {
+	if !strings.HasPrefix(v, sealPrefix) {
+		return "", false
+	}
+	id, data, ok := strings.Cut(v[len(sealPrefix):], ":")
+	if !ok {
+		return "", false
+	}
+	s, err := sealerFor(id)
+	if err != nil {
+		return "", false
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return "", false
+	}
+	n := s.aead.NonceSize()
+	plain, err := s.aead.Open(nil, raw[:n], raw[n:], nil)
+	if err != nil {
+		return "", false
+	}
+	return string(plain), true
+}
+
+// fileKeyID returns the ID of the key new rows in d are sealed with, or ""
+// if d is not encrypted.
+func fileKeyID(d querier) (string, error) {
+	var id string
+	err := d.QueryRow(`SELECT key_id FROM encryption WHERE id = 1`).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("db: encryption: %w", err)
+	}
+	return id, nil
+}
+
+// fileSealer returns the sealer new rows in d are sealed with, or nil if d
+// is not encrypted.
+func fileSealer(d querier) (*sealer, error) {
+	id, err := fileKeyID(d)
+	if id == "" || err != nil {
+		return nil, err
+	}
+	return sealerFor(id)
+}
+
+// checkSeal picks up an Encrypt or Decrypt run on the tracker's file since
+// it was opened, at most every sealCheckInterval.
+func (t *Tracker) checkSeal(now time.Time) {
+	if !t.sealChecked.IsZero() && !now.Before(t.sealChecked) && now.Sub(t.sealChecked) < sealCheckInterval {
+		return
+	}
+	t.sealChecked = now
+	s, err := fileSealer(t.db)
+	if err != nil {
+		log.Printf("db: %v", err)
+		return
+	}
+	t.seal = s
+}
+
+// Encrypt encrypts this machine's DB file in dbpath: the free text already
+// in it is sealed, and from then on so are new rows. It uses the key
+// another file in dbpath is encrypted with if this machine has it, so that
+// one key covers the folder, or else makes a new one and keeps it in the
+// keyring. It returns the key's ID.
+func Encrypt(dbpath string) (string, error) {
+	d, _, err := openLocal(dbpath)
+	if err != nil {
+		return "", err
+	}
+	defer d.Close()
+
+	s, err := fileSealer(d)
+	if err != nil {
+		return "", err
+	}
+	if s == nil {
+		if s, err = folderSealer(dbpath); err != nil {
+			return "", err
+		}
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO encryption (id, key_id, enabled_at) VALUES (1, ?, ?)`, s.id, time.Now().UTC()); err != nil {
+		return "", fmt.Errorf("db: encrypt: %w", err)
+	}
+	for _, c := range sealedColumns {
+		q := fmt.Sprintf(`UPDATE %s SET %s = seal(%s, ?) WHERE %s <> ''`, c.table, c.column, c.column, c.column)
+		if _, err := tx.Exec(q, s.id); err != nil {
+			return "", fmt.Errorf("db: encrypt %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("db: encrypt: %w", err)
+	}
+	return s.id, compact(d)
+}
+
+// Decrypt turns encryption of this machine's DB file in dbpath off and
+// unseals everything in it. The key stays in the keyring.
+func Decrypt(dbpath string) error {
+	d, _, err := openLocal(dbpath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM encryption`); err != nil {
+		return fmt.Errorf("db: decrypt: %w", err)
+	}
+	for _, c := range sealedColumns {
+		q := fmt.Sprintf(`UPDATE %s SET %s = unseal(%s) WHERE %s LIKE '%s%%'`, c.table, c.column, c.column, c.column, sealPrefix)
+		if _, err := tx.Exec(q); err != nil {
+			return fmt.Errorf("db: decrypt %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("db: decrypt: %w", err)
+	}
+	return compact(d)
+}
+
+// compact rewrites d without its free pages and empties its WAL, so that
+// nothing an Encrypt or Decrypt replaced is left behind in either file.
+func compact(d *sql.DB) error {
+	for _, q := range []string{`PRAGMA wal_checkpoint(TRUNCATE)`, `VACUUM`, `PRAGMA wal_checkpoint(TRUNCATE)`} {
+		if _, err := d.Exec(q); err != nil {
+			return fmt.Errorf("db: %s: %w", q, err)
+		}
+	}
+	return nil
+}
+
+// folderSealer returns the sealer for the key other files in dbpath are
+// encrypted with, if this machine has it, or for a new key.
+func folderSealer(dbpath string) (*sealer, error) {
+	matches, _ := filepath.Glob(filepath.Join(dbpath, "timewarp-*.db"))
+	for _, m := range matches {
+		d, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_busy_timeout=5000&mode=ro", m))
+		if err != nil {
+			continue
+		}
+		id, _ := fileKeyID(d)
+		d.Close()
+		if id == "" {
+			continue
+		}
+		if s, err := sealerFor(id); err == nil {
+			return s, nil
+		}
+	}
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return storeKey(key)
+}
+
+// ExportKey returns the key this machine's DB file in dbpath is encrypted
+// with, for ImportKey on the other machines that read the folder.
+func ExportKey(dbpath string) (string, error) {
+	d, _, err := openLocal(dbpath)
+	if err != nil {
+		return "", err
+	}
+	defer d.Close()
+	s, err := fileSealer(d)
+	if err != nil {
+		return "", err
+	}
+	if s == nil {
+		return "", errors.New("db: this machine's DB file is not encrypted")
+	}
+	return base64.StdEncoding.EncodeToString(s.key), nil
+}
+
+// ImportKey keeps a key from ExportKey in this machine's keyring, and
+// returns its ID.
+func ImportKey(encoded string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", fmt.Errorf("db: key: %w", err)
+	}
+	s, err := storeKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.id, nil
+}
@@ -0,0 +1,296 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/keyring"
+)
+
+// useKeyring points the DB keys at a fresh keyring in a temp folder, and
+// forgets the keys already looked up.
+func useKeyring(t *testing.T) {
+	t.Helper()
+	k := keyring.File{Dir: t.TempDir()}
+	keysMu.Lock()
+	old, oldSealers := keys, sealers
+	keys, sealers = k, map[string]*sealer{}
+	keysMu.Unlock()
+	t.Cleanup(func() {
+		keysMu.Lock()
+		keys, sealers = old, oldSealers
+		keysMu.Unlock()
+	})
+}
+
+// fileContains reports whether this machine's DB file in dir, or its WAL,
+// holds s anywhere.
+func fileContains(t *testing.T, dir, s string) bool {
+	t.Helper()
+	hostname, _ := os.Hostname()
+	for _, name := range []string{"timewarp-%s.db", "timewarp-%s.db-wal"} {
+		b, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf(name, hostname)))
+		if err == nil && strings.Contains(string(b), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func storedTitles(t *testing.T, dir string) []string {
+	t.Helper()
+	hostname, _ := os.Hostname()
+	d, err := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-"+hostname+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	rows, err := d.Query(`SELECT window_title FROM focus_events ORDER BY started_at`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var titles []string
+	for rows.Next() {
+		var s string
+		rows.Scan(&s)
+		titles = append(titles, s)
+	}
+	return titles
+}
+
+func TestEncrypt_SealsFreeTextAndReadsBack(t *testing.T) {
+	useKeyring(t)
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "Contoso merger 25-125.dwg - AutoCAD", base.Add(time.Duration(i)*time.Second))
+	}
+	tr.Close()
+	if _, err := AddManualEntry(dir, ManualInput{Project: strp("25-125"), Description: strp("Contoso site visit"), Start: timep(base.Add(time.Hour)), Minutes: minp(60)}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := Encrypt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileContains(t, dir, "Contoso") {
+		t.Error("plaintext left in the file after Encrypt")
+	}
+	if got := storedTitles(t, dir); len(got) != 1 || !strings.HasPrefix(got[0], sealPrefix+id+":") {
+		t.Errorf("expected sealed titles, got %q", got)
+	}
+
+	// Readers see the plaintext; new rows are sealed as they are written
+	sessions, err := ListSessions(dir, base, base.Add(24*time.Hour), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Title != "Contoso merger 25-125.dwg - AutoCAD" || sessions[0].Project != "25-125" {
+		t.Fatalf("unexpected sessions: %+v", sessions)
+	}
+	entries, err := ListManualEntries(dir, base, base.Add(24*time.Hour))
+	if err != nil || len(entries) != 1 || entries[0].Description != "Contoso site visit" {
+		t.Fatalf("unexpected entries: %+v %v", entries, err)
+	}
+
+	tr, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		tr.RecordFocus("HOST", "user", "winword.exe", "Contoso board pack.docx - Word", base.Add(2*time.Hour+time.Duration(i)*time.Second))
+	}
+	tr.Close()
+	if fileContains(t, dir, "board pack") {
+		t.Error("new rows written in plaintext")
+	}
+	sessions, _ = ListSessions(dir, base, base.Add(24*time.Hour), "winword.exe")
+	if len(sessions) != 1 || sessions[0].Title != "Contoso board pack.docx - Word" {
+		t.Errorf("unexpected sessions: %+v", sessions)
+	}
+
+	if err := Decrypt(dir); err != nil {
+		t.Fatal(err)
+	}
+	got := storedTitles(t, dir)
+	if len(got) != 2 || got[0] != "Contoso merger 25-125.dwg - AutoCAD" || got[1] != "Contoso board pack.docx - Word" {
+		t.Errorf("expected plaintext titles after Decrypt, got %q", got)
+	}
+}
+
+func TestEncrypt_KeyFromAnotherMachine(t *testing.T) {
+	useKeyring(t)
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "Contoso 25-125.dwg", base.Add(time.Duration(i)*time.Second))
+	}
+	tr.Close()
+	id, err := Encrypt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ExportKey(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another machine reading the folder, without the key
+	useKeyring(t)
+	if _, err := ListSessions(dir, base, base.Add(time.Hour), ""); err == nil || !strings.Contains(err.Error(), "encryption-key -import") {
+		t.Fatalf("expected an error naming the key to import, got %v", err)
+	}
+	if _, err := Open(dir); err == nil {
+		t.Error("expected Open to refuse an encrypted file without its key")
+	}
+
+	got, err := ImportKey(exported)
+	if err != nil || got != id {
+		t.Fatalf("imported %q %v, want %q", got, err, id)
+	}
+	sessions, err := ListSessions(dir, base, base.Add(time.Hour), "")
+	if err != nil || len(sessions) != 1 || sessions[0].Title != "Contoso 25-125.dwg" {
+		t.Fatalf("unexpected sessions: %+v %v", sessions, err)
+	}
+}
+
+func TestUpdate_SkipsFileWithoutItsKey(t *testing.T) {
+	useKeyring(t)
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "Contoso 25-125.dwg", base.Add(time.Duration(i)*time.Second))
+	}
+	tr.Close()
+	if _, err := Encrypt(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another machine changing the folder, without the key
+	useKeyring(t)
+	for _, dryRun := range []bool{true, false} {
+		ra, err := Reattribute(dir, testRules(t), time.Time{}, time.Time{}, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ra.Skipped) != 1 || len(ra.Files) != 0 || len(ra.Changes) != 0 {
+			t.Errorf("dry run %v: expected the file skipped by reattribute, got %+v", dryRun, ra)
+		}
+		pr, err := Purge(dir, PurgeFilter{TitleMatch: regexp.MustCompile(`Contoso`)}, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pr.Skipped) != 1 || len(pr.Files) != 0 || pr.Sessions != 0 {
+			t.Errorf("dry run %v: expected the file skipped by purge, got %+v", dryRun, pr)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	d, _ := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-"+hostname+".db"))
+	defer d.Close()
+	var (
+		n       int
+		project sql.NullString
+	)
+	d.QueryRow(`SELECT COUNT(*), MAX(project_number) FROM focus_events`).Scan(&n, &project)
+	if n != 1 || project.String != "25-125" {
+		t.Errorf("expected the session left as it was, got %d rows on %v", n, project)
+	}
+}
+
+func TestCheckSeal_RunningTrackerPicksUpEncrypt(t *testing.T) {
+	useKeyring(t)
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	tr, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	tr.RecordFocus("HOST", "user", "acad.exe", "Before.dwg", base)
+
+	if _, err := Encrypt(dir); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 30; i++ {
+		tr.RecordFocus("HOST", "user", "acad.exe", "Before.dwg", base.Add(time.Duration(i)*time.Second))
+	}
+	for i := 0; i < 30; i++ {
+		tr.RecordFocus("HOST", "user", "winword.exe", "After.docx", base.Add(2*time.Minute+time.Duration(i)*time.Second))
+	}
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Inbox", base.Add(3*time.Minute))
+
+	got := storedTitles(t, dir)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 sessions, got %q", got)
+	}
+	for _, title := range got {
+		if !strings.HasPrefix(title, sealPrefix) {
+			t.Errorf("expected %q sealed", title)
+		}
+	}
+}
+
+func TestEncrypt_PlaintextThatLooksSealed(t *testing.T) {
+	useKeyring(t)
+	dir := t.TempDir()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	record := func(title string, at time.Time) {
+		tr, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 30; i++ {
+			tr.RecordFocus("HOST", "user", "notepad.exe", title, at.Add(time.Duration(i)*time.Second))
+		}
+		tr.Close()
+	}
+	record("tw1:Contoso notes.txt - Notepad", base)
+	if _, err := Encrypt(dir); err != nil {
+		t.Fatal(err)
+	}
+	record("tw1:Contoso minutes.txt - Notepad", base.Add(time.Hour))
+	if fileContains(t, dir, "Contoso") {
+		t.Error("plaintext starting with the sealed prefix left in the file")
+	}
+
+	// A value that can't be opened reads as it is, without failing the rest
+	hostname, _ := os.Hostname()
+	d, _ := sql.Open("sqlite", "file:"+filepath.Join(dir, "timewarp-"+hostname+".db"))
+	d.Exec(`INSERT INTO focus_events (hostname, username, process_name, window_title, started_at, ended_at, duration_seconds) VALUES (?,?,?,?,?,?,?)`,
+		hostname, "user", "notepad.exe", "tw1:0123:not sealed", base.Add(2*time.Hour), base.Add(3*time.Hour), 3600.0)
+	d.Close()
+
+	sessions, err := ListSessions(dir, base, base.Add(24*time.Hour), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, s := range sessions {
+		titles = append(titles, s.Title)
+	}
+	if strings.Join(titles, "|") != "tw1:Contoso notes.txt - Notepad|tw1:Contoso minutes.txt - Notepad|tw1:0123:not sealed" {
+		t.Errorf("unexpected titles: %q", titles)
+	}
+}
@@ -0,0 +1,372 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session is a focus session as the queries see it: a focus_events row with
+// any overrides applied. Its ID is "hostname:id" for a stored row, and
+// "hostname:id+N" for the part of a split row that starts N seconds in, so
+// IDs stay the same however the row is later split or reassigned. Minutes
+// is the whole session; IdleMinutes is the inactivity within it, which
+// active totals leave out. Title is the latest title and Titles every
+// distinct one seen during the session; URL is the page shown with the
+// latest title, for a browser with the companion extension. App is the
+// application identity, which sessions are grouped by: the process name
+// unless that is a runtime such as java or python (see package appid).
+// A RolledUp session is an hour of an app's time on a project kept after
+// the sessions themselves passed their retention; it has no titles.
+type Session struct {
+	ID          string    `json:"id"`
+	Machine     string    `json:"machine"`
+	Process     string    `json:"process"`
+	App         string    `json:"app"`
+	Title       string    `json:"title"`
+	Titles      []string  `json:"titles,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	Project     string    `json:"project_number,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Minutes     float64   `json:"minutes"`
+	IdleMinutes float64   `json:"idle_minutes,omitempty"`
+	Overridden  bool      `json:"overridden,omitempty"`
+	RolledUp    bool      `json:"rolled_up,omitempty"`
+
+	seconds   float64
+	idle      float64 // seconds
+	base      string  // ID of the focus_events row
+	baseStart time.Time
+	merged    bool
+}
+
+// override is one row of session_overrides.
+type override struct {
+	kind      string // "assign", "split" or "merge"
+	target    string
+	project   string
+	splitAt   time.Time
+	mergeInto string
+	createdAt time.Time
+}
+
+// loadSessions reads the focus sessions in every file that overlap
+// [start, end) once overrides from every file have been applied, only
+// those of process if it is set. Sessions are whole; aggregations count
+// only the part in range (see within). Sessions past their retention come
+// from the rollups, an hour at a time.
+func loadSessions(dbs []*sql.DB, start, end time.Time, process string) []*Session {
+	overrides := loadOverrides(dbs)
+	f := newProcessFilter(process, overrides)
+	var sessions []*Session
+	for _, db := range dbs {
+		sessions = append(sessions, loadFocusSessions(db, start, end, f)...)
+		sessions = append(sessions, loadArchived(db, start, end)...)
+	}
+
+	sessions = applyOverrides(sessions, overrides)
+
+	var inRange []*Session
+	for _, s := range sessions {
+		if s.Start.Before(end) && s.End.After(start) && (process == "" || s.isApp(process)) {
+			s.setMinutes()
+			inRange = append(inRange, s)
+		}
+	}
+	return inRange
+}
+
+// loadFocusSessions reads the focus_events rows in d that overlap
+// [start, end) and pass f, before overrides.
+func loadFocusSessions(d *sql.DB, start, end time.Time, f processFilter) []*Session {
+	startStr := start.UTC().Format("2006-01-02 15:04:05")
+	endStr := end.UTC().Format("2006-01-02 15:04:05")
+	where, args := f.where(d, "f.")
+	args = append([]any{endStr, startStr}, args...)
+
+	// Rows that started earlier can still have a split part in range
+	titles, _, err := loadTitles(d, `f.started_at < ? AND f.ended_at > ? AND `+where, args...)
+	if err != nil {
+		return nil
+	}
+	rows, err := d.Query(`SELECT f.id, hostname, process_name, COALESCE(app, process_name), unseal(window_title), COALESCE(unseal(url), ''), project_number, started_at, ended_at, duration_seconds, COALESCE(idle_seconds, 0) FROM focus_events f WHERE f.started_at < ? AND f.ended_at > ? AND `+where, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var sessions []*Session
+	for rows.Next() {
+		var (
+			id      int64
+			s       Session
+			projNum sql.NullString
+		)
+		if rows.Scan(&id, &s.Machine, &s.Process, &s.App, &s.Title, &s.URL, &projNum, &s.Start, &s.End, &s.seconds, &s.idle) != nil {
+			continue
+		}
+		s.ID = fmt.Sprintf("%s:%d", s.Machine, id)
+		s.Project = projNum.String
+		s.Titles = titles[id]
+		if len(s.Titles) == 0 {
+			s.Titles = []string{s.Title}
+		}
+		s.Start, s.End = s.Start.UTC(), s.End.UTC()
+		s.base, s.baseStart = s.ID, s.Start
+		sessions = append(sessions, &s)
+	}
+	return sessions
+}
+
+// processKeysVersion is the schema version from which every focus_events
+// row has a process key, and the indexes processFilter uses.
+const processKeysVersion = 17
+
+// processFilter narrows a read of focus_events to the rows that may be of
+// one process or app once overrides are applied, using the indexes: those
+// whose process key or app matches, and those merges name, as a merged
+// session takes the process of the one merged into. The rows still have to
+// be matched exactly (see isApp). The zero processFilter passes every row,
+// as does any filter in a file from before processKeysVersion.
+type processFilter struct {
+	process string
+	merged  []int64
+}
+
+func newProcessFilter(process string, overrides []override) processFilter {
+	f := processFilter{process: process}
+	if process == "" {
+		return f
+	}
+	for _, o := range overrides {
+		if o.kind != "merge" {
+			continue
+		}
+		for _, id := range []string{o.target, o.mergeInto} {
+			if _, n, err := parseSessionID(id); err == nil {
+				f.merged = append(f.merged, n)
+			}
+		}
+	}
+	return f
+}
+
+// where returns f as a condition on the focus_events rows in d, with its
+// columns prefixed by alias, and the condition's arguments.
+func (f processFilter) where(d querier, alias string) (string, []any) {
+	if f.process == "" {
+		return "1=1", nil
+	}
+	if v, _ := schemaVersion(d); v < processKeysVersion {
+		return "1=1", nil
+	}
+	key := processKey(f.process)
+	where := fmt.Sprintf("(%[1]sprocess_key = ? OR LOWER(%[1]sapp) = ?", alias)
+	args := []any{key, key}
+	if len(f.merged) > 0 {
+		where += " OR " + alias + "id IN (?" + strings.Repeat(", ?", len(f.merged)-1) + ")"
+		for _, n := range f.merged {
+			args = append(args, n)
+		}
+	}
+	return where + ")", args
+}
+
+// loadTitles reads the titles of the focus_events rows in d matching where,
+// and the URLs seen with them, keyed by row id. Rows from before titles
+// were kept have none; a title seen without a URL has "".
+func loadTitles(d querier, where string, args ...any) (titles, urls map[int64][]string, err error) {
+	rows, err := d.Query(`SELECT t.focus_event_id, unseal(t.title), COALESCE(unseal(t.url), '') FROM focus_event_titles t JOIN focus_events f ON f.id = t.focus_event_id WHERE `+where+` ORDER BY t.id`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	titles, urls = map[int64][]string{}, map[int64][]string{}
+	for rows.Next() {
+		var (
+			id         int64
+			title, url string
+		)
+		if err := rows.Scan(&id, &title, &url); err != nil {
+			return nil, nil, err
+		}
+		titles[id] = append(titles[id], title)
+		urls[id] = append(urls[id], url)
+	}
+	return titles, urls, rows.Err()
+}
+
+// addTitles appends to dst the titles not already in it, up to max in all.
+func addTitles(dst, titles []string, max int) []string {
+	for _, title := range titles {
+		if len(dst) >= max {
+			break
+		}
+		seen := false
+		for _, have := range dst {
+			if have == title {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			dst = append(dst, title)
+		}
+	}
+	return dst
+}
+
+// setMinutes fills in the exported minutes from the seconds.
+func (s *Session) setMinutes() {
+	s.Minutes = round1(s.seconds / 60.0)
+	s.IdleMinutes = round1(s.idle / 60.0)
+}
+
+// isApp reports whether s is in the named application, given by its
+// identity or its process name.
+func (s *Session) isApp(name string) bool {
+	return strings.EqualFold(s.App, name) || strings.EqualFold(s.Process, name)
+}
+
+// activeSeconds is the session less the inactivity within it.
+func (s *Session) activeSeconds() float64 {
+	return s.seconds - s.idle
+}
+
+// startsIn reports whether s starts in [from, to), which is how sessions
+// are picked out by time for listing and overrides.
+func startsIn(s *Session, from, to time.Time) bool {
+	return !s.Start.Before(from) && s.Start.Before(to)
+}
+
+// loadOverrides reads the overrides from every file, oldest first.
+func loadOverrides(dbs []*sql.DB) []override {
+	var all []override
+	for _, db := range dbs {
+		rows, err := db.Query(`SELECT kind, target, COALESCE(project_number, ''), split_at, COALESCE(merge_into, ''), created_at FROM session_overrides ORDER BY id`)
+		if err != nil {
+			continue
+		}
+		for rows.Next() {
+			var (
+				o       override
+				splitAt sql.NullTime
+			)
+			if rows.Scan(&o.kind, &o.target, &o.project, &splitAt, &o.mergeInto, &o.createdAt) != nil {
+				continue
+			}
+			o.splitAt = splitAt.Time.UTC()
+			all = append(all, o)
+		}
+		rows.Close()
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].createdAt.Before(all[j].createdAt) })
+	return all
+}
+
+// applyOverrides replays overrides in the order they were made. Overrides
+// whose sessions are not loaded are skipped.
+func applyOverrides(sessions []*Session, overrides []override) []*Session {
+	byID := map[string]*Session{}
+	for _, s := range sessions {
+		byID[s.ID] = s
+	}
+
+	for _, o := range overrides {
+		switch o.kind {
+		case "assign":
+			if s := byID[o.target]; s != nil {
+				s.Project = o.project
+				s.Overridden = true
+			}
+
+		case "split":
+			s := byID[o.target]
+			if s == nil {
+				continue
+			}
+			// The target may have been split already; cut whichever part holds the time
+			for _, p := range sessions {
+				if p.base == s.base && !p.merged && p.Start.Before(o.splitAt) && o.splitAt.Before(p.End) {
+					sessions = append(sessions, cut(p, o.splitAt))
+					byID[sessions[len(sessions)-1].ID] = sessions[len(sessions)-1]
+					break
+				}
+			}
+
+		case "merge":
+			src, dst := byID[o.target], byID[o.mergeInto]
+			if src == nil || dst == nil || src == dst {
+				continue
+			}
+			dst.seconds += src.seconds
+			dst.idle += src.idle
+			dst.Titles = addTitles(dst.Titles, src.Titles, len(dst.Titles)+len(src.Titles))
+			if src.Start.Before(dst.Start) {
+				dst.Start = src.Start
+			}
+			if src.End.After(dst.End) {
+				dst.End = src.End
+			}
+			dst.Overridden = true
+			src.merged = true
+			// Later overrides naming the merged session apply to the result
+			for id, s := range byID {
+				if s == src {
+					byID[id] = dst
+				}
+			}
+		}
+	}
+
+	kept := sessions[:0]
+	for _, s := range sessions {
+		if !s.merged {
+			kept = append(kept, s)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Start.Before(kept[j].Start) })
+	return kept
+}
+
+// cut cuts p at t, shortening p and returning the part from t on.
+func cut(p *Session, t time.Time) *Session {
+	n := *p
+	n.ID = fmt.Sprintf("%s+%d", p.base, int64(t.Sub(p.baseStart).Seconds()))
+	n.Start = t
+	// Which titles fell on which side isn't known, so both parts keep them all
+	n.Titles = append([]string(nil), p.Titles...)
+	frac := p.End.Sub(t).Seconds() / p.End.Sub(p.Start).Seconds()
+	n.seconds = p.seconds * frac
+	n.idle = p.idle * frac
+	n.Overridden = true
+	p.seconds -= n.seconds
+	p.idle -= n.idle
+	p.End = t
+	p.Overridden = true
+	return &n
+}
+
+// parseSessionID splits a session ID into its hostname and focus_events row
+// id, ignoring any split offset.
+func parseSessionID(id string) (string, int64, error) {
+	i := strings.LastIndex(id, ":")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("invalid session id %q (want hostname:number)", id)
+	}
+	num := id[i+1:]
+	if strings.HasPrefix(num, "r") {
+		return "", 0, fmt.Errorf("session %s is a rolled-up hour; it can only be assigned by time range", id)
+	}
+	if j := strings.Index(num, "+"); j >= 0 {
+		num = num[:j]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid session id %q (want hostname:number)", id)
+	}
+	return id[:i], n, nil
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// awayState follows system events to tell whether the user is away: from
+// suspend until resume, and from lock until unlock. A machine is often
+// suspended while locked, in which case the user is away until both have
+// ended.
+type awayState struct {
+	asleep, locked bool
+}
+
+// apply updates s for event, reporting false for an unknown event.
+func (s *awayState) apply(event string) bool {
+	switch event {
+	case "suspend", "shutdown":
+		s.asleep = true
+	case "resume":
+		s.asleep = false
+	case "lock":
+		s.locked = true
+	case "unlock":
+		s.locked = false
+	default:
+		return false
+	}
+	return true
+}
+
+func (s awayState) away() bool {
+	return s.asleep || s.locked
+}
+
+// awayMinutes returns how long the user of the machine whose file is d was
+// away in [from, to).
+func awayMinutes(d *sql.DB, from, to time.Time) float64 {
+	var minutes float64
+	for _, p := range awayPeriods(d, from, to) {
+		minutes += p.end.Sub(p.start).Minutes() * within(p.start, p.end, from, to)
+	}
+	return minutes
+}
+
+// awayPeriods returns the periods the user of the machine whose file is d
+// was away that overlap [from, to), whole. Away time is kept apart from
+// inactivity, which is time at an unlocked, running machine without input.
+//
+// A shutdown ends the period rather than starting one, as there is no
+// resume to close it; whatever else was missed, the period ends at the next
+// focus session, as the tracker records none while the user is away. A
+// period that is still open is not counted.
+func awayPeriods(d *sql.DB, from, to time.Time) []span {
+	rows, err := d.Query(`SELECT event, occurred_at FROM system_events WHERE occurred_at < ? ORDER BY occurred_at, id`,
+		to.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil
+	}
+	var (
+		periods []span
+		state   awayState
+		start   time.Time
+	)
+	for rows.Next() {
+		var event string
+		var at time.Time
+		if rows.Scan(&event, &at) != nil {
+			continue
+		}
+		wasAway := state.away()
+		if event == "shutdown" {
+			state = awayState{}
+		} else if !state.apply(event) {
+			continue
+		}
+		switch {
+		case !wasAway && state.away():
+			start = at
+		case wasAway && !state.away():
+			periods = append(periods, span{start, at})
+		}
+	}
+	rows.Close()
+
+	var inRange []span
+	for _, p := range periods {
+		if !p.end.After(from) {
+			continue
+		}
+		var next time.Time
+		if d.QueryRow(`SELECT started_at FROM focus_events WHERE started_at > ? AND started_at < ? ORDER BY started_at LIMIT 1`,
+			p.start.UTC().Format("2006-01-02 15:04:05"), p.end.UTC().Format("2006-01-02 15:04:05")).Scan(&next) == nil {
+			p.end = next
+		}
+		inRange = append(inRange, p)
+	}
+	return inRange
+}
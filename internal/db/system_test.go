@@ -0,0 +1,62 @@
+package db
+
+import (
+	"database/sql"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAwayMinutes(t *testing.T) {
+	d, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+	if err := migrate(d); err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	event := func(kind string, when time.Time) {
+		if _, err := d.Exec(`INSERT INTO system_events (hostname, username, event, occurred_at) VALUES ('HOST', 'user', ?, ?)`, kind, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Suspended while locked: away until both end (70 minutes)
+	event("lock", at(10, 0))
+	event("suspend", at(10, 5))
+	event("resume", at(11, 0))
+	event("unlock", at(11, 10))
+	// Shutdown ends the period (30 minutes)
+	event("suspend", at(13, 0))
+	event("shutdown", at(13, 30))
+	// The resume was missed; tracking picked up at 16:00 (60 minutes)
+	event("suspend", at(15, 0))
+	if _, err := d.Exec(`INSERT INTO focus_events (hostname, username, process_name, window_title, started_at, ended_at, duration_seconds) VALUES ('HOST', 'user', 'acad.exe', 'x', ?, ?, 600)`,
+		at(16, 0), at(16, 10)); err != nil {
+		t.Fatal(err)
+	}
+	event("resume", at(18, 0))
+	// Still locked: not counted
+	event("lock", at(20, 0))
+
+	cases := []struct {
+		from, to time.Time
+		want     float64
+	}{
+		{day, day.AddDate(0, 0, 1), 160},
+		{at(10, 30), at(12, 0), 40},
+		{at(15, 30), at(23, 0), 30},
+		{day.AddDate(0, 0, 1), day.AddDate(0, 0, 2), 0},
+	}
+	for _, c := range cases {
+		if got := awayMinutes(d, c.from, c.to); math.Abs(got-c.want) > 0.01 {
+			t.Errorf("away %s–%s: expected %v minutes, got %v", c.from.Format("15:04"), c.to.Format("15:04"), c.want, got)
+		}
+	}
+}
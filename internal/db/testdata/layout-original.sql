@@ -0,0 +1,38 @@
+-- A DB file as created by the first release: three tables, no attribution
+-- columns and no schema_version. Sessions are on Monday 2026-03-02.
+CREATE TABLE focus_events (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	hostname        TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	process_name    TEXT NOT NULL,
+	window_title    TEXT NOT NULL,
+	project_number  TEXT,
+	started_at      DATETIME NOT NULL,
+	ended_at        DATETIME NOT NULL,
+	duration_seconds REAL NOT NULL
+);
+CREATE TABLE inactivity_periods (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	hostname        TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	started_at      DATETIME NOT NULL,
+	ended_at        DATETIME NOT NULL,
+	duration_seconds REAL NOT NULL
+);
+CREATE TABLE meeting_sessions (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	hostname        TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	process_name    TEXT NOT NULL,
+	subject         TEXT NOT NULL,
+	started_at      DATETIME NOT NULL,
+	ended_at        DATETIME NOT NULL,
+	duration_seconds REAL NOT NULL
+);
+INSERT INTO focus_events (hostname, username, process_name, window_title, project_number, started_at, ended_at, duration_seconds) VALUES
+	('LAPTOP-OLD', 'user', 'acad.exe', '25-125_SLD-E101.dwg - AutoCAD', '25-125', '2026-03-02 09:00:00+00:00', '2026-03-02 10:00:00+00:00', 3600),
+	('LAPTOP-OLD', 'user', 'chrome.exe', 'Google Search', NULL, '2026-03-02 10:00:00+00:00', '2026-03-02 10:15:00+00:00', 900);
+INSERT INTO meeting_sessions (hostname, username, process_name, subject, started_at, ended_at, duration_seconds) VALUES
+	('LAPTOP-OLD', 'user', 'ms-teams.exe', '25-019 Design Review', '2026-03-02 11:00:00+00:00', '2026-03-02 11:30:00+00:00', 1800);
+INSERT INTO inactivity_periods (hostname, username, started_at, ended_at, duration_seconds) VALUES
+	('LAPTOP-OLD', 'user', '2026-03-02 12:00:00+00:00', '2026-03-02 12:20:00+00:00', 1200);
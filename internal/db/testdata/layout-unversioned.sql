@@ -0,0 +1,79 @@
+-- A DB file as created by the last release before schema_version: every
+-- table up to manual entries, but no record of which migrations it has.
+-- Sessions are on Monday 2026-03-02.
+CREATE TABLE focus_events (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	hostname        TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	process_name    TEXT NOT NULL,
+	window_title    TEXT NOT NULL,
+	project_number  TEXT,
+	started_at      DATETIME NOT NULL,
+	ended_at        DATETIME NOT NULL,
+	duration_seconds REAL NOT NULL,
+	client          TEXT,
+	task            TEXT,
+	category        TEXT
+);
+CREATE TABLE inactivity_periods (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	hostname        TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	started_at      DATETIME NOT NULL,
+	ended_at        DATETIME NOT NULL,
+	duration_seconds REAL NOT NULL
+);
+CREATE TABLE attribution_changes (
+	id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+	focus_event_id     INTEGER NOT NULL,
+	changed_at         DATETIME NOT NULL,
+	old_project_number TEXT,
+	old_client         TEXT,
+	old_task           TEXT,
+	old_category       TEXT,
+	new_project_number TEXT,
+	new_client         TEXT,
+	new_task           TEXT,
+	new_category       TEXT
+);
+CREATE TABLE meeting_sessions (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	hostname        TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	process_name    TEXT NOT NULL,
+	subject         TEXT NOT NULL,
+	started_at      DATETIME NOT NULL,
+	ended_at        DATETIME NOT NULL,
+	duration_seconds REAL NOT NULL
+);
+CREATE TABLE session_overrides (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind            TEXT NOT NULL,
+	target          TEXT NOT NULL,
+	project_number  TEXT,
+	split_at        DATETIME,
+	merge_into      TEXT,
+	created_at      DATETIME NOT NULL
+);
+CREATE TABLE manual_entries (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	hostname        TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	project_number  TEXT NOT NULL,
+	client          TEXT,
+	task            TEXT,
+	category        TEXT,
+	description     TEXT NOT NULL,
+	started_at      DATETIME NOT NULL,
+	ended_at        DATETIME NOT NULL,
+	duration_seconds REAL NOT NULL,
+	created_at      DATETIME NOT NULL,
+	updated_at      DATETIME NOT NULL
+);
+INSERT INTO focus_events (hostname, username, process_name, window_title, project_number, client, started_at, ended_at, duration_seconds) VALUES
+	('DESKTOP-NEW', 'user', 'acad.exe', '26-001_plan.dwg - AutoCAD', '26-001', 'Acme', '2026-03-02 13:00:00+00:00', '2026-03-02 14:00:00+00:00', 3600),
+	('DESKTOP-NEW', 'user', 'chrome.exe', 'Google Search', NULL, NULL, '2026-03-02 14:00:00+00:00', '2026-03-02 14:30:00+00:00', 1800);
+INSERT INTO session_overrides (kind, target, project_number, created_at) VALUES
+	('assign', 'DESKTOP-NEW:2', '26-001', '2026-03-03 08:00:00+00:00');
+INSERT INTO manual_entries (hostname, username, project_number, description, started_at, ended_at, duration_seconds, created_at, updated_at) VALUES
+	('DESKTOP-NEW', 'user', '26-001', 'Site visit', '2026-03-02 07:00:00+00:00', '2026-03-02 08:00:00+00:00', 3600, '2026-03-02 18:00:00+00:00', '2026-03-02 18:00:00+00:00');
@@ -0,0 +1,96 @@
+// Package keyring keeps small secrets, such as the key DB files are
+// encrypted with, in the operating system's store for them: DPAPI on
+// Windows and the Secret Service on Linux. Where there is none, they are
+// kept in files only the user can read.
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrNotFound is returned by Get for a secret that is not in the keyring.
+var ErrNotFound = errors.New("keyring: not found")
+
+// Keyring stores secrets by name.
+type Keyring interface {
+	Get(name string) ([]byte, error)
+	Set(name string, secret []byte) error
+}
+
+// validName matches the names secrets may have, which double as file names.
+var validName = regexp.MustCompile(`^[\w.-]+$`)
+
+func checkName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("keyring: invalid name %q", name)
+	}
+	return nil
+}
+
+// File keeps each secret as it is in a file of its own in Dir, readable only
+// by the user. It is the fallback where the platform has no keyring, and
+// what tests use.
+type File struct {
+	Dir string
+}
+
+func (f File) Get(name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(f.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+	return b, nil
+}
+
+func (f File) Set(name string, secret []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if err := writeFile(f.Dir, name, secret); err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+	return nil
+}
+
+// writeFile replaces dir/name with data, readable only by the user.
+func writeFile(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// dir is the folder secrets are kept in where they are kept in files.
+func dir() (string, error) {
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("keyring: %w", err)
+	}
+	return filepath.Join(d, "timewarp", "keys"), nil
+}
@@ -0,0 +1,184 @@
+//go:build !windows
+
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretsName      = "org.freedesktop.secrets"
+	secretsPath      = "/org/freedesktop/secrets"
+	secretsService   = "org.freedesktop.Secret.Service"
+	secretsDefault   = "/org/freedesktop/secrets/aliases/default"
+	secretsNoPrompt  = "/"
+	secretsPromptMax = 2 * time.Minute
+)
+
+// Default returns the user's keyring: the Secret Service (GNOME Keyring,
+// KWallet and the like) if the session has one, or else files in the
+// user's config folder.
+func Default() (Keyring, error) {
+	s, err := newSecretService()
+	if err == nil {
+		return s, nil
+	}
+	d, derr := dir()
+	if derr != nil {
+		return nil, derr
+	}
+	log.Printf("keyring: no Secret Service (%v); keeping secrets in %s", err, d)
+	return File{Dir: d}, nil
+}
+
+// secretService keeps secrets in the default collection of the Secret
+// Service, as items with the attributes application=timewarp and name.
+type secretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+// secret is the Secret Service's Secret struct.
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+func newSecretService() (*secretService, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("session bus: %w", err)
+	}
+	var (
+		output  dbus.Variant
+		session dbus.ObjectPath
+	)
+	// The plain algorithm sends secrets unencrypted over the session bus,
+	// which only this user can connect to.
+	err = conn.Object(secretsName, secretsPath).Call(secretsService+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &session)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &secretService{conn: conn, session: session}, nil
+}
+
+func attributes(name string) map[string]string {
+	return map[string]string{"application": "timewarp", "name": name}
+}
+
+func (s *secretService) Get(name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	var unlocked, locked []dbus.ObjectPath
+	if err := s.conn.Object(secretsName, secretsPath).Call(secretsService+".SearchItems", 0, attributes(name)).Store(&unlocked, &locked); err != nil {
+		return nil, fmt.Errorf("keyring: search %s: %w", name, err)
+	}
+	if len(unlocked) == 0 {
+		if len(locked) == 0 {
+			return nil, ErrNotFound
+		}
+		var err error
+		if unlocked, err = s.unlock(locked[:1]); err != nil {
+			return nil, fmt.Errorf("keyring: unlock %s: %w", name, err)
+		}
+		if len(unlocked) == 0 {
+			return nil, fmt.Errorf("keyring: %s is locked", name)
+		}
+	}
+	var sec secret
+	if err := s.conn.Object(secretsName, unlocked[0]).Call("org.freedesktop.Secret.Item.GetSecret", 0, s.session).Store(&sec); err != nil {
+		return nil, fmt.Errorf("keyring: get %s: %w", name, err)
+	}
+	return sec.Value, nil
+}
+
+func (s *secretService) Set(name string, value []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if _, err := s.unlock([]dbus.ObjectPath{secretsDefault}); err != nil {
+		return fmt.Errorf("keyring: unlock the default collection: %w", err)
+	}
+	props := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant("Timewarp " + name),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(attributes(name)),
+	}
+	sec := secret{Session: s.session, Parameters: []byte{}, Value: value, ContentType: "application/octet-stream"}
+	var item, prompt dbus.ObjectPath
+	if err := s.conn.Object(secretsName, secretsDefault).Call("org.freedesktop.Secret.Collection.CreateItem", 0, props, sec, true).Store(&item, &prompt); err != nil {
+		return fmt.Errorf("keyring: set %s: %w", name, err)
+	}
+	if prompt != secretsNoPrompt {
+		if _, err := s.prompt(prompt); err != nil {
+			return fmt.Errorf("keyring: set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// unlock unlocks objects, prompting the user if the service needs to, and
+// returns those that were unlocked.
+func (s *secretService) unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, error) {
+	var (
+		unlocked []dbus.ObjectPath
+		prompt   dbus.ObjectPath
+	)
+	if err := s.conn.Object(secretsName, secretsPath).Call(secretsService+".Unlock", 0, objects).Store(&unlocked, &prompt); err != nil {
+		return nil, err
+	}
+	if prompt == secretsNoPrompt {
+		return unlocked, nil
+	}
+	result, err := s.prompt(prompt)
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := result.Value().([]dbus.ObjectPath)
+	return paths, nil
+}
+
+// prompt shows a Secret Service prompt and waits for the user to complete
+// it, returning its result.
+func (s *secretService) prompt(path dbus.ObjectPath) (dbus.Variant, error) {
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface("org.freedesktop.Secret.Prompt"),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := s.conn.AddMatchSignal(match...); err != nil {
+		return dbus.Variant{}, err
+	}
+	defer s.conn.RemoveMatchSignal(match...)
+	signals := make(chan *dbus.Signal, 4)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	if err := s.conn.Object(secretsName, path).Call("org.freedesktop.Secret.Prompt.Prompt", 0, "").Err; err != nil {
+		return dbus.Variant{}, err
+	}
+	timeout := time.After(secretsPromptMax)
+	for {
+		select {
+		case sig := <-signals:
+			if sig.Path != path || sig.Name != "org.freedesktop.Secret.Prompt.Completed" || len(sig.Body) < 2 {
+				continue
+			}
+			if dismissed, _ := sig.Body[0].(bool); dismissed {
+				return dbus.Variant{}, errors.New("prompt dismissed")
+			}
+			result, _ := sig.Body[1].(dbus.Variant)
+			return result, nil
+		case <-timeout:
+			return dbus.Variant{}, errors.New("prompt timed out")
+		}
+	}
+}
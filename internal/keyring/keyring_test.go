@@ -0,0 +1,36 @@
+package keyring
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys")
+	k := File{Dir: dir}
+	if _, err := k.Get("db-key-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := k.Set("db-key-1", []byte{0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Set("db-key-1", []byte{3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := k.Get("db-key-1")
+	if err != nil || string(got) != "\x03\x04" {
+		t.Fatalf("got %v %v", got, err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, "db-key-1"))
+		if err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("expected a file only the user can read, got %v %v", info.Mode(), err)
+		}
+	}
+	if err := k.Set("../escape", []byte{1}); err == nil {
+		t.Error("expected error for a name with a path in it")
+	}
+}
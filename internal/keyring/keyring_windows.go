@@ -0,0 +1,78 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Default returns the user's keyring: secrets encrypted with DPAPI, which
+// only this user on this machine can decrypt, in files in the user's
+// config folder.
+func Default() (Keyring, error) {
+	d, err := dir()
+	if err != nil {
+		return nil, err
+	}
+	return dpapi{dir: d}, nil
+}
+
+type dpapi struct {
+	dir string
+}
+
+func (k dpapi) Get(name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	blob, err := os.ReadFile(filepath.Join(k.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+	secret, err := crypt(blob, false)
+	if err != nil {
+		return nil, fmt.Errorf("keyring: unprotect %s: %w", name, err)
+	}
+	return secret, nil
+}
+
+func (k dpapi) Set(name string, secret []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	blob, err := crypt(secret, true)
+	if err != nil {
+		return fmt.Errorf("keyring: protect %s: %w", name, err)
+	}
+	if err := writeFile(k.dir, name, blob); err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+	return nil
+}
+
+// crypt protects or unprotects data with the user's DPAPI key.
+func crypt(data []byte, protect bool) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty data")
+	}
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+	var err error
+	if protect {
+		err = windows.CryptProtectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	} else {
+		err = windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), nil
+}
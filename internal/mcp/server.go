@@ -7,9 +7,11 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
 	"time"
 
 	"github.com/vinistoisr/timewarp/internal/db"
+	"github.com/vinistoisr/timewarp/internal/rules"
 )
 
 type jsonRPCRequest struct {
@@ -40,40 +42,44 @@ type toolDef struct {
 var tools = []toolDef{
 	{
 		Name:        "get_weekly_summary",
-		Description: "Get a weekly focus activity summary for timecard generation. Returns attributed project time, unattributed app time, meetings, and inactivity.",
+		Description: "Get a weekly focus activity summary for timecard generation. Returns attributed project time, unattributed app time, meetings, imported calendar events with their scheduled and attended minutes, inactivity, and time away (asleep or locked). Project and app minutes are active time, leaving out inactivity within sessions.",
 		InputSchema: json.RawMessage(`{
 			"type": "object",
 			"properties": {
 				"week_start": {
 					"type": "string",
 					"description": "ISO date of the Monday starting the week (e.g. 2026-03-02). Defaults to current week."
-				}
+				},
+				"gross": {"type": "boolean", "description": "Also report gross_minutes, which count sessions whole, including idle time within them. total_minutes is always active time."},
+				` + timezoneProperty + `
 			}
 		}`),
 	},
 	{
 		Name:        "get_focus_time",
-		Description: "Get total focused minutes for a specific process across a date range.",
+		Description: "Get total focused minutes for a specific app across a date range.",
 		InputSchema: json.RawMessage(`{
 			"type": "object",
 			"properties": {
-				"process_name": {"type": "string", "description": "Process name (e.g. acad.exe)"},
+				"process_name": {"type": "string", "description": "Process name (e.g. acad.exe), or app identity for apps run by java, python, node or electron (e.g. java:loadcalc.jar)"},
 				"date_from": {"type": "string", "description": "Start date (ISO, e.g. 2026-03-02)"},
-				"date_to": {"type": "string", "description": "End date (ISO, e.g. 2026-03-08)"}
+				"date_to": {"type": "string", "description": "End date (ISO, e.g. 2026-03-08)"},
+				` + timezoneProperty + `
 			},
 			"required": ["process_name", "date_from", "date_to"]
 		}`),
 	},
 	{
 		Name:        "list_top_apps",
-		Description: "List top 10 processes by total focused minutes for a given week.",
+		Description: "List top 10 apps by total focused minutes for a given week. Apps run by java, python, node or electron are told apart by what they run, e.g. java:loadcalc.jar.",
 		InputSchema: json.RawMessage(`{
 			"type": "object",
 			"properties": {
 				"week_start": {
 					"type": "string",
 					"description": "ISO date of the Monday starting the week. Defaults to current week."
-				}
+				},
+				` + timezoneProperty + `
 			}
 		}`),
 	},
@@ -84,10 +90,164 @@ var tools = []toolDef{
 			"type": "object",
 			"properties": {
 				"date_from": {"type": "string", "description": "Start date (ISO, e.g. 2026-03-02). Defaults to current week Monday."},
-				"date_to": {"type": "string", "description": "End date (ISO, e.g. 2026-03-08). Defaults to Sunday after date_from."}
+				"date_to": {"type": "string", "description": "End date (ISO, e.g. 2026-03-08). Defaults to Sunday after date_from."},
+				"gross": {"type": "boolean", "description": "Also report gross_minutes, which count sessions whole, including idle time within them. total_minutes is always active time."},
+				` + timezoneProperty + `
 			}
 		}`),
 	},
+	{
+		Name:        "reattribute",
+		Description: "Re-evaluate project, client, task and category attribution for stored sessions using the current rules file (" + rules.FileName + " in the data folder). Runs as a dry run by default and returns the changes it would make; call again with dry_run false to apply them. Applied changes are recorded in an audit table.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"date_from": {"type": "string", "description": "First day to re-evaluate, inclusive (ISO, e.g. 2026-03-02). Defaults to the earliest session."},
+				"date_to": {"type": "string", "description": "Last day to re-evaluate, inclusive (ISO). Defaults to the latest session."},
+				"dry_run": {"type": "boolean", "description": "Only report what would change. Defaults to true."},
+				` + timezoneProperty + `
+			}
+		}`),
+	},
+	{
+		Name:        "add_manual_entry",
+		Description: "Log time that was not tracked, such as a site visit, phone call or whiteboard session. Manual entries are included in get_weekly_summary and get_daily_breakdown, counted towards their project and listed separately as manual. Returns the new entry with its id.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"project_number": {"type": "string", "description": "Project the time is for (e.g. 25-125)"},
+				"date": {"type": "string", "description": "Day of the entry (ISO, e.g. 2026-03-02)"},
+				"start_time": {"type": "string", "description": "Start time, HH:MM. Optional."},
+				"minutes": {"type": "number", "description": "Length of the entry in minutes"},
+				"description": {"type": "string", "description": "What the time was for (e.g. Site visit)"},
+				"client": {"type": "string", "description": "Client. Optional."},
+				"task": {"type": "string", "description": "Task. Optional."},
+				"category": {"type": "string", "description": "Category. Optional."},
+				` + timezoneProperty + `
+			},
+			"required": ["project_number", "date", "minutes"]
+		}`),
+	},
+	{
+		Name:        "edit_entry",
+		Description: "Correct a manual entry. Only the fields given are changed. Entries can only be edited on the machine they were logged on.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"id": {"type": "string", "description": "Entry id as returned by add_manual_entry or listed under manual_entries (hostname:mN)"},
+				"project_number": {"type": "string"},
+				"date": {"type": "string", "description": "New day (ISO). Required when start_time is given."},
+				"start_time": {"type": "string", "description": "New start time, HH:MM"},
+				"minutes": {"type": "number"},
+				"description": {"type": "string"},
+				"client": {"type": "string"},
+				"task": {"type": "string"},
+				"category": {"type": "string"},
+				` + timezoneProperty + `
+			},
+			"required": ["id"]
+		}`),
+	},
+	{
+		Name:        "delete_entry",
+		Description: "Delete a manual entry. Entries can only be deleted on the machine they were logged on.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"id": {"type": "string", "description": "Entry id (hostname:mN)"}
+			},
+			"required": ["id"]
+		}`),
+	},
+	{
+		Name:        "list_sessions",
+		Description: "List individual focus sessions with their ids, for use with assign_project, split_session and merge_sessions. Session ids also appear as session_ids in get_weekly_summary and get_daily_breakdown.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"date_from": {"type": "string", "description": "First day, inclusive (ISO, e.g. 2026-03-02). Defaults to Monday of the current week."},
+				"date_to": {"type": "string", "description": "Last day, inclusive (ISO). Defaults to date_from."},
+				"process_name": {"type": "string", "description": "Only sessions of this process (e.g. acad.exe). Optional."},
+				` + timezoneProperty + `
+			}
+		}`),
+	},
+	{
+		Name:        "assign_project",
+		Description: "Attribute sessions to a project, overriding the rules. Give either session_ids, or a from/to time range with an optional process_name. The stored sessions are not modified; the correction is recorded as an override that every query applies.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"session_ids": {"type": "array", "items": {"type": "string"}, "description": "Session ids (hostname:N or hostname:N+S)"},
+				"from": {"type": "string", "description": "Start of the time range (e.g. 2026-03-02T09:00:00Z)"},
+				"to": {"type": "string", "description": "End of the time range, exclusive"},
+				"process_name": {"type": "string", "description": "With from/to, only sessions of this process"},
+				"project_number": {"type": "string", "description": "Project to assign (e.g. 25-125). An empty string marks the sessions unattributed."},
+				` + timezoneProperty + `
+			},
+			"required": ["project_number"]
+		}`),
+	},
+	{
+		Name:        "split_session",
+		Description: "Split a session in two at a point in time, so the parts can be assigned to different projects. The first part keeps the session id; the second gets a new id, which is returned.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"id": {"type": "string", "description": "Session id"},
+				"at": {"type": "string", "description": "Time to split at, within the session (e.g. 2026-03-02T10:15:00Z)"},
+				` + timezoneProperty + `
+			},
+			"required": ["id", "at"]
+		}`),
+	},
+	{
+		Name:        "merge_sessions",
+		Description: "Merge sessions into the first one listed, which keeps its id, process, title and project and counts the combined time.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"ids": {"type": "array", "items": {"type": "string"}, "description": "Session ids, at least two"}
+			},
+			"required": ["ids"]
+		}`),
+	},
+	{
+		Name:        "forget_range",
+		Description: "Permanently delete tracked time that started in a time range from every machine's file: sessions, meetings, the raw log and inactivity, or with process_name or title_match only the matching sessions and meetings. Manual entries and calendar events are kept. Runs as a dry run by default and returns what it would delete; call again with dry_run false to delete it. Cannot be undone.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"from": {"type": "string", "description": "Start of the time range (e.g. 2026-03-02T09:00:00Z, or a day)"},
+				"to": {"type": "string", "description": "End of the time range, exclusive"},
+				"process_name": {"type": "string", "description": "Only this process or app (e.g. chrome.exe). Optional."},
+				"title_match": {"type": "string", "description": "Only sessions and meetings with a title matching this regular expression. Optional."},
+				"dry_run": {"type": "boolean", "description": "Only report what would be deleted. Defaults to true."},
+				` + timezoneProperty + `
+			},
+			"required": ["from", "to"]
+		}`),
+	},
+}
+
+// DefaultLocation is the time zone for day and week boundaries, and for
+// times without an offset, when a tool call does not give one.
+var DefaultLocation = time.Local
+
+// timezoneProperty is the schema of zoneArg, for the tools that take it.
+const timezoneProperty = `"timezone": {"type": "string", "description": "IANA time zone for day boundaries and times without an offset (e.g. America/Toronto). Defaults to the server's zone."}`
+
+// zoneArg is the timezone argument of the tools that work in days or
+// local times.
+type zoneArg struct {
+	Timezone string `json:"timezone"`
+}
+
+func (z zoneArg) location() (*time.Location, error) {
+	if z.Timezone == "" {
+		return DefaultLocation, nil
+	}
+	return db.LoadLocation(z.Timezone)
 }
 
 // Run starts the MCP stdio server. It reads JSON-RPC 2.0 requests from stdin
@@ -184,6 +344,24 @@ func handleToolCall(dbpath string, req *jsonRPCRequest) {
 		result, err = callListTopApps(dbpath, params.Arguments)
 	case "get_daily_breakdown":
 		result, err = callGetDailyBreakdown(dbpath, params.Arguments)
+	case "reattribute":
+		result, err = callReattribute(dbpath, params.Arguments)
+	case "add_manual_entry":
+		result, err = callAddManualEntry(dbpath, params.Arguments)
+	case "edit_entry":
+		result, err = callEditEntry(dbpath, params.Arguments)
+	case "delete_entry":
+		result, err = callDeleteEntry(dbpath, params.Arguments)
+	case "list_sessions":
+		result, err = callListSessions(dbpath, params.Arguments)
+	case "assign_project":
+		result, err = callAssignProject(dbpath, params.Arguments)
+	case "split_session":
+		result, err = callSplitSession(dbpath, params.Arguments)
+	case "merge_sessions":
+		result, err = callMergeSessions(dbpath, params.Arguments)
+	case "forget_range":
+		result, err = callForgetRange(dbpath, params.Arguments)
 	default:
 		toolResult := map[string]interface{}{
 			"content": []map[string]string{
@@ -217,23 +395,28 @@ func handleToolCall(dbpath string, req *jsonRPCRequest) {
 func callGetWeeklySummary(dbpath string, args json.RawMessage) (json.RawMessage, error) {
 	var a struct {
 		WeekStart string `json:"week_start"`
+		Gross     bool   `json:"gross"`
+		zoneArg
 	}
 	if len(args) > 0 {
 		json.Unmarshal(args, &a)
 	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
 
 	var weekStart time.Time
 	if a.WeekStart == "" {
-		weekStart = db.CurrentWeekMonday()
+		weekStart = db.CurrentWeekMonday(loc)
 	} else {
-		var err error
-		weekStart, err = db.ParseWeekStart(a.WeekStart)
+		weekStart, err = db.ParseWeekStart(a.WeekStart, loc)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return db.GetWeeklySummary(dbpath, weekStart)
+	return db.GetWeeklySummary(dbpath, weekStart, a.Gross)
 }
 
 func callGetFocusTime(dbpath string, args json.RawMessage) (json.RawMessage, error) {
@@ -241,6 +424,7 @@ func callGetFocusTime(dbpath string, args json.RawMessage) (json.RawMessage, err
 		ProcessName string `json:"process_name"`
 		DateFrom    string `json:"date_from"`
 		DateTo      string `json:"date_to"`
+		zoneArg
 	}
 	if err := json.Unmarshal(args, &a); err != nil {
 		return nil, fmt.Errorf("invalid arguments: %w", err)
@@ -248,12 +432,16 @@ func callGetFocusTime(dbpath string, args json.RawMessage) (json.RawMessage, err
 	if a.ProcessName == "" || a.DateFrom == "" || a.DateTo == "" {
 		return nil, fmt.Errorf("process_name, date_from, and date_to are required")
 	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
 
-	dateFrom, err := time.Parse("2006-01-02", a.DateFrom)
+	dateFrom, err := db.ParseDate(a.DateFrom, loc)
 	if err != nil {
 		return nil, fmt.Errorf("invalid date_from: %w", err)
 	}
-	dateTo, err := time.Parse("2006-01-02", a.DateTo)
+	dateTo, err := db.ParseDate(a.DateTo, loc)
 	if err != nil {
 		return nil, fmt.Errorf("invalid date_to: %w", err)
 	}
@@ -264,17 +452,21 @@ func callGetFocusTime(dbpath string, args json.RawMessage) (json.RawMessage, err
 func callListTopApps(dbpath string, args json.RawMessage) (json.RawMessage, error) {
 	var a struct {
 		WeekStart string `json:"week_start"`
+		zoneArg
 	}
 	if len(args) > 0 {
 		json.Unmarshal(args, &a)
 	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
 
 	var weekStart time.Time
 	if a.WeekStart == "" {
-		weekStart = db.CurrentWeekMonday()
+		weekStart = db.CurrentWeekMonday(loc)
 	} else {
-		var err error
-		weekStart, err = db.ParseWeekStart(a.WeekStart)
+		weekStart, err = db.ParseWeekStart(a.WeekStart, loc)
 		if err != nil {
 			return nil, err
 		}
@@ -287,17 +479,22 @@ func callGetDailyBreakdown(dbpath string, args json.RawMessage) (json.RawMessage
 	var a struct {
 		DateFrom string `json:"date_from"`
 		DateTo   string `json:"date_to"`
+		Gross    bool   `json:"gross"`
+		zoneArg
 	}
 	if len(args) > 0 {
 		json.Unmarshal(args, &a)
 	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
 
 	var dateFrom time.Time
 	if a.DateFrom == "" {
-		dateFrom = db.CurrentWeekMonday()
+		dateFrom = db.CurrentWeekMonday(loc)
 	} else {
-		var err error
-		dateFrom, err = time.Parse("2006-01-02", a.DateFrom)
+		dateFrom, err = db.ParseDate(a.DateFrom, loc)
 		if err != nil {
 			return nil, fmt.Errorf("invalid date_from: %w", err)
 		}
@@ -307,8 +504,7 @@ func callGetDailyBreakdown(dbpath string, args json.RawMessage) (json.RawMessage
 	if a.DateTo == "" {
 		dateTo = dateFrom.AddDate(0, 0, 7)
 	} else {
-		var err error
-		dateTo, err = time.Parse("2006-01-02", a.DateTo)
+		dateTo, err = db.ParseDate(a.DateTo, loc)
 		if err != nil {
 			return nil, fmt.Errorf("invalid date_to: %w", err)
 		}
@@ -320,7 +516,301 @@ func callGetDailyBreakdown(dbpath string, args json.RawMessage) (json.RawMessage
 		dateTo = dateFrom.AddDate(0, 0, 1)
 	}
 
-	return db.GetDailyBreakdown(dbpath, dateFrom, dateTo)
+	return db.GetDailyBreakdown(dbpath, dateFrom, dateTo, a.Gross)
+}
+
+func callReattribute(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a struct {
+		DateFrom string `json:"date_from"`
+		DateTo   string `json:"date_to"`
+		DryRun   *bool  `json:"dry_run"`
+		zoneArg
+	}
+	if len(args) > 0 {
+		json.Unmarshal(args, &a)
+	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
+
+	var dateFrom, dateTo time.Time
+	if a.DateFrom != "" {
+		if dateFrom, err = db.ParseDate(a.DateFrom, loc); err != nil {
+			return nil, fmt.Errorf("invalid date_from: %w", err)
+		}
+	}
+	if a.DateTo != "" {
+		if dateTo, err = db.ParseDate(a.DateTo, loc); err != nil {
+			return nil, fmt.Errorf("invalid date_to: %w", err)
+		}
+		dateTo = dateTo.AddDate(0, 0, 1)
+	}
+	dryRun := a.DryRun == nil || *a.DryRun
+
+	r, err := rules.LoadDir(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	report, err := db.Reattribute(dbpath, r, dateFrom, dateTo, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(report)
+}
+
+// entryArgs are the manual entry fields shared by add_manual_entry and
+// edit_entry. Absent fields are nil.
+type entryArgs struct {
+	ID          string   `json:"id"`
+	Project     *string  `json:"project_number"`
+	Date        string   `json:"date"`
+	StartTime   string   `json:"start_time"`
+	Minutes     *float64 `json:"minutes"`
+	Description *string  `json:"description"`
+	Client      *string  `json:"client"`
+	Task        *string  `json:"task"`
+	Category    *string  `json:"category"`
+	zoneArg
+}
+
+func (a *entryArgs) input() (db.ManualInput, error) {
+	in := db.ManualInput{
+		Project:     a.Project,
+		Client:      a.Client,
+		Task:        a.Task,
+		Category:    a.Category,
+		Description: a.Description,
+		Minutes:     a.Minutes,
+	}
+	if a.StartTime != "" && a.Date == "" {
+		return in, fmt.Errorf("date is required with start_time")
+	}
+	if a.Date != "" {
+		loc, err := a.location()
+		if err != nil {
+			return in, err
+		}
+		start, err := db.ParseEntryStart(a.Date, a.StartTime, loc)
+		if err != nil {
+			return in, err
+		}
+		in.Start = &start
+	}
+	return in, nil
+}
+
+func callAddManualEntry(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a entryArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if a.Date == "" {
+		return nil, fmt.Errorf("date is required")
+	}
+	in, err := a.input()
+	if err != nil {
+		return nil, err
+	}
+	e, err := db.AddManualEntry(dbpath, in)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(e)
+}
+
+func callEditEntry(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a entryArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if a.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	in, err := a.input()
+	if err != nil {
+		return nil, err
+	}
+	e, err := db.EditManualEntry(dbpath, a.ID, in)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(e)
+}
+
+func callDeleteEntry(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if a.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	if err := db.DeleteManualEntry(dbpath, a.ID); err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]string{"deleted": a.ID})
+}
+
+func callListSessions(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a struct {
+		DateFrom    string `json:"date_from"`
+		DateTo      string `json:"date_to"`
+		ProcessName string `json:"process_name"`
+		zoneArg
+	}
+	if len(args) > 0 {
+		json.Unmarshal(args, &a)
+	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
+
+	dateFrom := db.CurrentWeekMonday(loc)
+	if a.DateFrom != "" {
+		if dateFrom, err = db.ParseDate(a.DateFrom, loc); err != nil {
+			return nil, fmt.Errorf("invalid date_from: %w", err)
+		}
+	}
+	dateTo := dateFrom
+	if a.DateTo != "" {
+		if dateTo, err = db.ParseDate(a.DateTo, loc); err != nil {
+			return nil, fmt.Errorf("invalid date_to: %w", err)
+		}
+	}
+
+	sessions, err := db.ListSessions(dbpath, dateFrom, dateTo.AddDate(0, 0, 1), a.ProcessName)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sessions)
+}
+
+func callAssignProject(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a struct {
+		SessionIDs  []string `json:"session_ids"`
+		From        string   `json:"from"`
+		To          string   `json:"to"`
+		ProcessName string   `json:"process_name"`
+		Project     *string  `json:"project_number"`
+		zoneArg
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if a.Project == nil {
+		return nil, fmt.Errorf("project_number is required")
+	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
+
+	var from, to time.Time
+	if len(a.SessionIDs) == 0 {
+		if a.From == "" || a.To == "" {
+			return nil, fmt.Errorf("session_ids or from and to are required")
+		}
+		if from, err = db.ParseTime(a.From, loc); err != nil {
+			return nil, err
+		}
+		if to, err = db.ParseTime(a.To, loc); err != nil {
+			return nil, err
+		}
+	}
+
+	sessions, err := db.AssignProject(dbpath, a.SessionIDs, from, to, a.ProcessName, *a.Project)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sessions)
+}
+
+func callSplitSession(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a struct {
+		ID string `json:"id"`
+		At string `json:"at"`
+		zoneArg
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if a.ID == "" || a.At == "" {
+		return nil, fmt.Errorf("id and at are required")
+	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
+	at, err := db.ParseTime(a.At, loc)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := db.SplitSession(dbpath, a.ID, at)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(parts)
+}
+
+func callMergeSessions(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	merged, err := db.MergeSessions(dbpath, a.IDs)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(merged)
+}
+
+func callForgetRange(dbpath string, args json.RawMessage) (json.RawMessage, error) {
+	var a struct {
+		From        string `json:"from"`
+		To          string `json:"to"`
+		ProcessName string `json:"process_name"`
+		TitleMatch  string `json:"title_match"`
+		DryRun      *bool  `json:"dry_run"`
+		zoneArg
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if a.From == "" || a.To == "" {
+		return nil, fmt.Errorf("from and to are required")
+	}
+	loc, err := a.location()
+	if err != nil {
+		return nil, err
+	}
+
+	f := db.PurgeFilter{Process: a.ProcessName}
+	if f.From, err = db.ParseTime(a.From, loc); err != nil {
+		return nil, err
+	}
+	if f.To, err = db.ParseTime(a.To, loc); err != nil {
+		return nil, err
+	}
+	if !f.From.Before(f.To) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if a.TitleMatch != "" {
+		if f.TitleMatch, err = regexp.Compile(a.TitleMatch); err != nil {
+			return nil, fmt.Errorf("invalid title_match: %w", err)
+		}
+	}
+
+	report, err := db.Purge(dbpath, f, a.DryRun == nil || *a.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(report)
 }
 
 func writeResult(id json.RawMessage, result interface{}) {
@@ -6,6 +6,9 @@ import (
 	"io"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/db"
 )
 
 // captureStdout runs fn with stdout redirected and returns the output.
@@ -107,15 +110,17 @@ func TestToolsList(t *testing.T) {
 	}
 	json.Unmarshal(resp.Result, &result)
 
-	if len(result.Tools) != 4 {
-		t.Fatalf("expected 4 tools, got %d", len(result.Tools))
+	if len(result.Tools) != 13 {
+		t.Fatalf("expected 13 tools, got %d", len(result.Tools))
 	}
 
 	names := map[string]bool{}
 	for _, tool := range result.Tools {
 		names[tool.Name] = true
 	}
-	for _, expected := range []string{"get_weekly_summary", "get_focus_time", "list_top_apps", "get_daily_breakdown"} {
+	for _, expected := range []string{"get_weekly_summary", "get_focus_time", "list_top_apps", "get_daily_breakdown", "reattribute",
+		"add_manual_entry", "edit_entry", "delete_entry", "list_sessions", "assign_project", "split_session", "merge_sessions",
+		"forget_range"} {
 		if !names[expected] {
 			t.Errorf("missing tool: %s", expected)
 		}
@@ -214,3 +219,219 @@ func TestToolsCall_NilParams(t *testing.T) {
 		t.Errorf("expected -32602, got %d", resp.Error.Code)
 	}
 }
+
+// callTool runs a tools/call request against dbpath and returns the text of
+// the result and whether it was an error.
+func callTool(t *testing.T, dbpath, name string, args map[string]interface{}) (string, bool) {
+	t.Helper()
+	params, _ := json.Marshal(map[string]interface{}{"name": name, "arguments": args})
+	req := &jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(`8`),
+		Method:  "tools/call",
+		Params:  params,
+	}
+
+	output := captureStdout(t, func() {
+		handleRequest(dbpath, req)
+	})
+
+	var resp jsonRPCResponse
+	json.Unmarshal([]byte(output), &resp)
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Content) != 1 {
+		t.Fatalf("unexpected response: %s", output)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func TestManualEntryTools(t *testing.T) {
+	dir := t.TempDir()
+
+	text, isErr := callTool(t, dir, "add_manual_entry", map[string]interface{}{
+		"project_number": "25-125", "date": "2026-03-03", "start_time": "08:00", "minutes": 90, "description": "Site visit",
+		"timezone": "UTC",
+	})
+	if isErr {
+		t.Fatalf("add_manual_entry: %s", text)
+	}
+	var e db.ManualEntry
+	json.Unmarshal([]byte(text), &e)
+	if e.ID == "" || e.Minutes != 90 || e.Start != "2026-03-03T08:00:00Z" {
+		t.Fatalf("unexpected entry: %s", text)
+	}
+
+	text, isErr = callTool(t, dir, "edit_entry", map[string]interface{}{"id": e.ID, "minutes": 45})
+	if isErr {
+		t.Fatalf("edit_entry: %s", text)
+	}
+	json.Unmarshal([]byte(text), &e)
+	if e.Minutes != 45 || e.Description != "Site visit" {
+		t.Errorf("unexpected edited entry: %s", text)
+	}
+
+	if text, isErr = callTool(t, dir, "edit_entry", map[string]interface{}{"id": e.ID, "start_time": "09:00"}); !isErr {
+		t.Errorf("expected start_time without date to fail, got %s", text)
+	}
+
+	if text, isErr = callTool(t, dir, "delete_entry", map[string]interface{}{"id": e.ID}); isErr {
+		t.Fatalf("delete_entry: %s", text)
+	}
+	if _, isErr = callTool(t, dir, "delete_entry", map[string]interface{}{"id": e.ID}); !isErr {
+		t.Error("expected deleting twice to fail")
+	}
+}
+
+func TestReattribute_DefaultsToDryRun(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Close()
+
+	text, isErr := callTool(t, dir, "reattribute", map[string]interface{}{"date_from": "2026-03-02"})
+	if isErr {
+		t.Fatalf("reattribute: %s", text)
+	}
+
+	var report db.ReattributeReport
+	if err := json.Unmarshal([]byte(text), &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || report.DateFrom != "2026-03-02" || len(report.Files) != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestForgetRange(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for s := 0; s <= 60; s += 5 {
+		tr.RecordFocus("HOST", "user", "acad.exe", "Contoso 25-125.dwg", start.Add(time.Duration(s)*time.Second))
+	}
+	tr.RecordFocus("HOST", "user", "chrome.exe", "Inbox", start.Add(2*time.Minute))
+	tr.Close()
+
+	args := map[string]interface{}{"from": "2026-03-02T09:00:00Z", "to": "2026-03-02T10:00:00Z", "title_match": "(?i)contoso"}
+	text, isErr := callTool(t, dir, "forget_range", args)
+	if isErr {
+		t.Fatalf("forget_range: %s", text)
+	}
+	var report db.PurgeReport
+	json.Unmarshal([]byte(text), &report)
+	if !report.DryRun || report.Sessions != 1 {
+		t.Errorf("unexpected dry run: %s", text)
+	}
+
+	args["dry_run"] = false
+	if text, isErr = callTool(t, dir, "forget_range", args); isErr {
+		t.Fatalf("forget_range: %s", text)
+	}
+	sessions, err := db.ListSessions(dir, start, start.Add(time.Hour), "")
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("expected the session forgotten, got %+v %v", sessions, err)
+	}
+}
+
+func TestSessionOverrideTools(t *testing.T) {
+	dir := t.TempDir()
+	tr, err := db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	// acad.exe 09:00 to the last tick before 10:00, then chrome.exe to 10:30
+	for s := 0; s <= 90*60; s += 20 {
+		proc := "acad.exe"
+		if s >= 60*60 {
+			proc = "chrome.exe"
+		}
+		tr.RecordFocus("HOST", "user", proc, proc, start.Add(time.Duration(s)*time.Second))
+	}
+	tr.Close()
+
+	text, isErr := callTool(t, dir, "list_sessions", map[string]interface{}{"date_from": "2026-03-02", "timezone": "UTC"})
+	if isErr {
+		t.Fatalf("list_sessions: %s", text)
+	}
+	var sessions []db.Session
+	json.Unmarshal([]byte(text), &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %s", text)
+	}
+
+	text, isErr = callTool(t, dir, "split_session", map[string]interface{}{"id": sessions[0].ID, "at": "2026-03-02T09:20:00Z"})
+	if isErr {
+		t.Fatalf("split_session: %s", text)
+	}
+	var parts []db.Session
+	json.Unmarshal([]byte(text), &parts)
+	if len(parts) != 2 || parts[0].Minutes != 20 || parts[1].Minutes != 39.7 {
+		t.Fatalf("unexpected parts: %s", text)
+	}
+
+	text, isErr = callTool(t, dir, "assign_project", map[string]interface{}{
+		"session_ids": []string{parts[1].ID}, "project_number": "25-125",
+	})
+	if isErr {
+		t.Fatalf("assign_project: %s", text)
+	}
+
+	text, isErr = callTool(t, dir, "merge_sessions", map[string]interface{}{"ids": []string{parts[1].ID, sessions[1].ID}})
+	if isErr {
+		t.Fatalf("merge_sessions: %s", text)
+	}
+	var merged db.Session
+	json.Unmarshal([]byte(text), &merged)
+	if merged.Project != "25-125" || merged.Minutes != 69.7 {
+		t.Errorf("unexpected merged session: %s", text)
+	}
+
+	if text, isErr = callTool(t, dir, "assign_project", map[string]interface{}{"project_number": "25-125"}); !isErr {
+		t.Errorf("expected assign_project without ids or range to fail, got %s", text)
+	}
+}
+
+func TestTimezoneArgument(t *testing.T) {
+	dir := t.TempDir()
+
+	text, isErr := callTool(t, dir, "add_manual_entry", map[string]interface{}{
+		"project_number": "25-125", "date": "2026-03-03", "start_time": "08:00", "minutes": 30,
+		"timezone": "America/Toronto",
+	})
+	if isErr {
+		t.Fatalf("add_manual_entry: %s", text)
+	}
+	var e db.ManualEntry
+	json.Unmarshal([]byte(text), &e)
+	if e.Start != "2026-03-03T13:00:00Z" {
+		t.Errorf("expected 08:00 Toronto time, got %s", e.Start)
+	}
+
+	text, isErr = callTool(t, dir, "get_daily_breakdown", map[string]interface{}{
+		"date_from": "2026-03-03", "date_to": "2026-03-03", "timezone": "America/Toronto",
+	})
+	if isErr {
+		t.Fatalf("get_daily_breakdown: %s", text)
+	}
+	var daily db.DailyBreakdown
+	json.Unmarshal([]byte(text), &daily)
+	if daily.Timezone != "America/Toronto" || len(daily.Days) != 1 || len(daily.Days[0].Manual) != 1 {
+		t.Errorf("unexpected breakdown: %s", text)
+	}
+
+	if text, isErr = callTool(t, dir, "get_weekly_summary", map[string]interface{}{"timezone": "Mars/Olympus_Mons"}); !isErr {
+		t.Errorf("expected unknown zone to fail, got %s", text)
+	}
+}
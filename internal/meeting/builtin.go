@@ -0,0 +1,68 @@
+package meeting
+
+import (
+	"regexp"
+
+	"github.com/vinistoisr/timewarp/internal/browser"
+)
+
+// The built-in detectors, in the order they are tried. Each app's title
+// patterns go from the most specific to the least; the last Teams and Zoom
+// patterns are the "Meeting ..." titles detected before there were
+// detectors, so sessions keep the subjects they had.
+var (
+	Teams = &App{
+		Name:    "Teams",
+		Process: regexp.MustCompile(`(?i)^((ms-)?teams|teams-for-linux)(\.exe)?$`),
+		Titles: []*regexp.Regexp{
+			regexp.MustCompile(`^(?P<subject>(?:Meeting|Call) with (?P<attendees>.+?))(?:\s*\| Microsoft Teams.*)?$`),
+			regexp.MustCompile(`^(?P<subject>.+?) \((?:Meeting|Call)\)(?:\s*\| Microsoft Teams.*)?$`),
+			regexp.MustCompile(`^Meeting(?: in| now)?\b\s*(?P<subject>.*?)(?:\s*\| Microsoft Teams.*)?$`),
+			regexp.MustCompile(`\bMeeting\b\s*(?P<subject>.*?)(?:\s*\| Microsoft Teams.*)?$`),
+		},
+	}
+
+	Zoom = &App{
+		Name:    "Zoom",
+		Process: regexp.MustCompile(`(?i)^zoom(\.exe|\.us)?$`),
+		Titles: []*regexp.Regexp{
+			regexp.MustCompile(`^Zoom (?:Meeting|Webinar)(?:\s+[-–|]\s+(?P<subject>.+))?$`),
+			regexp.MustCompile(`^(?P<subject>(?P<attendees>.+?)'s (?:Personal Meeting Room|Zoom Meeting))$`),
+			regexp.MustCompile(`\bMeeting\b\s*(?P<subject>.*)$`),
+		},
+	}
+
+	Webex = &App{
+		Name:    "Webex",
+		Process: regexp.MustCompile(`(?i)^(ciscocollabhost|webex|webexhost|atmgr)(\.exe)?$`),
+		Titles: []*regexp.Regexp{
+			regexp.MustCompile(`^(?:Cisco )?Webex Meetings?(?:\s+[-–|]\s+(?P<subject>.+))?$`),
+			regexp.MustCompile(`^(?P<subject>(?P<attendees>.+?)'s Personal Room)(?:\s+[-–|]\s+(?:Cisco )?Webex.*)?$`),
+			regexp.MustCompile(`^(?P<subject>.+?)\s+[-–|]\s+(?:Cisco )?Webex(?: Meetings?)?$`),
+		},
+	}
+
+	Meet = &App{
+		Name:    "Google Meet",
+		Process: regexp.MustCompile(browser.ProcessPattern),
+		Titles: []*regexp.Regexp{
+			regexp.MustCompile(`^Meet\s+[-–—]\s+(?P<subject>.+?)(?:\s+[-–—]\s+.*(?:Google Chrome|Edge|Firefox|Brave|Opera|Vivaldi|Chromium))?$`),
+		},
+	}
+
+	Slack = &App{
+		Name:    "Slack",
+		Process: regexp.MustCompile(`(?i)^slack(\.exe)?$`),
+		Titles: []*regexp.Regexp{
+			regexp.MustCompile(`(?:^|\|\s*)(?P<subject>Huddle with (?P<attendees>[^|]+?))\s*(?:\||\s-\s|$)`),
+			regexp.MustCompile(`(?:^|\|\s*)(?P<subject>Huddle in #?[^\s|]+)`),
+			regexp.MustCompile(`(?:^|\|\s*)Huddle:\s*(?P<subject>#?[^\s|]+)`),
+		},
+	}
+)
+
+func init() {
+	for _, d := range []Detector{Teams, Zoom, Webex, Meet, Slack} {
+		Register(d)
+	}
+}
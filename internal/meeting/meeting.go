@@ -0,0 +1,103 @@
+// Package meeting recognises video calls from the window in front: which app
+// it is, the meeting's subject, and who is in it when the title says.
+// Detectors for the common apps are built in; others can be registered.
+package meeting
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Meeting is what a detector found in a window.
+type Meeting struct {
+	App       string
+	Subject   string
+	Attendees []string
+}
+
+// Detector recognises the meeting windows of one app.
+type Detector interface {
+	Detect(process, title string) (Meeting, bool)
+}
+
+// App detects an app's meetings from title patterns. Process matches the
+// process name and should be case-insensitive; Titles are tried in order.
+// A title pattern's named groups give the meeting's subject and attendees;
+// without a subject, the app's name is used.
+type App struct {
+	Name    string
+	Process *regexp.Regexp
+	Titles  []*regexp.Regexp
+}
+
+func (a *App) Detect(process, title string) (Meeting, bool) {
+	if !a.Process.MatchString(process) {
+		return Meeting{}, false
+	}
+	for _, re := range a.Titles {
+		sub := re.FindStringSubmatch(title)
+		if sub == nil {
+			continue
+		}
+		m := Meeting{App: a.Name}
+		for i, name := range re.SubexpNames() {
+			switch name {
+			case "subject":
+				m.Subject = strings.Trim(sub[i], " \"“”")
+			case "attendees":
+				m.Attendees = splitAttendees(sub[i])
+			}
+		}
+		if m.Subject == "" {
+			m.Subject = a.Name
+		}
+		return m, true
+	}
+	return Meeting{}, false
+}
+
+var (
+	separator = regexp.MustCompile(`\s*(?:,|;|\band\b|&)\s*`)
+	// others matches the "3 others" Teams and Slack add to long lists.
+	others = regexp.MustCompile(`^\d+ (?:others?|more)$`)
+)
+
+// splitAttendees splits a list of names such as "Ann Lee, Bo Chan and 2
+// others", leaving out the count of the rest.
+func splitAttendees(s string) []string {
+	var names []string
+	for _, part := range separator.Split(s, -1) {
+		part = strings.TrimSpace(part)
+		if part != "" && !others.MatchString(part) {
+			names = append(names, part)
+		}
+	}
+	return names
+}
+
+var (
+	mu        sync.RWMutex
+	detectors []Detector
+)
+
+// Register adds d to the detectors tried by Detect, after those already
+// registered.
+func Register(d Detector) {
+	mu.Lock()
+	defer mu.Unlock()
+	detectors = append(detectors, d)
+}
+
+// Detect reports whether the window of process with title is a meeting, and
+// which, using the first detector that recognises it.
+func Detect(process, title string) (Meeting, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, d := range detectors {
+		if m, ok := d.Detect(process, title); ok {
+			return m, true
+		}
+	}
+	return Meeting{}, false
+}
@@ -0,0 +1,113 @@
+package meeting
+
+import (
+	"reflect"
+	"testing"
+)
+
+type titleCase struct {
+	process, title string
+	want           *Meeting // nil if not a meeting
+}
+
+func runCases(t *testing.T, cases []titleCase) {
+	t.Helper()
+	for _, c := range cases {
+		got, ok := Detect(c.process, c.title)
+		switch {
+		case c.want == nil && ok:
+			t.Errorf("%s %q: detected %+v, want no meeting", c.process, c.title, got)
+		case c.want != nil && !ok:
+			t.Errorf("%s %q: not detected, want %+v", c.process, c.title, *c.want)
+		case c.want != nil && !reflect.DeepEqual(got, *c.want):
+			t.Errorf("%s %q: got %+v, want %+v", c.process, c.title, got, *c.want)
+		}
+	}
+}
+
+func TestDetect_Teams(t *testing.T) {
+	runCases(t, []titleCase{
+		{"ms-teams.exe", "Meeting with Ann Lee, Bo Chan and 2 others | Microsoft Teams",
+			&Meeting{App: "Teams", Subject: "Meeting with Ann Lee, Bo Chan and 2 others", Attendees: []string{"Ann Lee", "Bo Chan"}}},
+		{"ms-teams.exe", "Call with Ann Lee | Microsoft Teams",
+			&Meeting{App: "Teams", Subject: "Call with Ann Lee", Attendees: []string{"Ann Lee"}}},
+		{"ms-teams.exe", "25-019 Design Review (Meeting) | Microsoft Teams",
+			&Meeting{App: "Teams", Subject: "25-019 Design Review"}},
+		{"Teams.exe", "Meeting 25-019 Design Review",
+			&Meeting{App: "Teams", Subject: "25-019 Design Review"}},
+		{"Teams.exe", `Meeting in "General" | Microsoft Teams`,
+			&Meeting{App: "Teams", Subject: "General"}},
+		{"ms-teams.exe", "Meeting now | Microsoft Teams",
+			&Meeting{App: "Teams", Subject: "Teams"}},
+		{"ms-teams.exe", "Chat | Ann Lee | Microsoft Teams", nil},
+		{"ms-teams.exe", "Calendar | Microsoft Teams", nil},
+		{"winword.exe", "Meeting with Ann Lee.docx - Word", nil},
+	})
+}
+
+func TestDetect_Zoom(t *testing.T) {
+	runCases(t, []titleCase{
+		{"Zoom.exe", "Zoom Meeting", &Meeting{App: "Zoom", Subject: "Zoom"}},
+		{"zoom.exe", "Zoom Webinar - Quarterly Update", &Meeting{App: "Zoom", Subject: "Quarterly Update"}},
+		{"zoom.us", "Ann Lee's Personal Meeting Room",
+			&Meeting{App: "Zoom", Subject: "Ann Lee's Personal Meeting Room", Attendees: []string{"Ann Lee"}}},
+		{"zoom.exe", "Meeting 25-019 Design Review", &Meeting{App: "Zoom", Subject: "25-019 Design Review"}},
+		{"zoom.exe", "Zoom Workplace", nil},
+	})
+}
+
+func TestDetect_Webex(t *testing.T) {
+	runCases(t, []titleCase{
+		{"CiscoCollabHost.exe", "Webex Meetings", &Meeting{App: "Webex", Subject: "Webex"}},
+		{"atmgr.exe", "Webex Meeting - Site Coordination", &Meeting{App: "Webex", Subject: "Site Coordination"}},
+		{"CiscoCollabHost.exe", "Site Coordination | Webex", &Meeting{App: "Webex", Subject: "Site Coordination"}},
+		{"webex.exe", "Ann Lee's Personal Room",
+			&Meeting{App: "Webex", Subject: "Ann Lee's Personal Room", Attendees: []string{"Ann Lee"}}},
+		{"CiscoCollabHost.exe", "Webex", nil},
+	})
+}
+
+func TestDetect_Meet(t *testing.T) {
+	runCases(t, []titleCase{
+		{"chrome.exe", "Meet - abc-defg-hij - Google Chrome", &Meeting{App: "Google Meet", Subject: "abc-defg-hij"}},
+		{"msedge.exe", "Meet – Design Review - Work - Microsoft​ Edge", &Meeting{App: "Google Meet", Subject: "Design Review"}},
+		{"firefox.exe", "Meet - Design Review — Mozilla Firefox", &Meeting{App: "Google Meet", Subject: "Design Review"}},
+		{"chrome.exe", "Google Meet - Google Chrome", nil},
+		{"chrome.exe", "Meet the team - Company Blog - Google Chrome", nil},
+		{"acad.exe", "Meet - abc-defg-hij", nil},
+	})
+}
+
+func TestDetect_Slack(t *testing.T) {
+	runCases(t, []titleCase{
+		{"slack.exe", "Slack | Huddle with Ann Lee & Bo Chan | Acme",
+			&Meeting{App: "Slack", Subject: "Huddle with Ann Lee & Bo Chan", Attendees: []string{"Ann Lee", "Bo Chan"}}},
+		{"slack.exe", "Huddle with Ann Lee - Acme - Slack",
+			&Meeting{App: "Slack", Subject: "Huddle with Ann Lee", Attendees: []string{"Ann Lee"}}},
+		{"Slack.exe", "Slack | Huddle in #site-coordination | Acme", &Meeting{App: "Slack", Subject: "Huddle in #site-coordination"}},
+		{"slack.exe", "Huddle: #general - Acme - Slack", &Meeting{App: "Slack", Subject: "#general"}},
+		{"slack.exe", "Slack | general | Acme", nil},
+	})
+}
+
+type fixed struct{ process string }
+
+func (f fixed) Detect(process, title string) (Meeting, bool) {
+	if process != f.process {
+		return Meeting{}, false
+	}
+	return Meeting{App: "Custom", Subject: title}, true
+}
+
+func TestRegister(t *testing.T) {
+	saved := detectors
+	defer func() { detectors = saved }()
+
+	Register(fixed{"jitsi.exe"})
+	if got, ok := Detect("jitsi.exe", "Standup"); !ok || got.Subject != "Standup" {
+		t.Errorf("custom detector: got %+v, %v", got, ok)
+	}
+	if got, _ := Detect("ms-teams.exe", "Meeting with Ann Lee"); got.App != "Teams" {
+		t.Errorf("built-ins still tried first, got %+v", got)
+	}
+}
@@ -0,0 +1,157 @@
+// Package privacy decides what of a captured window may be recorded at all,
+// before it reaches the metrics or the DB: processes that are never
+// recorded, title patterns that are blanked out, applications whose time is
+// recorded but not their titles, and private browsing windows.
+package privacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/vinistoisr/timewarp/internal/appid"
+	"github.com/vinistoisr/timewarp/internal/browser"
+	"github.com/vinistoisr/timewarp/internal/capture"
+)
+
+// FileName is the privacy file looked for in the DB folder when no path is
+// given, so every machine sharing the folder keeps the same things out.
+const FileName = "timewarp-privacy.json"
+
+// privateWindow matches the titles browsers give incognito, InPrivate and
+// private browsing windows.
+var privateWindow = regexp.MustCompile(`(?i)\bincognito\b|\binprivate\b|\bprivate browsing\b|\(private\)\s*$`)
+
+// Config is a privacy file. Deny and TimeOnly are case-insensitive regular
+// expressions on the process name or the application identity (see
+// package appid); Redact are regular expressions on titles.
+type Config struct {
+	// Deny are processes that are not recorded at all: not their time,
+	// not their titles
+	Deny []string `json:"deny,omitempty"`
+	// TimeOnly are processes whose time is recorded under their process
+	// name, without titles or command lines
+	TimeOnly []string `json:"time_only,omitempty"`
+	// Redact are blanked out of titles and command lines, as by
+	// appid.Redact: the groups they capture, or else all they match
+	Redact []string `json:"redact,omitempty"`
+	// PrivateBrowsing records incognito, InPrivate and private browsing
+	// windows as if they were TimeOnly; it is on unless set to false
+	PrivateBrowsing *bool `json:"private_browsing,omitempty"`
+}
+
+// Policy is a compiled Config.
+type Policy struct {
+	deny, timeOnly, redact []*regexp.Regexp
+	privateBrowsing        bool
+	allTimeOnly            bool
+}
+
+// Default returns a Policy that only keeps the titles of private browsing
+// windows out.
+func Default() *Policy {
+	return &Policy{privateBrowsing: true}
+}
+
+// New compiles c.
+func New(c Config) (*Policy, error) {
+	p := &Policy{privateBrowsing: c.PrivateBrowsing == nil || *c.PrivateBrowsing}
+	var err error
+	if p.deny, err = compile("deny", "(?i)", c.Deny); err != nil {
+		return nil, err
+	}
+	if p.timeOnly, err = compile("time_only", "(?i)", c.TimeOnly); err != nil {
+		return nil, err
+	}
+	if p.redact, err = compile("redact", "", c.Redact); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func compile(field, flags string, patterns []string) ([]*regexp.Regexp, error) {
+	var out []*regexp.Regexp
+	for i, s := range patterns {
+		if s == "" {
+			return nil, fmt.Errorf("privacy: %s %d is empty", field, i+1)
+		}
+		re, err := regexp.Compile(flags + s)
+		if err != nil {
+			return nil, fmt.Errorf("privacy: %s %d: %w", field, i+1, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// Load reads a privacy file of the form {"deny": [...], "time_only": [...],
+// "redact": [...]}.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("privacy: read %s: %w", path, err)
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("privacy: parse %s: %w", path, err)
+	}
+	return New(c)
+}
+
+// LoadDir loads FileName from dir, or returns Default if there is none.
+func LoadDir(dir string) (*Policy, error) {
+	p, err := Load(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil
+	}
+	return p, err
+}
+
+// Private returns a copy of p under which no application's titles are
+// recorded, as for the -private flag.
+func (p *Policy) Private() *Policy {
+	c := *p
+	c.allTimeOnly = true
+	return &c
+}
+
+// Apply returns w as it may be recorded, and false if it may not be
+// recorded at all. A time-only window keeps its process, executable and
+// class, but its title becomes the process name and its command line is
+// dropped.
+func (p *Policy) Apply(w capture.Window) (capture.Window, bool) {
+	app := appid.Identify(w.ProcessName, w.ExePath, w.Class, w.CommandLine)
+	if matchAny(p.deny, w.ProcessName, app) {
+		return capture.Window{ID: w.ID}, false
+	}
+	if p.allTimeOnly || matchAny(p.timeOnly, w.ProcessName, app) ||
+		(p.privateBrowsing && browser.IsBrowser(w.ProcessName) && privateWindow.MatchString(w.Title)) {
+		w.Title = w.ProcessName
+		w.CommandLine = ""
+		return w, true
+	}
+	w.Title = p.Redact(w.Title)
+	w.CommandLine = p.Redact(w.CommandLine)
+	return w, true
+}
+
+// Redact blanks the policy's redactions out of s.
+func (p *Policy) Redact(s string) string {
+	if s == "" || len(p.redact) == 0 {
+		return s
+	}
+	return appid.Redact(s, p.redact)
+}
+
+func matchAny(patterns []*regexp.Regexp, process, app string) bool {
+	for _, re := range patterns {
+		if re.MatchString(process) || re.MatchString(app) {
+			return true
+		}
+	}
+	return false
+}
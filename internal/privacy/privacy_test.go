@@ -0,0 +1,123 @@
+package privacy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vinistoisr/timewarp/internal/capture"
+)
+
+func TestApply(t *testing.T) {
+	p, err := New(Config{
+		Deny:     []string{`^keepass`, `^java:vault\.jar$`},
+		TimeOnly: []string{`^outlook\.exe$`},
+		Redact:   []string{`\b(?:\d{4} ){3}\d{4}\b`, `Patient:\s*(\w+)`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		in    capture.Window
+		want  capture.Window
+		allow bool
+	}{
+		{capture.Window{ID: 1, ProcessName: "KeePassXC.exe", Title: "Personal.kdbx"}, capture.Window{ID: 1}, false},
+		{capture.Window{ID: 2, ProcessName: "javaw.exe", Title: "Vault", CommandLine: "javaw -jar vault.jar"}, capture.Window{ID: 2}, false},
+		{
+			capture.Window{ProcessName: "OUTLOOK.EXE", Title: "RE: Severance terms - Outlook", Class: "rctrl_renwnd32", CommandLine: "OUTLOOK.EXE /select x"},
+			capture.Window{ProcessName: "OUTLOOK.EXE", Title: "OUTLOOK.EXE", Class: "rctrl_renwnd32"}, true,
+		},
+		{
+			capture.Window{ProcessName: "chrome.exe", Title: "Card 4111 1111 1111 1111 - Bank"},
+			capture.Window{ProcessName: "chrome.exe", Title: "Card *** - Bank"}, true,
+		},
+		{
+			capture.Window{ProcessName: "acad.exe", Title: "Patient: Hartley.dwg", CommandLine: `acad.exe "Patient: Hartley.dwg"`},
+			capture.Window{ProcessName: "acad.exe", Title: "Patient: ***.dwg", CommandLine: `acad.exe "Patient: ***.dwg"`}, true,
+		},
+	}
+	for _, c := range cases {
+		got, ok := p.Apply(c.in)
+		if got != c.want || ok != c.allow {
+			t.Errorf("%s %q: got %+v %v, want %+v %v", c.in.ProcessName, c.in.Title, got, ok, c.want, c.allow)
+		}
+	}
+}
+
+func TestApply_PrivateBrowsing(t *testing.T) {
+	titles := map[string]string{
+		"msedge.exe":    "Results - [InPrivate] - Microsoft Edge",
+		"chrome.exe":    "New Tab - Google Chrome (Incognito)",
+		"firefox":       "Results — Mozilla Firefox Private Browsing",
+		"brave.exe":     "Results - Brave (Private)",
+		"notepad.exe":   "incognito-notes.txt - Notepad",
+		"google-chrome": "Going incognito, explained - Google Chrome",
+	}
+	p := Default()
+	for process, title := range titles {
+		got, _ := p.Apply(capture.Window{ProcessName: process, Title: title})
+		if process == "notepad.exe" {
+			if got.Title != title {
+				t.Errorf("%s: title changed to %q", process, got.Title)
+			}
+		} else if got.Title != process {
+			t.Errorf("%s: expected title %q, got %q", process, process, got.Title)
+		}
+	}
+
+	off := false
+	p, err := New(Config{PrivateBrowsing: &off})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := p.Apply(capture.Window{ProcessName: "msedge.exe", Title: titles["msedge.exe"]}); got.Title != titles["msedge.exe"] {
+		t.Errorf("expected the title kept with private_browsing off, got %q", got.Title)
+	}
+}
+
+func TestPrivate(t *testing.T) {
+	p, err := New(Config{Deny: []string{`^keepass`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	private := p.Private()
+	if got, _ := private.Apply(capture.Window{ProcessName: "acad.exe", Title: "25-125.dwg"}); got.Title != "acad.exe" {
+		t.Errorf("expected the process name, got %q", got.Title)
+	}
+	if _, ok := private.Apply(capture.Window{ProcessName: "keepass.exe"}); ok {
+		t.Error("expected the deny list kept")
+	}
+	if got, _ := p.Apply(capture.Window{ProcessName: "acad.exe", Title: "25-125.dwg"}); got.Title != "25-125.dwg" {
+		t.Errorf("Private changed the original policy: %q", got.Title)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if p, err := LoadDir(dir); err != nil || !p.privateBrowsing {
+		t.Fatalf("expected the default without a file, got %v", err)
+	}
+
+	data := `{"deny": ["^keepass"], "redact": ["\\d{3}-\\d{2}-\\d{4}"], "private_browsing": false}`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := p.Apply(capture.Window{ProcessName: "excel.exe", Title: "SSN 123-45-6789.xlsx"}); got.Title != "SSN ***.xlsx" {
+		t.Errorf("got %q", got.Title)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"deny": ["("]}`), 0o644)
+	if _, err := Load(bad); err == nil {
+		t.Error("expected error for bad regex")
+	}
+	os.WriteFile(bad, []byte(`{"time_only": [""]}`), 0o644)
+	if _, err := Load(bad); err == nil {
+		t.Error("expected error for an empty pattern")
+	}
+}
@@ -0,0 +1,202 @@
+// Package profiles holds the per-process settings that decide how focus
+// samples are stitched into sessions: how long a gap is bridged, how short a
+// session is dropped, and which processes are not tracked at all.
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FileName is the profiles file looked for in the DB folder when no path is
+// given, so every machine sharing the folder stitches sessions the same way.
+const FileName = "timewarp-profiles.json"
+
+const (
+	// DefaultBridge is the longest gap in a process's samples that still
+	// counts as one session, for processes no profile sets it for.
+	DefaultBridge = 30 * time.Second
+	// DefaultMinimum is the shortest session kept; anything shorter was
+	// most likely a window passed through on the way to another.
+	DefaultMinimum = 10 * time.Second
+)
+
+// suppressed are processes that are never tracked, whatever the profiles
+// say: remote desktop (the remote machine tracks itself), window hosts that
+// only ever front another app, and the lock and logon screens.
+var suppressed = map[string]bool{
+	"mstsc.exe":                true,
+	"applicationframehost.exe": true,
+	"shellexperiencehost.exe":  true,
+	"lockapp.exe":              true,
+	"logonui.exe":              true,
+}
+
+// Settings are the sessionization settings for one process.
+type Settings struct {
+	Bridge   time.Duration
+	Minimum  time.Duration
+	Suppress bool
+}
+
+// Profile is one entry in a profiles file. Process is a case-insensitive
+// regular expression on the process name; a profile without one applies to
+// every process. Unset fields are left to later profiles and then the
+// defaults.
+type Profile struct {
+	Name     string    `json:"name,omitempty"`
+	Process  string    `json:"process,omitempty"`
+	Bridge   *Duration `json:"bridge,omitempty"`
+	Minimum  *Duration `json:"minimum,omitempty"`
+	Suppress bool      `json:"suppress,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "90s" or "2m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"90s\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return fmt.Errorf("negative duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+type compiled struct {
+	Profile
+	re *regexp.Regexp
+}
+
+// Set is an ordered, compiled list of profiles. Like attribution rules, each
+// setting is taken from the first matching profile that sets it, so a broad
+// profile further down can fill in what a specific one above left out.
+type Set struct {
+	profiles []compiled
+}
+
+// Default returns a Set with no profiles, under which every process gets
+// the default settings.
+func Default() *Set {
+	return &Set{}
+}
+
+// New compiles profiles in order.
+func New(profiles []Profile) (*Set, error) {
+	s := &Set{}
+	for i, p := range profiles {
+		c := compiled{Profile: p}
+		if p.Process != "" {
+			re, err := regexp.Compile("(?i)" + p.Process)
+			if err != nil {
+				return nil, fmt.Errorf("profiles: profile %d (%s): %w", i+1, p.Name, err)
+			}
+			c.re = re
+		}
+		if p.Bridge == nil && p.Minimum == nil && !p.Suppress {
+			return nil, fmt.Errorf("profiles: profile %d (%s) sets nothing", i+1, p.Name)
+		}
+		s.profiles = append(s.profiles, c)
+	}
+	return s, nil
+}
+
+// Load reads a profiles file of the form {"profiles": [...]}.
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("profiles: read %s: %w", path, err)
+	}
+	var f struct {
+		Profiles []Profile `json:"profiles"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("profiles: parse %s: %w", path, err)
+	}
+	return New(f.Profiles)
+}
+
+// LoadDir loads FileName from dir, or returns Default if there is none.
+func LoadDir(dir string) (*Set, error) {
+	s, err := Load(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil
+	}
+	return s, err
+}
+
+// For returns the settings for process.
+func (s *Set) For(process string) Settings {
+	var (
+		out             Settings
+		bridge, minimum bool
+	)
+	for _, p := range s.profiles {
+		if p.re != nil && !p.re.MatchString(process) {
+			continue
+		}
+		if p.Bridge != nil && !bridge {
+			out.Bridge, bridge = time.Duration(*p.Bridge), true
+		}
+		if p.Minimum != nil && !minimum {
+			out.Minimum, minimum = time.Duration(*p.Minimum), true
+		}
+		out.Suppress = out.Suppress || p.Suppress
+	}
+	if !bridge {
+		out.Bridge = DefaultBridge
+	}
+	if !minimum {
+		out.Minimum = DefaultMinimum
+	}
+	if suppressed[strings.ToLower(process)] {
+		out.Suppress = true
+	}
+	return out
+}
+
+// Describe returns one line per profile, then the defaults, for showing
+// which profiles are in effect.
+func (s *Set) Describe() []string {
+	var lines []string
+	for i, p := range s.profiles {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("profile %d", i+1)
+		}
+		process := p.Process
+		if process == "" {
+			process = "any process"
+		}
+		var parts []string
+		if p.Bridge != nil {
+			parts = append(parts, "bridge "+time.Duration(*p.Bridge).String())
+		}
+		if p.Minimum != nil {
+			parts = append(parts, "minimum "+time.Duration(*p.Minimum).String())
+		}
+		if p.Suppress {
+			parts = append(parts, "not tracked")
+		}
+		lines = append(lines, fmt.Sprintf("%s (%s): %s", name, process, strings.Join(parts, ", ")))
+	}
+	return append(lines, fmt.Sprintf("default: bridge %s, minimum %s", DefaultBridge, DefaultMinimum))
+}
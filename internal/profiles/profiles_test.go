@@ -0,0 +1,76 @@
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFor_Default(t *testing.T) {
+	s := Default()
+	if got := s.For("acad.exe"); got != (Settings{Bridge: DefaultBridge, Minimum: DefaultMinimum}) {
+		t.Errorf("got %+v", got)
+	}
+	if got := s.For("LockApp.exe"); !got.Suppress {
+		t.Errorf("expected LockApp.exe to be suppressed, got %+v", got)
+	}
+}
+
+func TestFor_FirstMatchPerSetting(t *testing.T) {
+	d := func(v time.Duration) *Duration { x := Duration(v); return &x }
+	s, err := New([]Profile{
+		{Name: "cad", Process: `^(acad|revit)\.exe$`, Bridge: d(2 * time.Minute)},
+		{Name: "dialer", Process: `^phone\.exe$`, Minimum: d(0)},
+		{Name: "helpers", Process: `^onedrive\.exe$`, Suppress: true},
+		{Name: "everything", Bridge: d(time.Minute), Minimum: d(5 * time.Second)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]Settings{
+		"ACAD.EXE":     {Bridge: 2 * time.Minute, Minimum: 5 * time.Second},
+		"phone.exe":    {Bridge: time.Minute, Minimum: 0},
+		"OneDrive.exe": {Bridge: time.Minute, Minimum: 5 * time.Second, Suppress: true},
+		"chrome.exe":   {Bridge: time.Minute, Minimum: 5 * time.Second},
+	}
+	for process, want := range cases {
+		if got := s.For(process); got != want {
+			t.Errorf("%s: got %+v, want %+v", process, got, want)
+		}
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	if _, err := New([]Profile{{Name: "empty", Process: "x"}}); err == nil {
+		t.Error("expected error for profile that sets nothing")
+	}
+	if _, err := New([]Profile{{Process: `(`, Suppress: true}}); err == nil {
+		t.Error("expected error for bad regex")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if s, err := LoadDir(dir); err != nil || s.For("acad.exe").Bridge != DefaultBridge {
+		t.Fatalf("expected defaults without a file, got %v", err)
+	}
+
+	data := `{"profiles": [{"name": "cad", "process": "^acad\\.exe$", "bridge": "2m", "minimum": "30s"}]}`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.For("acad.exe"); got.Bridge != 2*time.Minute || got.Minimum != 30*time.Second {
+		t.Errorf("got %+v", got)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"profiles": [{"process": "x", "bridge": 90}]}`), 0o644)
+	if _, err := Load(bad); err == nil {
+		t.Error("expected error for a duration that is not a string")
+	}
+}
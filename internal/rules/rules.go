@@ -0,0 +1,210 @@
+// Package rules attributes focus sessions to a project, client, task and
+// category using an ordered list of matchers loaded from a JSON file.
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// FileName is the rules file looked for in the DB folder when no path is
+// given, so every machine sharing the folder uses the same rules.
+const FileName = "timewarp-rules.json"
+
+// Input is what a session is matched on. App is the application identity
+// (see package appid), which is the process name for most applications.
+type Input struct {
+	Title   string
+	Process string
+	ExePath string
+	Class   string
+	App     string
+	URL     string
+}
+
+// Attribution is the result of evaluating a Set against an Input.
+// Empty fields mean no rule supplied a value.
+type Attribution struct {
+	Project  string `json:"project,omitempty"`
+	Client   string `json:"client,omitempty"`
+	Task     string `json:"task,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// Rule is one entry in a rules file. Every non-empty matcher must match for
+// the rule to apply. Title and URL are case-sensitive regular expressions;
+// Process, ExePath, Class (the window class), App (the application
+// identity) and Domain (the host name in the URL) are matched
+// case-insensitively.
+//
+// Outputs are templates: $1 or ${1} expand to a numbered capture group of
+// the first matcher the rule uses (title, url, domain, exe_path, class, app,
+// then process), and ${name} to a named group from any of them.
+type Rule struct {
+	Name    string `json:"name,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Process string `json:"process,omitempty"`
+	ExePath string `json:"exe_path,omitempty"`
+	Class   string `json:"class,omitempty"`
+	App     string `json:"app,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+
+	Project  string `json:"project,omitempty"`
+	Client   string `json:"client,omitempty"`
+	Task     string `json:"task,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+type matcher struct {
+	re    *regexp.Regexp
+	field func(Input) string
+}
+
+type compiled struct {
+	Rule
+	matchers []matcher
+}
+
+// Set is an ordered, compiled list of rules. Rules are tried in order and
+// each output field is taken from the first matching rule that sets it, so a
+// broad rule further down can fill in a category that a specific rule above
+// left blank.
+type Set struct {
+	rules []compiled
+}
+
+// DefaultProjectPattern is the project number format used when no rules
+// file is configured: YY-NNN, e.g. 25-125.
+const DefaultProjectPattern = `(?:^|\D)(\d{2}-\d{3})(?:\D|$)`
+
+// Default returns the built-in rules: project numbers in the YY-NNN form
+// anywhere in the window title.
+func Default() *Set {
+	s, err := New([]Rule{{Name: "project number in title", Title: DefaultProjectPattern, Project: "$1"}})
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// New compiles rules in order.
+func New(rules []Rule) (*Set, error) {
+	s := &Set{}
+	for i, r := range rules {
+		c := compiled{Rule: r}
+		for _, m := range []struct {
+			pattern string
+			flags   string
+			field   func(Input) string
+		}{
+			{r.Title, "", func(in Input) string { return in.Title }},
+			{r.URL, "", func(in Input) string { return in.URL }},
+			{r.Domain, "(?i)", func(in Input) string { return domain(in.URL) }},
+			{r.ExePath, "(?i)", func(in Input) string { return in.ExePath }},
+			{r.Class, "(?i)", func(in Input) string { return in.Class }},
+			{r.App, "(?i)", func(in Input) string { return in.App }},
+			{r.Process, "(?i)", func(in Input) string { return in.Process }},
+		} {
+			if m.pattern == "" {
+				continue
+			}
+			re, err := regexp.Compile(m.flags + m.pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rules: rule %d (%s): %w", i+1, r.Name, err)
+			}
+			c.matchers = append(c.matchers, matcher{re: re, field: m.field})
+		}
+		if len(c.matchers) == 0 {
+			return nil, fmt.Errorf("rules: rule %d (%s) has no matchers", i+1, r.Name)
+		}
+		s.rules = append(s.rules, c)
+	}
+	return s, nil
+}
+
+// Load reads a rules file of the form {"rules": [...]}.
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("rules: read %s: %w", path, err)
+	}
+	var f struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("rules: parse %s: %w", path, err)
+	}
+	return New(f.Rules)
+}
+
+// LoadDir loads FileName from dir, or returns Default if there is none.
+func LoadDir(dir string) (*Set, error) {
+	s, err := Load(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Default(), nil
+	}
+	return s, err
+}
+
+// Evaluate runs the rules against in.
+func (s *Set) Evaluate(in Input) Attribution {
+	var a Attribution
+	for _, r := range s.rules {
+		groups, ok := r.match(in)
+		if !ok {
+			continue
+		}
+		fill(&a.Project, r.Project, groups)
+		fill(&a.Client, r.Client, groups)
+		fill(&a.Task, r.Task, groups)
+		fill(&a.Category, r.Category, groups)
+		if a.Project != "" && a.Client != "" && a.Task != "" && a.Category != "" {
+			break
+		}
+	}
+	return a
+}
+
+// match reports whether every matcher of r matches in, and returns the
+// capture groups available to its templates.
+func (r *compiled) match(in Input) (map[string]string, bool) {
+	groups := map[string]string{}
+	for i, m := range r.matchers {
+		sub := m.re.FindStringSubmatch(m.field(in))
+		if sub == nil {
+			return nil, false
+		}
+		for j, name := range m.re.SubexpNames() {
+			if i == 0 && j > 0 {
+				groups[fmt.Sprint(j)] = sub[j]
+			}
+			if name != "" {
+				groups[name] = sub[j]
+			}
+		}
+	}
+	return groups, true
+}
+
+// domain returns the host name in a URL, or "" if it has none.
+func domain(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func fill(dst *string, tmpl string, groups map[string]string) {
+	if *dst != "" || tmpl == "" {
+		return
+	}
+	*dst = os.Expand(tmpl, func(k string) string { return groups[k] })
+}
@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefault_ProjectNumber(t *testing.T) {
+	cases := map[string]string{
+		"25-125_SLD-E101.dwg - AutoCAD": "25-125",
+		"RE: 25-019 Design Review":      "25-019",
+		"ESPN - NBA Scores":             "",
+		"Invoice 2025-1234":             "",
+	}
+	s := Default()
+	for title, want := range cases {
+		if got := s.Evaluate(Input{Title: title}).Project; got != want {
+			t.Errorf("%q: got %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestEvaluate_FirstMatchPerField(t *testing.T) {
+	s, err := New([]Rule{
+		{Title: `\b(?P<key>[A-Z]+-\d+)\b`, Process: `chrome\.exe`, Project: "${key}", Task: "Jira $1"},
+		{Title: `\d{2}-\d{3}`, Project: "never"},
+		{Process: `^chrome\.exe$`, Client: "Contoso", Category: "Browsing"},
+		{Process: `.*`, Category: "Other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Evaluate(Input{Title: "PRJ-4412 25-125 Board - Jira", Process: "Chrome.exe"})
+	want := Attribution{Project: "PRJ-4412", Client: "Contoso", Task: "Jira PRJ-4412", Category: "Browsing"}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got = s.Evaluate(Input{Title: "Untitled", Process: "notepad.exe"})
+	if got != (Attribution{Category: "Other"}) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestEvaluate_AllMatchersMustMatch(t *testing.T) {
+	s, err := New([]Rule{{ExePath: `\\Autodesk\\`, URL: `^https://`, Project: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Evaluate(Input{ExePath: `C:\Program Files\Autodesk\acad.exe`}); got.Project != "" {
+		t.Errorf("matched without URL: %+v", got)
+	}
+	if got := s.Evaluate(Input{ExePath: `c:\program files\autodesk\acad.exe`, URL: "https://example.com"}); got.Project != "x" {
+		t.Errorf("expected case-insensitive exe path match, got %+v", got)
+	}
+}
+
+func TestEvaluate_DomainAndURL(t *testing.T) {
+	s, err := New([]Rule{
+		{Domain: `^acme\.atlassian\.net$`, URL: `/browse/(?P<project>[A-Z]+)-\d+`, Project: "${project}", Category: "Tickets"},
+		{Domain: `(^|\.)sharepoint\.com$`, URL: `/Projects/(\d{2}-\d{3})`, Project: "$1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]Attribution{
+		"https://ACME.atlassian.net/browse/PRJ-4412":                                {Project: "PRJ", Category: "Tickets"},
+		"https://contoso.sharepoint.com/sites/eng/Projects/25-125/Drawings":         {Project: "25-125"},
+		"https://evil.example/acme.atlassian.net/browse/PRJ-1":                      {},
+		"https://contoso.sharepoint.com.example/sites/eng/Projects/25-125/Drawings": {},
+	}
+	for u, want := range cases {
+		if got := s.Evaluate(Input{Title: "Chrome", Process: "chrome.exe", URL: u}); got != want {
+			t.Errorf("%s: got %+v, want %+v", u, got, want)
+		}
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	if _, err := New([]Rule{{Name: "empty", Project: "x"}}); err == nil {
+		t.Error("expected error for rule without matchers")
+	}
+	if _, err := New([]Rule{{Title: `(`, Project: "x"}}); err == nil {
+		t.Error("expected error for bad regex")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	data := `{"rules": [{"name": "jira", "title": "\\b([A-Z]{2,}-\\d+)\\b", "project": "$1", "category": "Development"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Evaluate(Input{Title: "OPS-17 Fix login"})
+	if got.Project != "OPS-17" || got.Category != "Development" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestEvaluate_AppAndClass(t *testing.T) {
+	s, err := New([]Rule{
+		{App: `^java:loadcalc\.jar$`, Category: "Engineering"},
+		{Class: `^jetbrains-`, Category: "Development"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Evaluate(Input{Process: "javaw.exe", App: "java:LoadCalc.jar"}); got.Category != "Engineering" {
+		t.Errorf("app: got %+v", got)
+	}
+	if got := s.Evaluate(Input{Process: "java", App: "java:com.intellij.idea.Main", Class: "jetbrains-idea"}); got.Category != "Development" {
+		t.Errorf("class: got %+v", got)
+	}
+	if got := s.Evaluate(Input{Process: "javaw.exe", App: "javaw.exe"}); got != (Attribution{}) {
+		t.Errorf("expected no match on the bare runtime, got %+v", got)
+	}
+}
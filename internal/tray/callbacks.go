@@ -0,0 +1,30 @@
+package tray
+
+import "path/filepath"
+
+// Callbacks allows the main app to wire up tray actions.
+type Callbacks struct {
+	OnReady                func()
+	OnQuit                 func()
+	OnPause                func()
+	OnResume               func()
+	SetInactivityThreshold func(seconds uint64)
+	OnPrometheusToggle     func(enable bool)
+	OnSetDBPath            func(path string)
+	// ReloadProfiles re-reads the session profiles and returns a
+	// description of them, or why they could not be loaded.
+	ReloadProfiles func() string
+	// GetMCPConfig returns the MCP JSON config string.
+	GetMCPConfig func() string
+	// GetMCPPaths returns the exe path and db path for MCP config.
+	GetMCPPaths func() (exePath, dbPath string)
+}
+
+// HasExistingDB checks if any timewarp-*.db files exist in the given directory.
+func HasExistingDB(dir string) bool {
+	matches, err := filepath.Glob(filepath.Join(dir, "timewarp-*.db"))
+	if err != nil {
+		return false
+	}
+	return len(matches) > 0
+}
@@ -1,3 +1,5 @@
+//go:build windows
+
 package tray
 
 import (
@@ -31,15 +33,6 @@ func messageBox(title, text string, flags uintptr) int {
 	return int(ret)
 }
 
-// HasExistingDB checks if any timewarp-*.db files exist in the given directory.
-func HasExistingDB(dir string) bool {
-	matches, err := filepath.Glob(filepath.Join(dir, "timewarp-*.db"))
-	if err != nil {
-		return false
-	}
-	return len(matches) > 0
-}
-
 // hiddenCmd returns a Cmd with the console window hidden.
 func hiddenCmd(name string, args ...string) *exec.Cmd {
 	cmd := exec.Command(name, args...)
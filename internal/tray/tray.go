@@ -1,3 +1,5 @@
+//go:build windows
+
 package tray
 
 import (
@@ -11,21 +13,6 @@ import (
 	"github.com/getlantern/systray"
 )
 
-// Callbacks allows the main app to wire up tray actions.
-type Callbacks struct {
-	OnReady                func()
-	OnQuit                 func()
-	OnPause                func()
-	OnResume               func()
-	SetInactivityThreshold func(seconds uint64)
-	OnPrometheusToggle     func(enable bool)
-	OnSetDBPath            func(path string)
-	// GetMCPConfig returns the MCP JSON config string.
-	GetMCPConfig func() string
-	// GetMCPPaths returns the exe path and db path for MCP config.
-	GetMCPPaths func() (exePath, dbPath string)
-}
-
 // Run starts the system tray icon. It blocks until quit.
 func Run(dbpath string, cb Callbacks) {
 	systray.Run(func() {
@@ -61,6 +48,7 @@ func Run(dbpath string, cb Callbacks) {
 			thresholdItems = append(thresholdItems, item)
 		}
 		thresholdItems[1].Check()
+		mProfiles := systray.AddMenuItem("Session Profiles...", "Reload and show the per-process session settings")
 
 		systray.AddSeparator()
 
@@ -116,6 +104,11 @@ func Run(dbpath string, cb Callbacks) {
 				case <-thresholdItems[4].ClickedCh:
 					selectThreshold(thresholdItems, 4, thresholds[4].seconds, cb.SetInactivityThreshold)
 
+				case <-mProfiles.ClickedCh:
+					if cb.ReloadProfiles != nil {
+						messageBox("Timewarp — Session Profiles", cb.ReloadProfiles(), mbOK|mbIconInfo)
+					}
+
 				case <-mProm.ClickedCh:
 					if promEnabled.Load() {
 						promEnabled.Store(false)
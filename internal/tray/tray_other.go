@@ -0,0 +1,55 @@
+//go:build !windows
+
+package tray
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// Run has no tray icon outside Windows. It starts tracking and blocks until
+// the process is interrupted.
+func Run(dbpath string, cb Callbacks) {
+	if cb.OnReady != nil {
+		cb.OnReady()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+
+	if cb.OnQuit != nil {
+		cb.OnQuit()
+	}
+}
+
+// HasScheduledTask checks if the Timewarp XDG autostart entry exists.
+func HasScheduledTask() bool {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(dir, "autostart", "timewarp.desktop"))
+	return err == nil
+}
+
+// RunOnboarding has no folder picker outside Windows; it uses the executable's
+// directory, which can be overridden with -dbpath.
+func RunOnboarding() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	dir := filepath.Dir(exe)
+	log.Printf("No -dbpath given; storing data in %s", dir)
+	return dir
+}
+
+// OfferScheduledTask is a no-op outside Windows; use -install to add an
+// autostart entry.
+func OfferScheduledTask(exePath, dbpath string) bool {
+	return false
+}
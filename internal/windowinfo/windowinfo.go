@@ -3,38 +3,52 @@ package windowinfo
 import (
 	"fmt"
 	"os"
-	"regexp"
-	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vinistoisr/timewarp/internal/capture"
+	"github.com/vinistoisr/timewarp/internal/clock"
+	"github.com/vinistoisr/timewarp/internal/db"
 	"github.com/vinistoisr/timewarp/internal/inactivity"
-	"golang.org/x/sys/windows"
+	"github.com/vinistoisr/timewarp/internal/meeting"
+	"github.com/vinistoisr/timewarp/internal/privacy"
 )
 
 var (
-	user32                  = syscall.NewLazyDLL("user32.dll")
-	kernel32                = syscall.NewLazyDLL("kernel32.dll")
-	psapi                   = syscall.NewLazyDLL("psapi.dll")
-	procGetForegroundWindow = user32.NewProc("GetForegroundWindow")
-	procGetWindowTextW      = user32.NewProc("GetWindowTextW")
-	procGetModuleBaseNameW  = psapi.NewProc("GetModuleBaseNameW")
-	openProcess             = kernel32.NewProc("OpenProcess")
-	closeHandle             = kernel32.NewProc("CloseHandle")
-	currentForegroundWindow windows.HWND
+	currentForegroundWindow uint64
 	LastWindowInfo          ActiveWindowInfo
 	LastWindowFocusTime     time.Time
-	mutex                   sync.Mutex
+	// TickInterval is how often ProcessWindowInfo is called; each idle tick
+	// adds this much to the inactivity counter.
+	TickInterval = time.Second
+	mutex        sync.Mutex
+
+	policy atomic.Pointer[privacy.Policy]
 )
 
+// SetPrivacy sets the privacy policy every captured window is put through
+// before anything of it reaches the metrics or the tracker. Until it is
+// called, privacy.Default applies.
+func SetPrivacy(p *privacy.Policy) {
+	policy.Store(p)
+}
+
+func currentPolicy() *privacy.Policy {
+	if p := policy.Load(); p != nil {
+		return p
+	}
+	return privacy.Default()
+}
+
 // FocusTracker is the interface for recording focus and inactivity events to the DB.
 type FocusTracker interface {
-	RecordFocus(hostname, username, processName, windowTitle string, now time.Time)
+	RecordWindow(hostname, username string, w db.Window, now time.Time)
+	RecordWindowChange(hostname, username string, w db.Window, at time.Time)
 	RecordInactivityStart(now time.Time)
 	RecordInactivityEnd(hostname, username string, now time.Time)
+	RecordSystemEvent(hostname, username, event string, at time.Time)
 }
 
 // ActiveWindowInfo struct to hold information about the active window
@@ -42,100 +56,67 @@ type ActiveWindowInfo struct {
 	Title       string
 	ProcessID   uint32
 	ProcessName string
+	ExePath     string
+	Class       string
+	CommandLine string
 	Hostname    string
 	Username    string
+	// denied is set, and the rest left empty, for a window the privacy
+	// policy keeps out altogether
+	denied bool
 }
 
 // GetActiveWindowInfo retrieves information about the active window
 func GetActiveWindowInfo(focusChangeCounter prometheus.CounterVec) (ActiveWindowInfo, error) {
-	hwnd := getForegroundWindow()
-	if hwnd == 0 {
-		return ActiveWindowInfo{}, fmt.Errorf("could not get foreground window")
-	}
-
-	title := getWindowText(hwnd)
-
-	var processID uint32
-	if _, err := windows.GetWindowThreadProcessId(hwnd, &processID); err != nil {
-		return ActiveWindowInfo{}, fmt.Errorf("could not get process ID: %w", err)
+	win, err := capture.Current().ActiveWindow()
+	if err != nil {
+		return ActiveWindowInfo{}, err
 	}
+	return windowInfoFrom(win, focusChangeCounter)
+}
 
-	processName := getProcessName(processID)
-
+// windowInfoFrom puts a captured window through the privacy policy, so
+// nothing it keeps out is ever seen further down, adds the host and user to
+// it, and counts the change if it is a different window from last time.
+// Changes to windows the policy keeps out are not counted.
+func windowInfoFrom(win capture.Window, focusChangeCounter prometheus.CounterVec) (ActiveWindowInfo, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
 		return ActiveWindowInfo{}, fmt.Errorf("could not get hostname: %w", err)
 	}
 
-	username := os.Getenv("USERNAME")
+	username := db.CurrentUser()
+
+	changed := win.ID != currentForegroundWindow
+	currentForegroundWindow = win.ID
+	win, ok := currentPolicy().Apply(win)
+	if !ok {
+		return ActiveWindowInfo{Hostname: hostname, Username: username, denied: true}, nil
+	}
 
 	// If the foreground window has changed, increment the focus change counter
-	if hwnd != currentForegroundWindow {
-		currentForegroundWindow = hwnd
+	if changed {
 		focusChangeCounter.WithLabelValues(hostname, username).Inc()
 	}
 
 	return ActiveWindowInfo{
-		Title:       title,
-		ProcessID:   processID,
-		ProcessName: processName,
+		Title:       win.Title,
+		ProcessID:   win.ProcessID,
+		ProcessName: win.ProcessName,
+		ExePath:     win.ExePath,
+		Class:       win.Class,
+		CommandLine: win.CommandLine,
 		Hostname:    hostname,
 		Username:    username,
 	}, nil
 }
 
-func getForegroundWindow() windows.HWND {
-	ret, _, _ := procGetForegroundWindow.Call()
-	return windows.HWND(ret)
-}
-
-func getWindowText(hwnd windows.HWND) string {
-	const maxChars = 256
-	text := make([]uint16, maxChars)
-	ret, _, _ := procGetWindowTextW.Call(
-		uintptr(hwnd),
-		uintptr(unsafe.Pointer(&text[0])),
-		uintptr(maxChars),
-	)
-	if ret == 0 {
-		return "" // Empty string if no title
-	}
-	return windows.UTF16ToString(text)
-}
-
-func getProcessName(processID uint32) string {
-	// PROCESS_QUERY_INFORMATION | PROCESS_VM_READ
-	desiredAccess := uint32(0x0400 | 0x0010)
-	handle, _, _ := openProcess.Call(uintptr(desiredAccess), 0, uintptr(processID))
-	if handle == 0 {
-		return ""
-	}
-	defer closeHandle.Call(handle)
-
-	const maxPath = 260
-	var processName [maxPath]uint16
-	ret, _, _ := procGetModuleBaseNameW.Call(
-		handle,
-		0,
-		uintptr(unsafe.Pointer(&processName[0])),
-		uintptr(maxPath),
-	)
-	if ret == 0 {
-		return ""
-	}
-	return windows.UTF16ToString(processName[:])
-}
-
-func ExtractMeetingSubject(title string) string {
-	re := regexp.MustCompile(`Meeting\s*(.*)`)
-	matches := re.FindStringSubmatch(title)
-	if len(matches) > 1 {
-		return strings.TrimSpace(matches[1])
-	}
-	return ""
+// window returns the window as the tracker records it.
+func (w ActiveWindowInfo) window() db.Window {
+	return db.Window{Process: w.ProcessName, Title: w.Title, ExePath: w.ExePath, Class: w.Class, CommandLine: w.CommandLine}
 }
 
-func ProcessWindowInfo(inactivityThreshold uint64, privateMode bool, debugMode bool,
+func ProcessWindowInfo(inactivityThreshold uint64, debugMode bool, clk clock.Clock,
 	focusChangeCounter, focusedWindowDuration, meetingDuration, inactivityMetric *prometheus.CounterVec, windowPidGauge *prometheus.GaugeVec,
 	tracker FocusTracker,
 ) {
@@ -151,6 +132,8 @@ func ProcessWindowInfo(inactivityThreshold uint64, privateMode bool, debugMode b
 		fmt.Println("Window Title:", windowInfo.Title)
 		fmt.Println("Process ID:", windowInfo.ProcessID)
 		fmt.Println("Process Name:", windowInfo.ProcessName)
+		fmt.Println("Executable:", windowInfo.ExePath)
+		fmt.Println("Window Class:", windowInfo.Class)
 		fmt.Println("Hostname:", windowInfo.Hostname)
 		fmt.Println("Username:", windowInfo.Username)
 	}
@@ -158,32 +141,15 @@ func ProcessWindowInfo(inactivityThreshold uint64, privateMode bool, debugMode b
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	windowTitle := windowInfo.Title
-	if privateMode {
-		windowTitle = windowInfo.ProcessName
-	}
-
-	windowPidGauge.Reset()
-	windowPidGauge.WithLabelValues(windowInfo.Hostname, windowInfo.Username, windowTitle, windowInfo.ProcessName).Set(float64(windowInfo.ProcessID))
+	setWindowGauge(windowPidGauge, windowInfo)
 
-	now := time.Now()
+	now := clk.Now()
 
-	if tracker != nil {
-		tracker.RecordFocus(windowInfo.Hostname, windowInfo.Username, windowInfo.ProcessName, windowInfo.Title, now)
+	if tracker != nil && !windowInfo.denied {
+		tracker.RecordWindow(windowInfo.Hostname, windowInfo.Username, windowInfo.window(), now)
 	}
 
-	if windowInfo != LastWindowInfo {
-		duration := time.Since(LastWindowFocusTime).Seconds()
-		focusedWindowDuration.WithLabelValues(LastWindowInfo.Hostname, LastWindowInfo.Username, LastWindowInfo.ProcessName).Add(duration)
-
-		if (windowInfo.ProcessName == "ms-teams.exe" || windowInfo.ProcessName == "zoom.exe") && strings.Contains(windowInfo.Title, "Meeting") {
-			meetingSubject := ExtractMeetingSubject(windowInfo.Title)
-			meetingDuration.WithLabelValues(windowInfo.Hostname, windowInfo.Username, meetingSubject).Add(duration)
-		}
-
-		LastWindowInfo = windowInfo
-		LastWindowFocusTime = now
-	}
+	accountFocus(windowInfo, now, focusedWindowDuration, meetingDuration)
 
 	inactivityTime, shouldIncrementCounter := inactivity.GetInactivityTime(inactivityThreshold)
 	if debugMode {
@@ -191,7 +157,7 @@ func ProcessWindowInfo(inactivityThreshold uint64, privateMode bool, debugMode b
 	}
 
 	if shouldIncrementCounter {
-		inactivityMetric.WithLabelValues(windowInfo.Hostname, windowInfo.Username).Inc()
+		inactivityMetric.WithLabelValues(windowInfo.Hostname, windowInfo.Username).Add(TickInterval.Seconds())
 		if tracker != nil {
 			tracker.RecordInactivityStart(now)
 		}
@@ -199,3 +165,90 @@ func ProcessWindowInfo(inactivityThreshold uint64, privateMode bool, debugMode b
 		tracker.RecordInactivityEnd(windowInfo.Hostname, windowInfo.Username, now)
 	}
 }
+
+// ProcessFocusEvent records a focus or title change pushed by a
+// capture.Watcher. The change is stamped with the time it happened, so the
+// previous session ends exactly at the switch rather than at its last tick.
+// Idle detection stays with the polling tick.
+func ProcessFocusEvent(win capture.Window, at time.Time, debugMode bool,
+	focusChangeCounter, focusedWindowDuration, meetingDuration *prometheus.CounterVec, windowPidGauge *prometheus.GaugeVec,
+	tracker FocusTracker,
+) {
+	windowInfo, err := windowInfoFrom(win, *focusChangeCounter)
+	if err != nil {
+		if debugMode {
+			fmt.Println("Error getting window information:", err)
+		}
+		return
+	}
+
+	if debugMode {
+		fmt.Println("Focus event:", windowInfo.ProcessName, windowInfo.Title)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	setWindowGauge(windowPidGauge, windowInfo)
+
+	if tracker != nil && !windowInfo.denied {
+		tracker.RecordWindowChange(windowInfo.Hostname, windowInfo.Username, windowInfo.window(), at)
+	}
+
+	accountFocus(windowInfo, at, focusedWindowDuration, meetingDuration)
+}
+
+// ProcessSystemEvent records a suspend, resume, lock, unlock or shutdown
+// reported by a capture.SystemWatcher. The tracker ends the current session
+// and any inactivity at the moment the user went away, rather than wherever
+// the ticks happen to stop.
+func ProcessSystemEvent(ev capture.SystemEvent, at time.Time, debugMode bool, tracker FocusTracker) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		if debugMode {
+			fmt.Println("Error getting hostname:", err)
+		}
+		return
+	}
+
+	if debugMode {
+		fmt.Println("System event:", ev)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if tracker != nil {
+		tracker.RecordSystemEvent(hostname, db.CurrentUser(), string(ev), at)
+	}
+}
+
+// setWindowGauge shows the window in front, or nothing while the privacy
+// policy keeps it out.
+func setWindowGauge(windowPidGauge *prometheus.GaugeVec, windowInfo ActiveWindowInfo) {
+	windowPidGauge.Reset()
+	if windowInfo.denied {
+		return
+	}
+	windowPidGauge.WithLabelValues(windowInfo.Hostname, windowInfo.Username, windowInfo.Title, windowInfo.ProcessName).Set(float64(windowInfo.ProcessID))
+}
+
+// accountFocus adds the time spent in the previous window to the duration
+// counters when the focused window changes. Time in a window the privacy
+// policy keeps out is not counted.
+func accountFocus(windowInfo ActiveWindowInfo, now time.Time, focusedWindowDuration, meetingDuration *prometheus.CounterVec) {
+	if windowInfo == LastWindowInfo {
+		return
+	}
+	duration := now.Sub(LastWindowFocusTime).Seconds()
+	if !LastWindowInfo.denied {
+		focusedWindowDuration.WithLabelValues(LastWindowInfo.Hostname, LastWindowInfo.Username, LastWindowInfo.ProcessName).Add(duration)
+	}
+
+	if m, ok := meeting.Detect(windowInfo.ProcessName, windowInfo.Title); ok {
+		meetingDuration.WithLabelValues(windowInfo.Hostname, windowInfo.Username, m.Subject).Add(duration)
+	}
+
+	LastWindowInfo = windowInfo
+	LastWindowFocusTime = now
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vinistoisr/timewarp/internal/capture"
+	"github.com/vinistoisr/timewarp/internal/clock"
+	"github.com/vinistoisr/timewarp/internal/db"
+	"github.com/vinistoisr/timewarp/internal/windowinfo"
+)
+
+// runReplay plays a recorded timeline through the same per-second tick as
+// runExporter, on a simulated clock, and writes the result to the DB. Spans
+// where the timeline says the machine is off are skipped rather than ticked.
+// System events are delivered at their time, ahead of that second's tick.
+func runReplay(path string) error {
+	clk := clock.NewSim(time.Time{})
+	r, err := capture.LoadReplay(path, clk)
+	if err != nil {
+		return err
+	}
+	clk.Set(r.Start())
+	capture.SetCurrent(r)
+
+	p := dbpath
+	if p == "" {
+		p = db.ExeDir()
+	}
+	t, err := openTracker(p)
+	if err != nil {
+		return fmt.Errorf("open DB: %w", err)
+	}
+	t.SetClock(clk)
+	// The browser's tabs have nothing to do with the timeline
+	t.SetURLs(nil)
+	setTracker(t)
+	defer func() {
+		setTracker(nil)
+		t.Close()
+	}()
+
+	inactThresholdMs.Store(inactivityThresholdSec * 1000)
+
+	promMu.Lock()
+	if promReg == nil {
+		promReg = setupMetrics()
+	}
+	promMu.Unlock()
+
+	windowinfo.LastWindowInfo, _ = windowinfo.GetActiveWindowInfo(*focusChangeCounter)
+	windowinfo.LastWindowFocusTime = clk.Now()
+
+	system := r.SystemEvents()
+	var ticks int
+	for now := r.Start(); !now.After(r.End()); now = now.Add(time.Second) {
+		for len(system) > 0 && !system[0].At.After(now) {
+			clk.Set(system[0].At)
+			handleSystemEvent(system[0].System, system[0].At)
+			system = system[1:]
+		}
+		if r.Off(now) {
+			continue
+		}
+		clk.Set(now)
+		tick(clk)
+		ticks++
+	}
+	log.Printf("Replayed %s: %d ticks from %s to %s", path, ticks, r.Start().Format(time.RFC3339), r.End().Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/vinistoisr/timewarp/internal/db"
+	"github.com/vinistoisr/timewarp/internal/mcp"
+)
+
+// TestReplay_WorkWeek plays testdata/workweek.json (Mon–Fri, 09:00–15:00 UTC)
+// through the full pipeline and checks the metrics, the stored sessions and
+// what the MCP server reports for the week.
+//
+// Each day: 2h AutoCAD on 25-125, 30m Outlook on 25-125, 45m Chrome then 30m
+// idle on the same tab, 1h Teams meeting on 25-019, 1h15m AutoCAD on 25-019.
+func TestReplay_WorkWeek(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, oldThreshold := dbpath, inactivityThresholdSec
+	dbpath, inactivityThresholdSec = dir, 60
+	defer func() { dbpath, inactivityThresholdSec = oldPath, oldThreshold }()
+
+	if err := runReplay(filepath.Join("testdata", "workweek.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	hostname, _ := os.Hostname()
+	username := os.Getenv("USERNAME")
+	if username == "" {
+		username = os.Getenv("USER")
+	}
+
+	// Metrics: one focus change at start, four on Monday, five on each later
+	// day (the morning AutoCAD window differs from the previous afternoon's).
+	if got := testutil.ToFloat64(focusChangeCounter.WithLabelValues(hostname, username)); got != 25 {
+		t.Errorf("expected 25 focus changes, got %v", got)
+	}
+	// Idle crosses the 60s threshold at 12:16:00 and ends at 12:45:00.
+	if got := testutil.ToFloat64(inactivityMetric.WithLabelValues(hostname, username)); got != 5*29*60 {
+		t.Errorf("expected %d inactive seconds, got %v", 5*29*60, got)
+	}
+
+	weekStart := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	raw, err := db.GetWeeklySummary(dir, weekStart, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkWorkWeek(t, raw, hostname)
+
+	// The MCP server must report the same week, and with gross, the Chrome
+	// session whole.
+	text := callMCPTool(t, dir, "get_weekly_summary", `{"week_start": "2026-03-02", "gross": true}`)
+	checkWorkWeek(t, json.RawMessage(text), hostname)
+	var s db.WeeklySummary
+	json.Unmarshal([]byte(text), &s)
+	if len(s.Unattributed) == 1 {
+		approx(t, "chrome.exe gross", s.Unattributed[0].GrossMinutes, 5*75)
+	}
+}
+
+func checkWorkWeek(t *testing.T, raw json.RawMessage, hostname string) {
+	t.Helper()
+	var s db.WeeklySummary
+	if err := json.Unmarshal(raw, &s); err != nil {
+		t.Fatalf("bad summary: %v\n%s", err, raw)
+	}
+
+	if len(s.Machines) != 1 || s.Machines[0] != hostname {
+		t.Errorf("unexpected machines: %v", s.Machines)
+	}
+
+	// Sessions end at the last tick, so each loses its final second.
+	projects := map[string]float64{}
+	for _, p := range s.Attributed {
+		projects[p.ProjectNumber] = p.TotalMinutes
+	}
+	approx(t, "25-125", projects["25-125"], 5*150)
+	approx(t, "25-019", projects["25-019"], 5*135)
+
+	if len(s.Unattributed) != 1 || s.Unattributed[0].Process != "chrome.exe" {
+		t.Fatalf("expected only chrome.exe unattributed, got %+v", s.Unattributed)
+	}
+	// Less the 29 idle minutes on the same tab
+	approx(t, "chrome.exe", s.Unattributed[0].TotalMinutes, 5*46)
+
+	if len(s.Meetings) != 1 || s.Meetings[0].Subject != "25-019 Design Review" || s.Meetings[0].Sessions != 5 {
+		t.Fatalf("unexpected meetings: %+v", s.Meetings)
+	}
+	approx(t, "meeting", s.Meetings[0].TotalMinutes, 5*60)
+	approx(t, "inactivity", s.InactivityMinutes, 5*29)
+}
+
+func approx(t *testing.T, what string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 0.5 {
+		t.Errorf("%s: expected ~%v minutes, got %v", what, want, got)
+	}
+}
+
+// callMCPTool runs the MCP stdio server for a single tools/call request and
+// returns the tool's text content.
+func callMCPTool(t *testing.T, dir, name, args string) string {
+	t.Helper()
+
+	in := filepath.Join(t.TempDir(), "mcp-in.jsonl")
+	req := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + name + `","arguments":` + args + `}}` + "\n"
+	if err := os.WriteFile(in, []byte(req), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldIn, oldOut := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, w
+	runErr := mcp.Run(dir)
+	os.Stdin, os.Stdout = oldIn, oldOut
+	w.Close()
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	r.Close()
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+
+	var resp struct {
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+		t.Fatalf("bad MCP response: %v\n%s", err, buf.String())
+	}
+	if resp.Result.IsError || len(resp.Result.Content) == 0 {
+		t.Fatalf("MCP tool error: %s", buf.String())
+	}
+	return resp.Result.Content[0].Text
+}
+
+// TestReplay_Away plays testdata/away.json: AutoCAD from 09:00, idle from
+// 10:00, locked 10:10–10:40, then asleep 11:00–12:00. Inactivity and the
+// AutoCAD session end at the lock, and the locked and asleep time is
+// reported as away rather than idle.
+func TestReplay_Away(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, oldThreshold := dbpath, inactivityThresholdSec
+	dbpath, inactivityThresholdSec = dir, 60
+	defer func() { dbpath, inactivityThresholdSec = oldPath, oldThreshold }()
+
+	if err := runReplay(filepath.Join("testdata", "away.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := db.GetWeeklySummary(dir, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s db.WeeklySummary
+	if err := json.Unmarshal(raw, &s); err != nil {
+		t.Fatalf("bad summary: %v\n%s", err, raw)
+	}
+	if len(s.Attributed) != 1 || len(s.Unattributed) != 0 {
+		t.Fatalf("expected only 25-125, got %+v %+v", s.Attributed, s.Unattributed)
+	}
+	// 09:00–10:10, 10:40–11:00 and 12:00–13:00, less 10:01–10:10 idle
+	approx(t, "25-125", s.Attributed[0].TotalMinutes, 141)
+	if n := len(s.Attributed[0].SessionIDs); n != 3 {
+		t.Errorf("expected 3 sessions, got %d", n)
+	}
+	// Idle crosses the threshold at 10:01:00 and ends at the lock.
+	approx(t, "inactivity", s.InactivityMinutes, 9)
+	approx(t, "away", s.AwayMinutes, 90)
+
+	text := callMCPTool(t, dir, "get_daily_breakdown", `{"date_from": "2026-03-09", "date_to": "2026-03-10", "timezone": "UTC"}`)
+	var daily db.DailyBreakdown
+	if err := json.Unmarshal([]byte(text), &daily); err != nil {
+		t.Fatalf("bad breakdown: %v\n%s", err, text)
+	}
+	if len(daily.Days) != 1 {
+		t.Fatalf("expected 1 day, got %+v", daily.Days)
+	}
+	approx(t, "daily away", daily.Days[0].AwayMinutes, 90)
+	approx(t, "daily total", daily.Days[0].TotalMinutes, 141)
+}
+
+// TestReplay_Privacy plays testdata/privacy.json under a privacy file in the
+// DB folder, with command lines and the raw log on, and checks that nothing
+// the policy keeps out is in any table or any metric: the denied password
+// manager, the card number, the InPrivate window's title and the time-only
+// Outlook title.
+func TestReplay_Privacy(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, oldThreshold, oldCmd, oldRaw := dbpath, inactivityThresholdSec, commandLines, rawLog
+	dbpath, inactivityThresholdSec, commandLines, rawLog = dir, 60, true, true
+	defer func() { dbpath, inactivityThresholdSec, commandLines, rawLog = oldPath, oldThreshold, oldCmd, oldRaw }()
+
+	policy := `{
+		"deny": ["^keepass"],
+		"time_only": ["^outlook\\.exe$"],
+		"redact": ["\\b(?:\\d{4} ){3}\\d{4}\\b", "Patient:\\s*(\\w+)"]
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "timewarp-privacy.json"), []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runReplay(filepath.Join("testdata", "privacy.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := []string{"KeePass", "kdbx", "4111", "Hartley", "Lab results", "Severance"}
+	leaked := func(where, s string) {
+		for _, secret := range secrets {
+			if strings.Contains(strings.ToLower(s), strings.ToLower(secret)) {
+				t.Errorf("%s: %q reached %q", where, secret, s)
+			}
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "timewarp-*.db"))
+	if len(files) != 1 {
+		t.Fatalf("expected one DB file, got %v", files)
+	}
+	d, err := sql.Open("sqlite", "file:"+files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	var tables []string
+	rows, err := d.Query(`SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		tables = append(tables, name)
+	}
+	rows.Close()
+	for _, table := range tables {
+		rows, err := d.Query(`SELECT * FROM "` + table + `"`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cols, _ := rows.Columns()
+		for rows.Next() {
+			vals := make([]any, len(cols))
+			ptrs := make([]any, len(cols))
+			for i := range vals {
+				ptrs[i] = &vals[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range vals {
+				switch v := v.(type) {
+				case string:
+					leaked(table+"."+cols[i], v)
+				case []byte:
+					leaked(table+"."+cols[i], string(v))
+				}
+			}
+		}
+		rows.Close()
+	}
+
+	// What is left is recorded as the policy says.
+	titles := map[string]string{}
+	commands := map[string]string{}
+	rows, err = d.Query(`SELECT process_name, window_title, COALESCE(command_line, '') FROM focus_events`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var process, title, command string
+		rows.Scan(&process, &title, &command)
+		titles[process], commands[process] = title, command
+	}
+	rows.Close()
+	want := map[string]string{
+		"chrome.exe":  "Card *** - First Bank - Google Chrome",
+		"msedge.exe":  "msedge.exe",
+		"OUTLOOK.EXE": "OUTLOOK.EXE",
+		"acad.exe":    "25-125_SLD-E101.dwg - AutoCAD",
+	}
+	if len(titles) != len(want) {
+		t.Errorf("expected sessions for %d processes, got %v", len(want), titles)
+	}
+	for process, title := range want {
+		if titles[process] != title {
+			t.Errorf("%s: got title %q, want %q", process, titles[process], title)
+		}
+	}
+	if got := commands["acad.exe"]; got != `acad.exe /b "C:\Scripts\Patient: ***.scr"` {
+		t.Errorf("acad.exe: got command line %q", got)
+	}
+
+	families, err := promReg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				leaked(f.GetName()+"."+l.GetName(), l.GetValue())
+			}
+		}
+	}
+}